@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/alerts"
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "List and test alerts for new messages",
+	Long: `Alerts are saved searches, defined in the config file, that are checked
+whenever ingest adds messages to their database. When new messages match,
+a JSON payload, or a Slack incoming-webhook message, is posted to the
+alert's webhook.
+
+  alerts:
+    - name: cve
+      query: CVE
+      database: sig-security
+      webhook: https://hooks.slack.com/services/...
+      format: slack
+
+Run 'alerts' to list the configured alerts and 'alerts test <name>' to
+send the latest matches to a webhook.`,
+	Args: cobra.NoArgs,
+	RunE: runAlertsList,
+}
+
+var alertsTestCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Send an alert's latest matches to its webhook",
+	Long: `Send the most recent messages matching an alert to its webhook, whether
+or not they are new, to check that the webhook is set up correctly.
+
+Example:
+  k8s-slack-searcher alerts test cve`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAlertsTest,
+}
+
+var alertsTestLimit int
+
+// alertTimeout bounds each webhook request
+const alertTimeout = 30 * time.Second
+
+func init() {
+	alertsTestCmd.Flags().IntVarP(&alertsTestLimit, "limit", "l", 5,
+		"Number of recent matches to send")
+
+	alertsCmd.AddCommand(alertsTestCmd)
+}
+
+func runAlertsList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Alerts) == 0 {
+		path, _ := config.Path()
+		fmt.Printf("No alerts configured. Add them to %s.\n", path)
+		return nil
+	}
+
+	for _, alert := range cfg.Alerts {
+		status := ""
+		if err := alerts.Validate(alert); err != nil {
+			status = fmt.Sprintf(" (invalid: %v)", err)
+		}
+		format := alert.Format
+		if format == "" {
+			format = alerts.FormatJSON
+		}
+		fmt.Printf("%s: %q in %s -> %s [%s]%s\n", alert.Name, alert.Query, alert.Database, alert.Webhook, format, status)
+	}
+
+	return nil
+}
+
+func runAlertsTest(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	var alert *config.Alert
+	for i := range cfg.Alerts {
+		if cfg.Alerts[i].Name == args[0] {
+			alert = &cfg.Alerts[i]
+		}
+	}
+	if alert == nil {
+		return fmt.Errorf("no alert named %s", args[0])
+	}
+	if err := alerts.Validate(*alert); err != nil {
+		return err
+	}
+
+	dbName := qualify(alert.Database)
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	payload, err := alerts.Check(cmd.Context(), search, *alert, 0, alertsTestLimit)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return fmt.Errorf("no messages match %q", alert.Query)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), alertTimeout)
+	defer cancel()
+	if err := alerts.Send(ctx, http.DefaultClient, *alert, payload); err != nil {
+		return err
+	}
+
+	fmt.Printf("Sent %d message(s) to %s\n", len(payload.Messages), alert.Webhook)
+	return nil
+}
+
+// runAlerts checks the alerts for a database against the messages stored
+// after afterID and notifies their webhooks. Failures are reported as
+// warnings, since the ingest itself succeeded.
+func runAlerts(ctx context.Context, dbName string, afterID int) {
+	sent, errs := checkAlerts(ctx, dbName, afterID)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if len(sent) > 0 {
+		fmt.Printf("Alerts sent: %s\n", strings.Join(sent, ", "))
+	}
+}
+
+// checkAlerts runs the alerts for a database against the messages stored
+// after afterID. It returns the alerts that were sent, with their match
+// counts, and the errors from any that failed.
+func checkAlerts(ctx context.Context, dbName string, afterID int) ([]string, []error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to load alerts: %w", err)}
+	}
+
+	var matching []config.Alert
+	for _, alert := range cfg.Alerts {
+		if qualify(alert.Database) == dbName {
+			matching = append(matching, alert)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, nil
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to check alerts: %w", err)}
+	}
+	defer search.Close()
+
+	var sent []string
+	var errs []error
+	for _, alert := range matching {
+		if err := alerts.Validate(alert); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		payload, err := alerts.Check(ctx, search, alert, afterID, alerts.MaxMessages)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if payload == nil {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, alertTimeout)
+		err = alerts.Send(sendCtx, http.DefaultClient, alert, payload)
+		cancel()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent = append(sent, fmt.Sprintf("%s (%d)", alert.Name, payload.Count))
+	}
+
+	return sent, errs
+}
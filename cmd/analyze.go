@@ -0,0 +1,666 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/analyzer"
+	"github.com/raesene/k8s-slack-searcher/pkg/identifiers"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze activity in a channel database",
+	Long:  `Commands for producing aggregate reports about activity in a channel database.`,
+}
+
+var analyzeLeaderboardCmd = &cobra.Command{
+	Use:   "leaderboard <database>",
+	Short: "List the most active users in a channel",
+	Long: `List the top posters, top thread starters and top repliers in a channel
+database, optionally restricted to a single year.
+
+Examples:
+  k8s-slack-searcher analyze leaderboard sig-auth
+  k8s-slack-searcher analyze leaderboard sig-auth --year 2023 --limit 5
+  k8s-slack-searcher analyze leaderboard sig-auth --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runAnalyzeLeaderboard,
+}
+
+var analyzeClustersCmd = &cobra.Command{
+	Use:   "clusters <database>",
+	Short: "Group similar messages matching a query",
+	Long: `Group messages matching a query into clusters of near-duplicate text,
+surfacing questions that have been asked repeatedly over the years. Useful
+for identifying FAQ topics worth documenting.
+
+Similarity is estimated with MinHash over word pairs; --threshold sets the
+minimum estimated similarity (0-1) for messages to share a cluster.
+
+Examples:
+  k8s-slack-searcher analyze clusters sig-node --query "imagepullbackoff"
+  k8s-slack-searcher analyze clusters sig-node --query "imagepullbackoff" --threshold 0.3 --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runAnalyzeClusters,
+}
+
+var analyzeGraphCmd = &cobra.Command{
+	Use:   "graph <database>",
+	Short: "Export who-replies-to-whom in threads as a graph",
+	Long: `Build a directed interaction graph from a channel's threads: an edge runs
+from each user who replied in a thread to the user who started it,
+weighted by the number of replies. Replies in one's own threads are not
+counted.
+
+The graph is written as GEXF for Gephi or as JSON with "nodes" and "links"
+arrays for D3's force layout, chosen by the --output file extension or
+--format. Without --output, JSON is written to stdout.
+
+Examples:
+  k8s-slack-searcher analyze graph sig-auth --output sig-auth.gexf
+  k8s-slack-searcher analyze graph sig-node --year 2023 --min-replies 3 --output sig-node.json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runAnalyzeGraph,
+}
+
+var analyzeResponseTimesCmd = &cobra.Command{
+	Use:   "response-times <database>...",
+	Short: "Measure how long threads wait for a first reply",
+	Long: `Measure the time between the start of each thread and its first reply
+from someone other than the person who started it: the distribution over
+all threads and the median, 90th percentile and mean for each month.
+Several databases are reported one after another, followed by a
+side-by-side summary.
+
+Examples:
+  k8s-slack-searcher analyze response-times sig-auth
+  k8s-slack-searcher analyze response-times sig-auth sig-node --year 2023
+  k8s-slack-searcher analyze response-times sig-auth --json`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runAnalyzeResponseTimes,
+}
+
+var analyzeCompareCmd = &cobra.Command{
+	Use:   "compare <database> <database>",
+	Short: "Compare activity, top terms and users of two channels",
+	Long: `Compare two channel databases side by side: message, thread and user
+counts, messages per year, each channel's most common terms, and how many
+users post in both. Users are matched by ID, so only channels from the
+same workspace have users in common.
+
+The report is printed as text, or written as Markdown or a standalone HTML
+page with --format.
+
+Examples:
+  k8s-slack-searcher analyze compare sig-auth sig-node
+  k8s-slack-searcher analyze compare sig-auth sig-node --limit 20 --json
+  k8s-slack-searcher analyze compare sig-auth sig-node --format html --output compare.html`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runAnalyzeCompare,
+}
+
+var analyzeVersionsCmd = &cobra.Command{
+	Use:   "versions <database>",
+	Short: "Show when each Kubernetes release was discussed",
+	Long: `List the Kubernetes minor versions mentioned in a channel (as v1.27,
+v1.27.3 or "Kubernetes 1.27"), with the number of messages mentioning
+each, the first and last mention, and the month discussion peaked. With
+--version, show that release's mentions month by month.
+
+Examples:
+  k8s-slack-searcher analyze versions sig-node
+  k8s-slack-searcher analyze versions sig-node --version 1.27
+  k8s-slack-searcher analyze versions sig-node --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runAnalyzeVersions,
+}
+
+var (
+	leaderboardYear  int
+	leaderboardLimit int
+	analyzeJSON      bool
+
+	clustersQuery     string
+	clustersThreshold float64
+	clustersMax       int
+	clustersMinSize   int
+	clustersLimit     int
+
+	graphOutput     string
+	graphFormat     string
+	graphYear       int
+	graphMinReplies int
+
+	responseYear int
+
+	compareLimit  int
+	compareFormat string
+	compareOutput string
+	compareTheme  string
+
+	versionsVersion string
+)
+
+func init() {
+	analyzeLeaderboardCmd.Flags().IntVar(&leaderboardYear, "year", 0,
+		"Only count messages from this year")
+	analyzeLeaderboardCmd.Flags().IntVarP(&leaderboardLimit, "limit", "l", 10,
+		"Number of users to show in each list")
+
+	analyzeCmd.PersistentFlags().BoolVar(&analyzeJSON, "json", false,
+		"Output results as JSON")
+
+	analyzeClustersCmd.Flags().StringVarP(&clustersQuery, "query", "q", "",
+		"Search query selecting the messages to cluster (required)")
+	analyzeClustersCmd.Flags().Float64Var(&clustersThreshold, "threshold", 0.5,
+		"Minimum estimated similarity (0-1) for messages to share a cluster")
+	analyzeClustersCmd.Flags().IntVar(&clustersMax, "max-messages", 1000,
+		"Maximum number of matching messages to consider")
+	analyzeClustersCmd.Flags().IntVar(&clustersMinSize, "min-size", 2,
+		"Smallest cluster to report")
+	analyzeClustersCmd.Flags().IntVarP(&clustersLimit, "limit", "l", 10,
+		"Maximum number of clusters to show")
+	analyzeClustersCmd.MarkFlagRequired("query")
+
+	analyzeGraphCmd.Flags().StringVarP(&graphOutput, "output", "o", "",
+		"Write the graph to this .gexf or .json file instead of stdout")
+	analyzeGraphCmd.Flags().StringVarP(&graphFormat, "format", "f", "",
+		fmt.Sprintf("Graph format (%s), overriding the output file extension", strings.Join(analyzer.GraphFormats, "|")))
+	analyzeGraphCmd.Flags().IntVar(&graphYear, "year", 0,
+		"Only include threads started in this year")
+	analyzeGraphCmd.Flags().IntVar(&graphMinReplies, "min-replies", 1,
+		"Leave out pairs of users with fewer replies between them")
+	analyzeGraphCmd.RegisterFlagCompletionFunc("format", completeValues(analyzer.GraphFormats...))
+
+	analyzeCmd.AddCommand(analyzeLeaderboardCmd)
+	analyzeCmd.AddCommand(analyzeClustersCmd)
+	analyzeResponseTimesCmd.Flags().IntVar(&responseYear, "year", 0,
+		"Only include threads started in this year")
+
+	analyzeCmd.AddCommand(analyzeGraphCmd)
+	analyzeCmd.AddCommand(analyzeResponseTimesCmd)
+
+	analyzeCompareCmd.Flags().IntVarP(&compareLimit, "limit", "l", 10,
+		"Number of terms and shared users to show")
+	analyzeCompareCmd.Flags().StringVarP(&compareFormat, "format", "f", "text",
+		"Output format (text|markdown|html)")
+	analyzeCompareCmd.Flags().StringVarP(&compareOutput, "output", "o", "",
+		"Write the report to a file instead of stdout")
+	analyzeCompareCmd.Flags().StringVar(&compareTheme, "theme", searcher.DefaultTheme,
+		"Built-in theme for HTML output ("+strings.Join(searcher.Themes(), "|")+")")
+	analyzeCompareCmd.RegisterFlagCompletionFunc("format", completeValues("text", "markdown", "html"))
+	analyzeCompareCmd.RegisterFlagCompletionFunc("theme", completeValues(searcher.Themes()...))
+	analyzeCmd.AddCommand(analyzeCompareCmd)
+
+	analyzeVersionsCmd.Flags().StringVar(&versionsVersion, "version", "",
+		"Show month-by-month mentions of this version, e.g. 1.27")
+	analyzeCmd.AddCommand(analyzeVersionsCmd)
+}
+
+// openAnalyzer validates that a database exists and opens an analyzer for it
+func openAnalyzer(dbName string) (*analyzer.Analyzer, error) {
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return nil, fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	a, err := analyzer.NewAnalyzer(dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return a, nil
+}
+
+// printJSON writes a value to stdout as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func runAnalyzeLeaderboard(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	a, err := openAnalyzer(dbName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	leaderboard, err := a.Leaderboard(cmd.Context(), leaderboardYear, leaderboardLimit)
+	if err != nil {
+		return fmt.Errorf("failed to build leaderboard: %w", err)
+	}
+	leaderboard.Database = dbName
+
+	if analyzeJSON {
+		return printJSON(leaderboard)
+	}
+
+	period := "all time"
+	if leaderboardYear != 0 {
+		period = fmt.Sprintf("%d", leaderboardYear)
+	}
+	fmt.Printf("Leaderboard for %s (%s)\n", dbName, period)
+
+	printLeaderboardSection("Top posters", leaderboard.TopPosters)
+	printLeaderboardSection("Top thread starters", leaderboard.TopThreadStarters)
+	printLeaderboardSection("Top repliers", leaderboard.TopRepliers)
+
+	return nil
+}
+
+// printLeaderboardSection prints a ranked list of users and their counts
+func printLeaderboardSection(title string, entries []models.LeaderboardEntry) {
+	fmt.Printf("\n%s:\n", title)
+
+	if len(entries) == 0 {
+		fmt.Println("  (none)")
+		return
+	}
+
+	for i, entry := range entries {
+		name := entry.UserName
+		if entry.UserRealName != "" {
+			name = fmt.Sprintf("%s (%s)", entry.UserRealName, entry.UserName)
+		}
+		if name == "" {
+			name = entry.UserID
+		}
+
+		fmt.Printf("  %2d. %-40s %d\n", i+1, name, entry.Count)
+	}
+}
+
+func runAnalyzeClusters(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	a, err := openAnalyzer(dbName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	clusters, err := a.Clusters(cmd.Context(), clustersQuery, analyzer.ClusterOptions{
+		Threshold:   clustersThreshold,
+		MaxMessages: clustersMax,
+		MinSize:     clustersMinSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cluster messages: %w", err)
+	}
+
+	if clustersLimit > 0 && len(clusters) > clustersLimit {
+		clusters = clusters[:clustersLimit]
+	}
+
+	if analyzeJSON {
+		return printJSON(clusters)
+	}
+
+	if len(clusters) == 0 {
+		fmt.Printf("No clusters of similar messages found for: %s\n", clustersQuery)
+		return nil
+	}
+
+	fmt.Printf("Found %d cluster(s) of similar messages for: %s\n", len(clusters), clustersQuery)
+	for i, cluster := range clusters {
+		fmt.Printf("\n--- Cluster %d: %d messages, %s to %s ---\n", i+1, cluster.Size,
+			cluster.FirstSeen.Format("2006-01-02"), cluster.LastSeen.Format("2006-01-02"))
+
+		for _, message := range cluster.Messages {
+			text := strings.Join(strings.Fields(message.Text), " ")
+			if len(text) > 120 {
+				text = text[:117] + "..."
+			}
+			author := message.UserName
+			if author == "" {
+				author = message.UserID
+			}
+			fmt.Printf("  %s  %-15s %s\n", message.Date.Format("2006-01-02"), author, text)
+		}
+	}
+
+	return nil
+}
+
+func runAnalyzeGraph(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	format := graphFormat
+	if format == "" {
+		format = analyzer.GraphFormatJSON
+		if strings.EqualFold(filepath.Ext(graphOutput), ".gexf") {
+			format = analyzer.GraphFormatGEXF
+		}
+	}
+	if format != analyzer.GraphFormatGEXF && format != analyzer.GraphFormatJSON {
+		return fmt.Errorf("unsupported graph format: %s (supported: %s)", format, strings.Join(analyzer.GraphFormats, ", "))
+	}
+
+	a, err := openAnalyzer(dbName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	graph, err := a.InteractionGraph(cmd.Context(), analyzer.GraphOptions{Year: graphYear, MinReplies: graphMinReplies})
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+	graph.Database = dbName
+
+	if graphOutput == "" {
+		return analyzer.WriteGraph(os.Stdout, graph, format)
+	}
+
+	file, err := os.Create(graphOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	if err := analyzer.WriteGraph(file, graph, format); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+
+	fmt.Printf("Graph with %d user(s) and %d edge(s) written to: %s\n", len(graph.Nodes), len(graph.Edges), graphOutput)
+	return nil
+}
+
+func runAnalyzeResponseTimes(cmd *cobra.Command, args []string) error {
+	var reports []*models.ResponseTimes
+	for _, arg := range args {
+		dbName := qualify(arg)
+
+		a, err := openAnalyzer(dbName)
+		if err != nil {
+			return err
+		}
+		report, err := a.ResponseTimes(cmd.Context(), responseYear)
+		a.Close()
+		if err != nil {
+			return fmt.Errorf("failed to measure response times for %s: %w", dbName, err)
+		}
+		report.Database = dbName
+		reports = append(reports, report)
+	}
+
+	if analyzeJSON {
+		if len(reports) == 1 {
+			return printJSON(reports[0])
+		}
+		return printJSON(reports)
+	}
+
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printResponseTimes(report)
+	}
+
+	if len(reports) > 1 {
+		fmt.Printf("\nBy channel:\n")
+		fmt.Printf("  %-25s %8s %9s %10s %10s\n", "Channel", "Threads", "Answered", "Median", "90th pct")
+		for _, report := range reports {
+			fmt.Printf("  %-25s %8d %9d %10s %10s\n", report.Database, report.Threads, report.Answered,
+				formatWait(report.Answered, report.MedianSeconds), formatWait(report.Answered, report.P90Seconds))
+		}
+	}
+
+	return nil
+}
+
+// printResponseTimes prints one database's first-reply report
+func printResponseTimes(report *models.ResponseTimes) {
+	period := "all time"
+	if responseYear != 0 {
+		period = fmt.Sprintf("%d", responseYear)
+	}
+	fmt.Printf("First-reply times for %s (%s)\n", report.Database, period)
+
+	if report.Threads == 0 {
+		fmt.Println("  No threads found.")
+		return
+	}
+
+	fmt.Printf("\nThreads: %d, answered by someone else: %d (%.0f%%)\n", report.Threads, report.Answered,
+		100*float64(report.Answered)/float64(report.Threads))
+	fmt.Printf("Median: %s, 90th percentile: %s, mean: %s\n", formatWait(report.Answered, report.MedianSeconds),
+		formatWait(report.Answered, report.P90Seconds), formatWait(report.Answered, report.MeanSeconds))
+
+	fmt.Printf("\nTime to first reply:\n")
+	for _, bucket := range report.Buckets {
+		fmt.Printf("  %-22s %6d\n", bucket.Label, bucket.Count)
+	}
+
+	fmt.Printf("\nBy month:\n")
+	fmt.Printf("  %-8s %8s %9s %10s %10s\n", "Month", "Threads", "Answered", "Median", "90th pct")
+	for _, month := range report.Months {
+		fmt.Printf("  %-8s %8d %9d %10s %10s\n", month.Month, month.Threads, month.Answered,
+			formatWait(month.Answered, month.MedianSeconds), formatWait(month.Answered, month.P90Seconds))
+	}
+}
+
+// formatWait renders a wait in seconds in its two largest units, or "-"
+// when no thread was answered
+func formatWait(answered int, seconds int64) string {
+	if answered == 0 {
+		return "-"
+	}
+
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", seconds)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%02dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+func runAnalyzeCompare(cmd *cobra.Command, args []string) error {
+	if compareFormat != "text" && compareFormat != "markdown" && compareFormat != "html" {
+		return fmt.Errorf("unsupported format: %s (expected text, markdown or html)", compareFormat)
+	}
+	// Check the theme before creating the output file
+	if compareFormat == "html" {
+		if _, err := searcher.ThemeCSS(compareTheme); err != nil {
+			return err
+		}
+	}
+
+	leftName, rightName := qualify(args[0]), qualify(args[1])
+	if leftName == rightName {
+		return fmt.Errorf("cannot compare %s with itself", leftName)
+	}
+
+	left, err := openAnalyzer(leftName)
+	if err != nil {
+		return err
+	}
+	defer left.Close()
+
+	right, err := openAnalyzer(rightName)
+	if err != nil {
+		return err
+	}
+	defer right.Close()
+
+	comparison, err := left.Compare(cmd.Context(), right, compareLimit)
+	if err != nil {
+		return fmt.Errorf("failed to compare channels: %w", err)
+	}
+	comparison.Left.Database = leftName
+	comparison.Right.Database = rightName
+
+	if analyzeJSON {
+		return printJSON(comparison)
+	}
+
+	var w io.Writer = os.Stdout
+	if compareOutput != "" {
+		file, err := os.Create(compareOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch compareFormat {
+	case "html":
+		err = analyzer.GenerateCompareHTML(w, comparison, compareTheme)
+	case "markdown":
+		err = analyzer.GenerateCompareMarkdown(w, comparison)
+	default:
+		printComparison(w, comparison)
+	}
+	if err != nil {
+		return err
+	}
+
+	if compareOutput != "" {
+		fmt.Printf("Comparison written to: %s\n", compareOutput)
+	}
+
+	return nil
+}
+
+// printComparison writes a channel comparison as plain text columns
+func printComparison(w io.Writer, c *models.ChannelComparison) {
+	row := func(label string, left, right interface{}) {
+		fmt.Fprintf(w, "  %-16s %20v %20v\n", label, left, right)
+	}
+	day := func(p models.ChannelProfile, t time.Time) string {
+		if p.Messages == 0 {
+			return "-"
+		}
+		return t.Format("2006-01-02")
+	}
+
+	fmt.Fprintf(w, "Comparing %s and %s\n\n", c.Left.Database, c.Right.Database)
+	row("", c.Left.Database, c.Right.Database)
+	row("Messages", c.Left.Messages, c.Right.Messages)
+	row("Threads", c.Left.Threads, c.Right.Threads)
+	row("Users", c.Left.Users, c.Right.Users)
+	row("First message", day(c.Left, c.Left.FirstMessage), day(c.Right, c.Right.FirstMessage))
+	row("Last message", day(c.Left, c.Left.LastMessage), day(c.Right, c.Right.LastMessage))
+
+	fmt.Fprintf(w, "\nMessages per year:\n")
+	for _, year := range c.Years {
+		row(fmt.Sprint(year.Year), year.Left, year.Right)
+	}
+
+	fmt.Fprintf(w, "\nTop terms (messages using them):\n")
+	for i := 0; i < len(c.Left.TopTerms) || i < len(c.Right.TopTerms); i++ {
+		var left, right string
+		if i < len(c.Left.TopTerms) {
+			left = fmt.Sprintf("%s (%d)", c.Left.TopTerms[i].Term, c.Left.TopTerms[i].Documents)
+		}
+		if i < len(c.Right.TopTerms) {
+			right = fmt.Sprintf("%s (%d)", c.Right.TopTerms[i].Term, c.Right.TopTerms[i].Documents)
+		}
+		row(fmt.Sprintf("%2d.", i+1), left, right)
+	}
+	if len(c.SharedTerms) > 0 {
+		fmt.Fprintf(w, "  In both: %s\n", strings.Join(c.SharedTerms, ", "))
+	}
+
+	fmt.Fprintf(w, "\nUsers: %d in both, %d only in %s, %d only in %s (%.0f%% overlap)\n",
+		c.Users.Shared, c.Users.LeftOnly, c.Left.Database, c.Users.RightOnly, c.Right.Database, 100*c.Users.Jaccard)
+	for i, user := range c.Users.TopShared {
+		name := user.UserName
+		if user.UserRealName != "" {
+			name = fmt.Sprintf("%s (%s)", user.UserRealName, user.UserName)
+		}
+		if name == "" {
+			name = user.UserID
+		}
+		fmt.Fprintf(w, "  %2d. %-40s %6d %6d\n", i+1, name, user.Left, user.Right)
+	}
+}
+
+func runAnalyzeVersions(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	version := ""
+	if versionsVersion != "" {
+		var err error
+		if version, err = identifiers.ParseVersion(versionsVersion); err != nil {
+			return err
+		}
+	}
+
+	a, err := openAnalyzer(dbName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	report, err := a.Versions(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to report versions: %w", err)
+	}
+	report.Database = dbName
+
+	if version != "" {
+		var selected []models.VersionActivity
+		for _, v := range report.Versions {
+			if v.Version == version {
+				selected = append(selected, v)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no messages in %s mention Kubernetes %s", dbName, version)
+		}
+		report.Versions = selected
+	}
+
+	if analyzeJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Versions) == 0 {
+		fmt.Printf("No Kubernetes versions mentioned in %s\n", dbName)
+		return nil
+	}
+
+	if version != "" {
+		v := report.Versions[0]
+		fmt.Printf("Mentions of Kubernetes %s in %s: %d message(s), peaking in %s\n\n", v.Version, dbName, v.Messages, v.PeakMonth)
+		for _, month := range v.Months {
+			fmt.Printf("  %s %5d %s\n", month.Month, month.Messages, strings.Repeat("#", month.Messages*40/v.PeakMessages))
+		}
+		return nil
+	}
+
+	fmt.Printf("Kubernetes versions mentioned in %s\n\n", dbName)
+	fmt.Printf("  %-8s %8s  %-10s  %-10s  %s\n", "Version", "Messages", "First", "Last", "Peak month")
+	for _, v := range report.Versions {
+		fmt.Printf("  %-8s %8d  %-10s  %-10s  %s (%d)\n", v.Version, v.Messages,
+			v.First.Format("2006-01-02"), v.Last.Format("2006-01-02"), v.PeakMonth, v.PeakMessages)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/audit"
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Review the searches recorded in the audit log",
+	Long: `Show the searches recorded in the audit log, newest last: when each ran,
+who ran it, the query, the databases searched and how many results it
+returned.
+
+Searches are only recorded when audit_log is set in the config file:
+  audit_log: /var/log/k8s-slack-searcher/audit.jsonl
+
+Examples:
+  k8s-slack-searcher audit
+  k8s-slack-searcher audit --user alice --since 2024-05-01
+  k8s-slack-searcher audit --limit 0 --json > audit.json`,
+	Args: cobra.NoArgs,
+	RunE: runAudit,
+}
+
+var (
+	auditFile  string
+	auditUser  string
+	auditSince string
+	auditLimit int
+	auditJSON  bool
+)
+
+func init() {
+	auditCmd.Flags().StringVar(&auditFile, "file", "",
+		"Audit log to read (defaults to audit_log from the config file)")
+	auditCmd.Flags().StringVar(&auditUser, "user", "",
+		"Only show searches by this user")
+	auditCmd.Flags().StringVar(&auditSince, "since", "",
+		"Only show searches on or after this day, as YYYY-MM-DD")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "l", 50,
+		"Show at most this many of the latest searches (0 for all)")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Output as JSON")
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	path := auditFile
+	if path == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.AuditLog == "" {
+			configPath, _ := config.Path()
+			return fmt.Errorf("no audit log: set audit_log in %s or pass --file", configPath)
+		}
+		path = cfg.AuditLog
+	}
+
+	var since time.Time
+	if auditSince != "" {
+		var err error
+		since, err = time.ParseInLocation("2006-01-02", auditSince, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --since %q: expected YYYY-MM-DD", auditSince)
+		}
+	}
+
+	entries, err := audit.Read(path)
+	if err != nil {
+		return err
+	}
+
+	var matched []audit.Entry
+	for _, entry := range entries {
+		if auditUser != "" && entry.User != auditUser {
+			continue
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+	if auditLimit > 0 && len(matched) > auditLimit {
+		matched = matched[len(matched)-auditLimit:]
+	}
+
+	if auditJSON {
+		if matched == nil {
+			matched = []audit.Entry{}
+		}
+		return printJSON(matched)
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No searches recorded.")
+		return nil
+	}
+
+	for _, entry := range matched {
+		fmt.Printf("%s  %-12s %6d  %-20s %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.User,
+			entry.Results, strings.Join(entry.Databases, ","), entry.Query)
+	}
+
+	return nil
+}
+
+// recordSearch appends a search to the audit log when one is configured.
+// Failing to record is an error, so searches are never left unaudited.
+func recordSearch(command, query string, databases []string, results int) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.AuditLog == "" {
+		return nil
+	}
+
+	return audit.Append(cfg.AuditLog, audit.Entry{
+		Time:      time.Now().UTC(),
+		User:      audit.CurrentUser(),
+		Command:   command,
+		Query:     query,
+		Databases: databases,
+		Results:   results,
+	})
+}
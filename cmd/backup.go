@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/backup"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <database>",
+	Short: "Write a snapshot of a database to a file",
+	Long: `Write a consistent snapshot of a database to a file using SQLite's online
+backup API. The database is copied a few pages at a time, so it stays
+usable by other processes, including a running ingest, while the backup
+is taken.
+
+Without --output the snapshot is written to the current directory as
+<database>-<YYYYMMDD-HHMMSS>.db.
+
+Examples:
+  k8s-slack-searcher backup sig-auth
+  k8s-slack-searcher backup sig-auth --output backups/sig-auth.db`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <database> <backup-file>",
+	Short: "Replace a database with a backup",
+	Long: `Replace a database with a snapshot written by 'backup', or create the
+database from it if it does not exist. Processes with the database open
+see the restored contents once the restore finishes.
+
+Replacing an existing database requires --force. Encrypted databases
+can't be restored from a backup; copy their encrypted file back instead.
+
+Examples:
+  k8s-slack-searcher restore sig-auth backups/sig-auth.db --force`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runRestore,
+}
+
+var (
+	backupOutput string
+	restoreForce bool
+)
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "",
+		"File to write the backup to (default <database>-<timestamp>.db)")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false,
+		"Replace the database if it already exists")
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	// Encrypted databases are replaced atomically whenever they change, so
+	// copying the file is enough
+	if storagepaths.IsEncrypted(dbName) {
+		return fmt.Errorf("database %s is encrypted; copy %s instead", dbName, storagepaths.EncryptedPath(dbName))
+	}
+
+	output := backupOutput
+	if output == "" {
+		output = fmt.Sprintf("%s-%s.db", storagepaths.Sanitize(dbName), time.Now().Format("20060102-150405"))
+	}
+
+	if err := backup.Backup(cmd.Context(), storagepaths.DatabasePath(dbName), output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup of %s written to: %s\n", dbName, output)
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	if searcher.ValidateDatabaseExists(dbName) && !restoreForce {
+		return fmt.Errorf("database %s already exists; use --force to replace it", dbName)
+	}
+
+	// A plaintext copy beside an encrypted database would be opened in its
+	// place, without the key, and leave it out of date
+	if storagepaths.IsEncrypted(dbName) {
+		return fmt.Errorf("database %s is encrypted; replace %s with a copy of the encrypted file instead", dbName, storagepaths.EncryptedPath(dbName))
+	}
+
+	path := storagepaths.DatabasePath(dbName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	if err := backup.Restore(cmd.Context(), args[1], path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s from: %s\n", dbName, args[1])
+	return nil
+}
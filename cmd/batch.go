@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+// runBatchSearch runs every query in --queries-file with the other search
+// flags and writes one report with each query's hit count and top results
+func runBatchSearch(cmd *cobra.Command) error {
+	queries, err := readQueriesFile(queriesFile)
+	if err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("no queries in %s", queriesFile)
+	}
+
+	format := searcher.BatchFormatJSON
+	if batchReport != "" {
+		format, err = searcher.BatchFormatForPath(batchReport)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := cmd.Context()
+	if searchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, searchTimeout)
+		defer cancel()
+	}
+
+	// Queries often share databases, so open each one once
+	searchers := make(map[string]*searcher.Searcher)
+	defer func() {
+		for _, search := range searchers {
+			search.Close()
+		}
+	}()
+
+	report := &searcher.BatchReport{GeneratedAt: time.Now()}
+	for _, line := range queries {
+		result, err := runBatchQuery(ctx, searchers, line.text)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("search timed out after %s", searchTimeout)
+			}
+			return fmt.Errorf("%s line %d: %w", queriesFile, line.number, err)
+		}
+		report.Queries = append(report.Queries, *result)
+	}
+
+	if batchReport == "" {
+		return searcher.WriteBatchReport(os.Stdout, format, report)
+	}
+
+	file, err := os.Create(batchReport)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer file.Close()
+
+	if err := searcher.WriteBatchReport(file, format, report); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	for _, query := range report.Queries {
+		fmt.Printf("%6d  %s\n", query.Hits, query.Query)
+	}
+	fmt.Printf("Batch report written to: %s\n", batchReport)
+	noteExported(batchReport)
+
+	return nil
+}
+
+// runBatchQuery counts a query's matches in each of its databases and
+// keeps the best --limit results across them
+func runBatchQuery(ctx context.Context, searchers map[string]*searcher.Searcher, rawQuery string) (*searcher.BatchQuery, error) {
+	matchQuery, filter, databases, err := planSearch(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &searcher.BatchQuery{Query: rawQuery, Databases: databases, Results: []searcher.BatchResult{}}
+	var found []*models.SearchResult
+	for _, name := range databases {
+		search, ok := searchers[name]
+		if !ok {
+			search, err = searcher.NewSearcher(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open database: %w", err)
+			}
+			searchers[name] = search
+		}
+
+		count, err := search.Count(ctx, matchQuery, filter)
+		if err != nil {
+			return nil, fmt.Errorf("count failed: %w", err)
+		}
+		result.Hits += count
+
+		if err := traceSearches([]*searcher.Searcher{search}, "search --queries-file", rawQuery); err != nil {
+			return nil, err
+		}
+		matches, err := search.SearchFiltered(ctx, matchQuery, filter, searchLimit)
+		if err != nil {
+			return nil, fmt.Errorf("search failed: %w", err)
+		}
+		for _, match := range matches {
+			match.Database = name
+		}
+		found = append(found, matches...)
+	}
+
+	if err := recordSearch("search --queries-file", rawQuery, databases, result.Hits); err != nil {
+		return nil, err
+	}
+
+	searcher.SortResults(found, filter.Sort)
+	if searchLimit > 0 && len(found) > searchLimit {
+		found = found[:searchLimit]
+	}
+	for _, match := range found {
+		result.Results = append(result.Results, searcher.NewBatchResult(match.Database, match))
+	}
+
+	return result, nil
+}
+
+type queryLine struct {
+	number int
+	text   string
+}
+
+// readQueriesFile reads one query per line, skipping blank lines and
+// lines starting with #
+func readQueriesFile(path string) ([]queryLine, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open queries file: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var queries []queryLine
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		queries = append(queries, queryLine{number: number, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read queries file: %w", err)
+	}
+
+	return queries, nil
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/bookmarks"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var bookmarkCmd = &cobra.Command{
+	Use:   "bookmark",
+	Short: "Save messages to named collections",
+	Long: `Bookmark useful messages into named collections, which can mix messages
+from any number of databases and be exported as Markdown or HTML.
+
+Bookmarks are kept in databases/.bookmarks.json. Each keeps a copy of the
+message, so collections outlive the databases they came from.
+
+Examples:
+  k8s-slack-searcher bookmark add sig-auth 1684141200.000100 --collection rbac-tips
+  k8s-slack-searcher bookmark add sig-node p1715680000000100 -c rbac-tips --note "kubelet authz"
+  k8s-slack-searcher bookmark list
+  k8s-slack-searcher bookmark export rbac-tips --format html -o rbac-tips.html`,
+}
+
+var bookmarkAddCmd = &cobra.Command{
+	Use:   "add <database> <ts>",
+	Short: "Bookmark a message",
+	Long: `Bookmark a message by its Slack timestamp, permalink timestamp or full
+permalink. Bookmarking a message again replaces its note.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runBookmarkAdd,
+}
+
+var bookmarkListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List collections, or the bookmarks in one",
+	Args:  cobra.NoArgs,
+	RunE:  runBookmarkList,
+}
+
+var bookmarkRemoveCmd = &cobra.Command{
+	Use:               "remove <database> <ts>",
+	Short:             "Remove a bookmark",
+	Long:              `Remove a message from a collection, or from every collection when --collection is omitted.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runBookmarkRemove,
+}
+
+var bookmarkExportCmd = &cobra.Command{
+	Use:               "export <collection>",
+	Short:             "Export a collection as Markdown or HTML",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeCollections,
+	RunE:              runBookmarkExport,
+}
+
+var (
+	bookmarkCollection   string
+	bookmarkAddTo        string
+	bookmarkNote         string
+	bookmarkFormat       string
+	bookmarkOutput       string
+	bookmarkTheme        string
+	bookmarkWorkspaceURL string
+)
+
+func init() {
+	bookmarkAddCmd.Flags().StringVarP(&bookmarkAddTo, "collection", "c", bookmarks.DefaultCollection,
+		"Collection to add the message to")
+	bookmarkAddCmd.Flags().StringVarP(&bookmarkNote, "note", "n", "",
+		"Note saved with the bookmark")
+	bookmarkListCmd.Flags().StringVarP(&bookmarkCollection, "collection", "c", "",
+		"List the bookmarks in a collection instead of the collections")
+	bookmarkRemoveCmd.Flags().StringVarP(&bookmarkCollection, "collection", "c", "",
+		"Collection to remove the message from (defaults to every collection)")
+	bookmarkExportCmd.Flags().StringVarP(&bookmarkFormat, "format", "f", bookmarks.FormatMarkdown,
+		"Export format (markdown|html)")
+	bookmarkExportCmd.Flags().StringVarP(&bookmarkOutput, "output", "o", "",
+		"Write the export to a file instead of stdout")
+	bookmarkExportCmd.Flags().StringVar(&bookmarkTheme, "theme", searcher.DefaultTheme,
+		"Built-in theme for HTML output ("+strings.Join(searcher.Themes(), "|")+")")
+	bookmarkExportCmd.Flags().StringVar(&bookmarkWorkspaceURL, "workspace-url", slacktext.DefaultWorkspaceURL,
+		"Slack workspace URL used to build permalinks")
+
+	for _, c := range []*cobra.Command{bookmarkAddCmd, bookmarkListCmd, bookmarkRemoveCmd} {
+		c.RegisterFlagCompletionFunc("collection", completeCollections)
+	}
+	bookmarkExportCmd.RegisterFlagCompletionFunc("format", completeValues(bookmarks.Formats...))
+	bookmarkExportCmd.RegisterFlagCompletionFunc("theme", completeValues(searcher.Themes()...))
+
+	bookmarkCmd.AddCommand(bookmarkAddCmd, bookmarkListCmd, bookmarkRemoveCmd, bookmarkExportCmd)
+}
+
+// completeCollections completes the names of bookmark collections
+func completeCollections(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	store, err := bookmarks.Load(storagepaths.Default.BookmarksPath())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, collection := range store.Collections() {
+		names = append(names, collection.Name)
+	}
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func runBookmarkAdd(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	ts, err := slacktext.ParseTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	message, err := search.GetMessageByTimestamp(cmd.Context(), ts)
+	if err != nil {
+		return err
+	}
+
+	// The channel ID is only needed for permalinks, so carry on without it
+	channelID := ""
+	if info, err := search.GetChannelInfo(cmd.Context(), search.ChannelName()); err == nil {
+		channelID = info.ID
+	}
+
+	store, err := bookmarks.Load(storagepaths.Default.BookmarksPath())
+	if err != nil {
+		return err
+	}
+
+	bookmark := bookmarks.NewBookmark(bookmarkAddTo, dbName, channelID, message)
+	bookmark.Note = bookmarkNote
+	added := store.Add(bookmark)
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	if added {
+		fmt.Printf("Bookmarked %s in %s to collection %s\n", ts, dbName, bookmark.Collection)
+	} else {
+		fmt.Printf("Updated bookmark %s in %s in collection %s\n", ts, dbName, bookmark.Collection)
+	}
+	return nil
+}
+
+func runBookmarkList(cmd *cobra.Command, args []string) error {
+	store, err := bookmarks.Load(storagepaths.Default.BookmarksPath())
+	if err != nil {
+		return err
+	}
+
+	if bookmarkCollection == "" {
+		collections := store.Collections()
+		if len(collections) == 0 {
+			fmt.Println("No bookmarks. Add one with 'k8s-slack-searcher bookmark add <database> <ts>'.")
+			return nil
+		}
+
+		fmt.Printf("Collections (%d):\n\n", len(collections))
+		for _, collection := range collections {
+			fmt.Printf("  %-30s %4d bookmark(s)\n", collection.Name, collection.Count)
+		}
+		return nil
+	}
+
+	marked := store.Collection(bookmarkCollection)
+	if len(marked) == 0 {
+		return fmt.Errorf("collection not found: %s", bookmarkCollection)
+	}
+
+	fmt.Printf("%s (%d bookmark(s)):\n\n", bookmarkCollection, len(marked))
+	for _, bookmark := range marked {
+		text := strings.Join(strings.Fields(bookmark.Text), " ")
+		if runes := []rune(text); len(runes) > 80 {
+			text = string(runes[:77]) + "..."
+		}
+		fmt.Printf("  %s  %-12s %s  %s: %s\n", bookmark.Date.Local().Format("2006-01-02 15:04"),
+			bookmark.Database, bookmark.Timestamp, bookmark.UserName, text)
+		if bookmark.Note != "" {
+			fmt.Printf("  %s\n", bookmark.Note)
+		}
+	}
+
+	return nil
+}
+
+func runBookmarkRemove(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	ts, err := slacktext.ParseTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+
+	store, err := bookmarks.Load(storagepaths.Default.BookmarksPath())
+	if err != nil {
+		return err
+	}
+
+	removed := store.Remove(bookmarkCollection, dbName, ts)
+	if removed == 0 {
+		return fmt.Errorf("no bookmark for %s in %s", ts, dbName)
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d bookmark(s)\n", removed)
+	return nil
+}
+
+func runBookmarkExport(cmd *cobra.Command, args []string) error {
+	collection := args[0]
+
+	if bookmarkFormat != bookmarks.FormatMarkdown && bookmarkFormat != bookmarks.FormatHTML {
+		return fmt.Errorf("unsupported export format: %s (supported: %s)", bookmarkFormat, strings.Join(bookmarks.Formats, ", "))
+	}
+
+	store, err := bookmarks.Load(storagepaths.Default.BookmarksPath())
+	if err != nil {
+		return err
+	}
+
+	marked := store.Collection(collection)
+	if len(marked) == 0 {
+		return fmt.Errorf("collection not found: %s", collection)
+	}
+
+	// Check the theme before creating the output file
+	css, err := searcher.ThemeCSS(bookmarkTheme)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if bookmarkOutput != "" {
+		file, err := os.Create(bookmarkOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if bookmarkFormat == bookmarks.FormatHTML {
+		err = bookmarks.WriteHTML(w, collection, marked, css, bookmarkWorkspaceURL)
+	} else {
+		err = bookmarks.WriteMarkdown(w, collection, marked, bookmarkWorkspaceURL)
+	}
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	if bookmarkOutput != "" {
+		fmt.Printf("Exported %d bookmark(s) to: %s\n", len(marked), bookmarkOutput)
+		noteExported(bookmarkOutput)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse <database> --date <YYYY-MM-DD>",
+	Short: "Read every message posted on a day",
+	Long: `Print every message posted in a channel on a given day, in order, with
+thread replies indented beneath the messages they answer. This is a
+reading mode for the archive, independent of search.
+
+Replies posted on later days are shown with their thread. Replies to
+threads started on an earlier day appear where they were posted.
+
+Examples:
+  k8s-slack-searcher browse sig-auth --date 2021-03-14
+  k8s-slack-searcher browse sig-auth --date 2021-03-14 | less`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runBrowse,
+}
+
+var browseDate string
+
+func init() {
+	browseCmd.Flags().StringVar(&browseDate, "date", "",
+		"Day to show, as YYYY-MM-DD")
+	browseCmd.MarkFlagRequired("date")
+}
+
+func runBrowse(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	day, err := time.ParseInLocation("2006-01-02", browseDate, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date %q: expected YYYY-MM-DD", browseDate)
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	messages, err := search.Browse(cmd.Context(), day)
+	if err != nil {
+		return fmt.Errorf("failed to load messages: %w", err)
+	}
+
+	if len(messages) == 0 {
+		fmt.Printf("No messages in %s on %s\n", dbName, day.Format("2006-01-02"))
+		return nil
+	}
+
+	fmt.Printf("%s on %s (%s)\n\n", dbName, day.Format("2006-01-02"), day.Format("Monday"))
+	fmt.Print(searcher.FormatDay(messages))
+	return nil
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var channelCmd = &cobra.Command{
+	Use:   "channel <database>",
+	Short: "Show channel metadata for a database",
+	Long: `Show channel metadata from channels.json for an indexed database,
+including creation date, creator, archived status, topic and purpose,
+plus the dates of the first and last indexed messages.
+
+By default the channel with the same name as the database is shown.
+Use --name to look up a different channel stored in the database.
+
+Examples:
+  k8s-slack-searcher channel sig-auth
+  k8s-slack-searcher channel sig-auth --name sig-node`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runChannel,
+}
+
+var channelsCmd = &cobra.Command{
+	Use:   "channels",
+	Short: "Discover channels stored in a database",
+	Long:  `Commands for discovering channels from the metadata stored in a database.`,
+}
+
+var channelsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Find channels by name, topic or purpose",
+	Long: `Search channel names, topics and purposes using full-text search.
+
+Every database stores metadata for all channels in the export, so any
+database can be used to discover which channels discuss a subject.
+
+Examples:
+  k8s-slack-searcher channels search kubeadm --database sig-auth
+  k8s-slack-searcher channels search "auth*" --database sig-auth`,
+	Args: cobra.ExactArgs(1),
+	RunE: runChannelsSearch,
+}
+
+var (
+	channelName         string
+	channelsDatabase    string
+	channelsSearchLimit int
+)
+
+func init() {
+	channelCmd.Flags().StringVarP(&channelName, "name", "n", "",
+		"Channel name to show (defaults to the database name)")
+
+	channelsSearchCmd.Flags().StringVarP(&channelsDatabase, "database", "d", "",
+		"Database name (channel name) to search in (defaults to the active database)")
+	channelsSearchCmd.Flags().IntVarP(&channelsSearchLimit, "limit", "l", 10,
+		"Maximum number of channels to return")
+
+	channelsSearchCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+
+	channelsCmd.AddCommand(channelsSearchCmd)
+}
+
+func runChannel(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	name := channelName
+	if name == "" {
+		name = search.ChannelName()
+	}
+
+	info, err := search.GetChannelInfo(cmd.Context(), name)
+	if err != nil {
+		return err
+	}
+
+	archived := "no"
+	if info.IsArchived {
+		archived = "yes"
+	}
+
+	creator := info.Creator
+	if info.CreatorName != "" {
+		creator = fmt.Sprintf("%s (%s)", info.CreatorName, info.Creator)
+	}
+
+	fmt.Printf("Channel: #%s (%s)\n", info.Name, info.ID)
+	fmt.Printf("Type: %s\n", conversationLabel(info.Kind))
+	fmt.Printf("Created: %s\n", time.Unix(info.Created, 0).Format("2006-01-02 15:04:05"))
+	fmt.Printf("Creator: %s\n", creator)
+	fmt.Printf("Archived: %s\n", archived)
+	fmt.Printf("Topic: %s\n", info.Topic)
+	fmt.Printf("Purpose: %s\n", info.Purpose)
+	fmt.Printf("Members: %d\n", info.MemberCount)
+	fmt.Printf("Messages indexed: %d\n", info.MessageCount)
+
+	if info.MessageCount > 0 {
+		fmt.Printf("First message: %s\n", info.FirstMessage.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Last message: %s\n", info.LastMessage.Format("2006-01-02 15:04:05"))
+	}
+
+	return nil
+}
+
+func runChannelsSearch(cmd *cobra.Command, args []string) error {
+	query := args[0]
+
+	// Fall back to the active database when --database is omitted
+	if err := resolveDatabase(&channelsDatabase); err != nil {
+		return err
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(channelsDatabase) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", channelsDatabase)
+	}
+
+	search, err := searcher.NewSearcher(channelsDatabase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	results, err := search.SearchChannels(cmd.Context(), query, channelsSearchLimit)
+	if err != nil {
+		return fmt.Errorf("channel search failed: %w", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No channels found matching: %s\n", query)
+		return nil
+	}
+
+	fmt.Printf("Found %d channel(s) matching: %s\n\n", len(results), query)
+	for _, result := range results {
+		archived := ""
+		if result.IsArchived {
+			archived = " [archived]"
+		}
+
+		fmt.Printf("#%s (%d members)%s\n", result.Name, result.MemberCount, archived)
+		if result.Topic != "" {
+			fmt.Printf("  Topic: %s\n", result.Topic)
+		}
+		if result.Purpose != "" {
+			fmt.Printf("  Purpose: %s\n", result.Purpose)
+		}
+		fmt.Printf("  Match: %s\n\n", result.Snippet)
+	}
+
+	return nil
+}
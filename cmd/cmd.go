@@ -2,7 +2,44 @@ package cmd
 
 // Export commands for use in main.go
 var (
-	IngestCmd = ingestCmd
-	SearchCmd = searchCmd
-	ListCmd   = listCmd
-)
\ No newline at end of file
+	IngestCmd         = ingestCmd
+	IngestAllCmd      = ingestAllCmd
+	SearchCmd         = searchCmd
+	ListCmd           = listCmd
+	UsersCmd          = usersCmd
+	ChannelCmd        = channelCmd
+	ChannelsCmd       = channelsCmd
+	AnalyzeCmd        = analyzeCmd
+	DigestCmd         = digestCmd
+	SummarizeCmd      = summarizeCmd
+	ExportCmd         = exportCmd
+	UseCmd            = useCmd
+	ShowCmd           = showCmd
+	ThreadCmd         = threadCmd
+	OpenCmd           = openCmd
+	SuggestCmd        = suggestCmd
+	GetCmd            = getCmd
+	SimilarCmd        = similarCmd
+	BrowseCmd         = browseCmd
+	StatsCmd          = statsCmd
+	DiffCmd           = diffCmd
+	MergeCmd          = mergeCmd
+	BackupCmd         = backupCmd
+	RestoreCmd        = restoreCmd
+	AuditCmd          = auditCmd
+	SlowlogCmd        = slowlogCmd
+	AlertsCmd         = alertsCmd
+	CronCmd           = cronCmd
+	FeedbackCmd       = feedbackCmd
+	BookmarkCmd       = bookmarkCmd
+	TagCmd            = tagCmd
+	SetsCmd           = setsCmd
+	MediaCmd          = mediaCmd
+	SkippedCmd        = skippedCmd
+	GenFixtureCmd     = genFixtureCmd
+	StopwordsCmd      = stopwordsCmd
+	QueryCmd          = queryCmd
+	VerifyCmd         = verifyCmd
+	RawCmd            = rawCmd
+	ValidateSourceCmd = validateSourceCmd
+)
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+// completeDatabases completes database names from the databases directory
+func completeDatabases(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	databases, err := searcher.ListDatabases()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	return filterPrefix(databases, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDatabaseArg completes a database name as the first positional argument
+func completeDatabaseArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeDatabases(cmd, args, toComplete)
+}
+
+// completeDatabaseArgs completes database names for commands that take
+// exactly two databases
+func completeDatabaseArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeDatabases(cmd, args, toComplete)
+}
+
+// completeChannelDirs completes channel directory names within the source
+// data directory given by the command's --source flag
+func completeChannelDirs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	source, err := cmd.Flags().GetString("source")
+	if err != nil || source == "" {
+		source = "source-data"
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var channels []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			channels = append(channels, entry.Name())
+		}
+	}
+
+	return filterPrefix(channels, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues returns a completion function offering a fixed set of values
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return filterPrefix(values, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// filterPrefix returns the candidates starting with prefix
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
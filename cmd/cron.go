@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/scheduler"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var cronCmd = &cobra.Command{
+	Use:   "cron",
+	Short: "Run scheduled sync, optimize, digest and alert jobs",
+	Long: `Run the jobs listed under cron in the config file at their intervals,
+without needing an external cron daemon. Jobs run one at a time, and the
+outcome of every run is logged to stdout and to cron.log_file if set.
+
+Tasks:
+  sync      Ingest new message files for a channel, then check its alerts
+  optimize  Compact a database's search index and refresh its statistics
+  digest    Write the digest of the previous week to a file
+  alerts    Check alerts against messages added by other processes
+
+  cron:
+    log_file: /var/log/k8s-slack-searcher-cron.log
+    jobs:
+      - task: sync
+        database: sig-auth
+        source: /data/slack-export
+        every: 1h
+      - task: optimize
+        database: sig-auth
+        every: 24h
+      - task: digest
+        database: sig-auth
+        every: 168h
+        output: digests/{database}-{week}.html
+        format: html
+
+Examples:
+  k8s-slack-searcher cron
+  k8s-slack-searcher cron --once`,
+	Args: cobra.NoArgs,
+	RunE: runCron,
+}
+
+var cronOnce bool
+
+// Scheduled tasks
+const (
+	taskSync     = "sync"
+	taskOptimize = "optimize"
+	taskDigest   = "digest"
+	taskAlerts   = "alerts"
+)
+
+// minJobInterval keeps a misconfigured job from running constantly
+const minJobInterval = time.Minute
+
+func init() {
+	cronCmd.Flags().BoolVar(&cronOnce, "once", false,
+		"Run every job once and exit")
+}
+
+func runCron(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Cron.Jobs) == 0 {
+		path, _ := config.Path()
+		return fmt.Errorf("no cron jobs configured. Add them to %s", path)
+	}
+
+	jobs, err := cronJobs(cfg.Cron.Jobs)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if cfg.Cron.LogFile != "" {
+		file, err := os.OpenFile(cfg.Cron.LogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open cron log: %w", err)
+		}
+		defer file.Close()
+		w = io.MultiWriter(os.Stdout, file)
+	}
+	logger := log.New(w, "", log.LstdFlags)
+
+	s := scheduler.New(jobs, logger)
+	if cronOnce {
+		if failed := s.RunOnce(cmd.Context()); failed > 0 {
+			return fmt.Errorf("%d of %d job(s) failed", failed, len(jobs))
+		}
+		return nil
+	}
+
+	logger.Printf("Scheduled %d job(s)", len(jobs))
+	s.Run(cmd.Context())
+	logger.Printf("Stopped")
+
+	return nil
+}
+
+// cronJobs checks the configured jobs and builds a scheduler job for each
+func cronJobs(configured []config.Job) ([]scheduler.Job, error) {
+	var jobs []scheduler.Job
+	for i, job := range configured {
+		name := job.Name
+		if name == "" {
+			name = strings.TrimSpace(job.Task + " " + job.Database)
+		}
+
+		every, err := time.ParseDuration(job.Every)
+		if err != nil {
+			return nil, fmt.Errorf("cron job %d (%s): invalid interval %q: %w", i+1, name, job.Every, err)
+		}
+		if every < minJobInterval {
+			return nil, fmt.Errorf("cron job %d (%s): interval must be at least %s", i+1, name, minJobInterval)
+		}
+
+		var run func(context.Context) (string, error)
+		switch job.Task {
+		case taskSync:
+			run, err = syncTask(job)
+		case taskOptimize:
+			run, err = optimizeTask(job)
+		case taskDigest:
+			run, err = digestTask(job)
+		case taskAlerts:
+			run, err = alertsTask(job)
+		default:
+			err = fmt.Errorf("unknown task %q (expected %s, %s, %s or %s)", job.Task, taskSync, taskOptimize, taskDigest, taskAlerts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cron job %d (%s): %w", i+1, name, err)
+		}
+
+		jobs = append(jobs, scheduler.Job{Name: name, Every: every, Run: run})
+	}
+
+	return jobs, nil
+}
+
+// syncTask ingests any message files added to a channel's export since the
+// last run, then checks the channel's alerts against the new messages
+func syncTask(job config.Job) (func(context.Context) (string, error), error) {
+	if job.Database == "" {
+		return nil, fmt.Errorf("sync needs a database")
+	}
+	source := job.Source
+	if source == "" {
+		source = "source-data"
+	}
+	dbName := qualify(job.Database)
+	_, channel := storagepaths.SplitName(dbName)
+
+	return func(ctx context.Context) (string, error) {
+		if _, err := os.Stat(filepath.Join(source, channel)); err != nil {
+			return "", fmt.Errorf("channel directory does not exist: %s", filepath.Join(source, channel))
+		}
+
+		// The config file is the consent ingest would otherwise ask for
+		kind, err := indexer.ConversationKind(source, channel)
+		if err != nil {
+			return "", err
+		}
+		if kind != models.KindChannel && !job.IncludePrivate {
+			return "", fmt.Errorf("%s is a %s; set include_private to sync it", channel, conversationLabel(kind))
+		}
+
+		if err := os.MkdirAll("databases", 0755); err != nil {
+			return "", fmt.Errorf("failed to create databases directory: %w", err)
+		}
+
+		idx, err := indexer.NewIndexer(source, dbName)
+		if err != nil {
+			return "", fmt.Errorf("failed to create indexer: %w", err)
+		}
+		lastID, err := idx.LastMessageID(ctx)
+		if err != nil {
+			idx.Close()
+			return "", err
+		}
+		err = idx.IndexChannel(ctx)
+		inserted := idx.Metrics().Inserted
+		if closeErr := idx.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+
+		summary := fmt.Sprintf("%d new message(s)", inserted)
+		if inserted == 0 {
+			return summary, nil
+		}
+
+		sent, errs := checkAlerts(ctx, dbName, lastID)
+		if len(sent) > 0 {
+			summary += "; alerts sent: " + strings.Join(sent, ", ")
+		}
+		for _, err := range errs {
+			summary += fmt.Sprintf("; alert failed: %v", err)
+		}
+		return summary, nil
+	}, nil
+}
+
+// optimizeTask compacts a database's search index
+func optimizeTask(job config.Job) (func(context.Context) (string, error), error) {
+	if job.Database == "" {
+		return nil, fmt.Errorf("optimize needs a database")
+	}
+	dbName := qualify(job.Database)
+
+	return func(ctx context.Context) (string, error) {
+		if !searcher.ValidateDatabaseExists(dbName) {
+			return "", fmt.Errorf("database not found: %s", dbName)
+		}
+		if err := indexer.Optimize(ctx, dbName); err != nil {
+			return "", err
+		}
+		return "optimized " + dbName, nil
+	}, nil
+}
+
+// digestTask writes the digest of the last complete ISO week. The output
+// path may contain {database} and {week}, which are replaced with the
+// database name and the week.
+func digestTask(job config.Job) (func(context.Context) (string, error), error) {
+	if job.Database == "" {
+		return nil, fmt.Errorf("digest needs a database")
+	}
+	format := job.Format
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "html" {
+		return nil, fmt.Errorf("unsupported digest format: %s (expected markdown or html)", format)
+	}
+	output := job.Output
+	if output == "" {
+		output = "digests/{database}-{week}.md"
+		if format == "html" {
+			output = "digests/{database}-{week}.html"
+		}
+	}
+	dbName := qualify(job.Database)
+
+	return func(ctx context.Context) (string, error) {
+		year, number := time.Now().AddDate(0, 0, -7).ISOWeek()
+		week := fmt.Sprintf("%d-W%02d", year, number)
+
+		digest, err := buildDigest(ctx, dbName, week, 5)
+		if err != nil {
+			return "", err
+		}
+
+		path := strings.NewReplacer("{database}", storagepaths.Sanitize(dbName), "{week}", week).Replace(output)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create digest directory: %w", err)
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to create digest file: %w", err)
+		}
+		defer file.Close()
+
+		if err := writeDigest(file, digest, format, searcher.DefaultTheme); err != nil {
+			return "", err
+		}
+		return "wrote " + path, nil
+	}, nil
+}
+
+// alertsTask checks alerts against messages added since the previous run,
+// for databases updated by another process such as a separate ingest. The
+// first run only notes where each database ends. Without a database it
+// covers every database named by an alert.
+func alertsTask(job config.Job) (func(context.Context) (string, error), error) {
+	lastIDs := make(map[string]int)
+
+	return func(ctx context.Context) (string, error) {
+		var databases []string
+		if job.Database != "" {
+			databases = []string{qualify(job.Database)}
+		} else {
+			cfg, err := config.Load()
+			if err != nil {
+				return "", err
+			}
+			seen := make(map[string]bool)
+			for _, alert := range cfg.Alerts {
+				name := qualify(alert.Database)
+				if alert.Database != "" && !seen[name] {
+					seen[name] = true
+					databases = append(databases, name)
+				}
+			}
+		}
+
+		var sent, failed []string
+		for _, dbName := range databases {
+			if !searcher.ValidateDatabaseExists(dbName) {
+				failed = append(failed, fmt.Sprintf("database not found: %s", dbName))
+				continue
+			}
+
+			search, err := searcher.NewSearcher(dbName)
+			if err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			lastID, err := search.LastMessageID(ctx)
+			search.Close()
+			if err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+
+			previous, ok := lastIDs[dbName]
+			lastIDs[dbName] = lastID
+			if !ok || lastID <= previous {
+				continue
+			}
+
+			names, errs := checkAlerts(ctx, dbName, previous)
+			sent = append(sent, names...)
+			for _, err := range errs {
+				failed = append(failed, err.Error())
+			}
+		}
+
+		if len(failed) > 0 {
+			return "", fmt.Errorf("%s", strings.Join(failed, "; "))
+		}
+		if len(sent) == 0 {
+			return fmt.Sprintf("checked %d database(s), nothing new matched", len(databases)), nil
+		}
+		return "alerts sent: " + strings.Join(sent, ", "), nil
+	}, nil
+}
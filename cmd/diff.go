@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/analyzer"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Show messages in a new database that an older one lacks",
+	Long: `Compare two databases of the same channel, typically ingested from
+successive Slack exports, and print the messages in the new database that
+are not in the old one. Messages are matched by their Slack timestamp, so
+this shows what a fresh export adds, for "what's new" digests.
+
+Each database can be given by name or as the path to a .db file, such as
+a copy kept from an earlier ingest.
+
+Examples:
+  k8s-slack-searcher diff 2024-05/sig-auth 2024-06/sig-auth
+  k8s-slack-searcher diff backups/sig-auth.db sig-auth
+  k8s-slack-searcher diff backups/sig-auth.db sig-auth --json > new.json`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArgs,
+	RunE:              runDiff,
+}
+
+var diffJSON bool
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output as JSON")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	old, err := openAnalyzerArg(args[0])
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+
+	current, err := openAnalyzerArg(args[1])
+	if err != nil {
+		return err
+	}
+	defer current.Close()
+
+	added, err := current.NewMessages(cmd.Context(), old)
+	if err != nil {
+		return fmt.Errorf("failed to compare databases: %w", err)
+	}
+
+	if diffJSON {
+		if added == nil {
+			added = []*models.Message{}
+		}
+		return printJSON(added)
+	}
+
+	if len(added) == 0 {
+		fmt.Printf("No new messages in %s\n", args[1])
+		return nil
+	}
+
+	fmt.Printf("%d new message(s) in %s since %s\n\n", len(added), args[1], args[0])
+	fmt.Print(searcher.FormatDay(added))
+	return nil
+}
+
+// openAnalyzerArg opens a database given by name, or by path when the
+// argument names a .db file
+func openAnalyzerArg(arg string) (*analyzer.Analyzer, error) {
+	if !strings.HasSuffix(arg, ".db") {
+		return openAnalyzer(qualify(arg))
+	}
+
+	info, err := os.Stat(arg)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("database file not found: %s", arg)
+	}
+
+	// Database files are named after their sanitized channel name
+	name := strings.TrimSuffix(filepath.Base(arg), ".db")
+	if storagepaths.Sanitize(name) != name {
+		return nil, fmt.Errorf("unsupported database file name: %s", arg)
+	}
+
+	a, err := analyzer.NewAnalyzerInDir(filepath.Dir(arg), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return a, nil
+}
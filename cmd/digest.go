@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/analyzer"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Generate a weekly digest for a channel",
+	Long: `Generate a summary of a week's activity in a channel database: the most
+active threads, the top links shared and the most-reacted messages.
+
+Weeks use ISO 8601 notation (YYYY-Www). Output is Markdown by default,
+or a standalone HTML page with --format html.
+
+Examples:
+  k8s-slack-searcher digest --database sig-node --week 2024-W20
+  k8s-slack-searcher digest --database sig-node --week 2024-W20 --format html --output digest.html`,
+	Args: cobra.NoArgs,
+	RunE: runDigest,
+}
+
+var (
+	digestDatabase string
+	digestWeek     string
+	digestFormat   string
+	digestOutput   string
+	digestLimit    int
+	digestTheme    string
+)
+
+func init() {
+	digestCmd.Flags().StringVarP(&digestDatabase, "database", "d", "",
+		"Database name (channel name) to summarize (defaults to the active database)")
+	digestCmd.Flags().StringVarP(&digestWeek, "week", "w", "",
+		"ISO week to summarize, e.g. 2024-W20 (required)")
+	digestCmd.Flags().StringVarP(&digestFormat, "format", "f", "markdown",
+		"Output format (markdown|html)")
+	digestCmd.Flags().StringVarP(&digestOutput, "output", "o", "",
+		"Write the digest to a file instead of stdout")
+	digestCmd.Flags().IntVarP(&digestLimit, "limit", "l", 5,
+		"Maximum number of entries in each section")
+	digestCmd.Flags().StringVar(&digestTheme, "theme", searcher.DefaultTheme,
+		"Built-in theme for HTML output")
+
+	digestCmd.MarkFlagRequired("week")
+	digestCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+	digestCmd.RegisterFlagCompletionFunc("format", completeValues("markdown", "html"))
+	digestCmd.RegisterFlagCompletionFunc("theme", completeValues(searcher.Themes()...))
+}
+
+func runDigest(cmd *cobra.Command, args []string) error {
+	if digestFormat != "markdown" && digestFormat != "html" {
+		return fmt.Errorf("unsupported format: %s (expected markdown or html)", digestFormat)
+	}
+
+	// Fall back to the active database when --database is omitted
+	if err := resolveDatabase(&digestDatabase); err != nil {
+		return err
+	}
+
+	digest, err := buildDigest(cmd.Context(), digestDatabase, digestWeek, digestLimit)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if digestOutput != "" {
+		file, err := os.Create(digestOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := writeDigest(w, digest, digestFormat, digestTheme); err != nil {
+		return err
+	}
+
+	if digestOutput != "" {
+		fmt.Printf("Digest written to: %s\n", digestOutput)
+	}
+
+	return nil
+}
+
+// buildDigest summarizes a database's activity in an ISO week
+func buildDigest(ctx context.Context, dbName, week string, limit int) (*models.Digest, error) {
+	a, err := openAnalyzer(dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	digest, err := a.Digest(ctx, week, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build digest: %w", err)
+	}
+	digest.Database = dbName
+
+	return digest, nil
+}
+
+// writeDigest renders a digest as Markdown or HTML
+func writeDigest(w io.Writer, digest *models.Digest, format, theme string) error {
+	if format == "html" {
+		return analyzer.GenerateDigestHTML(w, digest, theme)
+	}
+	return analyzer.GenerateDigestMarkdown(w, digest)
+}
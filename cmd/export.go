@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/exporter"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <database>",
+	Short: "Export a channel database for use by other tools",
+	Long: `Export the messages in a channel database in a format suitable for
+other tools.
+
+Formats:
+  rag        Thread-aggregated documents split into overlapping chunks, written
+             as JSONL with metadata (channel, users, dates, permalink) for
+             embedding and retrieval-augmented generation pipelines
+  datasette  A Datasette metadata.json next to the database, with table
+             descriptions, full-text search on messages and canned queries.
+             Exporting several databases adds each to the same file.
+
+Examples:
+  k8s-slack-searcher export sig-auth --format rag --output sig-auth.jsonl
+  k8s-slack-searcher export sig-auth --format rag --chunk-size 200 --chunk-overlap 40
+  k8s-slack-searcher export sig-auth --format datasette
+  datasette databases/sig-auth.db --metadata databases/metadata.json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runExport,
+}
+
+var (
+	exportFormat       string
+	exportOutput       string
+	exportChunkSize    int
+	exportChunkOverlap int
+	exportWorkspaceURL string
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "rag",
+		"Export format (rag|datasette)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "",
+		"Write the export to a file instead of stdout")
+	exportCmd.Flags().IntVar(&exportChunkSize, "chunk-size", 300,
+		"Maximum number of words per chunk")
+	exportCmd.Flags().IntVar(&exportChunkOverlap, "chunk-overlap", 50,
+		"Number of words shared between consecutive chunks")
+	exportCmd.Flags().StringVar(&exportWorkspaceURL, "workspace-url", slacktext.DefaultWorkspaceURL,
+		"Slack workspace URL used to build permalinks")
+
+	exportCmd.RegisterFlagCompletionFunc("format", completeValues("rag", "datasette"))
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	if exportFormat != "rag" && exportFormat != "datasette" {
+		return fmt.Errorf("unsupported export format: %s", exportFormat)
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	exp, err := exporter.NewExporter(dbName)
+	if err != nil {
+		return err
+	}
+	defer exp.Close()
+
+	if exportFormat == "datasette" {
+		return exportDatasette(cmd, exp, dbName)
+	}
+
+	var w io.Writer = os.Stdout
+	if exportOutput != "" {
+		file, err := os.Create(exportOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := exp.ExportRAG(cmd.Context(), w, exporter.RAGOptions{
+		ChunkSize:    exportChunkSize,
+		ChunkOverlap: exportChunkOverlap,
+		WorkspaceURL: exportWorkspaceURL,
+	})
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	if exportOutput != "" {
+		fmt.Printf("Exported %d chunk(s) to: %s\n", count, exportOutput)
+		noteExported(exportOutput)
+	}
+
+	return nil
+}
+
+// exportDatasette writes Datasette metadata for a database, by default to
+// metadata.json in the database's directory
+func exportDatasette(cmd *cobra.Command, exp *exporter.Exporter, dbName string) error {
+	if storagepaths.IsEncrypted(dbName) {
+		return fmt.Errorf("%s is encrypted; Datasette can only serve unencrypted databases", dbName)
+	}
+
+	path := exportOutput
+	if path == "" {
+		path = filepath.Join(filepath.Dir(storagepaths.DatabasePath(dbName)), "metadata.json")
+	}
+
+	if err := exp.WriteDatasetteMetadata(cmd.Context(), path); err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	fmt.Printf("Datasette metadata for %s written to: %s\n", exporter.DatasetteName(dbName), path)
+	fmt.Printf("Serve it with: datasette %s --metadata %s\n", storagepaths.DatabasePath(dbName), path)
+	return nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback <result#> <helpful|not-helpful|clear>",
+	Short: "Mark a result from the last search as helpful or not",
+	Long: `Mark a message from the last search as helpful or not helpful. Feedback
+is stored in the message's database and used by later relevance-sorted
+searches: helpful messages rank higher and not helpful ones lower. Other
+messages in the same thread move by half as much.
+
+Results are numbered as printed by the search command. Use 'clear' to
+remove a mark and 'feedback list' to see the marks in a database.
+
+Examples:
+  k8s-slack-searcher search "token rotation" -d sig-auth
+  k8s-slack-searcher feedback 3 helpful
+  k8s-slack-searcher feedback 5 not-helpful`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeFeedback,
+	RunE:              runFeedback,
+}
+
+var feedbackListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the feedback recorded in a database",
+	Args:  cobra.NoArgs,
+	RunE:  runFeedbackList,
+}
+
+// feedbackScores maps the feedback given on the command line to scores
+var feedbackScores = map[string]int{
+	"helpful":     1,
+	"not-helpful": -1,
+	"clear":       0,
+}
+
+var feedbackDatabase string
+
+func init() {
+	feedbackListCmd.Flags().StringVarP(&feedbackDatabase, "database", "d", "",
+		"Database name (channel name) to list feedback from (defaults to the active database)")
+	feedbackListCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+
+	feedbackCmd.AddCommand(feedbackListCmd)
+}
+
+// completeFeedback offers the feedback values once a result is given
+func completeFeedback(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeValues("helpful", "not-helpful", "clear")(cmd, args, toComplete)
+}
+
+func runFeedback(cmd *cobra.Command, args []string) error {
+	score, ok := feedbackScores[args[1]]
+	if !ok {
+		return fmt.Errorf("invalid feedback: %s (use helpful, not-helpful or clear)", args[1])
+	}
+
+	search, message, err := loadResult(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	// Keep the query the feedback was given for, to help review it later
+	query := ""
+	if state, err := config.LoadState(); err == nil && state.LastResults != nil {
+		query = state.LastResults.Query
+	}
+
+	if err := search.SetFeedback(cmd.Context(), message, score, query); err != nil {
+		return err
+	}
+
+	switch {
+	case score > 0:
+		fmt.Printf("Marked result %s as helpful\n", args[0])
+	case score < 0:
+		fmt.Printf("Marked result %s as not helpful\n", args[0])
+	default:
+		fmt.Printf("Cleared feedback on result %s\n", args[0])
+	}
+	return nil
+}
+
+func runFeedbackList(cmd *cobra.Command, args []string) error {
+	if err := resolveDatabase(&feedbackDatabase); err != nil {
+		return err
+	}
+
+	if !searcher.ValidateDatabaseExists(feedbackDatabase) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", feedbackDatabase)
+	}
+
+	search, err := searcher.NewSearcher(feedbackDatabase)
+	if err != nil {
+		return fmt.Errorf("failed to create searcher: %w", err)
+	}
+	defer search.Close()
+
+	feedback, err := search.GetFeedback(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if len(feedback) == 0 {
+		fmt.Printf("No feedback recorded in %s\n", feedbackDatabase)
+		return nil
+	}
+
+	for _, f := range feedback {
+		mark := "helpful"
+		if f.Score < 0 {
+			mark = "not helpful"
+		}
+		text := strings.Join(strings.Fields(f.Text), " ")
+		if runes := []rune(text); len(runes) > 80 {
+			text = string(runes[:77]) + "..."
+		}
+		fmt.Printf("%s  %-11s  @%s: %s\n", f.Updated.Local().Format("2006-01-02 15:04"), mark, f.UserName, text)
+		if f.Query != "" {
+			fmt.Printf("                   for query: %s\n", f.Query)
+		}
+	}
+
+	return nil
+}
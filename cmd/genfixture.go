@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/fixture"
+
+	"github.com/spf13/cobra"
+)
+
+var genFixtureCmd = &cobra.Command{
+	Use:   "gen-fixture",
+	Short: "Generate a synthetic Slack export for benchmarking",
+	Long: `Generate a synthetic Slack export of a chosen size, for benchmarking ingest
+and search and testing large-scale behavior without real archives, which
+may hold sensitive conversations.
+
+The export has users.json, channels.json and a file of messages for each
+day, in the layout ingest reads. Messages are spread evenly over the days
+and written by randomly chosen users; --thread-ratio sets the share that
+are replies to a recent thread. The same flags and --seed always give the
+same export. The output directory must not already hold an export.
+
+Examples:
+  k8s-slack-searcher gen-fixture --output /tmp/bench
+  k8s-slack-searcher gen-fixture --output /tmp/bench --users 500 --days 365 --messages 1000000
+  k8s-slack-searcher ingest sig-bench --source /tmp/bench`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runGenFixture,
+}
+
+var (
+	genFixtureOutput      string
+	genFixtureChannel     string
+	genFixtureUsers       int
+	genFixtureDays        int
+	genFixtureMessages    int
+	genFixtureThreadRatio float64
+	genFixtureStart       string
+	genFixtureSeed        int64
+)
+
+func init() {
+	genFixtureCmd.Flags().StringVarP(&genFixtureOutput, "output", "o", "fixture-data",
+		"Directory to write the export to")
+	genFixtureCmd.Flags().StringVar(&genFixtureChannel, "channel", "sig-bench",
+		"Name of the generated channel")
+	genFixtureCmd.Flags().IntVar(&genFixtureUsers, "users", 50,
+		"Number of users")
+	genFixtureCmd.Flags().IntVar(&genFixtureDays, "days", 30,
+		"Number of days of messages")
+	genFixtureCmd.Flags().IntVar(&genFixtureMessages, "messages", 10000,
+		"Total number of messages")
+	genFixtureCmd.Flags().Float64Var(&genFixtureThreadRatio, "thread-ratio", 0.3,
+		"Share of messages that are thread replies, from 0 to below 1")
+	genFixtureCmd.Flags().StringVar(&genFixtureStart, "start", "2024-01-01",
+		"First day of the export (YYYY-MM-DD)")
+	genFixtureCmd.Flags().Int64Var(&genFixtureSeed, "seed", 1,
+		"Seed for the generator")
+}
+
+func runGenFixture(cmd *cobra.Command, args []string) error {
+	switch {
+	case genFixtureUsers < 1:
+		return fmt.Errorf("--users must be at least 1")
+	case genFixtureDays < 1:
+		return fmt.Errorf("--days must be at least 1")
+	case genFixtureMessages < 0:
+		return fmt.Errorf("--messages can't be negative")
+	case genFixtureThreadRatio < 0 || genFixtureThreadRatio >= 1:
+		return fmt.Errorf("--thread-ratio must be at least 0 and less than 1")
+	}
+	start, err := time.Parse("2006-01-02", genFixtureStart)
+	if err != nil {
+		return fmt.Errorf("--start must be a date (YYYY-MM-DD): %w", err)
+	}
+
+	summary, err := fixture.Generate(genFixtureOutput, fixture.Options{
+		Channel:     genFixtureChannel,
+		Users:       genFixtureUsers,
+		Days:        genFixtureDays,
+		Messages:    genFixtureMessages,
+		ThreadRatio: genFixtureThreadRatio,
+		Start:       start,
+		Seed:        genFixtureSeed,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %d message(s) from %d user(s) over %d day(s) in %s\n",
+		summary.Messages, summary.Users, summary.Days, genFixtureOutput)
+	fmt.Printf("Threads: %d with %d replies\n", summary.Threads, summary.Replies)
+	fmt.Printf("Ingest it with: k8s-slack-searcher ingest %s --source %s\n", genFixtureChannel, genFixtureOutput)
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+
+	"github.com/spf13/cobra"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get <database> <ts>",
+	Short: "Fetch a message by its Slack timestamp or permalink",
+	Long: `Fetch a single message by its Slack timestamp, for following a
+permalink from elsewhere back into the archive.
+
+The timestamp can be given as stored by Slack (1684141200.000100), in the
+form used in permalinks (p1684141200000100) or as a full permalink.
+Use --thread to show the whole thread the message belongs to.
+
+Examples:
+  k8s-slack-searcher get sig-auth 1684141200.000100
+  k8s-slack-searcher get sig-auth https://kubernetes.slack.com/archives/C0EN96KUY/p1684141200000100 --thread`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runGet,
+}
+
+var getThread bool
+
+func init() {
+	getCmd.Flags().BoolVarP(&getThread, "thread", "t", false,
+		"Show the whole thread the message belongs to")
+}
+
+func runGet(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	ts, err := slacktext.ParseTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	message, err := search.GetMessageByTimestamp(cmd.Context(), ts)
+	if err != nil {
+		return err
+	}
+	noteOpened(dbName, message)
+
+	if !getThread {
+		fmt.Print(searcher.FormatMessage(message))
+		return nil
+	}
+
+	messages, err := search.GetThread(cmd.Context(), message)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(searcher.FormatThread(messages))
+	return nil
+}
@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/dbcrypt"
 	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
 
 	"github.com/spf13/cobra"
 )
@@ -18,24 +26,152 @@ var ingestCmd = &cobra.Command{
 The channel directory should be a subdirectory within the source-data directory
 containing daily JSON message files (e.g., 2019-01-15.json).
 
+Exports that include private channels (groups.json), group DMs (mpims.json)
+or direct messages (dms.json) can be indexed too. Ingest asks for
+confirmation before indexing a private conversation; pass --include-private
+to skip the prompt. Direct message directories are named by conversation ID.
+
+Messages from bots, apps and integrations are skipped unless --include-bots
+is given. They are then attributed to their bot, named from the message's
+bot profile or the export's integration_logs.json, so GitHub notifications
+and CI alerts can be searched and filtered with from:@<bot name>.
+
+Pass --shared-meta to keep the export's users and channels in one
+workspace-meta.db shared by the workspace's databases rather than copying
+them into each; the channel database keeps only the users who posted in it.
+
+Pass --compress to store message text compressed with zstd. Only messages
+long enough to shrink are compressed. The search index keeps no copy of the
+text; it reads it decompressed through message_text(), so matching and
+snippets are unaffected and only cost the decompression. --compress=false
+converts the text back.
+
+Pass --keep-raw to keep the source JSON of each message in the database, so
+the raw command can show it after the export is gone.
+
+Pass --media-dir with a directory of the files shared in the channel, as
+downloaded with the export, to copy them into the workspace's media store
+and link them to the messages that shared them, for the media command and
+search --embed-media. Files are found by the Slack file ID in their path,
+such as F05ABCD1234/diagram.png or F05ABCD1234-diagram.png, or else by name.
+
+Pass --extract-files to extract the text of shared text files and PDFs that
+have a copy on disk, saved with the export or imported with --media-dir, and
+index it with the messages that shared them, so searches match the files'
+contents. Text already extracted by an earlier ingest is kept.
+
+Zulip stream exports and Matrix room exports can be indexed with --format.
+Put the export's JSON files in a directory under the source directory; Zulip
+topics and Matrix threads become threads.
+
+Any other message dump in NDJSON or CSV can be indexed with --format ndjson
+or --format csv and a field map, a YAML file naming the fields that hold
+each part of a message:
+
+  user: author.login
+  user_name: author.name
+  text: body
+  timestamp: created_at
+  id: id
+  thread: parent_id
+
 Example:
-  k8s-slack-searcher ingest sig-auth`,
-	Args: cobra.ExactArgs(1),
-	RunE: runIngest,
+  k8s-slack-searcher ingest sig-auth
+  k8s-slack-searcher ingest mpdm-alice--bob--carol-1 --include-private
+  k8s-slack-searcher ingest sig-release --include-bots
+  k8s-slack-searcher ingest sig-node --media-dir source-data/__uploads
+  k8s-slack-searcher ingest sig-node --media-dir source-data/__uploads --extract-files
+  k8s-slack-searcher ingest kubernetes-zulip --format zulip
+  k8s-slack-searcher ingest forum-posts --format ndjson --map forum-map.yaml`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeChannelDirs,
+	RunE:              runIngest,
 }
 
 var (
-	sourceDataDir string
+	sourceDataDir  string
+	includePrivate bool
+	encryptDB      bool
+	skipAlerts     bool
+	ingestFormat   string
+	ingestMap      string
+	includeBots    bool
+	keepRaw        bool
+	sharedMeta     bool
+	compressText   bool
+	mediaDir       string
+	extractFiles   bool
 )
 
 func init() {
 	ingestCmd.Flags().StringVarP(&sourceDataDir, "source", "s", "source-data", 
 		"Source data directory containing users.json, channels.json, and channel subdirectories")
+	ingestCmd.Flags().BoolVar(&includePrivate, "include-private", false,
+		"Index private channels and direct messages without asking for confirmation")
+	ingestCmd.Flags().BoolVar(&includeBots, "include-bots", false,
+		"Index messages from bots, apps and integrations (e.g. GitHub notifications and CI alerts), attributed to each bot")
+	ingestCmd.Flags().BoolVar(&keepRaw, "keep-raw", false,
+		"Keep the source JSON of each message in the database, for the raw command")
+	ingestCmd.Flags().BoolVar(&sharedMeta, "shared-meta", false,
+		"Keep users and channels in the workspace's shared "+storagepaths.MetaFile+" instead of in the channel database")
+	ingestCmd.Flags().BoolVar(&compressText, "compress", false,
+		"Store message text compressed with zstd (--compress=false stores it plain again); the search index reads it decompressed")
+	ingestCmd.Flags().BoolVar(&skipAlerts, "no-alerts", false,
+		"Don't check alerts against the new messages")
+	ingestCmd.Flags().StringVar(&mediaDir, "media-dir", "",
+		"Directory of the export's downloaded files to copy into the media store and link to their messages")
+	ingestCmd.Flags().BoolVar(&extractFiles, "extract-files", false,
+		"Extract the text of shared text files and PDFs and index it with their messages")
+	ingestCmd.Flags().StringVarP(&ingestFormat, "format", "f", indexer.FormatSlack,
+		"Export format ("+strings.Join(indexer.Formats, "|")+")")
+	ingestCmd.Flags().StringVar(&ingestMap, "map", "",
+		"YAML field map for the ndjson and csv formats")
+	ingestCmd.Flags().BoolVar(&encryptDB, "encrypt", false,
+		fmt.Sprintf("Encrypt the database at rest with the key in $%s or the file named by $%s", dbcrypt.KeyEnv, dbcrypt.KeyFileEnv))
+
+	ingestCmd.RegisterFlagCompletionFunc("format", completeValues(indexer.Formats...))
 }
 
 func runIngest(cmd *cobra.Command, args []string) error {
 	channelName := args[0]
 	
+	if err := indexer.ValidFormat(ingestFormat); err != nil {
+		return err
+	}
+	var fields *indexer.FieldMap
+	if indexer.NeedsFieldMap(ingestFormat) {
+		if ingestMap == "" {
+			return fmt.Errorf("--format %s needs a field map: pass --map", ingestFormat)
+		}
+		loaded, err := indexer.LoadFieldMap(ingestMap)
+		if err != nil {
+			return err
+		}
+		fields = loaded
+	} else if ingestMap != "" {
+		return fmt.Errorf("--map only applies to the ndjson and csv formats")
+	}
+	if sharedMeta && ingestFormat != indexer.FormatSlack {
+		return fmt.Errorf("--shared-meta only applies to Slack exports")
+	}
+	if sharedMeta && encryptDB {
+		return fmt.Errorf("--shared-meta can't be used with --encrypt, since %s is not encrypted", storagepaths.MetaFile)
+	}
+	if mediaDir != "" {
+		if ingestFormat != indexer.FormatSlack {
+			return fmt.Errorf("--media-dir only applies to Slack exports")
+		}
+		if encryptDB {
+			return fmt.Errorf("--media-dir can't be used with --encrypt, since the media store is not encrypted")
+		}
+		if info, err := os.Stat(mediaDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("media directory does not exist: %s", mediaDir)
+		}
+	}
+	if extractFiles && ingestFormat != indexer.FormatSlack {
+		return fmt.Errorf("--extract-files only applies to Slack exports")
+	}
+	
 	// Validate source directory exists
 	if _, err := os.Stat(sourceDataDir); os.IsNotExist(err) {
 		return fmt.Errorf("source directory does not exist: %s", sourceDataDir)
@@ -48,15 +184,10 @@ func runIngest(cmd *cobra.Command, args []string) error {
 	}
 	
 	// Check for required files
-	usersFile := filepath.Join(sourceDataDir, "users.json")
-	channelsFile := filepath.Join(sourceDataDir, "channels.json")
-	
-	if _, err := os.Stat(usersFile); os.IsNotExist(err) {
-		return fmt.Errorf("users.json not found in source directory: %s", usersFile)
-	}
-	
-	if _, err := os.Stat(channelsFile); os.IsNotExist(err) {
-		return fmt.Errorf("channels.json not found in source directory: %s", channelsFile)
+	if ingestFormat == indexer.FormatSlack {
+		if err := checkSlackExport(channelName); err != nil {
+			return err
+		}
 	}
 	
 	// Ensure databases directory exists
@@ -67,17 +198,182 @@ func runIngest(cmd *cobra.Command, args []string) error {
 	// Create and run indexer
 	fmt.Printf("Creating database for channel: %s\n", channelName)
 	
-	idx, err := indexer.NewIndexer(sourceDataDir, channelName)
+	dbName := qualify(channelName)
+	if encryptDB {
+		if err := encryptDatabase(dbName); err != nil {
+			return err
+		}
+	}
+	
+	opts := []indexer.Option{
+		indexer.WithFormat(ingestFormat),
+		indexer.WithFieldMap(fields),
+		indexer.WithBots(includeBots),
+		indexer.WithRawJSON(keepRaw),
+		indexer.WithWorkspaceMeta(sharedMeta),
+		indexer.WithLogger(log.New(os.Stdout, "", 0)),
+	}
+	// The database keeps its setting unless the flag is given
+	if cmd.Flags().Changed("compress") {
+		opts = append(opts, indexer.WithTextCompression(compressText))
+	}
+	idx, err := indexer.NewIndexer(sourceDataDir, dbName, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create indexer: %w", err)
 	}
 	defer idx.Close()
 	
-	if err := idx.IndexChannel(); err != nil {
+	// Alerts only look at messages stored from here on
+	lastID, err := idx.LastMessageID(cmd.Context())
+	if err != nil {
+		return err
+	}
+	
+	if err := idx.IndexChannel(cmd.Context()); err != nil {
+		if errors.Is(err, indexer.ErrInterrupted) {
+			return fmt.Errorf("%w; completed files were saved, run the same command again to resume", err)
+		}
 		return fmt.Errorf("failed to index channel: %w", err)
 	}
 	
-	fmt.Printf("\nDatabase created successfully: databases/%s.db\n", channelName)
+	idx.Metrics().Print(os.Stdout)
+	if len(idx.Metrics().Skipped) > 0 {
+		fmt.Printf("Run 'k8s-slack-searcher skipped %s' for examples of the skipped messages\n", dbName)
+	}
+	
+	if mediaDir != "" {
+		store := storagepaths.MediaPath(dbName)
+		cataloged, linked, err := idx.CatalogMedia(cmd.Context(), mediaDir, store)
+		if err != nil {
+			return fmt.Errorf("failed to catalog media: %w", err)
+		}
+		fmt.Printf("\nMedia: %d file(s) from %s stored in %s, %d linked to messages\n", cataloged, mediaDir, store, linked)
+	}
+	
+	if extractFiles {
+		extracted, failures, err := idx.ExtractFiles(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to extract file contents: %w", err)
+		}
+		for _, failure := range failures {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", failure)
+		}
+		fmt.Printf("\nFile contents: text extracted from %d file(s) and indexed\n", extracted)
+	}
+	
+	path := storagepaths.DatabasePath(dbName)
+	if storagepaths.IsEncrypted(dbName) {
+		path = storagepaths.EncryptedPath(dbName)
+	}
+	fmt.Printf("\nDatabase created successfully: %s\n", path)
+	
+	if !skipAlerts {
+		// Close first so an encrypted database is written back before the
+		// alerts open it
+		idx.Close()
+		runAlerts(cmd.Context(), dbName, lastID)
+	}
 	
 	return nil
+}
+
+// checkSlackExport checks that the source directory holds a Slack export,
+// and asks before indexing a private conversation from it
+func checkSlackExport(channelName string) error {
+	usersFile := filepath.Join(sourceDataDir, "users.json")
+	if _, err := os.Stat(usersFile); os.IsNotExist(err) {
+		return fmt.Errorf("users.json not found in source directory: %s", usersFile)
+	}
+
+	// Private conversations are only indexed with explicit consent
+	kind, err := indexer.ConversationKind(sourceDataDir, channelName)
+	if err != nil {
+		return err
+	}
+	if kind != models.KindChannel && !includePrivate {
+		ok, err := confirm(fmt.Sprintf("%s is a %s. Index it?", channelName, conversationLabel(kind)))
+		if err != nil {
+			return fmt.Errorf("%w; pass --include-private to index private conversations", err)
+		}
+		if !ok {
+			return fmt.Errorf("not indexing private conversation %s", channelName)
+		}
+	}
+
+	return nil
+}
+
+// encryptDatabase makes sure a database is stored encrypted before ingest
+// or merge writes to it: an existing plaintext database is encrypted in place, and
+// a new one starts as an encrypted empty file
+func encryptDatabase(dbName string) error {
+	key, err := dbcrypt.LoadKey()
+	if err != nil {
+		return err
+	}
+
+	path := storagepaths.DatabasePath(dbName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		// Encrypting over an existing encrypted copy would lose it
+		if storagepaths.IsEncrypted(dbName) {
+			return fmt.Errorf("both %s and %s exist; remove the one that is out of date", path, storagepaths.EncryptedPath(dbName))
+		}
+		if err := database.Checkpoint(path); err != nil {
+			return err
+		}
+		if err := dbcrypt.EncryptFile(path, storagepaths.EncryptedPath(dbName), key); err != nil {
+			return fmt.Errorf("failed to encrypt database: %w", err)
+		}
+		for _, leftover := range []string{path, path + "-wal", path + "-shm"} {
+			if err := os.Remove(leftover); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove unencrypted database: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if storagepaths.IsEncrypted(dbName) {
+		return nil
+	}
+	if err := dbcrypt.CreateEmpty(storagepaths.EncryptedPath(dbName), key); err != nil {
+		return fmt.Errorf("failed to create encrypted database: %w", err)
+	}
+
+	return nil
+}
+
+// conversationLabel describes a conversation kind for display
+func conversationLabel(kind string) string {
+	switch kind {
+	case models.KindGroup:
+		return "private channel"
+	case models.KindMPIM:
+		return "group direct message"
+	case models.KindDM:
+		return "direct message"
+	default:
+		return "public channel"
+	}
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no. It fails
+// when stdin is not a terminal rather than guessing.
+func confirm(question string) (bool, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("confirmation required but stdin is not a terminal")
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
 }
\ No newline at end of file
@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var ingestAllCmd = &cobra.Command{
+	Use:   "ingest-all [channel-directory...]",
+	Short: "Index several channel directories in parallel",
+	Long: `Index every channel directory in the source directory, or the ones named,
+each into its own database, several at a time.
+
+users.json and the conversation metadata files are parsed once and shared
+by every channel. While the ingest runs, a dashboard shows each channel's
+progress; when output is not a terminal a line is printed as each channel
+finishes instead. --max-parallel sets how many channels are indexed at
+once.
+
+Private conversations are skipped unless --include-private is given, since
+there is no one to ask about each. As with ingest, Ctrl-C finishes the
+files being indexed and running the same command again resumes.
+
+Examples:
+  k8s-slack-searcher ingest-all
+  k8s-slack-searcher ingest-all sig-auth sig-node sig-release --max-parallel 2
+  k8s-slack-searcher ingest-all --source /exports/kubernetes --include-bots`,
+	ValidArgsFunction: completeChannelDirs,
+	RunE:              runIngestAll,
+}
+
+var (
+	ingestAllSource     string
+	ingestAllParallel   int
+	ingestAllPrivate    bool
+	ingestAllBots       bool
+	ingestAllKeepRaw    bool
+	ingestAllSkipAlerts bool
+	ingestAllSharedMeta bool
+)
+
+func init() {
+	ingestAllCmd.Flags().StringVarP(&ingestAllSource, "source", "s", "source-data",
+		"Source data directory containing users.json, channels.json, and channel subdirectories")
+	ingestAllCmd.Flags().IntVarP(&ingestAllParallel, "max-parallel", "p", 4,
+		"Maximum number of channels to index at once")
+	ingestAllCmd.Flags().BoolVar(&ingestAllPrivate, "include-private", false,
+		"Index private channels and direct messages too")
+	ingestAllCmd.Flags().BoolVar(&ingestAllBots, "include-bots", false,
+		"Index messages from bots, apps and integrations, attributed to each bot")
+	ingestAllCmd.Flags().BoolVar(&ingestAllKeepRaw, "keep-raw", false,
+		"Keep the source JSON of each message in the database, for the raw command")
+	ingestAllCmd.Flags().BoolVar(&ingestAllSharedMeta, "shared-meta", false,
+		"Keep users and channels in the workspace's shared "+storagepaths.MetaFile+" instead of in each channel database")
+	ingestAllCmd.Flags().BoolVar(&ingestAllSkipAlerts, "no-alerts", false,
+		"Don't check alerts against the new messages")
+}
+
+// channelIngest is the outcome of indexing one channel
+type channelIngest struct {
+	channel  string
+	dbName   string
+	lastID   int
+	inserted int
+	err      error
+}
+
+func runIngestAll(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	if ingestAllParallel < 1 {
+		return fmt.Errorf("--max-parallel must be at least 1")
+	}
+	if _, err := os.Stat(ingestAllSource); os.IsNotExist(err) {
+		return fmt.Errorf("source directory does not exist: %s", ingestAllSource)
+	}
+
+	channels := args
+	if len(channels) == 0 {
+		var err error
+		if channels, err = indexer.ChannelDirs(ingestAllSource); err != nil {
+			return err
+		}
+	}
+
+	metadata, err := indexer.LoadSourceMetadata(ingestAllSource, ingestAllBots)
+	if err != nil {
+		return err
+	}
+
+	// Private conversations are only indexed with explicit consent
+	var selected []string
+	for _, channel := range channels {
+		channelDir := filepath.Join(ingestAllSource, channel)
+		if _, err := os.Stat(channelDir); os.IsNotExist(err) {
+			return fmt.Errorf("channel directory does not exist: %s", channelDir)
+		}
+		kind := metadata.ConversationKind(channel)
+		if kind != models.KindChannel && !ingestAllPrivate {
+			fmt.Printf("Skipping %s %s; pass --include-private to index it\n", conversationLabel(kind), channel)
+			continue
+		}
+		selected = append(selected, channel)
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no channels to index in %s", ingestAllSource)
+	}
+
+	if err := os.MkdirAll("databases", 0755); err != nil {
+		return fmt.Errorf("failed to create databases directory: %w", err)
+	}
+
+	// Every channel is in the same workspace, so its directory is stored
+	// once rather than by each parallel ingest
+	if ingestAllSharedMeta {
+		if err := indexer.StoreWorkspaceMeta("", qualify(selected[0]), metadata); err != nil {
+			return err
+		}
+	}
+
+	parallel := min(ingestAllParallel, len(selected))
+	fmt.Printf("Indexing %d channel(s), %d at a time (%d users)\n", len(selected), parallel, metadata.Users())
+
+	dashboard := newIngestDashboard(os.Stdout, selected, isTerminal(os.Stdout))
+	stop := dashboard.start()
+
+	results := make([]channelIngest, len(selected))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, channel := range selected {
+		wg.Add(1)
+		go func(i int, channel string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = ingestChannel(ctx, channel, metadata, dashboard)
+		}(i, channel)
+	}
+	wg.Wait()
+	stop()
+
+	var failed []string
+	interrupted := false
+	for _, result := range results {
+		switch {
+		case errors.Is(result.err, indexer.ErrInterrupted):
+			interrupted = true
+		case result.err != nil:
+			failed = append(failed, result.channel)
+		}
+	}
+	dashboard.printFailures()
+
+	if !ingestAllSkipAlerts {
+		for _, result := range results {
+			if result.err == nil && result.inserted > 0 {
+				runAlerts(ctx, result.dbName, result.lastID)
+			}
+		}
+	}
+
+	if interrupted {
+		return fmt.Errorf("%w; completed files were saved, run the same command again to resume", indexer.ErrInterrupted)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to index %d of %d channel(s): %s", len(failed), len(selected), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ingestChannel indexes one channel into its own database, reporting
+// progress to the dashboard
+func ingestChannel(ctx context.Context, channel string, metadata *indexer.SourceMetadata, dashboard *ingestDashboard) channelIngest {
+	result := channelIngest{channel: channel, dbName: qualify(channel)}
+	dashboard.begin(channel)
+	defer func() { dashboard.finish(channel, result.err) }()
+
+	idx, err := indexer.NewIndexer(ingestAllSource, result.dbName,
+		indexer.WithSourceMetadata(metadata),
+		indexer.WithBots(ingestAllBots),
+		indexer.WithRawJSON(ingestAllKeepRaw),
+		indexer.WithWorkspaceMeta(ingestAllSharedMeta))
+	if err != nil {
+		result.err = fmt.Errorf("failed to create indexer: %w", err)
+		return result
+	}
+	defer idx.Close()
+	idx.SetProgressFunc(func(event indexer.ProgressEvent) {
+		dashboard.update(channel, event)
+	})
+
+	if result.lastID, err = idx.LastMessageID(ctx); err != nil {
+		result.err = err
+		return result
+	}
+	result.err = idx.IndexChannel(ctx)
+	result.inserted = idx.Metrics().Inserted
+	return result
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ingestStatus is a channel's line on the ingest dashboard
+type ingestStatus struct {
+	state    string
+	done     int
+	total    int
+	messages int
+	started  time.Time
+	elapsed  time.Duration
+	failures []string
+	err      error
+}
+
+// ingestDashboard shows the progress of channels being indexed in
+// parallel. On a terminal it redraws a line per channel and a total line in
+// place; otherwise it prints a line as each channel finishes.
+type ingestDashboard struct {
+	mu       sync.Mutex
+	w        io.Writer
+	live     bool
+	channels []string
+	status   map[string]*ingestStatus
+	width    int
+	drawn    int
+}
+
+func newIngestDashboard(w io.Writer, channels []string, live bool) *ingestDashboard {
+	d := &ingestDashboard{w: w, live: live, channels: channels, status: make(map[string]*ingestStatus)}
+	for _, channel := range channels {
+		d.status[channel] = &ingestStatus{state: "waiting"}
+		if len(channel) > d.width {
+			d.width = len(channel)
+		}
+	}
+	return d
+}
+
+// start redraws the dashboard until the returned function is called, which
+// draws it a final time
+func (d *ingestDashboard) start() func() {
+	if !d.live {
+		return func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			fmt.Fprintln(d.w, d.totals())
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			d.draw()
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		d.draw()
+	}
+}
+
+func (d *ingestDashboard) begin(channel string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := d.status[channel]
+	status.state = "indexing"
+	status.started = time.Now()
+}
+
+func (d *ingestDashboard) update(channel string, event indexer.ProgressEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := d.status[channel]
+	status.done, status.total, status.messages = event.FilesDone, event.FilesTotal, event.Messages
+	if event.Type == indexer.EventFileFailed {
+		status.failures = append(status.failures, fmt.Sprintf("%s: %v", event.File, event.Err))
+	}
+}
+
+func (d *ingestDashboard) finish(channel string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := d.status[channel]
+	status.elapsed = time.Since(status.started)
+	status.err = err
+	switch {
+	case errors.Is(err, indexer.ErrInterrupted):
+		status.state = "stopped"
+	case err != nil:
+		status.state = "failed"
+	default:
+		status.state = "done"
+	}
+	if !d.live {
+		fmt.Fprintln(d.w, d.line(channel))
+	}
+}
+
+// draw rewrites the dashboard over its previous drawing
+func (d *ingestDashboard) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", d.drawn)
+	}
+	for _, channel := range d.channels {
+		b.WriteString("\r\033[K" + d.line(channel) + "\n")
+	}
+	b.WriteString("\r\033[K" + d.totals() + "\n")
+	d.drawn = len(d.channels) + 1
+	io.WriteString(d.w, b.String())
+}
+
+// line formats a channel's progress. Called with mu held.
+func (d *ingestDashboard) line(channel string) string {
+	status := d.status[channel]
+	line := fmt.Sprintf("%-*s  %-8s  %s %d/%d files  %d messages",
+		d.width, channel, status.state, progressBar(status.done, status.total), status.done, status.total, status.messages)
+	if status.state == "done" || status.state == "failed" || status.state == "stopped" {
+		line += fmt.Sprintf("  (%s)", status.elapsed.Round(100*time.Millisecond))
+	}
+	if len(status.failures) > 0 {
+		line += fmt.Sprintf("  %d file(s) failed", len(status.failures))
+	}
+	if status.err != nil && status.state == "failed" {
+		line += fmt.Sprintf("  %v", status.err)
+	}
+	return line
+}
+
+// totals formats the progress of the whole ingest. Called with mu held.
+func (d *ingestDashboard) totals() string {
+	finished, done, total, messages := 0, 0, 0, 0
+	for _, status := range d.status {
+		if status.state != "waiting" && status.state != "indexing" {
+			finished++
+		}
+		done += status.done
+		total += status.total
+		messages += status.messages
+	}
+	return fmt.Sprintf("Total: %d/%d channels, %d/%d files, %d messages", finished, len(d.channels), done, total, messages)
+}
+
+// printFailures lists the message files that could not be indexed
+func (d *ingestDashboard) printFailures() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, channel := range d.channels {
+		for _, failure := range d.status[channel].failures {
+			fmt.Fprintf(d.w, "Warning: %s: failed to process %s\n", channel, failure)
+		}
+	}
+}
+
+// progressBar draws a fixed-width bar for done out of total
+func progressBar(done, total int) string {
+	const width = 20
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var mediaCmd = &cobra.Command{
+	Use:   "media <database>",
+	Short: "List the files imported into the media store",
+	Long: `List the files imported with ingest --media-dir: each file's name, type
+and size, the message that shared it and where its copy is kept in the
+media store.
+
+The store keeps each file once, named by the SHA-256 of its contents, in a
+.media directory beside the workspace's databases. Files linked to a
+message are shown and embedded by search --embed-media from the store.
+
+Examples:
+  k8s-slack-searcher ingest sig-node --media-dir source-data/__uploads
+  k8s-slack-searcher media sig-node
+  k8s-slack-searcher media sig-node --unlinked`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runMedia,
+}
+
+var (
+	mediaUnlinked bool
+	mediaJSON     bool
+)
+
+func init() {
+	mediaCmd.Flags().BoolVar(&mediaUnlinked, "unlinked", false,
+		"Only list files not linked to an indexed message")
+	mediaCmd.Flags().BoolVar(&mediaJSON, "json", false,
+		"Output the catalog as JSON")
+}
+
+func runMedia(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	files, err := search.GetMediaFiles(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if mediaUnlinked {
+		var unlinked []models.MediaFile
+		for _, file := range files {
+			if file.MessageTS == "" {
+				unlinked = append(unlinked, file)
+			}
+		}
+		files = unlinked
+	}
+
+	if mediaJSON {
+		if files == nil {
+			files = []models.MediaFile{}
+		}
+		return printJSON(files)
+	}
+
+	if len(files) == 0 {
+		fmt.Printf("No media files in %s\n", dbName)
+		return nil
+	}
+
+	fmt.Printf("Media files in %s (%d):\n\n", dbName, len(files))
+	for _, file := range files {
+		message := file.MessageTS
+		if message == "" {
+			message = "not linked"
+		}
+		fmt.Printf("  %s\n", file.Name)
+		fmt.Printf("    Type: %s, %s\n", file.Mimetype, searcher.FormatSize(file.Size))
+		fmt.Printf("    Message: %s\n", message)
+		fmt.Printf("    Stored: %s\n", file.StoredPath)
+	}
+	return nil
+}
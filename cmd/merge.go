@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <dst> <src>...",
+	Short: "Combine several databases into one",
+	Long: `Copy the users, channels and messages of one or more source databases
+into a destination database, creating it if it does not exist, and rebuild
+its search index. Messages are de-duplicated by their Slack timestamp, so
+two ingests of the same channel from overlapping exports can be combined,
+as can the databases of several channels.
+
+Sources can be given by name or as the path to a .db file. Their messages
+are not changed, but a source written by an older version is brought up
+to the current schema first, as any other command would. When a source
+is encrypted, the destination is encrypted too, with the same key; an
+existing unencrypted destination is encrypted in place.
+
+Examples:
+  k8s-slack-searcher merge sig-auth 2024-05/sig-auth 2024-06/sig-auth
+  k8s-slack-searcher merge sig-security sig-auth sig-security-tooling
+  k8s-slack-searcher merge sig-auth backups/sig-auth.db`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeDatabases,
+	RunE:              runMerge,
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	dstName := qualify(args[0])
+
+	var srcPaths []string
+	encrypted := ""
+	for _, arg := range args[1:] {
+		path, err := databaseArgPath(arg)
+		if err != nil {
+			return err
+		}
+		srcPaths = append(srcPaths, path)
+		if !strings.HasSuffix(arg, ".db") && storagepaths.IsEncrypted(qualify(arg)) {
+			encrypted = qualify(arg)
+		}
+	}
+
+	// Messages from an encrypted database stay encrypted at rest
+	if encrypted != "" && !storagepaths.IsEncrypted(dstName) {
+		fmt.Printf("Encrypting %s, since %s is encrypted\n", dstName, encrypted)
+		if err := encryptDatabase(dstName); err != nil {
+			return err
+		}
+	}
+
+	added, err := indexer.Merge(cmd.Context(), dstName, srcPaths,
+		indexer.WithLogger(log.New(os.Stdout, "", 0)))
+	if err != nil {
+		return fmt.Errorf("merge failed: %w", err)
+	}
+
+	total := 0
+	for _, count := range added {
+		total += count
+	}
+	fmt.Printf("Added %d message(s) to %s\n", total, dstName)
+
+	return nil
+}
+
+// databaseArgPath resolves a database given by name, or by path when the
+// argument names a .db file, to its file path
+func databaseArgPath(arg string) (string, error) {
+	if strings.HasSuffix(arg, ".db") {
+		info, err := os.Stat(arg)
+		if err != nil || !info.Mode().IsRegular() {
+			return "", fmt.Errorf("database file not found: %s", arg)
+		}
+		return arg, nil
+	}
+
+	name := qualify(arg)
+	if !searcher.ValidateDatabaseExists(name) {
+		return "", fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", name)
+	}
+
+	return storagepaths.DatabasePath(name), nil
+}
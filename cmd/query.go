@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <database> <sql>",
+	Short: "Run a read-only SQL query against a database",
+	Long: `Run an ad-hoc SQL query against a channel database and print the
+rows as a table, CSV or JSON, without needing the sqlite3 tool.
+
+Only a single SELECT, WITH, VALUES or EXPLAIN statement is accepted (a
+WITH must lead to a SELECT), and it runs on a read-only connection, so the
+database cannot be changed.
+The messages, users, channels and tags tables are the most useful; the
+messages_fts table supports full-text MATCH queries.
+
+Examples:
+  k8s-slack-searcher query sig-auth "SELECT name, real_name FROM users LIMIT 5"
+  k8s-slack-searcher query sig-auth "SELECT strftime('%Y', date) AS year, COUNT(*) FROM messages GROUP BY year"
+  k8s-slack-searcher query sig-auth "SELECT rowid, text FROM messages_fts WHERE messages_fts MATCH 'rbac'" -f csv
+  k8s-slack-searcher query sig-auth "SELECT * FROM tags" -f json`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runQuery,
+}
+
+// Query output formats
+const (
+	queryFormatTable = "table"
+	queryFormatCSV   = "csv"
+	queryFormatJSON  = "json"
+)
+
+var queryFormats = []string{queryFormatTable, queryFormatCSV, queryFormatJSON}
+
+var (
+	queryFormat string
+	queryLimit  int
+)
+
+// queryCellWidth bounds the width of table cells; longer values are cut
+const queryCellWidth = 60
+
+// errQueryLimit stops a query once --limit rows have been printed
+var errQueryLimit = errors.New("row limit reached")
+
+func init() {
+	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", queryFormatTable,
+		fmt.Sprintf("Output format (%s)", strings.Join(queryFormats, "|")))
+	queryCmd.Flags().IntVarP(&queryLimit, "limit", "l", 1000,
+		"Maximum number of rows to print (0 for no limit)")
+
+	queryCmd.RegisterFlagCompletionFunc("format", completeValues(queryFormats...))
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	if queryFormat != queryFormatTable && queryFormat != queryFormatCSV && queryFormat != queryFormatJSON {
+		return fmt.Errorf("invalid --format %q (supported: %s)", queryFormat, strings.Join(queryFormats, ", "))
+	}
+	if queryLimit < 0 {
+		return fmt.Errorf("--limit must be zero (no limit) or positive")
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	// CSV is written as rows arrive; tables need every row to size their
+	// columns and JSON is written as one array
+	var columns []string
+	var rows [][]interface{}
+	writer := csv.NewWriter(out)
+	count := 0
+	names, err := search.ReadOnlyQuery(cmd.Context(), args[1], func(names []string, values []interface{}) error {
+		if queryLimit > 0 && count == queryLimit {
+			return errQueryLimit
+		}
+		count++
+
+		if queryFormat != queryFormatCSV {
+			columns = names
+			rows = append(rows, values)
+			return nil
+		}
+		if columns == nil {
+			columns = names
+			if err := writer.Write(columns); err != nil {
+				return err
+			}
+		}
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = formatQueryValue(value)
+		}
+		return writer.Write(record)
+	})
+	truncated := errors.Is(err, errQueryLimit)
+	if err != nil && !truncated {
+		return err
+	}
+
+	switch queryFormat {
+	case queryFormatCSV:
+		// A query without rows still gets a header
+		if columns == nil && names != nil {
+			writer.Write(names)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	case queryFormatJSON:
+		if err := writeQueryJSON(out, columns, rows); err != nil {
+			return err
+		}
+	default:
+		writeQueryTable(out, columns, rows)
+	}
+
+	if truncated {
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "Showing the first %d rows; use --limit to see more\n", queryLimit)
+	}
+	return nil
+}
+
+// formatQueryValue renders a column value as text
+func formatQueryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeQueryTable prints rows as an aligned text table, cutting long
+// values and flattening line breaks
+func writeQueryTable(out *bufio.Writer, columns []string, rows [][]interface{}) {
+	if len(rows) == 0 {
+		fmt.Fprintln(out, "No rows")
+		return
+	}
+
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, len(row))
+		for i, value := range row {
+			text := strings.Join(strings.Fields(formatQueryValue(value)), " ")
+			if utf8.RuneCountInString(text) > queryCellWidth {
+				text = string([]rune(text)[:queryCellWidth-3]) + "..."
+			}
+			cells[r][i] = text
+			if n := utf8.RuneCountInString(text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	printRow := func(values []string) {
+		for i, value := range values {
+			if i > 0 {
+				out.WriteString("  ")
+			}
+			if i == len(values)-1 {
+				out.WriteString(value)
+			} else {
+				out.WriteString(value + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)))
+			}
+		}
+		out.WriteString("\n")
+	}
+
+	printRow(columns)
+	rule := make([]string, len(columns))
+	for i, width := range widths {
+		rule[i] = strings.Repeat("-", width)
+	}
+	printRow(rule)
+	for _, row := range cells {
+		printRow(row)
+	}
+	fmt.Fprintf(out, "\n%d row(s)\n", len(rows))
+}
+
+// writeQueryJSON prints rows as a JSON array of objects keyed by column
+func writeQueryJSON(out *bufio.Writer, columns []string, rows [][]interface{}) error {
+	objects := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		object := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			value := row[i]
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			object[column] = value
+		}
+		objects = append(objects, object)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(objects)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+)
+
+var rawCmd = &cobra.Command{
+	Use:   "raw <database> <ts>",
+	Short: "Show the source JSON of a message",
+	Long: `Show the original JSON a message was ingested from, for debugging parsing
+issues and reading fields the database doesn't store.
+
+The timestamp can be given in any form get accepts. The JSON kept at ingest
+with --keep-raw is shown when there is any; otherwise the message is looked
+up in its daily file under the source directory, which must still hold the
+export it was ingested from.
+
+Examples:
+  k8s-slack-searcher raw sig-auth 1684141200.000100
+  k8s-slack-searcher raw sig-auth p1684141200000100 --source /exports/kubernetes`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runRaw,
+}
+
+var rawSource string
+
+func init() {
+	rawCmd.Flags().StringVarP(&rawSource, "source", "s", "source-data",
+		"Source data directory to read the message from when its JSON was not kept at ingest")
+}
+
+func runRaw(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	ts, err := slacktext.ParseTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	raw, kept, err := search.GetRawMessage(cmd.Context(), ts)
+	if err != nil {
+		return err
+	}
+	if !kept {
+		message, err := search.GetMessageByTimestamp(cmd.Context(), ts)
+		if err != nil {
+			return err
+		}
+		_, channel := storagepaths.SplitName(dbName)
+		found, err := indexer.FindRawMessage(filepath.Join(rawSource, channel, message.Filename), ts)
+		if err != nil {
+			return fmt.Errorf("%w; ingest with --keep-raw to keep the source JSON in the database", err)
+		}
+		raw = string(found)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(raw), "", "  "); err != nil {
+		return fmt.Errorf("failed to format message JSON: %w", err)
+	}
+	fmt.Println(indented.String())
+	return nil
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/browser"
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <result#>",
+	Short: "Show a result from the last search in full",
+	Long: `Show the full text and details of a message from the last search.
+
+Results are numbered as printed by the search command.
+
+Examples:
+  k8s-slack-searcher search "token rotation" -d sig-auth
+  k8s-slack-searcher show 3`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	RunE:              runShow,
+}
+
+var threadCmd = &cobra.Command{
+	Use:   "thread <result#>",
+	Short: "Show the thread a result from the last search belongs to",
+	Long: `Show every message in the thread containing a result from the last search.
+
+Examples:
+  k8s-slack-searcher search "token rotation" -d sig-auth
+  k8s-slack-searcher thread 3`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	RunE:              runThread,
+}
+
+var openCmd = &cobra.Command{
+	Use:   "open <result#>",
+	Short: "Open a result from the last search in Slack",
+	Long: `Open the Slack permalink for a message from the last search in the
+default browser. Use --print to only print the link.
+
+Examples:
+  k8s-slack-searcher search "token rotation" -d sig-auth
+  k8s-slack-searcher open 3`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	RunE:              runOpen,
+}
+
+var (
+	openWorkspaceURL string
+	openPrint        bool
+)
+
+func init() {
+	openCmd.Flags().StringVar(&openWorkspaceURL, "workspace-url", slacktext.DefaultWorkspaceURL,
+		"Slack workspace URL used to build permalinks")
+	openCmd.Flags().BoolVar(&openPrint, "print", false,
+		"Print the permalink instead of opening it")
+}
+
+// loadResult opens the database of the last search and loads the message
+// for a result number given on the command line
+func loadResult(cmd *cobra.Command, arg string) (*searcher.Searcher, *models.Message, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid result number: %s", arg)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbName, ref, err := state.Result(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return nil, nil, fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create searcher: %w", err)
+	}
+
+	message, err := search.GetMessage(cmd.Context(), ref.ID)
+	if err != nil {
+		search.Close()
+		return nil, nil, err
+	}
+
+	// IDs change when a database is rebuilt, so check we found the same message
+	if message.Timestamp != ref.Timestamp {
+		search.Close()
+		return nil, nil, fmt.Errorf("result %d no longer matches the database %s: run the search again", n, dbName)
+	}
+	noteOpened(dbName, message)
+
+	return search, message, nil
+}
+
+// saveResults records search results so later commands can refer to them
+// by number
+func saveResults(dbName, query string, results []*models.SearchResult) error {
+	state, err := config.LoadState()
+	if err != nil {
+		return err
+	}
+
+	set := &config.ResultSet{Database: dbName, Query: query}
+	for _, result := range results {
+		set.Results = append(set.Results, config.ResultRef{ID: result.ID, Timestamp: result.Timestamp, Database: result.Database})
+	}
+	state.LastResults = set
+
+	return state.Save()
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	search, message, err := loadResult(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	fmt.Print(searcher.FormatMessage(message))
+	return nil
+}
+
+func runThread(cmd *cobra.Command, args []string) error {
+	search, message, err := loadResult(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	messages, err := search.GetThread(cmd.Context(), message)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(searcher.FormatThread(messages))
+	return nil
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	search, message, err := loadResult(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	info, err := search.GetChannelInfo(cmd.Context(), search.ChannelName())
+	if err != nil {
+		return err
+	}
+
+	link := slacktext.Permalink(openWorkspaceURL, info.ID, message.Timestamp, message.ThreadTS)
+	if link == "" {
+		return fmt.Errorf("cannot build a permalink: the channel ID or message timestamp is unknown")
+	}
+
+	fmt.Println(link)
+	if openPrint {
+		return nil
+	}
+
+	return browser.Open(link)
+}
@@ -1,27 +1,74 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/raesene/k8s-slack-searcher/pkg/browser"
+	"github.com/raesene/k8s-slack-searcher/pkg/exporter"
+	"github.com/raesene/k8s-slack-searcher/pkg/fingerprint"
+	"github.com/raesene/k8s-slack-searcher/pkg/identifiers"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/query"
 	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
 
 	"github.com/spf13/cobra"
 )
 
 var searchCmd = &cobra.Command{
-	Use:   "search <query>",
-	Short: "Search messages in a channel database",
+	Use:     "search <query>",
+	Aliases: []string{"s"},
+	Short:   "Search messages in a channel database",
 	Long: `Search for messages in a channel database using full-text search.
 	
-The search supports SQLite FTS5 syntax including quoted phrases, 
+The search supports SQLite FTS4 syntax including quoted phrases, 
 boolean operators (AND, OR, NOT), and prefix matching.
 
+Slack-style filters can be mixed into the query:
+  from:@user          messages by a user (name, display name or ID)
+  in:#channel         search this database instead of --database (repeatable)
+  after:YYYY-MM-DD    messages after this day
+  before:YYYY-MM-DD   messages before this day
+  on:YYYY-MM-DD       messages on this day
+  during:YYYY-MM      messages in this month (or YYYY, or YYYY-MM-DD)
+  has:link|code|file|reaction|logs
+                      messages containing a link, code, a file, reactions
+                      or a pasted log or stack trace (also available as --has)
+  tag:name            messages tagged with the tag command, or in a tagged
+                      thread (also available as --tag)
+
 Examples:
   k8s-slack-searcher search "authentication" --database sig-auth
   k8s-slack-searcher search "cert* AND rotate*" --database sig-auth
-  k8s-slack-searcher search "RBAC OR authentication" --database sig-auth`,
-	Args: cobra.ExactArgs(1),
-	RunE: runSearch,
+  k8s-slack-searcher search "RBAC OR authentication" --database sig-auth
+  k8s-slack-searcher search "certificate" --exclude kubelet --database sig-auth
+  k8s-slack-searcher search --near "kubelet certificate" --distance 5 --database sig-node
+  k8s-slack-searcher search 'from:@liggitt in:#sig-auth after:2023-01-01 has:link "bound tokens"'
+  k8s-slack-searcher search "kubelet crash" --scope thread-starters --database sig-node
+  k8s-slack-searcher search "token" --tag needs-doc --database sig-auth
+  k8s-slack-searcher search "seccomp" --count --database sig-node
+  k8s-slack-searcher search 'from:@liggitt "bound tokens"' --explain --database sig-auth
+  k8s-slack-searcher search --error-like "$(kubectl logs mypod 2>&1 | tail -5)" --database sig-node
+  k8s-slack-searcher search "kubelet" --sample 20 --database sig-node
+  k8s-slack-searcher search "token rotation" --export-threads threads/ --database sig-auth
+  k8s-slack-searcher search --queries-file topics.txt --report topics.csv --database sig-auth
+
+When --database is omitted, the database selected with 'use' (or the
+default_database from the config file) is searched:
+  k8s-slack-searcher use sig-auth
+  k8s-slack-searcher s "authentication"`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeSearchTerms,
+	RunE:              runSearch,
 }
 
 var listCmd = &cobra.Command{
@@ -32,67 +79,955 @@ var listCmd = &cobra.Command{
 }
 
 var (
-	databaseName string
-	searchLimit  int
-	showStats    bool
+	databaseName  string
+	searchLimit   int
+	showStats     bool
+	htmlOutput    string
+	htmlTemplate  string
+	htmlTheme     string
+	pdfOutput     string
+	openReport    bool
+	allWorkspaces bool
+	excludeTerms  []string
+	hasContent    []string
+	hasLogs       bool
+	searchTags    []string
+	searchVersion string
+	errorLike     string
+	searchScope   string
+	searchSort    string
+	countOnly     bool
+	queriesFile   string
+	batchReport   string
+	sampleSize    int
+	threadsDir    string
+	threadsFormat string
+	nearTerms     string
+	nearDistance  int
+	searchTimeout time.Duration
+	searchFormat  string
+	explainOnly   bool
+	searchTrace   bool
+	searchColor   string
+	snippetCount  int
+	searchSaveAs  string
+	embedMedia    bool
+)
+
+// Values of search --color
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
 )
 
 func init() {
 	searchCmd.Flags().StringVarP(&databaseName, "database", "d", "", 
-		"Database name (channel name) to search in (required)")
+		"Database name (channel name) to search in (defaults to the active database)")
 	searchCmd.Flags().IntVarP(&searchLimit, "limit", "l", 10, 
-		"Maximum number of results to return")
+		"Maximum number of results to return (0 for every match, streamed as found)")
+	searchCmd.Flags().StringVar(&searchFormat, "format", searcher.StreamFormatText, 
+		fmt.Sprintf("Output format (%s); ndjson writes one JSON result per line as found", strings.Join(searcher.StreamFormats, "|")))
+	searchCmd.Flags().IntVar(&snippetCount, "snippets", 1, 
+		"Show up to this many snippets of messages matching in several places, joined with ...")
+	searchCmd.Flags().StringVar(&searchColor, "color", colorAuto, 
+		fmt.Sprintf("Color each search term's matches in text output (%s|%s|%s); auto colors a terminal unless NO_COLOR is set", colorAuto, colorAlways, colorNever))
 	searchCmd.Flags().BoolVar(&showStats, "stats", false, 
 		"Show database statistics")
+	searchCmd.Flags().StringVar(&htmlOutput, "html", "", 
+		"Write results to an HTML report file")
+	searchCmd.Flags().StringVar(&htmlTemplate, "template", "", 
+		"Custom HTML template file to use for the report")
+	searchCmd.Flags().StringVar(&htmlTheme, "theme", searcher.DefaultTheme, 
+		fmt.Sprintf("Built-in HTML report theme (%s)", strings.Join(searcher.Themes(), "|")))
+	searchCmd.Flags().BoolVar(&embedMedia, "embed-media", false, 
+		"Show the images and files shared in results in the HTML report, embedding local copies of images")
+	searchCmd.Flags().StringVar(&pdfOutput, "pdf", "", 
+		"Write results to a PDF report file")
+	searchCmd.Flags().BoolVar(&openReport, "open", false, 
+		"Open the HTML report in the default browser (uses a temporary file unless --html is given)")
+	
+	searchCmd.Flags().StringSliceVar(&excludeTerms, "exclude", nil, 
+		"Exclude messages containing this term (repeatable)")
+	searchCmd.Flags().StringVar(&nearTerms, "near", "", 
+		"Terms that must appear close together, e.g. \"kubelet certificate\"")
+	searchCmd.Flags().IntVar(&nearDistance, "distance", searcher.DefaultNearDistance, 
+		"Maximum number of words between --near terms")
+	searchCmd.Flags().StringSliceVar(&hasContent, "has", nil, 
+		fmt.Sprintf("Only messages containing this content (%s), repeatable", strings.Join(query.HasValues, "|")))
+	searchCmd.Flags().BoolVar(&hasLogs, "has-logs", false, 
+		"Only messages with a pasted log or stack trace (same as --has logs)")
+	searchCmd.Flags().StringSliceVar(&searchTags, "tag", nil, 
+		"Only messages carrying this tag, directly or through their thread (repeatable)")
+	searchCmd.Flags().StringVar(&searchVersion, "k8s-version", "", 
+		"Only messages mentioning this Kubernetes minor version, e.g. 1.27")
+	searchCmd.Flags().StringVar(&errorLike, "error-like", "", 
+		"Find messages quoting the same error as this pasted output, whatever its IDs, timestamps and pod names (- for stdin)")
 	
-	searchCmd.MarkFlagRequired("database")
+	searchCmd.Flags().StringVar(&searchScope, "scope", models.ScopeAll, 
+		fmt.Sprintf("Which messages to search (%s)", strings.Join(models.Scopes, "|")))
+	searchCmd.Flags().BoolVar(&countOnly, "count", false, 
+		"Only print the number of matching messages")
+	searchCmd.Flags().BoolVar(&explainOnly, "explain", false, 
+		"Print how the search would run (match expression, filters, SQL and query plan) instead of running it")
+	searchCmd.Flags().BoolVar(&searchTrace, "trace", false, 
+		"Print how long each search took, the rows it read and its query plan to stderr")
+	searchCmd.Flags().StringVar(&queriesFile, "queries-file", "", 
+		"Run each query in this file (one per line, - for stdin) and report hit counts and top results")
+	searchCmd.Flags().StringVar(&batchReport, "report", "", 
+		"Write the --queries-file report to this .json or .csv file (default: JSON on stdout)")
+	searchCmd.Flags().IntVar(&sampleSize, "sample", 0, 
+		"Return this many random matches instead of the best ones")
+	searchCmd.Flags().StringVar(&searchSort, "sort", models.SortRelevance, 
+		fmt.Sprintf("Result order (%s)", strings.Join(models.Sorts, "|")))
+	searchCmd.Flags().StringVar(&threadsDir, "export-threads", "", 
+		"Write the complete thread of each result that belongs to one into this directory")
+	searchCmd.Flags().StringVar(&threadsFormat, "thread-format", exporter.ThreadFormatMarkdown, 
+		fmt.Sprintf("Format of exported threads (%s)", strings.Join(exporter.ThreadFormats, "|")))
+	searchCmd.Flags().BoolVar(&allWorkspaces, "all-workspaces", false, 
+		"Search the database of the same name in every workspace")
+	searchCmd.Flags().DurationVar(&searchTimeout, "timeout", 0, 
+		"Abort the search after this long, e.g. 30s (0 for no limit)")
+	searchCmd.Flags().StringVar(&searchSaveAs, "save-as", "", 
+		"Save the results under this name in each database searched, for the sets command")
+	
+	searchCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+	searchCmd.RegisterFlagCompletionFunc("theme", completeValues(searcher.Themes()...))
+	searchCmd.RegisterFlagCompletionFunc("has", completeValues(query.HasValues...))
+	searchCmd.RegisterFlagCompletionFunc("scope", completeValues(models.Scopes...))
+	searchCmd.RegisterFlagCompletionFunc("sort", completeValues(models.Sorts...))
+	searchCmd.RegisterFlagCompletionFunc("thread-format", completeValues(exporter.ThreadFormats...))
+	searchCmd.RegisterFlagCompletionFunc("format", completeValues(searcher.StreamFormats...))
+	searchCmd.RegisterFlagCompletionFunc("color", completeValues(colorAuto, colorAlways, colorNever))
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
-	query := args[0]
+	rawQuery := ""
+	if len(args) > 0 {
+		rawQuery = args[0]
+	}
 	
-	// Validate database exists
-	if !searcher.ValidateDatabaseExists(databaseName) {
-		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", databaseName)
+	if queriesFile != "" {
+		if rawQuery != "" {
+			return fmt.Errorf("give either a query or --queries-file, not both")
+		}
+		if errorLike != "" {
+			return fmt.Errorf("--error-like cannot be combined with --queries-file")
+		}
+		if explainOnly {
+			return fmt.Errorf("--explain cannot be combined with --queries-file")
+		}
+		if searchSaveAs != "" {
+			return fmt.Errorf("--save-as cannot be combined with --queries-file")
+		}
+	}
+	
+	if !validScope(searchScope) {
+		return fmt.Errorf("invalid --scope %q (supported: %s)", searchScope, strings.Join(models.Scopes, ", "))
+	}
+	if !validSort(searchSort) {
+		return fmt.Errorf("invalid --sort %q (supported: %s)", searchSort, strings.Join(models.Sorts, ", "))
 	}
 	
-	// Create searcher
-	search, err := searcher.NewSearcher(databaseName)
+	// --sample draws random matches rather than the first ones
+	if sampleSize < 0 {
+		return fmt.Errorf("--sample must be positive")
+	}
+	if sampleSize > 0 {
+		if cmd.Flags().Changed("sort") {
+			return fmt.Errorf("--sample cannot be combined with --sort")
+		}
+		searchLimit = sampleSize
+	}
+	if searchLimit < 0 {
+		return fmt.Errorf("--limit must be zero (no limit) or positive")
+	}
+	if searchFormat != searcher.StreamFormatText && searchFormat != searcher.StreamFormatNDJSON {
+		return fmt.Errorf("invalid --format %q (supported: %s)", searchFormat, strings.Join(searcher.StreamFormats, ", "))
+	}
+	if snippetCount < 1 {
+		return fmt.Errorf("--snippets must be at least 1")
+	}
+	if searchColor != colorAuto && searchColor != colorAlways && searchColor != colorNever {
+		return fmt.Errorf("invalid --color %q (supported: %s, %s, %s)", searchColor, colorAuto, colorAlways, colorNever)
+	}
+	if searchSaveAs != "" {
+		if countOnly || explainOnly {
+			return fmt.Errorf("--save-as cannot be combined with --count or --explain")
+		}
+		if err := validateSetName(searchSaveAs); err != nil {
+			return err
+		}
+	}
+	streaming := searchLimit == 0 || searchFormat == searcher.StreamFormatNDJSON
+	if embedMedia && htmlOutput == "" && !openReport {
+		return fmt.Errorf("--embed-media needs --html or --open")
+	}
+	if streaming && (htmlOutput != "" || pdfOutput != "" || openReport) {
+		return fmt.Errorf("--limit 0 and --format ndjson stream results and cannot be combined with --html, --pdf or --open")
+	}
+	if threadsDir != "" && threadsFormat != exporter.ThreadFormatMarkdown && threadsFormat != exporter.ThreadFormatJSON {
+		return fmt.Errorf("invalid --thread-format %q (supported: %s)", threadsFormat, strings.Join(exporter.ThreadFormats, ", "))
+	}
+	
+	if queriesFile != "" {
+		return runBatchSearch(cmd)
+	}
+	
+	matchQuery, filter, databases, err := planSearch(rawQuery)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
+	}
+	databaseName = databases[0]
+	
+	// Bound the search by --timeout; Ctrl-C cancels via the command context
+	ctx := cmd.Context()
+	if searchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, searchTimeout)
+		defer cancel()
 	}
-	defer search.Close()
 	
-	// Show stats if requested
-	if showStats {
-		stats, err := search.GetStats()
+	// Create searchers
+	var searchers []*searcher.Searcher
+	for _, name := range databases {
+		search, err := searcher.NewSearcher(name)
 		if err != nil {
-			return fmt.Errorf("failed to get stats: %w", err)
+			return fmt.Errorf("failed to open database: %w", err)
 		}
+		defer search.Close()
+		search.SetSnippets(snippetCount)
+		searchers = append(searchers, search)
 		
-		fmt.Printf("Database: %s\n", databaseName)
-		fmt.Printf("- Users: %d\n", stats["users"])
-		fmt.Printf("- Channels: %d\n", stats["channels"])
-		fmt.Printf("- Messages: %d\n\n", stats["messages"])
+		// Show stats if requested
+		if showStats {
+			stats, err := search.GetStats(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get stats: %w", err)
+			}
+			
+			fmt.Printf("Database: %s\n", name)
+			fmt.Printf("- Users: %d\n", stats["users"])
+			fmt.Printf("- Channels: %d\n", stats["channels"])
+			fmt.Printf("- Messages: %d\n\n", stats["messages"])
+		}
+	}
+	
+	if explainOnly {
+		return printExplanations(ctx, searchers, databases, rawQuery, matchQuery, filter)
+	}
+	
+	if err := traceSearches(searchers, "search", rawQuery); err != nil {
+		return err
+	}
+	
+	if countOnly {
+		return printCounts(ctx, searchers, databases, rawQuery, matchQuery, filter)
+	}
+	
+	if streaming {
+		return streamSearch(ctx, searchers, databases, rawQuery, matchQuery, filter)
 	}
 	
 	// Perform search
-	fmt.Printf("Searching for: %s\n", query)
-	fmt.Printf("Database: %s\n", databaseName)
-	fmt.Printf("Limit: %d\n\n", searchLimit)
+	highlighter := newHighlighter(matchQuery)
+	fmt.Printf("Searching for: %s\n", rawQuery)
+	fmt.Printf("Database: %s\n", strings.Join(databases, ", "))
+	if legend := highlighter.Legend(); legend != "" {
+		fmt.Println(legend)
+	}
+	if sampleSize > 0 {
+		fmt.Printf("Sample: %d random matches\n\n", sampleSize)
+	} else {
+		fmt.Printf("Limit: %d\n\n", searchLimit)
+	}
 	
-	results, err := search.Search(query, searchLimit)
-	if err != nil {
-		return fmt.Errorf("search failed: %w", err)
+	var results []*models.SearchResult
+	// source maps each result to the index of the database it came from
+	source := make(map[*models.SearchResult]int)
+	for i, search := range searchers {
+		found, err := search.SearchFiltered(ctx, matchQuery, filter, searchLimit)
+		if err == nil {
+			err = search.CountThreadHits(ctx, matchQuery, found)
+		}
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("search timed out after %s", searchTimeout)
+			}
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return fmt.Errorf("search cancelled")
+			}
+			return fmt.Errorf("search failed: %w", err)
+		}
+		
+		// Label results with their database when several were searched
+		if len(searchers) > 1 {
+			for _, result := range found {
+				result.Database = databases[i]
+			}
+		}
+		results = append(results, found...)
+		for _, result := range found {
+			source[result] = i
+		}
+	}
+	
+	// Interleave results from several databases in the requested order,
+	// keeping the first --limit of them
+	if len(searchers) > 1 {
+		searcher.SortResults(results, filter.Sort)
+		if len(results) > searchLimit {
+			results = results[:searchLimit]
+		}
+	}
+	hitIDs := make([][]int, len(searchers))
+	setTimestamps := make([][]string, len(searchers))
+	for _, result := range results {
+		i := source[result]
+		hitIDs[i] = append(hitIDs[i], result.ID)
+		setTimestamps[i] = append(setTimestamps[i], result.Timestamp)
 	}
 	
+	if err := recordSearch("search", rawQuery, databases, len(results)); err != nil {
+		return err
+	}
+	noteSearch(rawQuery, databases, len(results), results)
+	
 	// Format and display results
-	output := searcher.FormatResults(results)
+	output := searcher.FormatResultsHighlighted(results, highlighter)
 	fmt.Print(output)
 	
+	// Remember the results so show/thread/open can refer to them by number
+	if err := saveResults(databaseName, rawQuery, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save results: %v\n", err)
+	}
+	if searchSaveAs != "" {
+		if err := saveResultSet(ctx, searchers, databases, searchSaveAs, rawQuery, setTimestamps); err != nil {
+			return err
+		}
+	}
+	
+	report := newReportData(rawQuery, results)
+	if htmlOutput != "" || openReport {
+		activity, err := searchActivity(ctx, searchers)
+		if err != nil {
+			return err
+		}
+		report.Activity = activity
+		
+		filter, err := reportTermFilter(ctx, searchers)
+		if err != nil {
+			return err
+		}
+		report.Summary = searcher.BuildSummary(results, matchQuery, filter)
+		
+		if embedMedia {
+			if report.Media, err = reportMedia(ctx, searchers, databases, report); err != nil {
+				return err
+			}
+		}
+	}
+	
+	if htmlOutput != "" {
+		if err := writeHTMLReport(ctx, htmlOutput, report, searchers); err != nil {
+			return err
+		}
+		fmt.Printf("HTML report written to: %s\n", htmlOutput)
+		noteExported(htmlOutput)
+	}
+	
+	if openReport {
+		if err := openHTMLReport(ctx, report, searchers); err != nil {
+			return err
+		}
+	}
+	
+	if pdfOutput != "" {
+		if err := writePDFReport(pdfOutput, report); err != nil {
+			return err
+		}
+		fmt.Printf("PDF report written to: %s\n", pdfOutput)
+		noteExported(pdfOutput)
+	}
+	
+	if threadsDir != "" {
+		written := 0
+		for i, name := range databases {
+			n, err := exportThreads(ctx, name, hitIDs[i])
+			if err != nil {
+				return err
+			}
+			written += n
+		}
+		fmt.Printf("Exported %d thread(s) to: %s\n", written, threadsDir)
+		noteExported(threadsDir)
+	}
+	
+	return nil
+}
+
+// maxSavedResults bounds the results a streamed search remembers for
+// show, thread and open
+const maxSavedResults = 1000
+
+// newHighlighter returns the highlighter coloring the terms of a search's
+// match expression in its text output, or nil when --color leaves the
+// output plain or there are no terms
+func newHighlighter(matchQuery string) *searcher.Highlighter {
+	switch searchColor {
+	case colorNever:
+		return nil
+	case colorAuto:
+		if _, set := os.LookupEnv("NO_COLOR"); set || !isTerminal(os.Stdout) {
+			return nil
+		}
+	}
+
+	terms := searcher.QueryTerms(matchQuery)
+	if len(terms) == 0 {
+		return nil
+	}
+	return searcher.NewHighlighter(terms)
+}
+
+// streamSearch writes results to stdout as they are read rather than
+// collecting them first, so --limit 0 can extract every match. Databases
+// are searched in turn, each in the requested order, until --limit results
+// have been written.
+func streamSearch(ctx context.Context, searchers []*searcher.Searcher, databases []string, rawQuery, matchQuery string, filter models.SearchFilter) error {
+	ndjson := searchFormat == searcher.StreamFormatNDJSON
+	var highlighter *searcher.Highlighter
+	if !ndjson {
+		highlighter = newHighlighter(matchQuery)
+		fmt.Printf("Searching for: %s\n", rawQuery)
+		fmt.Printf("Database: %s\n", strings.Join(databases, ", "))
+		if legend := highlighter.Legend(); legend != "" {
+			fmt.Println(legend)
+		}
+		if searchLimit == 0 {
+			fmt.Printf("Limit: none\n\n")
+		} else {
+			fmt.Printf("Limit: %d\n\n", searchLimit)
+		}
+	}
+	
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	writer, err := searcher.NewResultWriter(out, searchFormat, databases[0])
+	if err != nil {
+		return err
+	}
+	writer.SetHighlighter(highlighter)
+	
+	var saved []*models.SearchResult
+	hitIDs := make([][]int, len(searchers))
+	setTimestamps := make([][]string, len(searchers))
+	for i, search := range searchers {
+		// Later databases only fill what is left of --limit
+		limit := searchLimit
+		if searchLimit > 0 {
+			if limit -= writer.Count(); limit <= 0 {
+				break
+			}
+		}
+		err := search.StreamFiltered(ctx, matchQuery, filter, limit, func(result *models.SearchResult) error {
+			if len(searchers) > 1 {
+				result.Database = databases[i]
+			}
+			if len(saved) < maxSavedResults {
+				saved = append(saved, result)
+			}
+			if threadsDir != "" {
+				hitIDs[i] = append(hitIDs[i], result.ID)
+			}
+			if searchSaveAs != "" {
+				setTimestamps[i] = append(setTimestamps[i], result.Timestamp)
+			}
+			return writer.Write(result)
+		})
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("search timed out after %s", searchTimeout)
+			}
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return fmt.Errorf("search cancelled")
+			}
+			return fmt.Errorf("search failed: %w", err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	
+	if !ndjson {
+		if writer.Count() == 0 {
+			fmt.Println("No results found.")
+		} else {
+			fmt.Printf("Found %d result(s)\n", writer.Count())
+		}
+	}
+	
+	if err := recordSearch("search", rawQuery, databases, writer.Count()); err != nil {
+		return err
+	}
+	noteSearch(rawQuery, databases, writer.Count(), saved)
+	if searchSaveAs != "" {
+		if err := saveResultSet(ctx, searchers, databases, searchSaveAs, rawQuery, setTimestamps); err != nil {
+			return err
+		}
+	}
+	if err := saveResults(databaseName, rawQuery, saved); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save results: %v\n", err)
+	}
+	
+	if threadsDir != "" {
+		written := 0
+		for i, name := range databases {
+			n, err := exportThreads(ctx, name, hitIDs[i])
+			if err != nil {
+				return err
+			}
+			written += n
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d thread(s) to: %s\n", written, threadsDir)
+		noteExported(threadsDir)
+	}
+	
+	return nil
+}
+
+// exportThreads writes the threads containing the given messages of a
+// database to --export-threads, returning how many were written
+func exportThreads(ctx context.Context, database string, messageIDs []int) (int, error) {
+	if len(messageIDs) == 0 {
+		return 0, nil
+	}
+
+	exp, err := exporter.NewExporter(database)
+	if err != nil {
+		return 0, err
+	}
+	defer exp.Close()
+
+	// Keep workspaces apart when several were searched
+	dir := threadsDir
+	if workspace, _ := storagepaths.SplitName(database); workspace != "" {
+		dir = filepath.Join(dir, workspace)
+	}
+
+	paths, err := exp.ExportThreads(ctx, dir, messageIDs, threadsFormat)
+	if err != nil {
+		return len(paths), fmt.Errorf("failed to export threads: %w", err)
+	}
+
+	return len(paths), nil
+}
+
+// planSearch turns a query with Slack-style filters and the search flags
+// into an FTS match expression, a filter and the databases to search
+func planSearch(rawQuery string) (string, models.SearchFilter, []string, error) {
+	// Separate from:, in:, after: etc. filters from the free text
+	parsed, err := query.Parse(rawQuery)
+	if err != nil {
+		return "", models.SearchFilter{}, nil, err
+	}
+	for _, value := range hasContent {
+		has, err := query.ParseHas(value)
+		if err != nil {
+			return "", models.SearchFilter{}, nil, err
+		}
+		parsed.Has = append(parsed.Has, has)
+	}
+	if hasLogs {
+		parsed.Has = append(parsed.Has, models.HasLogs)
+	}
+	for _, value := range searchTags {
+		tag, err := query.ParseTag(value)
+		if err != nil {
+			return "", models.SearchFilter{}, nil, err
+		}
+		parsed.Tags = append(parsed.Tags, tag)
+	}
+	filter := parsed.Filter()
+	if searchVersion != "" {
+		if filter.K8sVersion, err = identifiers.ParseVersion(searchVersion); err != nil {
+			return "", filter, nil, err
+		}
+	}
+	if errorLike != "" {
+		if filter.Fingerprints, err = errorFingerprints(errorLike); err != nil {
+			return "", filter, nil, err
+		}
+	}
+	filter.Scope = searchScope
+	filter.Sort = searchSort
+	if sampleSize > 0 {
+		filter.Sort = models.SortRandom
+	}
+	
+	// Compile ergonomic flags into FTS syntax; filters alone need no match
+	matchQuery := parsed.Text
+	if matchQuery != "" || nearTerms != "" || len(excludeTerms) > 0 || filter.IsZero() {
+		matchQuery, err = searcher.BuildMatchQuery(parsed.Text, searcher.QueryOptions{
+			Exclude:  excludeTerms,
+			Near:     nearTerms,
+			Distance: nearDistance,
+		})
+		if err != nil {
+			return "", filter, nil, err
+		}
+	}
+	
+	// in:#channel selects the databases to search, overriding --database
+	var databases []string
+	if len(parsed.In) > 0 {
+		for _, name := range parsed.In {
+			databases = append(databases, qualify(name))
+		}
+	} else {
+		// Fall back to the active database when --database is omitted
+		if err := resolveDatabase(&databaseName); err != nil {
+			return "", filter, nil, err
+		}
+		databases = []string{databaseName}
+	}
+
+	// --all-workspaces searches each channel's database in every workspace
+	if allWorkspaces {
+		var expanded []string
+		for _, name := range databases {
+			matches, err := workspaceDatabases(name)
+			if err != nil {
+				return "", filter, nil, err
+			}
+			expanded = append(expanded, matches...)
+		}
+		databases = expanded
+	}
+	
+	// Validate database exists
+	for _, name := range databases {
+		if !searcher.ValidateDatabaseExists(name) {
+			return "", filter, nil, fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", name)
+		}
+	}
+	
+	return matchQuery, filter, databases, nil
+}
+
+// errorFingerprints returns the fingerprints of the error output given to
+// --error-like, reading it from stdin for -
+func errorFingerprints(value string) ([]string, error) {
+	if value == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --error-like text: %w", err)
+		}
+		value = string(data)
+	}
+
+	fingerprints := fingerprint.Query(value)
+	if len(fingerprints) == 0 {
+		return nil, fmt.Errorf("no error message found in --error-like text (errors need at least a few words)")
+	}
+	return fingerprints, nil
+}
+
+// printCounts prints how many messages match in each database. A single
+// database prints the bare number, for use in scripts.
+func printCounts(ctx context.Context, searchers []*searcher.Searcher, databases []string, rawQuery, query string, filter models.SearchFilter) error {
+	total := 0
+	var counts []int
+	for _, search := range searchers {
+		count, err := search.Count(ctx, query, filter)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("search timed out after %s", searchTimeout)
+			}
+			return fmt.Errorf("count failed: %w", err)
+		}
+		counts = append(counts, count)
+		total += count
+	}
+	
+	if err := recordSearch("search --count", rawQuery, databases, total); err != nil {
+		return err
+	}
+	noteSearch(rawQuery, databases, total, nil)
+	
+	if len(searchers) == 1 {
+		fmt.Println(total)
+		return nil
+	}
+
+	for i, count := range counts {
+		fmt.Printf("%s\t%d\n", databases[i], count)
+	}
+	fmt.Printf("total\t%d\n", total)
+
+	return nil
+}
+
+// printExplanations prints how a search would run in each database: the
+// query as given, the FTS match expression it became, the filters applied,
+// and the SQL, arguments and SQLite query plan
+func printExplanations(ctx context.Context, searchers []*searcher.Searcher, databases []string, rawQuery, query string, filter models.SearchFilter) error {
+	fmt.Printf("Query: %s\n", rawQuery)
+	if query != "" {
+		fmt.Printf("Match expression: %s\n", identifiers.RewriteQuery(query, "identifiers"))
+	} else {
+		fmt.Println("Match expression: (none, filters only)")
+	}
+	if searchLimit == 0 {
+		fmt.Println("Limit: none")
+	} else {
+		fmt.Printf("Limit: %d\n", searchLimit)
+	}
+	fmt.Println("Filters:")
+	for _, line := range filter.Describe() {
+		fmt.Printf("  %s\n", line)
+	}
+
+	for i, search := range searchers {
+		explanation, err := search.Explain(ctx, query, filter, searchLimit)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\nDatabase: %s\n", databases[i])
+		fmt.Println("SQL:")
+		for _, line := range strings.Split(explanation.SQL, "\n") {
+			fmt.Printf("  %s\n", strings.TrimSpace(line))
+		}
+		fmt.Println("Arguments:")
+		for n, arg := range explanation.Args {
+			fmt.Printf("  %d: %v\n", n+1, arg)
+		}
+		fmt.Println("Query plan:")
+		writeQueryPlan(os.Stdout, explanation.Plan, 0, "  ")
+	}
+
+	return nil
+}
+
+// writeQueryPlan writes the steps of a query plan under parent as an
+// indented tree, as the sqlite3 shell does
+func writeQueryPlan(w io.Writer, plan []models.QueryPlanStep, parent int, indent string) {
+	for _, step := range plan {
+		if step.Parent != parent {
+			continue
+		}
+		fmt.Fprintf(w, "%s%s\n", indent, step.Detail)
+		writeQueryPlan(w, plan, step.ID, indent+"  ")
+	}
+}
+
+// searchActivity builds the activity calendar of the searched databases
+func searchActivity(ctx context.Context, searchers []*searcher.Searcher) (*searcher.Heatmap, error) {
+	var counts []models.DayCount
+	for _, search := range searchers {
+		daily, err := search.DailyActivity(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load activity: %w", err)
+		}
+		counts = append(counts, daily...)
+	}
+
+	return searcher.BuildHeatmap(counts), nil
+}
+
+// reportTermFilter combines the term filters of the searched databases for
+// a report summary: a term left out of any database's statistics is left
+// out of the summary
+func reportTermFilter(ctx context.Context, searchers []*searcher.Searcher) (models.TermFilter, error) {
+	var combined models.TermFilter
+	for _, search := range searchers {
+		filter, err := search.GetTermFilter(ctx)
+		if err != nil {
+			return combined, fmt.Errorf("failed to load stopwords: %w", err)
+		}
+		combined.Stopwords = append(combined.Stopwords, filter.Stopwords...)
+		if filter.MinLength > combined.MinLength {
+			combined.MinLength = filter.MinLength
+		}
+	}
+	return combined, nil
+}
+
+// reportMedia loads the files shared in a report's results from the
+// databases they were found in
+func reportMedia(ctx context.Context, searchers []*searcher.Searcher, databases []string, report *searcher.ReportData) (searcher.ReportMedia, error) {
+	media := searcher.ReportMedia{}
+	for i, search := range searchers {
+		// Results are only labeled with their database when several
+		// were searched
+		database := report.Database
+		if len(searchers) > 1 {
+			database = databases[i]
+		}
+
+		var timestamps []string
+		for _, result := range report.Results {
+			if len(searchers) == 1 || result.Database == database {
+				timestamps = append(timestamps, result.Timestamp)
+			}
+		}
+		files, err := search.GetMessageFiles(ctx, timestamps)
+		if err != nil {
+			return nil, err
+		}
+		media.AddFiles(database, files, searcher.MaxEmbedSize)
+	}
+	return media, nil
+}
+
+// writeHTMLReport renders search results to an HTML file, with a day page
+// for each result's day in a directory alongside it
+func writeHTMLReport(ctx context.Context, path string, data *searcher.ReportData, searchers []*searcher.Searcher) error {
+	dayPages, err := writeDayPages(ctx, path, data.Results, searchers)
+	if err != nil {
+		return err
+	}
+	data.DayPages = dayPages
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer file.Close()
+
+	opts := searcher.HTMLOptions{
+		TemplatePath: htmlTemplate,
+		Theme:        htmlTheme,
+	}
+
+	if err := searcher.GenerateHTMLOutput(file, data, opts); err != nil {
+		return fmt.Errorf("failed to generate HTML report: %w", err)
+	}
+
 	return nil
 }
 
+// writeDayPages writes the day pages for a report's results into a
+// directory named after the report, returning its name relative to the
+// report, or an empty string when there are no results
+func writeDayPages(ctx context.Context, reportPath string, results []*models.SearchResult, searchers []*searcher.Searcher) (string, error) {
+	if len(results) == 0 {
+		return "", nil
+	}
+
+	// Results carry their database only when several were searched
+	byDatabase := make(map[string][]*models.SearchResult)
+	for _, result := range results {
+		name := result.Database
+		if name == "" {
+			name = searchers[0].ChannelName()
+		}
+		byDatabase[name] = append(byDatabase[name], result)
+	}
+
+	report := filepath.Base(reportPath)
+	dirName := strings.TrimSuffix(report, filepath.Ext(report)) + "_days"
+	dir := filepath.Join(filepath.Dir(reportPath), dirName)
+
+	for _, search := range searchers {
+		name := search.ChannelName()
+		if len(byDatabase[name]) == 0 {
+			continue
+		}
+
+		pages, err := search.ResultDayPages(ctx, byDatabase[name])
+		if err != nil {
+			return "", fmt.Errorf("failed to load day pages: %w", err)
+		}
+		for _, page := range pages {
+			// Day pages sit one directory below the report, plus one per
+			// workspace in the database name
+			page.ReportURL = strings.Repeat("../", 1+strings.Count(name, "/")) + report
+
+			path := filepath.Join(dir, filepath.FromSlash(searcher.DayPageName(name, page.Day)))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return "", fmt.Errorf("failed to create day pages directory: %w", err)
+			}
+			file, err := os.Create(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to create day page: %w", err)
+			}
+			err = searcher.GenerateDayHTML(file, page, htmlTheme)
+			file.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to generate day page: %w", err)
+			}
+		}
+	}
+
+	return dirName, nil
+}
+
+// openHTMLReport opens the HTML report in the default browser, rendering it
+// to a temporary file first when --html was not given
+func openHTMLReport(ctx context.Context, data *searcher.ReportData, searchers []*searcher.Searcher) error {
+	path := htmlOutput
+	if path == "" {
+		file, err := os.CreateTemp("", "k8s-slack-searcher-*.html")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary report: %w", err)
+		}
+		file.Close()
+		path = file.Name()
+
+		if err := writeHTMLReport(ctx, path, data, searchers); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Opening HTML report: %s\n", path)
+	return browser.Open(path)
+}
+
+// validScope reports whether scope is a supported --scope value
+func validScope(scope string) bool {
+	for _, s := range models.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validSort reports whether order is a supported --sort value
+func validSort(order string) bool {
+	for _, s := range models.Sorts {
+		if s == order {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceDatabases returns the database for a channel in every workspace
+// that has one
+func workspaceDatabases(name string) ([]string, error) {
+	_, channel := storagepaths.SplitName(name)
+
+	all, err := searcher.ListDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, db := range all {
+		if _, c := storagepaths.SplitName(db); c == channel {
+			matches = append(matches, db)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("database not found in any workspace: %s. Run 'k8s-slack-searcher list' to see available databases", channel)
+	}
+
+	return matches, nil
+}
+
 func runList(cmd *cobra.Command, args []string) error {
 	databases, err := searcher.ListDatabases()
 	if err != nil {
@@ -104,12 +1039,60 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	
-	fmt.Printf("Available databases (%d):\n\n", len(databases))
+	// Group databases by workspace, default workspace first
+	var workspaces []string
+	byWorkspace := make(map[string][]string)
 	for _, db := range databases {
-		fmt.Printf("  %s\n", db)
+		workspace, channel := storagepaths.SplitName(db)
+		if _, ok := byWorkspace[workspace]; !ok {
+			workspaces = append(workspaces, workspace)
+		}
+		byWorkspace[workspace] = append(byWorkspace[workspace], channel)
+	}
+	sort.Strings(workspaces)
+	
+	fmt.Printf("Available databases (%d):\n", len(databases))
+	for _, workspace := range workspaces {
+		if len(workspaces) > 1 || workspace != "" {
+			label := workspace
+			if label == "" {
+				label = "(default)"
+			}
+			fmt.Printf("\nWorkspace %s:\n", label)
+		} else {
+			fmt.Println()
+		}
+		for _, channel := range byWorkspace[workspace] {
+			fmt.Printf("  %s\n", channel)
+		}
 	}
 	
 	fmt.Printf("\nUse 'k8s-slack-searcher search <query> --database <name>' to search.\n")
 	
 	return nil
+}
+
+// writePDFReport renders search results to a PDF file
+func writePDFReport(path string, data *searcher.ReportData) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create PDF report: %w", err)
+	}
+	defer file.Close()
+
+	if err := searcher.GeneratePDFOutput(file, data); err != nil {
+		return fmt.Errorf("failed to generate PDF report: %w", err)
+	}
+
+	return nil
+}
+
+// newReportData builds the shared data model used by HTML and PDF reports
+func newReportData(query string, results []*models.SearchResult) *searcher.ReportData {
+	return &searcher.ReportData{
+		Query:       query,
+		Database:    databaseName,
+		GeneratedAt: time.Now(),
+		Results:     results,
+	}
 }
\ No newline at end of file
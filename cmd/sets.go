@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var setsCmd = &cobra.Command{
+	Use:   "sets",
+	Short: "List, show and combine saved result sets",
+	Long: `Work with result sets saved by search --save-as: list them, show one
+again, and combine two into a new set with union, intersect or diff, for
+comparing what different searches found.
+
+Sets are stored in the channel's database, holding their messages by
+timestamp, so they survive the database being rebuilt. A search of
+several databases saves each database's results in that database.
+
+Examples:
+  k8s-slack-searcher search "rbac after:2023-01-01 before:2024-01-01" -d sig-auth --save-as rbac-2023
+  k8s-slack-searcher search "rbac after:2024-01-01" -d sig-auth --save-as rbac-2024
+  k8s-slack-searcher sets list -d sig-auth
+  k8s-slack-searcher sets show rbac-2023 -d sig-auth
+  k8s-slack-searcher sets intersect rbac-2023 rbac-2024 -d sig-auth --save-as rbac-both`,
+}
+
+var setsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved result sets in a database",
+	Args:  cobra.NoArgs,
+	RunE:  runSetsList,
+}
+
+var setsShowCmd = &cobra.Command{
+	Use:               "show <name>",
+	Short:             "Show the messages in a saved result set",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSetArgs,
+	RunE:              runSetsShow,
+}
+
+var setsDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a saved result set",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSetArgs,
+	RunE:              runSetsDelete,
+}
+
+var (
+	setsDatabase string
+	setsSaveAs   string
+	setsLimit    int
+)
+
+// setNamePattern is the form of a result set name, as for tags
+var setNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func init() {
+	commands := []*cobra.Command{setsListCmd, setsShowCmd, setsDeleteCmd}
+	for _, op := range database.SetOperations {
+		commands = append(commands, newSetOperationCmd(op))
+	}
+
+	for _, c := range commands {
+		c.Flags().StringVarP(&setsDatabase, "database", "d", "",
+			"Database name (channel name) holding the sets (defaults to the active database)")
+		c.RegisterFlagCompletionFunc("database", completeDatabases)
+		setsCmd.AddCommand(c)
+	}
+	setsShowCmd.Flags().IntVarP(&setsLimit, "limit", "l", 0,
+		"Show at most this many messages (0 for all)")
+}
+
+// newSetOperationCmd returns the command combining two sets with op
+func newSetOperationCmd(op string) *cobra.Command {
+	descriptions := map[string]string{
+		database.SetUnion:     "Show the messages in either set",
+		database.SetIntersect: "Show the messages in both sets",
+		database.SetDiff:      "Show the messages in the first set but not the second",
+	}
+
+	c := &cobra.Command{
+		Use:               op + " <set> <set>",
+		Short:             descriptions[op],
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeSetArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSetOperation(cmd, op, args[0], args[1])
+		},
+	}
+	c.Flags().StringVar(&setsSaveAs, "save-as", "",
+		"Save the combined set under this name")
+	return c
+}
+
+// validateSetName checks a name to save a result set under
+func validateSetName(name string) error {
+	if !setNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid result set name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// completeSetArgs completes the names of the sets saved in the database
+func completeSetArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dbName := setsDatabase
+	if err := resolveDatabase(&dbName); err != nil || !searcher.ValidateDatabaseExists(dbName) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer search.Close()
+
+	sets, err := search.GetResultSets(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, set := range sets {
+		names = append(names, set.Name)
+	}
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func openSetsDatabase() (*searcher.Searcher, error) {
+	if err := resolveDatabase(&setsDatabase); err != nil {
+		return nil, err
+	}
+
+	if !searcher.ValidateDatabaseExists(setsDatabase) {
+		return nil, fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", setsDatabase)
+	}
+
+	search, err := searcher.NewSearcher(setsDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return search, nil
+}
+
+func runSetsList(cmd *cobra.Command, args []string) error {
+	search, err := openSetsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	sets, err := search.GetResultSets(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		fmt.Printf("No result sets in %s\n", setsDatabase)
+		return nil
+	}
+
+	fmt.Printf("Result sets in %s (%d):\n\n", setsDatabase, len(sets))
+	for _, set := range sets {
+		fmt.Printf("  %-24s %5d message(s)  %s  %s\n", set.Name, set.Messages,
+			set.Created.Local().Format("2006-01-02 15:04"), set.Query)
+	}
+	return nil
+}
+
+func runSetsShow(cmd *cobra.Command, args []string) error {
+	search, err := openSetsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	set, timestamps, err := search.GetResultSet(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	if setsLimit > 0 && len(timestamps) > setsLimit {
+		timestamps = timestamps[:setsLimit]
+	}
+
+	fmt.Printf("Result set: %s\n", set.Name)
+	fmt.Printf("Query: %s\n", set.Query)
+	fmt.Printf("Database: %s\n\n", setsDatabase)
+	return printSet(cmd, search, "set "+set.Name, timestamps)
+}
+
+func runSetsDelete(cmd *cobra.Command, args []string) error {
+	search, err := openSetsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	deleted, err := search.DeleteResultSet(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("no result set named %q in %s", args[0], setsDatabase)
+	}
+
+	fmt.Printf("Deleted result set %s from %s\n", args[0], setsDatabase)
+	return nil
+}
+
+func runSetOperation(cmd *cobra.Command, op, first, second string) error {
+	if setsSaveAs != "" {
+		if err := validateSetName(setsSaveAs); err != nil {
+			return err
+		}
+	}
+
+	search, err := openSetsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	ctx := cmd.Context()
+	_, a, err := search.GetResultSet(ctx, first)
+	if err != nil {
+		return err
+	}
+	_, b, err := search.GetResultSet(ctx, second)
+	if err != nil {
+		return err
+	}
+
+	combined, err := database.CombineResultSets(op, a, b)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("%s %s %s", first, op, second)
+	fmt.Printf("Result set: %s\n", description)
+	fmt.Printf("Database: %s\n\n", setsDatabase)
+	if err := printSet(cmd, search, description, combined); err != nil {
+		return err
+	}
+
+	if setsSaveAs != "" {
+		if err := search.SaveResultSet(ctx, setsSaveAs, description, combined); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %d message(s) as %s\n", len(combined), setsSaveAs)
+	}
+	return nil
+}
+
+// printSet prints the messages of a set like search results, and saves
+// them so show, thread and open can refer to them by number
+func printSet(cmd *cobra.Command, search *searcher.Searcher, description string, timestamps []string) error {
+	messages, err := search.GetMessagesByTimestamps(cmd.Context(), timestamps)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*models.SearchResult, len(messages))
+	for i, message := range messages {
+		results[i] = &models.SearchResult{Message: *message, Filename: message.Filename}
+	}
+	if len(results) == 0 {
+		fmt.Println("No results found.")
+	} else {
+		fmt.Print(searcher.FormatResults(results))
+	}
+	if missing := len(timestamps) - len(messages); missing > 0 {
+		fmt.Printf("%d message(s) in the set are no longer in the database.\n", missing)
+	}
+
+	if err := saveResults(setsDatabase, description, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save results: %v\n", err)
+	}
+	noteSearch(description, []string{setsDatabase}, len(results), results)
+	return nil
+}
+
+// saveResultSet saves the timestamps of the results a search found in
+// each database as a named set in that database, for search --save-as
+func saveResultSet(ctx context.Context, searchers []*searcher.Searcher, databases []string, name, query string, timestamps [][]string) error {
+	for i, search := range searchers {
+		if err := search.SaveResultSet(ctx, name, query, timestamps[i]); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Saved %d result(s) as %s in %s\n", len(timestamps[i]), name, databases[i])
+	}
+	return nil
+}
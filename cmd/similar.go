@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+
+	"github.com/spf13/cobra"
+)
+
+var similarCmd = &cobra.Command{
+	Use:   "similar <database> <ts>",
+	Short: "Find messages similar to a given one",
+	Long: `Find messages similar to a given one, for finding other times the same
+problem came up and how it was solved.
+
+The message's salient terms, the words it uses that are rare in the rest of
+the channel, are searched for, and each message found is scored by the
+share of them it contains, rarer terms counting for more. Messages in the
+message's own thread are left out. Results can be opened with show, thread
+and open like those of a search.
+
+The timestamp can be given as stored by Slack, in the form used in
+permalinks or as a full permalink.
+
+Examples:
+  k8s-slack-searcher similar sig-node 1688207025.000100
+  k8s-slack-searcher similar sig-auth https://kubernetes.slack.com/archives/C0EN96KUY/p1684141200000100 --limit 5`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runSimilar,
+}
+
+var (
+	similarLimit int
+	similarTerms int
+)
+
+func init() {
+	similarCmd.Flags().IntVarP(&similarLimit, "limit", "l", 10,
+		"Maximum number of similar messages to show")
+	similarCmd.Flags().IntVar(&similarTerms, "terms", 10,
+		"Number of the message's salient terms to search for")
+}
+
+func runSimilar(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+	if similarLimit < 1 {
+		return fmt.Errorf("--limit must be at least 1")
+	}
+	if similarTerms < 1 {
+		return fmt.Errorf("--terms must be at least 1")
+	}
+
+	ts, err := slacktext.ParseTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	ctx := cmd.Context()
+	message, err := search.GetMessageByTimestamp(ctx, ts)
+	if err != nil {
+		return err
+	}
+
+	terms, similar, err := search.SimilarMessages(ctx, message, similarTerms, similarLimit)
+	if err != nil {
+		return err
+	}
+
+	query := "similar to " + ts
+	if err := recordSearch("similar", query, []string{dbName}, len(similar)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Similar to: %s (%s)\n", ts, message.Date.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Database: %s\n", dbName)
+	if len(terms) == 0 {
+		fmt.Println("\nThe message has no terms rare enough to search for.")
+		return nil
+	}
+	fmt.Printf("Terms: %s\n\n", strings.Join(terms, ", "))
+	fmt.Print(searcher.FormatSimilar(similar))
+
+	// Remember the results so show/thread/open can refer to them by number
+	results := make([]*models.SearchResult, len(similar))
+	for i, result := range similar {
+		results[i] = &result.SearchResult
+	}
+	if err := saveResults(dbName, query, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save results: %v\n", err)
+	}
+	noteSearch(query, []string{dbName}, len(similar), results)
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var skippedCmd = &cobra.Command{
+	Use:   "skipped <database>",
+	Short: "Report the messages ingest skipped",
+	Long: `Report the messages in the export that ingest didn't index, counted by
+reason with examples of each, to judge whether the index is complete enough
+for a piece of research.
+
+Messages are skipped when they come from bots and integrations (unless
+ingested with --include-bots), have no author or no text, can't be parsed,
+or are events with a subtype ingest doesn't index, such as edits and
+deletions. Each example gives the export file and the message's place in
+it, so the source can be checked. Databases ingested before skipped
+messages were recorded need ingesting again for a report.
+
+Examples:
+  k8s-slack-searcher skipped sig-node
+  k8s-slack-searcher skipped sig-node --examples 10
+  k8s-slack-searcher skipped sig-node --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runSkipped,
+}
+
+var (
+	skippedExamples int
+	skippedJSON     bool
+)
+
+func init() {
+	skippedCmd.Flags().IntVarP(&skippedExamples, "examples", "e", 3,
+		"Examples to show of each reason (0 for none)")
+	skippedCmd.Flags().BoolVar(&skippedJSON, "json", false,
+		"Output the report as JSON")
+}
+
+func runSkipped(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+	if skippedExamples < 0 {
+		return fmt.Errorf("--examples can't be negative")
+	}
+
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	report, err := search.GetSkipReport(cmd.Context(), skippedExamples)
+	if err != nil {
+		return err
+	}
+
+	if skippedJSON {
+		if report.Reasons == nil {
+			report.Reasons = []models.SkipReason{}
+		}
+		return printJSON(report)
+	}
+
+	fmt.Printf("Skipped content in %s\n\n", dbName)
+	total := report.Indexed + report.Skipped
+	fmt.Printf("Indexed: %d message(s)\n", report.Indexed)
+	if report.Skipped == 0 {
+		fmt.Println("Skipped: none recorded")
+		return nil
+	}
+	fmt.Printf("Skipped: %d message(s), %.1f%% of the export\n", report.Skipped,
+		float64(report.Skipped)*100/float64(total))
+
+	for _, reason := range report.Reasons {
+		fmt.Printf("\n%s: %d message(s) in %d file(s)\n", reason.Reason, reason.Count, reason.Files)
+		if len(reason.Subtypes) > 0 {
+			fmt.Printf("  Subtypes: %s\n", formatSubtypes(reason.Subtypes))
+		}
+		for _, example := range reason.Examples {
+			fmt.Printf("  %s #%d", example.Filename, example.Position)
+			if example.Timestamp != "" {
+				fmt.Printf("  %s", example.Timestamp)
+			}
+			if example.UserID != "" {
+				fmt.Printf("  %s", example.UserID)
+			}
+			if example.Subtype != "" {
+				fmt.Printf("  [%s]", example.Subtype)
+			}
+			fmt.Println()
+			if example.Excerpt != "" {
+				fmt.Printf("    %s\n", example.Excerpt)
+			}
+		}
+	}
+	return nil
+}
+
+// formatSubtypes lists subtypes with their counts, most common first
+func formatSubtypes(subtypes map[string]int) string {
+	names := make([]string, 0, len(subtypes))
+	for name := range subtypes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if subtypes[names[i]] != subtypes[names[j]] {
+			return subtypes[names[i]] > subtypes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, subtypes[name])
+	}
+	return strings.Join(parts, ", ")
+}
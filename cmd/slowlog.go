@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/audit"
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slowlog"
+
+	"github.com/spf13/cobra"
+)
+
+var slowlogCmd = &cobra.Command{
+	Use:   "slowlog",
+	Short: "Show the slow searches recorded in the slow query log",
+	Long: `Show the searches recorded in the slow query log, slowest first: when each
+ran, how long it took, how many rows it read, how many results it
+returned, the database and the query. --plan adds SQLite's query plan for
+each.
+
+Searches are only recorded when slow_query_log is set in the config file.
+Those taking at least slow_query_threshold (default 1s), and those cut
+short by --timeout, are written:
+  slow_query_log: /var/log/k8s-slack-searcher/slow.jsonl
+  slow_query_threshold: 250ms
+
+Examples:
+  k8s-slack-searcher slowlog
+  k8s-slack-searcher slowlog --sort time --since 2024-05-01 --plan
+  k8s-slack-searcher slowlog --limit 0 --json > slow.json`,
+	Args: cobra.NoArgs,
+	RunE: runSlowlog,
+}
+
+// Orders for slowlog entries
+const (
+	slowlogSortDuration = "duration"
+	slowlogSortTime     = "time"
+)
+
+var (
+	slowlogFile     string
+	slowlogSince    string
+	slowlogDatabase string
+	slowlogSort     string
+	slowlogLimit    int
+	slowlogPlan     bool
+	slowlogJSON     bool
+)
+
+func init() {
+	slowlogCmd.Flags().StringVar(&slowlogFile, "file", "",
+		"Slow query log to read (defaults to slow_query_log from the config file)")
+	slowlogCmd.Flags().StringVar(&slowlogSince, "since", "",
+		"Only show searches on or after this day, as YYYY-MM-DD")
+	slowlogCmd.Flags().StringVarP(&slowlogDatabase, "database", "d", "",
+		"Only show searches of this database")
+	slowlogCmd.Flags().StringVar(&slowlogSort, "sort", slowlogSortDuration,
+		fmt.Sprintf("Order of the searches shown (%s|%s)", slowlogSortDuration, slowlogSortTime))
+	slowlogCmd.Flags().IntVarP(&slowlogLimit, "limit", "l", 20,
+		"Show at most this many searches (0 for all)")
+	slowlogCmd.Flags().BoolVar(&slowlogPlan, "plan", false,
+		"Show the query plan of each search")
+	slowlogCmd.Flags().BoolVar(&slowlogJSON, "json", false, "Output as JSON")
+
+	slowlogCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+	slowlogCmd.RegisterFlagCompletionFunc("sort", completeValues(slowlogSortDuration, slowlogSortTime))
+}
+
+func runSlowlog(cmd *cobra.Command, args []string) error {
+	if slowlogSort != slowlogSortDuration && slowlogSort != slowlogSortTime {
+		return fmt.Errorf("invalid --sort %q (supported: %s, %s)", slowlogSort, slowlogSortDuration, slowlogSortTime)
+	}
+
+	path := slowlogFile
+	if path == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.SlowQueryLog == "" {
+			configPath, _ := config.Path()
+			return fmt.Errorf("no slow query log: set slow_query_log in %s or pass --file", configPath)
+		}
+		path = cfg.SlowQueryLog
+	}
+
+	var since time.Time
+	if slowlogSince != "" {
+		var err error
+		since, err = time.ParseInLocation("2006-01-02", slowlogSince, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --since %q: expected YYYY-MM-DD", slowlogSince)
+		}
+	}
+
+	entries, err := slowlog.Read(path)
+	if err != nil {
+		return err
+	}
+
+	var matched []slowlog.Entry
+	for _, entry := range entries {
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		if slowlogDatabase != "" && entry.Database != qualify(slowlogDatabase) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+	if slowlogSort == slowlogSortDuration {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].Duration > matched[j].Duration
+		})
+	}
+	if slowlogLimit > 0 && len(matched) > slowlogLimit {
+		if slowlogSort == slowlogSortTime {
+			// The latest searches, still oldest first
+			matched = matched[len(matched)-slowlogLimit:]
+		} else {
+			matched = matched[:slowlogLimit]
+		}
+	}
+
+	if slowlogJSON {
+		if matched == nil {
+			matched = []slowlog.Entry{}
+		}
+		if !slowlogPlan {
+			for i := range matched {
+				matched[i].Plan = nil
+			}
+		}
+		return printJSON(matched)
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No slow searches recorded.")
+		return nil
+	}
+
+	fmt.Printf("%-19s  %10s  %9s  %7s  %-20s %s\n", "TIME", "DURATION", "SCANNED", "RESULTS", "DATABASE", "QUERY")
+	for _, entry := range matched {
+		query := entry.Query
+		if entry.Error != "" {
+			query += "  [" + entry.Error + "]"
+		}
+		fmt.Printf("%-19s  %8.1fms  %9d  %7d  %-20s %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.DurationMS(), entry.RowsScanned, entry.Results, entry.Database, query)
+		if slowlogPlan {
+			writeQueryPlan(os.Stdout, entry.Plan, 0, "    ")
+		}
+	}
+
+	return nil
+}
+
+// traceSearches sets up tracing of the searches a command runs with each
+// searcher: with --trace each trace is printed to stderr, and when a slow
+// query log is configured searches taking at least the threshold, or
+// timing out, are appended to it. A search is never failed by its trace.
+func traceSearches(searchers []*searcher.Searcher, command, rawQuery string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	threshold, err := cfg.SlowQueryDuration()
+	if err != nil {
+		return err
+	}
+	if !searchTrace && cfg.SlowQueryLog == "" {
+		return nil
+	}
+
+	tracer := func(trace *models.SearchTrace) {
+		if searchTrace {
+			printTrace(os.Stderr, trace)
+		}
+		if cfg.SlowQueryLog == "" || (trace.Duration < threshold && !trace.TimedOut) {
+			return
+		}
+		err := slowlog.Append(cfg.SlowQueryLog, slowlog.Entry{
+			SearchTrace: *trace,
+			User:        audit.CurrentUser(),
+			Command:     command,
+			Query:       rawQuery,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	for _, search := range searchers {
+		search.SetTracer(tracer)
+	}
+	return nil
+}
+
+// printTrace writes a search trace in the form --trace prints
+func printTrace(w io.Writer, trace *models.SearchTrace) {
+	fmt.Fprintf(w, "Trace %s: %.1fms, %d row(s) scanned, %d result(s)\n", trace.Database,
+		float64(trace.Duration.Microseconds())/1000, trace.RowsScanned, trace.Results)
+	if trace.Match != "" {
+		fmt.Fprintf(w, "  Match expression: %s\n", trace.Match)
+	}
+	for _, line := range trace.Filters {
+		fmt.Fprintf(w, "  Filter: %s\n", line)
+	}
+	if trace.Error != "" {
+		fmt.Fprintf(w, "  Error: %s\n", trace.Error)
+	}
+	fmt.Fprintln(w, "  Query plan:")
+	writeQueryPlan(w, trace.Plan, 0, "    ")
+}
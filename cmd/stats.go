@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/charts"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats <database>",
+	Short: "Show statistics for a channel database",
+	Long: `Show message, user and channel totals for a channel database, the span
+and busiest day of activity, and the top posters.
+
+With --chart, message volume over time is drawn to the given PNG or SVG
+file, and a top posters bar chart is written next to it with -posters
+added to the name (activity.png and activity-posters.png).
+
+Examples:
+  k8s-slack-searcher stats sig-auth
+  k8s-slack-searcher stats sig-auth --chart activity.png
+  k8s-slack-searcher stats sig-auth --chart activity.svg --limit 15`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runStats,
+}
+
+var (
+	statsChart string
+	statsLimit int
+	statsJSON  bool
+)
+
+func init() {
+	statsCmd.Flags().StringVar(&statsChart, "chart", "",
+		"Write activity charts to this .png or .svg file")
+	statsCmd.Flags().IntVarP(&statsLimit, "limit", "l", 10,
+		"Number of top posters to show")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false,
+		"Output statistics as JSON")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+
+	// Check the chart file name before doing any work
+	chartFormat := ""
+	if statsChart != "" {
+		var err error
+		if chartFormat, err = charts.FormatForPath(statsChart); err != nil {
+			return err
+		}
+	}
+
+	a, err := openAnalyzer(dbName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	stats, err := a.Stats(cmd.Context(), statsLimit)
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+	stats.Database = dbName
+
+	if statsJSON {
+		if err := printJSON(stats); err != nil {
+			return err
+		}
+	} else {
+		printStats(stats)
+	}
+
+	if statsChart != "" {
+		postersChart := postersChartPath(statsChart)
+		if err := writeChart(statsChart, func(f *os.File) error {
+			return charts.WriteVolumeChart(f, chartFormat, stats.Daily)
+		}); err != nil {
+			return err
+		}
+		if err := writeChart(postersChart, func(f *os.File) error {
+			return charts.WritePostersChart(f, chartFormat, stats.TopPosters)
+		}); err != nil {
+			return err
+		}
+		// Keep JSON output machine-readable
+		if !statsJSON {
+			fmt.Printf("\nCharts written to: %s, %s\n", statsChart, postersChart)
+		}
+	}
+
+	return nil
+}
+
+// printStats prints a database summary for the terminal
+func printStats(stats *models.ChannelStats) {
+	fmt.Printf("Database: %s\n", stats.Database)
+	fmt.Printf("- Users: %d\n", stats.Users)
+	fmt.Printf("- Channels: %d\n", stats.Channels)
+	fmt.Printf("- Messages: %d\n", stats.Messages)
+	if stats.ActiveDays > 0 {
+		fmt.Printf("- Active days: %d (%s to %s)\n", stats.ActiveDays, stats.FirstDay, stats.LastDay)
+		fmt.Printf("- Busiest day: %s (%d messages)\n", stats.BusiestDay.Date, stats.BusiestDay.Count)
+	}
+
+	printLeaderboardSection("Top posters", stats.TopPosters)
+}
+
+// postersChartPath names the top posters chart after the volume chart,
+// e.g. activity.png becomes activity-posters.png
+func postersChartPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-posters" + ext
+}
+
+// writeChart creates a chart file and renders into it
+func writeChart(path string, render func(*os.File) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create chart: %w", err)
+	}
+	defer file.Close()
+
+	if err := render(file); err != nil {
+		return fmt.Errorf("failed to render chart %s: %w", path, err)
+	}
+
+	return nil
+}
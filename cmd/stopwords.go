@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var stopwordsCmd = &cobra.Command{
+	Use:   "stopwords",
+	Short: "Configure the words left out of term statistics",
+	Long: `Configure the stopwords and minimum term length for a database. Terms
+in the stopword list or shorter than the minimum are left out of search
+term suggestions and of the term reports from analyze, so words like
+"the", "and" and "with" don't crowd out the subject matter.
+
+The settings are stored in the database itself. Until changed, a built-in
+list of common English and chat words and a minimum length of 3 apply.
+Messages remain searchable by every word.
+
+Examples:
+  k8s-slack-searcher stopwords list -d sig-auth
+  k8s-slack-searcher stopwords add lgtm ptal -d sig-auth
+  k8s-slack-searcher stopwords remove http -d sig-auth
+  k8s-slack-searcher stopwords min-length 4 -d sig-auth
+  k8s-slack-searcher stopwords reset -d sig-auth`,
+}
+
+var stopwordsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the stopwords and minimum term length",
+	Args:  cobra.NoArgs,
+	RunE:  runStopwordsList,
+}
+
+var stopwordsAddCmd = &cobra.Command{
+	Use:   "add <word>...",
+	Short: "Add words to the stopword list",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runStopwordsAdd,
+}
+
+var stopwordsRemoveCmd = &cobra.Command{
+	Use:   "remove <word>...",
+	Short: "Remove words from the stopword list",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runStopwordsRemove,
+}
+
+var stopwordsMinLengthCmd = &cobra.Command{
+	Use:   "min-length <n>",
+	Short: "Set the shortest term counted in term statistics",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStopwordsMinLength,
+}
+
+var stopwordsResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Restore the built-in stopwords and minimum term length",
+	Args:  cobra.NoArgs,
+	RunE:  runStopwordsReset,
+}
+
+var stopwordsDatabase string
+
+func init() {
+	for _, c := range []*cobra.Command{stopwordsListCmd, stopwordsAddCmd, stopwordsRemoveCmd, stopwordsMinLengthCmd, stopwordsResetCmd} {
+		c.Flags().StringVarP(&stopwordsDatabase, "database", "d", "",
+			"Database name (channel name) to configure (defaults to the active database)")
+		c.RegisterFlagCompletionFunc("database", completeDatabases)
+		c.ValidArgsFunction = cobra.NoFileCompletions
+	}
+
+	stopwordsCmd.AddCommand(stopwordsListCmd, stopwordsAddCmd, stopwordsRemoveCmd, stopwordsMinLengthCmd, stopwordsResetCmd)
+}
+
+// openStopwordsDatabase opens --database, or the active database
+func openStopwordsDatabase() (*searcher.Searcher, error) {
+	if err := resolveDatabase(&stopwordsDatabase); err != nil {
+		return nil, err
+	}
+
+	if !searcher.ValidateDatabaseExists(stopwordsDatabase) {
+		return nil, fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", stopwordsDatabase)
+	}
+
+	search, err := searcher.NewSearcher(stopwordsDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return search, nil
+}
+
+func runStopwordsList(cmd *cobra.Command, args []string) error {
+	search, err := openStopwordsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	filter, err := search.GetTermFilter(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	source := "custom"
+	if filter.Default {
+		source = "built-in"
+	}
+	fmt.Printf("Term statistics for %s leave out terms shorter than %d characters and %d %s stopword(s):\n\n",
+		stopwordsDatabase, filter.MinLength, len(filter.Stopwords), source)
+
+	// Wrap the list to fit a terminal
+	line := " "
+	for _, word := range filter.Stopwords {
+		if len(line)+len(word) > 78 {
+			fmt.Println(line)
+			line = " "
+		}
+		line += " " + word
+	}
+	if strings.TrimSpace(line) != "" {
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+func runStopwordsAdd(cmd *cobra.Command, args []string) error {
+	return updateStopwords(cmd, args, true)
+}
+
+func runStopwordsRemove(cmd *cobra.Command, args []string) error {
+	return updateStopwords(cmd, args, false)
+}
+
+// updateStopwords adds words to, or removes them from, the stopword list
+func updateStopwords(cmd *cobra.Command, words []string, add bool) error {
+	search, err := openStopwordsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	filter, err := search.GetTermFilter(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]bool)
+	for _, word := range filter.Stopwords {
+		set[word] = true
+	}
+
+	changed := 0
+	for _, word := range words {
+		// The index tokenizer lowercases terms
+		word = strings.ToLower(word)
+		if strings.ContainsAny(word, " \t\n") || word == "" {
+			return fmt.Errorf("invalid stopword: %q", word)
+		}
+		if set[word] != add {
+			set[word] = add
+			changed++
+		}
+	}
+
+	filter.Default = false
+	filter.Stopwords = filter.Stopwords[:0]
+	for word, ok := range set {
+		if ok {
+			filter.Stopwords = append(filter.Stopwords, word)
+		}
+	}
+	if err := search.SetTermFilter(cmd.Context(), filter); err != nil {
+		return err
+	}
+
+	if add {
+		fmt.Printf("Added %d stopword(s) to %s (%d in total)\n", changed, stopwordsDatabase, len(filter.Stopwords))
+	} else {
+		fmt.Printf("Removed %d stopword(s) from %s (%d in total)\n", changed, stopwordsDatabase, len(filter.Stopwords))
+	}
+	return nil
+}
+
+func runStopwordsMinLength(cmd *cobra.Command, args []string) error {
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid minimum term length: %s (expected a positive number)", args[0])
+	}
+
+	search, err := openStopwordsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	filter, err := search.GetTermFilter(cmd.Context())
+	if err != nil {
+		return err
+	}
+	filter.MinLength = n
+	if err := search.SetTermFilter(cmd.Context(), filter); err != nil {
+		return err
+	}
+
+	fmt.Printf("Terms shorter than %d characters are now left out of term statistics for %s\n", n, stopwordsDatabase)
+	return nil
+}
+
+func runStopwordsReset(cmd *cobra.Command, args []string) error {
+	search, err := openStopwordsDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	if err := search.SetTermFilter(cmd.Context(), nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored the built-in stopwords and minimum term length for %s\n", stopwordsDatabase)
+	return nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest <prefix>",
+	Short: "Suggest search terms starting with a prefix",
+	Long: `Suggest indexed terms that start with a prefix, most frequent first.
+
+Suggestions come from the full-text index vocabulary, so they only include
+words that actually appear in messages. The same suggestions are offered
+when completing search queries in the shell.
+
+Examples:
+  k8s-slack-searcher suggest admis -d sig-auth
+  k8s-slack-searcher suggest cert -d sig-auth -l 20`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	RunE:              runSuggest,
+}
+
+var (
+	suggestDatabase string
+	suggestLimit    int
+)
+
+func init() {
+	suggestCmd.Flags().StringVarP(&suggestDatabase, "database", "d", "",
+		"Database name (channel name) to take terms from (defaults to the active database)")
+	suggestCmd.Flags().IntVarP(&suggestLimit, "limit", "l", 10,
+		"Maximum number of suggestions to return")
+
+	suggestCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+}
+
+func runSuggest(cmd *cobra.Command, args []string) error {
+	prefix := args[0]
+
+	// Fall back to the active database when --database is omitted
+	if err := resolveDatabase(&suggestDatabase); err != nil {
+		return err
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(suggestDatabase) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", suggestDatabase)
+	}
+
+	search, err := searcher.NewSearcher(suggestDatabase)
+	if err != nil {
+		return fmt.Errorf("failed to create searcher: %w", err)
+	}
+	defer search.Close()
+
+	suggestions, err := search.Suggest(cmd.Context(), prefix, suggestLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(suggestions) == 0 {
+		fmt.Printf("No indexed terms start with: %s\n", prefix)
+		return nil
+	}
+
+	for _, suggestion := range suggestions {
+		fmt.Printf("  %-30s %6d messages %8d occurrences\n",
+			suggestion.Term, suggestion.Documents, suggestion.Occurrences)
+	}
+
+	return nil
+}
+
+// completeSearchTerms completes the last word of a search query from the
+// index vocabulary of the database named by --database or the active one
+func completeSearchTerms(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	dbName, _ := cmd.Flags().GetString("database")
+	if err := resolveDatabase(&dbName); err != nil || !searcher.ValidateDatabaseExists(dbName) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// Only the word being typed is completed; earlier words are kept
+	head, word := "", toComplete
+	if i := strings.LastIndexAny(toComplete, " \""); i >= 0 {
+		head, word = toComplete[:i+1], toComplete[i+1:]
+	}
+	if word == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer search.Close()
+
+	suggestions, err := search.Suggest(cmd.Context(), word, 20)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var completions []string
+	for _, suggestion := range suggestions {
+		completions = append(completions, head+suggestion.Term)
+	}
+
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
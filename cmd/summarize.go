@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/summarizer"
+
+	"github.com/spf13/cobra"
+)
+
+var summarizeCmd = &cobra.Command{
+	Use:   "summarize [query]",
+	Short: "Summarize a thread or search results with an LLM",
+	Long: `Summarize a thread, or the top results of a search query, into a short
+answer with citations back to specific messages.
+
+Any OpenAI-compatible chat completions API can be used, including local
+servers such as Ollama or llama.cpp. The endpoint, model and API key default
+to the OPENAI_BASE_URL, OPENAI_MODEL and OPENAI_API_KEY environment variables.
+Summaries are cached in the database so repeated requests are free.
+
+Examples:
+  k8s-slack-searcher summarize "bound service account tokens" --database sig-auth
+  k8s-slack-searcher summarize --thread 1684141200.000100 --database sig-auth
+  k8s-slack-searcher summarize "kubeadm upgrade" -d sig-auth --endpoint http://localhost:11434/v1 --model llama3`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSummarize,
+}
+
+var (
+	summarizeDatabase string
+	summarizeThread   string
+	summarizeLimit    int
+	summarizeEndpoint string
+	summarizeModel    string
+	summarizeNoCache  bool
+)
+
+func init() {
+	summarizeCmd.Flags().StringVarP(&summarizeDatabase, "database", "d", "",
+		"Database name (channel name) to use (defaults to the active database)")
+	summarizeCmd.Flags().StringVarP(&summarizeThread, "thread", "t", "",
+		"Timestamp of a thread starter message to summarize")
+	summarizeCmd.Flags().IntVarP(&summarizeLimit, "limit", "l", 10,
+		"Number of search results to summarize")
+	summarizeCmd.Flags().StringVar(&summarizeEndpoint, "endpoint", envOrDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+		"Base URL of an OpenAI-compatible API")
+	summarizeCmd.Flags().StringVar(&summarizeModel, "model", envOrDefault("OPENAI_MODEL", "gpt-4o-mini"),
+		"Model name to request")
+	summarizeCmd.Flags().BoolVar(&summarizeNoCache, "no-cache", false,
+		"Ignore cached summaries and generate a new one")
+
+	summarizeCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+}
+
+// envOrDefault returns the value of an environment variable, or a default
+// when it is unset
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func runSummarize(cmd *cobra.Command, args []string) error {
+	if (len(args) == 0) == (summarizeThread == "") {
+		return fmt.Errorf("provide either a query or --thread, but not both")
+	}
+
+	// Fall back to the active database when --database is omitted
+	if err := resolveDatabase(&summarizeDatabase); err != nil {
+		return err
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(summarizeDatabase) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", summarizeDatabase)
+	}
+
+	backend := summarizer.NewOpenAIBackend(summarizeEndpoint, os.Getenv("OPENAI_API_KEY"), summarizeModel)
+
+	s, err := summarizer.NewSummarizer(summarizeDatabase, backend)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.SetNoCache(summarizeNoCache)
+
+	var summary *summarizer.Summary
+	if summarizeThread != "" {
+		summary, err = s.SummarizeThread(cmd.Context(), summarizeThread)
+	} else {
+		summary, err = s.SummarizeQuery(cmd.Context(), args[0], summarizeLimit)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to summarize: %w", err)
+	}
+
+	fmt.Printf("%s\n\n", summary.Text)
+	fmt.Println("Sources:")
+	for i, message := range summary.Citations {
+		author := message.UserName
+		if message.UserRealName != "" {
+			author = fmt.Sprintf("%s (%s)", message.UserRealName, message.UserName)
+		}
+		if author == "" {
+			author = message.UserID
+		}
+		fmt.Printf("  [%d] %s, %s (ts %s)\n", i+1, author, message.Date.Format("2006-01-02 15:04"), message.Timestamp)
+	}
+
+	if summary.Cached {
+		fmt.Println("\n(cached summary; use --no-cache to regenerate)")
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/exporter"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/query"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Tag messages and threads for triage",
+	Long: `Tag messages, or whole threads, with labels such as needs-doc, then
+filter searches by tag and export everything carrying a tag.
+
+Tags are stored in the channel's database. Messages are given by Slack
+timestamp, permalink timestamp, full permalink, or the number of a result
+from the last search.
+
+Examples:
+  k8s-slack-searcher tag add 1684141200.000100 needs-doc -d sig-auth
+  k8s-slack-searcher tag add 3 needs-doc --thread
+  k8s-slack-searcher search "token" --tag needs-doc -d sig-auth
+  k8s-slack-searcher search "tag:needs-doc from:@liggitt" -d sig-auth
+  k8s-slack-searcher tag export needs-doc -d sig-auth --format csv -o needs-doc.csv`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <ts|result#> <tag>...",
+	Short: "Tag a message or its thread",
+	Long: `Tag a message. With --thread the tag applies to the message's whole
+thread, including replies posted later.`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeTagArgs,
+	RunE:              runTagAdd,
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:               "remove <ts|result#> <tag>...",
+	Short:             "Remove tags from a message and its thread",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeTagArgs,
+	RunE:              runTagRemove,
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list [ts|result#]",
+	Short: "List the tags in a database, or on a message",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runTagList,
+}
+
+var tagExportCmd = &cobra.Command{
+	Use:               "export <tag>",
+	Short:             "Export the messages carrying a tag",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTagArgs,
+	RunE:              runTagExport,
+}
+
+var (
+	tagDatabase     string
+	tagThread       bool
+	tagFormat       string
+	tagOutput       string
+	tagWorkspaceURL string
+)
+
+func init() {
+	for _, c := range []*cobra.Command{tagAddCmd, tagRemoveCmd, tagListCmd, tagExportCmd} {
+		c.Flags().StringVarP(&tagDatabase, "database", "d", "",
+			"Database name (channel name) holding the messages (defaults to the active database, or the last search's for result numbers)")
+		c.RegisterFlagCompletionFunc("database", completeDatabases)
+	}
+	tagAddCmd.Flags().BoolVarP(&tagThread, "thread", "t", false,
+		"Tag the message's whole thread")
+	tagExportCmd.Flags().StringVarP(&tagFormat, "format", "f", exporter.TaggedFormatMarkdown,
+		fmt.Sprintf("Export format (%s)", strings.Join(exporter.TaggedFormats, "|")))
+	tagExportCmd.Flags().StringVarP(&tagOutput, "output", "o", "",
+		"Write the export to a file instead of stdout")
+	tagExportCmd.Flags().StringVar(&tagWorkspaceURL, "workspace-url", slacktext.DefaultWorkspaceURL,
+		"Slack workspace URL used to build permalinks")
+
+	tagExportCmd.RegisterFlagCompletionFunc("format", completeValues(exporter.TaggedFormats...))
+
+	tagCmd.AddCommand(tagAddCmd, tagRemoveCmd, tagListCmd, tagExportCmd)
+}
+
+// completeTagArgs completes tag names already used in the database
+func completeTagArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if cmd.Name() != "export" && len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	dbName := tagDatabase
+	if err := resolveDatabase(&dbName); err != nil || !searcher.ValidateDatabaseExists(dbName) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer search.Close()
+
+	tags, err := search.GetTags(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Tag)
+	}
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// loadTagTarget loads the message a tag command refers to: a result number
+// from the last search, or a timestamp in --database
+func loadTagTarget(cmd *cobra.Command, arg string) (*searcher.Searcher, *models.Message, error) {
+	if _, err := strconv.Atoi(arg); err == nil && tagDatabase == "" {
+		return loadResult(cmd, arg)
+	}
+
+	ts, err := slacktext.ParseTimestamp(arg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	search, err := openTagDatabase()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message, err := search.GetMessageByTimestamp(cmd.Context(), ts)
+	if err != nil {
+		search.Close()
+		return nil, nil, err
+	}
+
+	return search, message, nil
+}
+
+// openTagDatabase opens --database, or the active database
+func openTagDatabase() (*searcher.Searcher, error) {
+	if err := resolveDatabase(&tagDatabase); err != nil {
+		return nil, err
+	}
+
+	if !searcher.ValidateDatabaseExists(tagDatabase) {
+		return nil, fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", tagDatabase)
+	}
+
+	search, err := searcher.NewSearcher(tagDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return search, nil
+}
+
+// parseTags validates the tags given on the command line
+func parseTags(values []string) ([]string, error) {
+	var tags []string
+	for _, value := range values {
+		tag, err := query.ParseTag(value)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func runTagAdd(cmd *cobra.Command, args []string) error {
+	tags, err := parseTags(args[1:])
+	if err != nil {
+		return err
+	}
+
+	search, message, err := loadTagTarget(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	for _, tag := range tags {
+		if err := search.AddTag(cmd.Context(), message, tag, tagThread); err != nil {
+			return err
+		}
+	}
+
+	target := "message " + message.Timestamp
+	if tagThread {
+		target = "thread of " + message.Timestamp
+	}
+	fmt.Printf("Tagged %s with %s\n", target, strings.Join(tags, ", "))
+	return nil
+}
+
+func runTagRemove(cmd *cobra.Command, args []string) error {
+	tags, err := parseTags(args[1:])
+	if err != nil {
+		return err
+	}
+
+	search, message, err := loadTagTarget(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	removed := 0
+	for _, tag := range tags {
+		n, err := search.RemoveTag(cmd.Context(), message, tag)
+		if err != nil {
+			return err
+		}
+		removed += n
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("message %s has none of the tags %s", message.Timestamp, strings.Join(tags, ", "))
+	}
+	fmt.Printf("Removed %d tag(s) from %s\n", removed, message.Timestamp)
+	return nil
+}
+
+func runTagList(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		search, message, err := loadTagTarget(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		defer search.Close()
+
+		tags, err := search.GetMessageTags(cmd.Context(), message)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			fmt.Printf("Message %s has no tags\n", message.Timestamp)
+			return nil
+		}
+		fmt.Println(strings.Join(tags, "\n"))
+		return nil
+	}
+
+	search, err := openTagDatabase()
+	if err != nil {
+		return err
+	}
+	defer search.Close()
+
+	tags, err := search.GetTags(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Printf("No tags in %s\n", tagDatabase)
+		return nil
+	}
+
+	fmt.Printf("Tags in %s (%d):\n\n", tagDatabase, len(tags))
+	for _, tag := range tags {
+		fmt.Printf("  %-30s %4d message(s) %4d thread(s)\n", tag.Tag, tag.Messages, tag.Threads)
+	}
+	return nil
+}
+
+func runTagExport(cmd *cobra.Command, args []string) error {
+	tag, err := query.ParseTag(args[0])
+	if err != nil {
+		return err
+	}
+	if tagFormat != exporter.TaggedFormatMarkdown && tagFormat != exporter.TaggedFormatJSON && tagFormat != exporter.TaggedFormatCSV {
+		return fmt.Errorf("unsupported export format: %s (supported: %s)", tagFormat, strings.Join(exporter.TaggedFormats, ", "))
+	}
+
+	if err := resolveDatabase(&tagDatabase); err != nil {
+		return err
+	}
+	if !searcher.ValidateDatabaseExists(tagDatabase) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", tagDatabase)
+	}
+
+	exp, err := exporter.NewExporter(tagDatabase)
+	if err != nil {
+		return err
+	}
+	defer exp.Close()
+
+	var w io.Writer = os.Stdout
+	if tagOutput != "" {
+		file, err := os.Create(tagOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := exp.ExportTagged(cmd.Context(), w, tag, tagFormat, tagWorkspaceURL)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	if tagOutput != "" {
+		fmt.Printf("Exported %d message(s) tagged %s to: %s\n", count, tag, tagOutput)
+		noteExported(tagOutput)
+	}
+	return nil
+}
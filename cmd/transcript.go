@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/audit"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/transcript"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var transcriptPath string
+
+// transcriptEntry collects what the running command adds to the
+// transcript; commands that note nothing leave no entry
+var transcriptEntry *transcript.Entry
+
+// registerTranscriptFlag adds the flag keeping a research transcript
+func registerTranscriptFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&transcriptPath, "transcript", "",
+		fmt.Sprintf("Append the searches run, their results and the messages opened and files exported to this Markdown log (default $%s)", transcript.EnvPath))
+}
+
+// noteSearch adds a search and its results to the transcript entry of the
+// running command
+func noteSearch(query string, databases []string, total int, results []*models.SearchResult) {
+	entry := currentTranscriptEntry()
+	entry.Query = query
+	entry.Databases = databases
+	entry.Total = total
+	for _, result := range results {
+		if len(entry.Results) == transcript.MaxResults {
+			break
+		}
+		database := result.Database
+		if database == "" {
+			database = databases[0]
+		}
+		entry.Results = append(entry.Results, transcriptMessage(database, &result.Message))
+	}
+}
+
+// noteOpened adds a message the running command showed to the transcript
+func noteOpened(database string, message *models.Message) {
+	entry := currentTranscriptEntry()
+	entry.Opened = append(entry.Opened, transcriptMessage(database, message))
+}
+
+// noteExported adds a file the running command wrote to the transcript
+func noteExported(path string) {
+	entry := currentTranscriptEntry()
+	entry.Exported = append(entry.Exported, path)
+}
+
+func currentTranscriptEntry() *transcript.Entry {
+	if transcriptEntry == nil {
+		transcriptEntry = &transcript.Entry{Time: time.Now()}
+	}
+	return transcriptEntry
+}
+
+func transcriptMessage(database string, message *models.Message) transcript.Message {
+	user := message.UserName
+	if user == "" {
+		user = message.UserID
+	}
+	return transcript.Message{
+		Database:  database,
+		Timestamp: message.Timestamp,
+		User:      user,
+		Date:      message.Date,
+		Text:      message.Text,
+	}
+}
+
+// WriteTranscript appends what the command that just ran noted to the
+// transcript, when one is being kept. A failure to write is only a
+// warning, since the command itself succeeded.
+func WriteTranscript(cmd *cobra.Command, args []string) error {
+	path := transcriptPath
+	if path == "" {
+		path = os.Getenv(transcript.EnvPath)
+	}
+	if path == "" || transcriptEntry == nil {
+		return nil
+	}
+
+	transcriptEntry.Command = commandLine(os.Args)
+	if err := transcript.Append(path, audit.CurrentUser(), *transcriptEntry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// commandLine quotes a command's arguments so it can be pasted into a
+// shell, leaving out --transcript
+func commandLine(args []string) string {
+	var words []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--transcript" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--transcript=") {
+			continue
+		}
+		if i == 0 {
+			arg = "k8s-slack-searcher"
+		}
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote quotes s for a POSIX shell unless it needs no quoting
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
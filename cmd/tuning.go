@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	sqliteCacheSizeMB int
+	sqliteMmapSizeMB  int
+	sqliteSynchronous string
+	sqliteTempStore   string
+	sqliteJournalMode string
+)
+
+// registerTuningFlags adds the flags overriding the SQLite settings
+func registerTuningFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&sqliteCacheSizeMB, "sqlite-cache-mb", 0,
+		fmt.Sprintf("SQLite page cache per connection in MB (default %d, %d for ingest)",
+			database.DefaultTuning.CacheSizeMB, database.IngestTuning.CacheSizeMB))
+	flags.IntVar(&sqliteMmapSizeMB, "sqlite-mmap-mb", 0,
+		fmt.Sprintf("Size of the database file read through memory-mapped I/O in MB, 0 to disable (default %d)",
+			database.DefaultTuning.MmapSizeMB))
+	flags.StringVar(&sqliteSynchronous, "sqlite-synchronous", "",
+		fmt.Sprintf("SQLite synchronous mode (%s; default %s, %s for ingest)",
+			strings.Join(database.SynchronousModes, "|"), database.DefaultTuning.Synchronous, database.IngestTuning.Synchronous))
+	flags.StringVar(&sqliteTempStore, "sqlite-temp-store", "",
+		fmt.Sprintf("Where SQLite keeps temporary data (%s; %s for ingest, otherwise %s)",
+			strings.Join(database.TempStores, "|"), database.IngestTuning.TempStore, database.DefaultTuning.TempStore))
+	flags.StringVar(&sqliteJournalMode, "sqlite-journal-mode", "",
+		fmt.Sprintf("SQLite journal mode, kept by the database once set (%s; %s for ingest, otherwise unchanged)",
+			strings.Join(database.JournalModes, "|"), database.IngestTuning.JournalMode))
+}
+
+// applyTuning sets the SQLite settings databases are opened with: the
+// defaults for ingest or for other commands, overridden by the sqlite
+// section of the config file and then by the --sqlite-* flags
+func applyTuning(cmd *cobra.Command) error {
+	tuning := database.DefaultTuning
+	ingest := cmd == ingestCmd || cmd == ingestAllCmd
+	if ingest {
+		tuning = database.IngestTuning
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	overrides := cfg.SQLite.Default
+	if ingest {
+		overrides = cfg.SQLite.Ingest
+	}
+	if overrides.CacheSizeMB != nil {
+		tuning.CacheSizeMB = *overrides.CacheSizeMB
+	}
+	if overrides.MmapSizeMB != nil {
+		tuning.MmapSizeMB = *overrides.MmapSizeMB
+	}
+	if overrides.Synchronous != "" {
+		tuning.Synchronous = strings.ToLower(overrides.Synchronous)
+	}
+	if overrides.TempStore != "" {
+		tuning.TempStore = strings.ToLower(overrides.TempStore)
+	}
+	if overrides.JournalMode != "" {
+		tuning.JournalMode = strings.ToLower(overrides.JournalMode)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("sqlite-cache-mb") {
+		tuning.CacheSizeMB = sqliteCacheSizeMB
+	}
+	if flags.Changed("sqlite-mmap-mb") {
+		tuning.MmapSizeMB = sqliteMmapSizeMB
+	}
+	if flags.Changed("sqlite-synchronous") {
+		tuning.Synchronous = strings.ToLower(sqliteSynchronous)
+	}
+	if flags.Changed("sqlite-temp-store") {
+		tuning.TempStore = strings.ToLower(sqliteTempStore)
+	}
+	if flags.Changed("sqlite-journal-mode") {
+		tuning.JournalMode = strings.ToLower(sqliteJournalMode)
+	}
+
+	if err := database.SetTuning(tuning); err != nil {
+		return fmt.Errorf("invalid SQLite settings: %w", err)
+	}
+	return nil
+}
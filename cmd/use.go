@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var useCmd = &cobra.Command{
+	Use:   "use [database]",
+	Short: "Select the active database for later commands",
+	Long: `Record a database as active so later commands can omit --database.
+
+Without arguments, shows which database is currently active and where it
+came from. The active database is stored alongside the databases directory.
+A default can also be set with default_database in the config file.
+
+Examples:
+  k8s-slack-searcher use sig-auth
+  k8s-slack-searcher s "bound tokens"
+  k8s-slack-searcher use --clear`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runUse,
+}
+
+var (
+	useClear bool
+)
+
+func init() {
+	useCmd.Flags().BoolVar(&useClear, "clear", false,
+		"Clear the active database")
+}
+
+func runUse(cmd *cobra.Command, args []string) error {
+	state, err := config.LoadState()
+	if err != nil {
+		return err
+	}
+
+	if useClear {
+		state.ActiveDatabase = ""
+		if err := state.Save(); err != nil {
+			return err
+		}
+		fmt.Println("Active database cleared.")
+		return nil
+	}
+
+	if len(args) == 0 {
+		name, source, err := activeDatabase()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Println("No active database. Use 'k8s-slack-searcher use <database>' to select one.")
+			return nil
+		}
+		fmt.Printf("Active database: %s (%s)\n", name, source)
+		return nil
+	}
+
+	name := qualify(args[0])
+	if !searcher.ValidateDatabaseExists(name) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", name)
+	}
+
+	state.ActiveDatabase = name
+	if err := state.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Active database set to: %s\n", name)
+	return nil
+}
+
+// activeDatabase returns the database selected with use, falling back to
+// the config file default, along with a description of where it came from
+func activeDatabase() (string, string, error) {
+	state, err := config.LoadState()
+	if err != nil {
+		return "", "", err
+	}
+	if state.ActiveDatabase != "" {
+		return state.ActiveDatabase, "selected with use", nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return "", "", err
+	}
+	if cfg.DefaultDatabase != "" {
+		path, _ := config.Path()
+		return cfg.DefaultDatabase, "default from " + path, nil
+	}
+
+	return "", "", nil
+}
+
+// resolveDatabase fills in the database named by a --database flag with the
+// active database when the flag was not given
+func resolveDatabase(name *string) error {
+	if *name != "" {
+		*name = qualify(*name)
+		return nil
+	}
+
+	active, _, err := activeDatabase()
+	if err != nil {
+		return err
+	}
+	if active == "" {
+		return fmt.Errorf("no database specified: pass --database, run 'k8s-slack-searcher use <database>', or set default_database in the config file")
+	}
+
+	*name = qualify(active)
+	return nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Work with users in a channel database",
+	Long:  `Commands for looking up users stored in a channel database.`,
+}
+
+var usersSearchCmd = &cobra.Command{
+	Use:   "search <pattern>",
+	Short: "Find users matching a name",
+	Long: `Find users whose username, real name or display name contains the pattern.
+
+Matching is case-insensitive. Use this to find a user's ID before
+filtering message searches by them.
+
+Examples:
+  k8s-slack-searcher users search liggitt --database sig-auth
+  k8s-slack-searcher users search "jordan" --database sig-auth --limit 5`,
+	Args: cobra.ExactArgs(1),
+	RunE: runUsersSearch,
+}
+
+var usersHistoryCmd = &cobra.Command{
+	Use:   "history <user>",
+	Short: "Show the names a user has posted under over time",
+	Long: `Show each profile (username, real name and display name) a user has
+posted under, with the period messages were seen under it. The user can
+be given by ID or by any name they have used.
+
+Profiles come from the copy of the author's profile Slack keeps in each
+message, so they are recorded for messages ingested by this version
+onwards. Search results and thread views show the name an author went
+by when each message was posted, and from: filters match former names.
+
+Examples:
+  k8s-slack-searcher users history liggitt --database sig-auth
+  k8s-slack-searcher users history U0123ABCD --database sig-auth`,
+	Args: cobra.ExactArgs(1),
+	RunE: runUsersHistory,
+}
+
+var (
+	usersDatabaseName string
+	usersLimit        int
+)
+
+func init() {
+	usersSearchCmd.Flags().StringVarP(&usersDatabaseName, "database", "d", "",
+		"Database name (channel name) to search in (defaults to the active database)")
+	usersSearchCmd.Flags().IntVarP(&usersLimit, "limit", "l", 20,
+		"Maximum number of users to return")
+
+	usersSearchCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+
+	usersHistoryCmd.Flags().StringVarP(&usersDatabaseName, "database", "d", "",
+		"Database name (channel name) to look in (defaults to the active database)")
+	usersHistoryCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+
+	usersCmd.AddCommand(usersSearchCmd)
+	usersCmd.AddCommand(usersHistoryCmd)
+}
+
+func runUsersSearch(cmd *cobra.Command, args []string) error {
+	pattern := args[0]
+
+	// Fall back to the active database when --database is omitted
+	if err := resolveDatabase(&usersDatabaseName); err != nil {
+		return err
+	}
+
+	// Validate database exists
+	if !searcher.ValidateDatabaseExists(usersDatabaseName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", usersDatabaseName)
+	}
+
+	search, err := searcher.NewSearcher(usersDatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	users, err := search.SearchUsers(cmd.Context(), pattern, usersLimit)
+	if err != nil {
+		return fmt.Errorf("user search failed: %w", err)
+	}
+
+	if len(users) == 0 {
+		fmt.Printf("No users found matching: %s\n", pattern)
+		return nil
+	}
+
+	fmt.Printf("Found %d user(s) matching: %s\n\n", len(users), pattern)
+	for _, user := range users {
+		flags := ""
+		if user.IsBot {
+			flags += " [bot]"
+		}
+		if user.Deleted {
+			flags += " [deleted]"
+		}
+
+		fmt.Printf("  %-12s %-20s %s", user.ID, user.Name, user.RealName)
+		if user.DisplayName != "" && user.DisplayName != user.Name {
+			fmt.Printf(" (display: %s)", user.DisplayName)
+		}
+		fmt.Printf("%s\n", flags)
+	}
+
+	return nil
+}
+
+func runUsersHistory(cmd *cobra.Command, args []string) error {
+	user := strings.TrimPrefix(args[0], "@")
+
+	if err := resolveDatabase(&usersDatabaseName); err != nil {
+		return err
+	}
+
+	if !searcher.ValidateDatabaseExists(usersDatabaseName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", usersDatabaseName)
+	}
+
+	search, err := searcher.NewSearcher(usersDatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	profiles, err := search.UserProfiles(cmd.Context(), user)
+	if err != nil {
+		return fmt.Errorf("user history lookup failed: %w", err)
+	}
+
+	if len(profiles) == 0 {
+		fmt.Printf("No profile history recorded for: %s\n", user)
+		return nil
+	}
+
+	current := ""
+	for _, profile := range profiles {
+		if profile.UserID != current {
+			if current != "" {
+				fmt.Println()
+			}
+			current = profile.UserID
+			fmt.Printf("%s:\n", profile.UserID)
+		}
+
+		fmt.Printf("  %s to %s  %-20s %s", profile.FirstSeen.Local().Format("2006-01-02"),
+			profile.LastSeen.Local().Format("2006-01-02"), profile.Name, profile.RealName)
+		if profile.DisplayName != "" && profile.DisplayName != profile.Name {
+			fmt.Printf(" (display: %s)", profile.DisplayName)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
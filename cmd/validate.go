@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+
+	"github.com/spf13/cobra"
+)
+
+var validateSourceCmd = &cobra.Command{
+	Use:   "validate-source <dir>",
+	Short: "Check a Slack export directory before ingesting it",
+	Long: `Check a Slack export directory for problems before ingesting from it.
+
+The check reads users.json and the conversation metadata files
+(channels.json, groups.json, mpims.json, dms.json), and decodes every
+message file in each channel directory the way ingest does. It reports:
+
+  - missing or unparseable users.json and metadata files
+  - message files not named by date (YYYY-MM-DD.json) or not holding an
+    array of messages, which ingest would fail to index
+  - files ingest skips, and directories no metadata file lists
+  - how many messages of each subtype were found; bot_message is skipped
+    unless ingest is given --include-bots
+
+The command exits with an error when it finds a problem that would make
+ingest fail.
+
+Examples:
+  k8s-slack-searcher validate-source source-data
+  k8s-slack-searcher validate-source /exports/kubernetes --json`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: runValidateSource,
+}
+
+var validateSourceJSON bool
+
+func init() {
+	validateSourceCmd.Flags().BoolVar(&validateSourceJSON, "json", false,
+		"Output the results as JSON")
+}
+
+func runValidateSource(cmd *cobra.Command, args []string) error {
+	report, err := indexer.ValidateSource(args[0])
+	if err != nil {
+		return err
+	}
+
+	if validateSourceJSON {
+		// An export with nothing wrong lists no problems rather than null
+		if report.Problems == nil {
+			report.Problems = []string{}
+		}
+		if report.Warnings == nil {
+			report.Warnings = []string{}
+		}
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printSourceReport(report)
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("found %d problem(s) in %s that ingest can't work around", len(report.Problems), report.Dir)
+	}
+	return nil
+}
+
+// printSourceReport prints the results of the validate-source command
+func printSourceReport(report *indexer.SourceReport) {
+	fmt.Printf("Source: %s\n", report.Dir)
+	fmt.Printf("- Users: %d\n", report.Users)
+	fmt.Printf("- Conversations listed: %d\n", report.Conversations)
+	fmt.Printf("- Channel directories: %d\n", report.Channels)
+	fmt.Printf("- Message files: %d\n", report.MessageFiles)
+	fmt.Printf("- Messages: %d\n", report.Messages)
+
+	if len(report.Subtypes) > 0 {
+		fmt.Println("\nMessage subtypes:")
+		for _, name := range report.SubtypeNames() {
+			fmt.Printf("  %-24s %d\n", name, report.Subtypes[name])
+		}
+	}
+
+	if len(report.Problems) > 0 {
+		fmt.Printf("\nProblems (%d):\n", len(report.Problems))
+		for _, problem := range report.Problems {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+	if len(report.Warnings) > 0 {
+		fmt.Printf("\nWarnings (%d):\n", len(report.Warnings))
+		for _, warning := range report.Warnings {
+			fmt.Printf("  %s\n", warning)
+		}
+	}
+
+	if report.OK() {
+		fmt.Println("\nNo problems found")
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <database>",
+	Short: "Check a database for corruption and search index problems",
+	Long: `Run SQLite's integrity check on a channel database.
+
+With --fts, also check that the full-text search index matches the
+messages and channels it was built from: every message indexed once, no
+index rows left over from removed messages, and the index's own
+integrity check passing. Messages missing from the index can't be found
+by search. --repair rebuilds the index from the messages table when a
+problem is found.
+
+The command exits with an error when a problem is found, so it can be
+run after an ingest in scripts.
+
+Examples:
+  k8s-slack-searcher verify sig-auth
+  k8s-slack-searcher verify sig-auth --fts
+  k8s-slack-searcher verify sig-auth --fts --repair`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabaseArg,
+	RunE:              runVerify,
+}
+
+var (
+	verifyFTS    bool
+	verifyRepair bool
+	verifyJSON   bool
+)
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyFTS, "fts", false,
+		"Also compare the search index with the messages and channels tables")
+	verifyCmd.Flags().BoolVar(&verifyRepair, "repair", false,
+		"Rebuild the search index when --fts finds a problem")
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false,
+		"Output the results as JSON")
+}
+
+// verifyReport is the JSON form of the verify command's results
+type verifyReport struct {
+	Database string `json:"database"`
+	// Integrity lists the problems found by SQLite's integrity check
+	Integrity []string          `json:"integrity"`
+	FTS       *models.FTSReport `json:"fts,omitempty"`
+	Repaired  bool              `json:"repaired,omitempty"`
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	dbName := qualify(args[0])
+	ctx := cmd.Context()
+
+	if verifyRepair && !verifyFTS {
+		return fmt.Errorf("--repair requires --fts")
+	}
+
+	if !searcher.ValidateDatabaseExists(dbName) {
+		return fmt.Errorf("database not found: %s. Run 'k8s-slack-searcher list' to see available databases", dbName)
+	}
+
+	search, err := searcher.NewSearcher(dbName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer search.Close()
+
+	report := verifyReport{Database: dbName}
+	if report.Integrity, err = search.IntegrityCheck(ctx); err != nil {
+		return err
+	}
+	// A sound database lists no problems rather than null
+	if report.Integrity == nil {
+		report.Integrity = []string{}
+	}
+
+	if verifyFTS {
+		if report.FTS, err = search.VerifyFTS(ctx); err != nil {
+			return err
+		}
+		if verifyRepair && !report.FTS.Consistent() {
+			if err := search.RebuildFTS(ctx); err != nil {
+				return err
+			}
+			report.Repaired = true
+			if report.FTS, err = search.VerifyFTS(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if verifyJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printVerifyReport(report)
+	}
+
+	if len(report.Integrity) > 0 {
+		return fmt.Errorf("database %s failed its integrity check; restore it from a backup or ingest it again", dbName)
+	}
+	if report.FTS != nil && !report.FTS.Consistent() {
+		return fmt.Errorf("search index of %s is inconsistent; run 'k8s-slack-searcher verify %s --fts --repair' to rebuild it", dbName, dbName)
+	}
+	return nil
+}
+
+// printVerifyReport prints the results of the verify command
+func printVerifyReport(report verifyReport) {
+	fmt.Printf("Database: %s\n", report.Database)
+	if len(report.Integrity) == 0 {
+		fmt.Println("Integrity check: ok")
+	} else {
+		fmt.Println("Integrity check: failed")
+		for _, problem := range report.Integrity {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+
+	fts := report.FTS
+	if fts == nil {
+		return
+	}
+	if report.Repaired {
+		fmt.Println("\nSearch index rebuilt")
+	}
+	fmt.Println("\nSearch index:")
+	fmt.Printf("- Messages: %d (%d indexed)\n", fts.Messages, fts.IndexedMessages)
+	fmt.Printf("- Unindexed messages: %d\n", fts.Unindexed)
+	if len(fts.UnindexedSample) > 0 {
+		fmt.Printf("    e.g. %s\n", strings.Join(fts.UnindexedSample, ", "))
+	}
+	fmt.Printf("- Orphaned index rows: %d\n", fts.Orphaned)
+	fmt.Printf("- Channels: %d (%d indexed)\n", fts.Channels, fts.IndexedChannels)
+	if fts.IndexError == "" {
+		fmt.Println("- Index integrity check: ok")
+	} else {
+		fmt.Printf("- Index integrity check: %s\n", fts.IndexError)
+	}
+	if fts.Consistent() {
+		fmt.Println("Search index is consistent")
+	}
+}
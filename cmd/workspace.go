@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	workspaceName string
+	timezone      string
+)
+
+// RegisterPersistentFlags adds the flags shared by every command
+func RegisterPersistentFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&workspaceName, "workspace", "W", "",
+		"Workspace the databases belong to, for archives from several Slack workspaces")
+	flags.StringVar(&timezone, "tz", "",
+		"Time zone for displaying and interpreting dates, e.g. UTC or America/New_York (defaults to the local time zone)")
+	registerTuningFlags(flags)
+	registerTranscriptFlag(flags)
+}
+
+// ApplyPersistentFlags applies the shared flags before a command runs.
+// --tz replaces the process's local time zone, so every date printed,
+// written to a report or JSON, or parsed from a flag uses it.
+func ApplyPersistentFlags(cmd *cobra.Command, args []string) error {
+	if err := applyTuning(cmd); err != nil {
+		return err
+	}
+
+	if timezone == "" {
+		return nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid --tz %q: %w", timezone, err)
+	}
+	time.Local = location
+
+	return nil
+}
+
+// qualify applies --workspace to a database name unless the name already
+// names its workspace as "workspace/channel"
+func qualify(name string) string {
+	if workspaceName == "" || strings.Contains(name, "/") {
+		return name
+	}
+	return storagepaths.QualifiedName(workspaceName, name)
+}
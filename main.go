@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/raesene/k8s-slack-searcher/cmd"
 
@@ -26,8 +29,44 @@ then provide full-text search capabilities across the indexed content.
 
 Commands:
   ingest <channel>  Index a channel directory and create a database
+  ingest-all        Index every channel directory in parallel
+  validate-source   Check an export directory for problems before ingest
   search <query>    Search messages in a channel database
-  list              List available databases`,
+  show <n>          Show result n of the last search in full
+  thread <n>        Show the thread containing result n of the last search
+  open <n>          Open result n of the last search in Slack
+  feedback <n> <f>  Mark result n of the last search as helpful or not
+  get <db> <ts>     Fetch a message by its Slack timestamp or permalink
+  similar <db> <ts> Find messages similar to a given one
+  browse <db>       Read every message posted on a day
+  bookmark          Save messages to collections and export them
+  tag               Tag messages and threads, and export tagged sets
+  sets              List, show and combine saved result sets
+  stats <db>        Show statistics and activity charts for a database
+  query <db> <sql>  Run a read-only SQL query against a database
+  verify <db>       Check a database and its search index for problems
+  raw <db> <ts>     Show the source JSON of a message
+  media <db>        List the files imported into the media store
+  skipped <db>      Report the messages ingest skipped, with examples
+  suggest <prefix>  Suggest search terms starting with a prefix
+  stopwords         Configure the words left out of term statistics
+  list              List available databases
+  users search      Find users matching a name
+  channel <db>      Show channel metadata for a database
+  channels search   Find channels by name, topic or purpose
+  analyze           Produce activity reports for a database
+  digest            Generate a weekly digest for a channel
+  summarize         Summarize a thread or search results with an LLM
+  diff <old> <new>  Show messages in a new database that an older one lacks
+  merge <dst> <src> Combine several databases into one
+  backup <db>       Write a snapshot of a database to a file
+  restore <db> <f>  Replace a database with a backup
+  alerts            List and test alerts for newly ingested messages
+  cron              Run scheduled sync, optimize, digest and alert jobs
+  audit             Review the searches recorded in the audit log
+  slowlog           Show the slow searches recorded in the slow query log
+  export <db>       Export a channel database for use by other tools
+  use <db>          Select the database used when --database is omitted`,
 }
 
 var versionCmd = &cobra.Command{
@@ -42,14 +81,59 @@ var versionCmd = &cobra.Command{
 
 func init() {
 	// Add commands
+	cmd.RegisterPersistentFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentPreRunE = cmd.ApplyPersistentFlags
+	rootCmd.PersistentPostRunE = cmd.WriteTranscript
+
 	rootCmd.AddCommand(cmd.IngestCmd)
+	rootCmd.AddCommand(cmd.IngestAllCmd)
 	rootCmd.AddCommand(cmd.SearchCmd)
 	rootCmd.AddCommand(cmd.ListCmd)
+	rootCmd.AddCommand(cmd.UsersCmd)
+	rootCmd.AddCommand(cmd.ChannelCmd)
+	rootCmd.AddCommand(cmd.ChannelsCmd)
+	rootCmd.AddCommand(cmd.AnalyzeCmd)
+	rootCmd.AddCommand(cmd.DigestCmd)
+	rootCmd.AddCommand(cmd.SummarizeCmd)
+	rootCmd.AddCommand(cmd.ExportCmd)
+	rootCmd.AddCommand(cmd.UseCmd)
+	rootCmd.AddCommand(cmd.ShowCmd)
+	rootCmd.AddCommand(cmd.ThreadCmd)
+	rootCmd.AddCommand(cmd.OpenCmd)
+	rootCmd.AddCommand(cmd.SuggestCmd)
+	rootCmd.AddCommand(cmd.GetCmd)
+	rootCmd.AddCommand(cmd.SimilarCmd)
+	rootCmd.AddCommand(cmd.BrowseCmd)
+	rootCmd.AddCommand(cmd.StatsCmd)
+	rootCmd.AddCommand(cmd.DiffCmd)
+	rootCmd.AddCommand(cmd.MergeCmd)
+	rootCmd.AddCommand(cmd.BackupCmd)
+	rootCmd.AddCommand(cmd.RestoreCmd)
+	rootCmd.AddCommand(cmd.AuditCmd)
+	rootCmd.AddCommand(cmd.SlowlogCmd)
+	rootCmd.AddCommand(cmd.AlertsCmd)
+	rootCmd.AddCommand(cmd.FeedbackCmd)
+	rootCmd.AddCommand(cmd.BookmarkCmd)
+	rootCmd.AddCommand(cmd.TagCmd)
+	rootCmd.AddCommand(cmd.SetsCmd)
+	rootCmd.AddCommand(cmd.StopwordsCmd)
+	rootCmd.AddCommand(cmd.QueryCmd)
+	rootCmd.AddCommand(cmd.VerifyCmd)
+	rootCmd.AddCommand(cmd.RawCmd)
+	rootCmd.AddCommand(cmd.MediaCmd)
+	rootCmd.AddCommand(cmd.SkippedCmd)
+	rootCmd.AddCommand(cmd.GenFixtureCmd)
+	rootCmd.AddCommand(cmd.ValidateSourceCmd)
+	rootCmd.AddCommand(cmd.CronCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel in-flight work on Ctrl-C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Printf("Error: %v", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,172 @@
+// Package alerts checks saved searches against newly ingested messages and
+// notifies webhooks about matches
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/config"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/query"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+)
+
+// Webhook payload formats
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Formats lists the supported webhook payload formats
+var Formats = []string{FormatJSON, FormatSlack}
+
+// MaxMessages is the most messages included in a notification
+const MaxMessages = 20
+
+// Payload is the JSON body posted for an alert
+type Payload struct {
+	Alert    string `json:"alert"`
+	Query    string `json:"query"`
+	Database string `json:"database"`
+	// Count is the number of matching messages, which may exceed the
+	// number included
+	Count    int       `json:"count"`
+	Messages []Message `json:"messages"`
+}
+
+// Message is a matching message in a payload
+type Message struct {
+	Timestamp string    `json:"ts"`
+	Date      time.Time `json:"date"`
+	User      string    `json:"user"`
+	Text      string    `json:"text"`
+}
+
+// Validate checks that an alert is complete
+func Validate(alert config.Alert) error {
+	if alert.Name == "" {
+		return fmt.Errorf("alert has no name")
+	}
+	if alert.Query == "" || alert.Database == "" || alert.Webhook == "" {
+		return fmt.Errorf("alert %s needs a query, database and webhook", alert.Name)
+	}
+	if alert.Format != "" && alert.Format != FormatJSON && alert.Format != FormatSlack {
+		return fmt.Errorf("alert %s has unsupported format %q (supported: %s)", alert.Name, alert.Format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+// Check searches for messages matching the alert's query that were stored
+// after the message with ID afterID. The payload holds the latest limit
+// matches, oldest first. It returns nil when nothing matches.
+func Check(ctx context.Context, search *searcher.Searcher, alert config.Alert, afterID, limit int) (*Payload, error) {
+	parsed, err := query.Parse(alert.Query)
+	if err != nil {
+		return nil, fmt.Errorf("alert %s: %w", alert.Name, err)
+	}
+
+	filter := parsed.Filter()
+	filter.AfterID = afterID
+	filter.Sort = models.SortNewest
+
+	matchQuery := parsed.Text
+	if matchQuery != "" {
+		matchQuery, err = searcher.BuildMatchQuery(parsed.Text, searcher.QueryOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("alert %s: %w", alert.Name, err)
+		}
+	}
+
+	count, err := search.Count(ctx, matchQuery, filter)
+	if err != nil {
+		return nil, fmt.Errorf("alert %s: %w", alert.Name, err)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	results, err := search.SearchFiltered(ctx, matchQuery, filter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("alert %s: %w", alert.Name, err)
+	}
+
+	payload := &Payload{
+		Alert:    alert.Name,
+		Query:    alert.Query,
+		Database: alert.Database,
+		Count:    count,
+	}
+	for i := len(results) - 1; i >= 0; i-- {
+		result := results[i]
+		user := result.UserName
+		if user == "" {
+			user = result.UserID
+		}
+		payload.Messages = append(payload.Messages, Message{
+			Timestamp: result.Timestamp,
+			Date:      result.Date,
+			User:      user,
+			Text:      result.Text,
+		})
+	}
+
+	return payload, nil
+}
+
+// Send posts the payload to the alert's webhook, as JSON or as a Slack
+// incoming-webhook message
+func Send(ctx context.Context, client *http.Client, alert config.Alert, payload *Payload) error {
+	var body interface{} = payload
+	if alert.Format == FormatSlack {
+		body = map[string]string{"text": SlackText(payload)}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, alert.Webhook, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert %s: webhook request failed: %w", alert.Name, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert %s: webhook returned status %s", alert.Name, resp.Status)
+	}
+
+	return nil
+}
+
+// SlackText formats a payload as Slack message markup
+func SlackText(payload *Payload) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("*%s*: %d new message(s) in #%s matching `%s`\n",
+		payload.Alert, payload.Count, payload.Database, payload.Query))
+	for _, message := range payload.Messages {
+		line := strings.ReplaceAll(message.Text, "\n", " ")
+		if len(line) > 300 {
+			line = line[:297] + "..."
+		}
+		text.WriteString(fmt.Sprintf(">%s *%s*: %s\n", message.Date.Format("2006-01-02 15:04"), message.User, line))
+	}
+	if more := payload.Count - len(payload.Messages); more > 0 {
+		text.WriteString(fmt.Sprintf("_and %d more_\n", more))
+	}
+	return text.String()
+}
@@ -0,0 +1,48 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+type Analyzer struct {
+	db *database.DB
+}
+
+// NewAnalyzer creates a new analyzer for a specific database
+func NewAnalyzer(channelName string) (*Analyzer, error) {
+	db, err := database.NewDB(channelName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Analyzer{db: db}, nil
+}
+
+// NewAnalyzerInDir creates a new analyzer for a database stored under dir
+// rather than the default databases directory
+func NewAnalyzerInDir(dir, channelName string) (*Analyzer, error) {
+	db, err := database.NewDBInDir(dir, channelName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Analyzer{db: db}, nil
+}
+
+// Close closes the analyzer and database connection
+func (a *Analyzer) Close() error {
+	return a.db.Close()
+}
+
+// Leaderboard returns the most active users, optionally for a single year
+func (a *Analyzer) Leaderboard(ctx context.Context, year, limit int) (*models.Leaderboard, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	return a.db.GetLeaderboard(ctx, year, limit)
+}
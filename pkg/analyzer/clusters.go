@@ -0,0 +1,176 @@
+package analyzer
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// numHashes is the MinHash signature length; more hashes give a more
+// accurate similarity estimate at the cost of speed
+const numHashes = 64
+
+// ClusterOptions controls near-duplicate clustering
+type ClusterOptions struct {
+	// Threshold is the minimum estimated Jaccard similarity for two messages
+	// to be placed in the same cluster
+	Threshold float64
+	// MaxMessages bounds how many matching messages are considered
+	MaxMessages int
+	// MinSize is the smallest cluster reported
+	MinSize int
+}
+
+// Clusters groups messages matching a query into clusters of similar text
+// using MinHash signatures over word shingles, largest clusters first
+func (a *Analyzer) Clusters(ctx context.Context, query string, opts ClusterOptions) ([]models.Cluster, error) {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 0.5
+	}
+	if opts.MaxMessages <= 0 {
+		opts.MaxMessages = 1000
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = 2
+	}
+
+	results, err := a.db.SearchMessages(ctx, query, opts.MaxMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*models.Message, len(results))
+	signatures := make([][numHashes]uint64, len(results))
+	for i, result := range results {
+		message := result.Message
+		message.Filename = result.Filename
+		messages[i] = &message
+		signatures[i] = minHash(shingles(message.Text))
+	}
+
+	// Union messages whose estimated similarity meets the threshold
+	parent := make([]int, len(messages))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range signatures {
+		for j := i + 1; j < len(signatures); j++ {
+			if similarity(&signatures[i], &signatures[j]) >= opts.Threshold {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := make(map[int][]*models.Message)
+	for i, message := range messages {
+		root := find(i)
+		groups[root] = append(groups[root], message)
+	}
+
+	clusters := []models.Cluster{}
+	for _, group := range groups {
+		if len(group) < opts.MinSize {
+			continue
+		}
+
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Date.Before(group[j].Date)
+		})
+
+		clusters = append(clusters, models.Cluster{
+			Size:           len(group),
+			FirstSeen:      group[0].Date,
+			LastSeen:       group[len(group)-1].Date,
+			Representative: group[0],
+			Messages:       group,
+		})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Size != clusters[j].Size {
+			return clusters[i].Size > clusters[j].Size
+		}
+		return clusters[i].FirstSeen.Before(clusters[j].FirstSeen)
+	})
+
+	return clusters, nil
+}
+
+// shingles returns the set of overlapping word pairs in normalized text.
+// Very short texts fall back to single words.
+func shingles(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(words) < 2 {
+		return words
+	}
+
+	set := make(map[string]bool)
+	for i := 0; i+1 < len(words); i++ {
+		set[words[i]+" "+words[i+1]] = true
+	}
+
+	result := make([]string, 0, len(set))
+	for shingle := range set {
+		result = append(result, shingle)
+	}
+
+	return result
+}
+
+// minHash computes a MinHash signature for a set of shingles
+func minHash(shingles []string) [numHashes]uint64 {
+	var signature [numHashes]uint64
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+
+	for _, shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		base := h.Sum64()
+
+		for i := range signature {
+			if v := mix(base ^ uint64(i)*0x9e3779b97f4a7c15); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+
+	return signature
+}
+
+// mix is the splitmix64 finalizer, used to derive independent hash functions
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// similarity estimates the Jaccard similarity of two signatures
+func similarity(a, b *[numHashes]uint64) float64 {
+	matches := 0
+	for i := range a {
+		if a[i] == b[i] {
+			matches++
+		}
+	}
+	return float64(matches) / numHashes
+}
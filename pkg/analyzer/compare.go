@@ -0,0 +1,238 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	texttemplate "text/template"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+)
+
+// Compare profiles this channel and other side by side: messages per year,
+// each channel's limit most common terms and the users posting in both.
+// Users are matched by ID, so only channels from the same workspace share
+// users.
+func (a *Analyzer) Compare(ctx context.Context, other *Analyzer, limit int) (*models.ChannelComparison, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	left, err := a.profile(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	right, err := other.profile(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &models.ChannelComparison{
+		Left:        left.ChannelProfile,
+		Right:       right.ChannelProfile,
+		Years:       []models.YearComparison{},
+		SharedTerms: []string{},
+	}
+
+	// Years run continuously from the first message in either channel to
+	// the last, so quiet years show as zeros rather than gaps
+	first, last := 0, 0
+	for _, years := range []map[int]int{left.years, right.years} {
+		for year := range years {
+			if first == 0 || year < first {
+				first = year
+			}
+			if year > last {
+				last = year
+			}
+		}
+	}
+	for year := first; first != 0 && year <= last; year++ {
+		comparison.Years = append(comparison.Years, models.YearComparison{
+			Year:  year,
+			Left:  left.years[year],
+			Right: right.years[year],
+		})
+	}
+
+	rightTerms := make(map[string]bool)
+	for _, term := range right.TopTerms {
+		rightTerms[term.Term] = true
+	}
+	for _, term := range left.TopTerms {
+		if rightTerms[term.Term] {
+			comparison.SharedTerms = append(comparison.SharedTerms, term.Term)
+		}
+	}
+
+	comparison.Users = overlapUsers(left.users, right.users, limit)
+
+	return comparison, nil
+}
+
+// channelProfile is a channel's profile along with the per-year and
+// per-user counts needed to compare it
+type channelProfile struct {
+	models.ChannelProfile
+	years map[int]int
+	users map[string]*models.LeaderboardEntry
+}
+
+// profile counts the channel's messages, threads and posters and looks up
+// its most common terms
+func (a *Analyzer) profile(ctx context.Context, limit int) (*channelProfile, error) {
+	p := &channelProfile{
+		ChannelProfile: models.ChannelProfile{TopTerms: []models.TermSuggestion{}},
+		years:          make(map[int]int),
+		users:          make(map[string]*models.LeaderboardEntry),
+	}
+
+	err := a.db.ForEachMessage(ctx, func(message *models.Message) error {
+		p.Messages++
+		if message.ThreadTS != "" && message.ThreadTS == message.Timestamp {
+			p.Threads++
+		}
+
+		date := message.Date.Local()
+		p.years[date.Year()]++
+		if p.FirstMessage.IsZero() || date.Before(p.FirstMessage) {
+			p.FirstMessage = date
+		}
+		if date.After(p.LastMessage) {
+			p.LastMessage = date
+		}
+
+		if message.UserID == "" {
+			return nil
+		}
+		user, ok := p.users[message.UserID]
+		if !ok {
+			user = &models.LeaderboardEntry{UserID: message.UserID, UserName: message.UserName, UserRealName: message.UserRealName}
+			p.users[message.UserID] = user
+		}
+		user.Count++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.Users = len(p.users)
+
+	terms, err := a.db.GetTopTerms(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	p.TopTerms = append(p.TopTerms, terms...)
+
+	return p, nil
+}
+
+// overlapUsers counts the users in one or both channels and returns up to
+// limit shared users, most active across both channels first
+func overlapUsers(left, right map[string]*models.LeaderboardEntry, limit int) models.UserOverlap {
+	overlap := models.UserOverlap{TopShared: []models.SharedUser{}}
+
+	var shared []models.SharedUser
+	for id, user := range left {
+		other, ok := right[id]
+		if !ok {
+			overlap.LeftOnly++
+			continue
+		}
+		entry := models.SharedUser{UserID: id, UserName: user.UserName, UserRealName: user.UserRealName,
+			Left: user.Count, Right: other.Count}
+		if entry.UserName == "" {
+			entry.UserName, entry.UserRealName = other.UserName, other.UserRealName
+		}
+		shared = append(shared, entry)
+	}
+	overlap.Shared = len(shared)
+	overlap.RightOnly = len(right) - overlap.Shared
+
+	if total := overlap.LeftOnly + overlap.RightOnly + overlap.Shared; total > 0 {
+		overlap.Jaccard = float64(overlap.Shared) / float64(total)
+	}
+
+	sort.Slice(shared, func(i, j int) bool {
+		ti, tj := shared[i].Left+shared[i].Right, shared[j].Left+shared[j].Right
+		if ti != tj {
+			return ti > tj
+		}
+		return shared[i].UserID < shared[j].UserID
+	})
+	if len(shared) > limit {
+		shared = shared[:limit]
+	}
+	overlap.TopShared = append(overlap.TopShared, shared...)
+
+	return overlap
+}
+
+// compareFuncs returns the helper functions available to comparison
+// templates
+func compareFuncs() map[string]interface{} {
+	funcs := digestFuncs()
+	funcs["percent"] = func(f float64) string {
+		return fmt.Sprintf("%.0f%%", 100*f)
+	}
+	funcs["term"] = func(terms []models.TermSuggestion, i int) *models.TermSuggestion {
+		if i < len(terms) {
+			return &terms[i]
+		}
+		return nil
+	}
+	funcs["rows"] = func(left, right []models.TermSuggestion) []int {
+		n := len(left)
+		if len(right) > n {
+			n = len(right)
+		}
+		rows := make([]int, n)
+		for i := range rows {
+			rows[i] = i
+		}
+		return rows
+	}
+	return funcs
+}
+
+// GenerateCompareMarkdown renders a channel comparison as Markdown
+func GenerateCompareMarkdown(w io.Writer, comparison *models.ChannelComparison) error {
+	tmpl, err := texttemplate.New("compare.md").Funcs(compareFuncs()).ParseFS(templates, "templates/compare.md")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, comparison); err != nil {
+		return fmt.Errorf("failed to render comparison: %w", err)
+	}
+
+	return nil
+}
+
+// GenerateCompareHTML renders a channel comparison as a standalone HTML
+// page using one of the built-in report themes
+func GenerateCompareHTML(w io.Writer, comparison *models.ChannelComparison, theme string) error {
+	css, err := searcher.ThemeCSS(theme)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := htmltemplate.New("compare.html").Funcs(compareFuncs()).ParseFS(templates, "templates/compare.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	data := struct {
+		*models.ChannelComparison
+		ThemeCSS htmltemplate.CSS
+	}{comparison, css}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render comparison: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package analyzer
+
+import (
+	"context"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// NewMessages returns the messages in this database that are not in old,
+// matched by Slack timestamp. Messages are grouped by thread, so new
+// replies to a thread appear together.
+func (a *Analyzer) NewMessages(ctx context.Context, old *Analyzer) ([]*models.Message, error) {
+	seen, err := old.db.MessageTimestamps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []*models.Message
+	err = a.db.ForEachMessage(ctx, func(message *models.Message) error {
+		if !seen[message.Timestamp] {
+			added = append(added, message)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
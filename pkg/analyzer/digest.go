@@ -0,0 +1,179 @@
+package analyzer
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+)
+
+//go:embed templates/*
+var templates embed.FS
+
+// ParseISOWeek parses an ISO 8601 week such as "2024-W20" and returns the
+// Monday that starts the week and the Monday that starts the following week
+func ParseISOWeek(week string) (time.Time, time.Time, error) {
+	var year, number int
+	if _, err := fmt.Sscanf(strings.ToUpper(week), "%d-W%d", &year, &number); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid week %q, expected format YYYY-Www (e.g. 2024-W20)", week)
+	}
+
+	// Week 1 is the week containing January 4th
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	start := jan4.AddDate(0, 0, -offset+(number-1)*7)
+
+	if y, w := start.ISOWeek(); number < 1 || y != year || w != number {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid week %q: %d has no week %d", week, year, number)
+	}
+
+	return start, start.AddDate(0, 0, 7), nil
+}
+
+// Digest builds a summary of the given ISO week's activity, with at most
+// limit entries in each section
+func (a *Analyzer) Digest(ctx context.Context, week string, limit int) (*models.Digest, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+
+	start, end, err := ParseISOWeek(week)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &models.Digest{
+		Week:  week,
+		Start: start,
+		End:   end,
+	}
+
+	messages, err := a.db.GetMessagesInRange(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	digest.MessageCount = len(messages)
+	digest.TopLinks = topLinks(messages, limit)
+
+	digest.ActiveThreads, err = a.db.GetActiveThreads(ctx, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	digest.MostReacted, err = a.db.GetMostReactedMessages(ctx, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+// topLinks counts the links shared across messages and returns the most
+// frequently shared
+func topLinks(messages []*models.Message, limit int) []models.LinkCount {
+	counts := make(map[string]int)
+	for _, message := range messages {
+		for _, link := range slacktext.ExtractLinks(message.Text) {
+			counts[link]++
+		}
+	}
+
+	links := []models.LinkCount{}
+	for url, count := range counts {
+		links = append(links, models.LinkCount{URL: url, Count: count})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Count != links[j].Count {
+			return links[i].Count > links[j].Count
+		}
+		return links[i].URL < links[j].URL
+	})
+
+	if len(links) > limit {
+		links = links[:limit]
+	}
+
+	return links
+}
+
+// digestFuncs returns the helper functions available to digest templates
+func digestFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"inc": func(i int) int {
+			return i + 1
+		},
+		"formatDate": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04")
+		},
+		"formatDay": func(t time.Time) string {
+			return t.Format("2006-01-02")
+		},
+		"lastDay": func(t time.Time) time.Time {
+			return t.AddDate(0, 0, -1)
+		},
+		"author": func(realName, name string) string {
+			if realName != "" {
+				return fmt.Sprintf("%s (%s)", realName, name)
+			}
+			return name
+		},
+		"summary": summarize,
+	}
+}
+
+// summarize flattens message text to a single line of bounded length
+func summarize(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > 200 {
+		text = text[:197] + "..."
+	}
+	return text
+}
+
+// GenerateDigestMarkdown renders a digest as Markdown
+func GenerateDigestMarkdown(w io.Writer, digest *models.Digest) error {
+	tmpl, err := texttemplate.New("digest.md").Funcs(digestFuncs()).ParseFS(templates, "templates/digest.md")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, digest); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	return nil
+}
+
+// GenerateDigestHTML renders a digest as a standalone HTML page using one of
+// the built-in report themes
+func GenerateDigestHTML(w io.Writer, digest *models.Digest, theme string) error {
+	css, err := searcher.ThemeCSS(theme)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := htmltemplate.New("digest.html").Funcs(digestFuncs()).ParseFS(templates, "templates/digest.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	data := struct {
+		*models.Digest
+		ThemeCSS htmltemplate.CSS
+	}{digest, css}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	return nil
+}
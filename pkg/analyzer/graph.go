@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Graph export formats
+const (
+	GraphFormatGEXF = "gexf"
+	GraphFormatJSON = "json"
+)
+
+// GraphFormats lists the supported graph export formats
+var GraphFormats = []string{GraphFormatGEXF, GraphFormatJSON}
+
+// GraphOptions controls how the interaction graph is built
+type GraphOptions struct {
+	// Year restricts the graph to threads started in this year; zero
+	// includes every thread
+	Year int
+	// MinReplies drops edges with fewer replies, and users left without
+	// edges, to keep large channels readable
+	MinReplies int
+}
+
+// InteractionGraph builds a directed who-replies-to-whom graph from the
+// channel's threads. An edge runs from each replier to the user who started
+// the thread, weighted by the number of replies; replies to one's own
+// thread are not counted.
+func (a *Analyzer) InteractionGraph(ctx context.Context, opts GraphOptions) (*models.InteractionGraph, error) {
+	nodes := make(map[string]*models.GraphNode)
+	node := func(message *models.Message) *models.GraphNode {
+		n, ok := nodes[message.UserID]
+		if !ok {
+			n = &models.GraphNode{ID: message.UserID, Name: message.UserName, RealName: message.UserRealName}
+			if n.Name == "" {
+				n.Name = message.UserID
+			}
+			nodes[message.UserID] = n
+		}
+		return n
+	}
+
+	type edgeKey struct{ source, target string }
+	edges := make(map[edgeKey]*models.GraphEdge)
+	seen := make(map[edgeKey]string)
+
+	// Messages arrive grouped by thread, starter first
+	var starter *models.Message
+	err := a.db.ForEachMessage(ctx, func(message *models.Message) error {
+		if message.UserID == "" || message.ThreadTS == "" {
+			return nil
+		}
+		if message.ThreadTS == message.Timestamp {
+			starter = nil
+			if opts.Year == 0 || message.Date.UTC().Year() == opts.Year {
+				starter = message
+				node(message).ThreadsStarted++
+			}
+			return nil
+		}
+		if starter == nil || starter.Timestamp != message.ThreadTS || message.UserID == starter.UserID {
+			return nil
+		}
+
+		node(message).Replies++
+		key := edgeKey{message.UserID, starter.UserID}
+		edge, ok := edges[key]
+		if !ok {
+			edge = &models.GraphEdge{Source: key.source, Target: key.target}
+			edges[key] = edge
+		}
+		edge.Replies++
+		if seen[key] != starter.Timestamp {
+			seen[key] = starter.Timestamp
+			edge.Threads++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &models.InteractionGraph{Year: opts.Year, Nodes: []models.GraphNode{}, Edges: []models.GraphEdge{}}
+	connected := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.Replies < opts.MinReplies {
+			continue
+		}
+		graph.Edges = append(graph.Edges, *edge)
+		connected[edge.Source] = true
+		connected[edge.Target] = true
+	}
+	for id, n := range nodes {
+		if connected[id] {
+			graph.Nodes = append(graph.Nodes, *n)
+		}
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Replies != graph.Edges[j].Replies {
+			return graph.Edges[i].Replies > graph.Edges[j].Replies
+		}
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+		return graph.Edges[i].Target < graph.Edges[j].Target
+	})
+
+	return graph, nil
+}
+
+// WriteGraph writes an interaction graph as GEXF, for Gephi, or as JSON
+// with nodes and links arrays, for D3's force layout
+func WriteGraph(w io.Writer, graph *models.InteractionGraph, format string) error {
+	switch format {
+	case GraphFormatGEXF:
+		return writeGEXF(w, graph)
+	case GraphFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(graph)
+	default:
+		return fmt.Errorf("unsupported graph format: %s (supported: %s)", format, strings.Join(GraphFormats, ", "))
+	}
+}
+
+// GEXF 1.3 document structure, limited to what the graph uses
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	Creator     string `xml:"creator"`
+	Description string `xml:"description"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string          `xml:"id,attr"`
+	Label     string          `xml:"label,attr"`
+	AttValues []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID        int             `xml:"id,attr"`
+	Source    string          `xml:"source,attr"`
+	Target    string          `xml:"target,attr"`
+	Weight    int             `xml:"weight,attr"`
+	AttValues []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttrValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func writeGEXF(w io.Writer, graph *models.InteractionGraph) error {
+	description := "Who replies to whom in " + graph.Database + " threads"
+	if graph.Year != 0 {
+		description += fmt.Sprintf(" started in %d", graph.Year)
+	}
+
+	doc := gexfDocument{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta:    gexfMeta{Creator: "k8s-slack-searcher", Description: description},
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Attributes: []gexfAttributes{
+				{Class: "node", Attributes: []gexfAttribute{
+					{ID: "real_name", Title: "Real name", Type: "string"},
+					{ID: "threads_started", Title: "Threads started", Type: "integer"},
+					{ID: "replies", Title: "Replies", Type: "integer"},
+				}},
+				{Class: "edge", Attributes: []gexfAttribute{
+					{ID: "threads", Title: "Threads", Type: "integer"},
+				}},
+			},
+		},
+	}
+
+	for _, n := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:    n.ID,
+			Label: n.Name,
+			AttValues: []gexfAttrValue{
+				{For: "real_name", Value: n.RealName},
+				{For: "threads_started", Value: fmt.Sprint(n.ThreadsStarted)},
+				{For: "replies", Value: fmt.Sprint(n.Replies)},
+			},
+		})
+	}
+	for i, e := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:        i,
+			Source:    e.Source,
+			Target:    e.Target,
+			Weight:    e.Replies,
+			AttValues: []gexfAttrValue{{For: "threads", Value: fmt.Sprint(e.Threads)}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// responseBuckets are the upper bounds of the time-to-first-reply ranges
+// reported; answers slower than the last fall into a final bucket
+var responseBuckets = []struct {
+	label string
+	limit time.Duration
+}{
+	{"under 15 minutes", 15 * time.Minute},
+	{"15 minutes to 1 hour", time.Hour},
+	{"1 to 4 hours", 4 * time.Hour},
+	{"4 to 24 hours", 24 * time.Hour},
+	{"1 to 7 days", 7 * 24 * time.Hour},
+}
+
+// ResponseTimes measures the time from the start of each thread to its
+// first reply by someone else, overall and for each month threads started
+// in (in the local time zone). A year of zero includes every thread.
+func (a *Analyzer) ResponseTimes(ctx context.Context, year int) (*models.ResponseTimes, error) {
+	threads, err := a.db.GetFirstReplies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ResponseTimes{Months: []models.MonthlyResponses{}}
+	for _, bucket := range responseBuckets {
+		report.Buckets = append(report.Buckets, models.ResponseBucket{Label: bucket.label})
+	}
+	report.Buckets = append(report.Buckets, models.ResponseBucket{Label: "over 7 days"})
+
+	var all []time.Duration
+	monthly := make(map[string][]time.Duration)
+	monthThreads := make(map[string]int)
+	var months []string
+
+	for _, thread := range threads {
+		started := thread.Started.Local()
+		if year != 0 && started.Year() != year {
+			continue
+		}
+
+		month := started.Format("2006-01")
+		if _, ok := monthThreads[month]; !ok {
+			months = append(months, month)
+		}
+		monthThreads[month]++
+		report.Threads++
+
+		if thread.FirstReply.IsZero() {
+			continue
+		}
+		wait := thread.FirstReply.Sub(thread.Started)
+		all = append(all, wait)
+		monthly[month] = append(monthly[month], wait)
+
+		i := sort.Search(len(responseBuckets), func(i int) bool {
+			return wait < responseBuckets[i].limit
+		})
+		report.Buckets[i].Count++
+	}
+
+	report.ResponseSummary = summarizeResponses(report.Threads, all)
+	for _, month := range months {
+		report.Months = append(report.Months, models.MonthlyResponses{
+			Month:           month,
+			ResponseSummary: summarizeResponses(monthThreads[month], monthly[month]),
+		})
+	}
+
+	return report, nil
+}
+
+// summarizeResponses computes the median, 90th percentile and mean of the
+// waits for first replies to a number of threads
+func summarizeResponses(threads int, waits []time.Duration) models.ResponseSummary {
+	summary := models.ResponseSummary{Threads: threads, Answered: len(waits)}
+	if len(waits) == 0 {
+		return summary
+	}
+
+	sorted := append([]time.Duration(nil), waits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, wait := range sorted {
+		total += wait
+	}
+
+	summary.MedianSeconds = int64(percentile(sorted, 50).Seconds())
+	summary.P90Seconds = int64(percentile(sorted, 90).Seconds())
+	summary.MeanSeconds = int64((total / time.Duration(len(sorted))).Seconds())
+	return summary
+}
+
+// percentile returns the p-th percentile of sorted waits by the nearest
+// rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
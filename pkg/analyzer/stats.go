@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Stats summarises a channel database: totals, the span of activity, daily
+// message counts and the top posters
+func (a *Analyzer) Stats(ctx context.Context, limit int) (*models.ChannelStats, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	counts, err := a.db.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := a.db.GetDailyMessageCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderboard, err := a.db.GetLeaderboard(ctx, 0, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top posters: %w", err)
+	}
+
+	stats := &models.ChannelStats{
+		Users:      counts["users"],
+		Channels:   counts["channels"],
+		Messages:   counts["messages"],
+		ActiveDays: len(daily),
+		Daily:      daily,
+		TopPosters: leaderboard.TopPosters,
+	}
+	if len(daily) > 0 {
+		stats.FirstDay = daily[0].Date
+		stats.LastDay = daily[len(daily)-1].Date
+	}
+	for _, day := range daily {
+		if day.Count > stats.BusiestDay.Count {
+			stats.BusiestDay = day
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/identifiers"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Versions reports each Kubernetes minor version mentioned in the channel:
+// how many messages mention it, when it was first and last mentioned, and
+// the month (in the local time zone) its discussion peaked. Versions are
+// listed oldest release first.
+func (a *Analyzer) Versions(ctx context.Context) (*models.VersionReport, error) {
+	mentions, err := a.db.GetVersionMentions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := make(map[string]*models.VersionActivity)
+	for _, mention := range mentions {
+		date := mention.Date.Local()
+		v, ok := activity[mention.Version]
+		if !ok {
+			v = &models.VersionActivity{Version: mention.Version, First: date}
+			activity[mention.Version] = v
+		}
+		v.Messages++
+		v.Last = date
+
+		// Mentions arrive oldest first, so months do too
+		month := date.Format("2006-01")
+		if n := len(v.Months); n > 0 && v.Months[n-1].Month == month {
+			v.Months[n-1].Messages++
+		} else {
+			v.Months = append(v.Months, models.MonthCount{Month: month, Messages: 1})
+		}
+	}
+
+	report := &models.VersionReport{Versions: []models.VersionActivity{}}
+	for _, v := range activity {
+		for _, month := range v.Months {
+			// The earliest month wins a tie
+			if month.Messages > v.PeakMessages {
+				v.PeakMonth, v.PeakMessages = month.Month, month.Messages
+			}
+		}
+		report.Versions = append(report.Versions, *v)
+	}
+	sort.Slice(report.Versions, func(i, j int) bool {
+		return identifiers.CompareVersions(report.Versions[i].Version, report.Versions[j].Version) < 0
+	})
+
+	return report, nil
+}
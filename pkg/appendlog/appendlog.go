@@ -0,0 +1,83 @@
+// Package appendlog appends records to log files that several processes
+// may write at once, such as the audit log, the slow query log and
+// research transcripts, and reads back logs of JSON lines
+package appendlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// maxLineSize bounds the length of a line ReadJSON accepts
+const maxLineSize = 1024 * 1024
+
+// Append adds to the file at path, creating it readable only by the owner
+// if needed. build returns the bytes to add, and is told whether the file
+// is empty so a new file can start with a heading. They are added in a
+// single write, which keeps concurrent appends from interleaving, and
+// synced to disk before Append returns.
+func Append(path string, build func(empty bool) []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(build(info.Size() == 0)); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// AppendJSON adds v to the file at path as one line of JSON
+func AppendJSON(path string, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return Append(path, func(bool) []byte {
+		return append(line, '\n')
+	})
+}
+
+// ReadJSON returns the values in a file written by AppendJSON, oldest
+// first, skipping blank lines. A missing file has no values.
+func ReadJSON[T any](path string) ([]T, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var values []T
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	number := 0
+	for scanner.Scan() {
+		number++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var value T
+		if err := json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, number, err)
+		}
+		values = append(values, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
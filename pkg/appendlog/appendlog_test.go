@@ -0,0 +1,104 @@
+package appendlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type record struct {
+	N    int    `json:"n"`
+	Text string `json:"text"`
+}
+
+func TestAppendJSONConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+
+	// Long lines would interleave if an append took several writes
+	text := strings.Repeat("x", 32*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if err := AppendJSON(path, record{N: n, Text: text}); err != nil {
+				t.Errorf("append failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	records, err := ReadJSON[record](path)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if len(records) != 20 {
+		t.Fatalf("read %d records, want 20", len(records))
+	}
+	seen := make(map[int]bool)
+	for _, r := range records {
+		if r.Text != text || seen[r.N] {
+			t.Fatalf("record %d read back wrong or twice", r.N)
+		}
+		seen[r.N] = true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("log has mode %o, want 600", perm)
+	}
+}
+
+func TestAppendHeading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript.md")
+	build := func(empty bool) []byte {
+		if empty {
+			return []byte("# heading\nentry\n")
+		}
+		return []byte("entry\n")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := Append(path, build); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# heading\nentry\nentry\nentry\n"; string(got) != want {
+		t.Errorf("file holds %q, want %q", got, want)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	dir := t.TempDir()
+
+	records, err := ReadJSON[record](filepath.Join(dir, "missing.jsonl"))
+	if err != nil || records != nil {
+		t.Errorf("reading a missing log returned %v, %v; want no records", records, err)
+	}
+
+	path := filepath.Join(dir, "log.jsonl")
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n\n{\"n\":2}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	records, err = ReadJSON[record](path)
+	if err != nil || len(records) != 2 || records[1].N != 2 {
+		t.Errorf("reading a log with a blank line returned %v, %v", records, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{\"n\":1}\nnot json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadJSON[record](path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("reading a corrupt log returned %v, want an error naming line 2", err)
+	}
+}
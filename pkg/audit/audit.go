@@ -0,0 +1,49 @@
+// Package audit records searches in an append-only log, so administrators
+// of a shared archive can review who searched for what
+package audit
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/appendlog"
+)
+
+// Entry is a single search recorded in the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Command   string    `json:"command"`
+	Query     string    `json:"query"`
+	Databases []string  `json:"databases"`
+	Results   int       `json:"results"`
+}
+
+// CurrentUser returns the name of the user running the process
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Append adds an entry to the log at path, creating it if needed. Entries
+// are written as one JSON object per line and never rewritten.
+func Append(path string, entry Entry) error {
+	if err := appendlog.AppendJSON(path, entry); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Read returns the entries in the log at path, oldest first. A missing log
+// has no entries.
+func Read(path string) ([]Entry, error) {
+	entries, err := appendlog.ReadJSON[Entry](path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
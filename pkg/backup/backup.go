@@ -0,0 +1,141 @@
+// Package backup snapshots and restores channel databases with SQLite's
+// online backup API, which copies a consistent state of a database while
+// other connections keep reading and writing it
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// pagesPerStep is how many pages are copied before other connections get a
+// chance to use the database
+const pagesPerStep = 256
+
+// stepPause is how long to wait between steps
+const stepPause = 5 * time.Millisecond
+
+// Backup writes a snapshot of the database at srcPath to dstPath, which
+// must not already exist
+func Backup(ctx context.Context, srcPath, dstPath string) error {
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("backup file already exists: %s", dstPath)
+	}
+
+	if err := copyDatabase(ctx, srcPath, dstPath); err != nil {
+		// Don't leave a partial snapshot behind
+		os.Remove(dstPath)
+		return err
+	}
+
+	return nil
+}
+
+// Restore replaces the database at dstPath with the backup at backupPath.
+// Connections to the database see the restored contents once it finishes.
+func Restore(ctx context.Context, backupPath, dstPath string) error {
+	if err := validate(ctx, backupPath); err != nil {
+		return err
+	}
+
+	return copyDatabase(ctx, backupPath, dstPath)
+}
+
+// validate checks that path is a channel database, without modifying it
+func validate(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("backup file not found: %s", path)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+
+	var tables int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name IN ('messages', 'users', 'channels')`).Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("%s is not a readable database: %w", path, err)
+	}
+	if tables != 3 {
+		return fmt.Errorf("%s is not a k8s-slack-searcher database", path)
+	}
+
+	return nil
+}
+
+// copyDatabase copies every page of the database at srcPath to dstPath a
+// step at a time, so writers to the source are only briefly blocked
+func copyDatabase(ctx context.Context, srcPath, dstPath string) error {
+	src, err := sql.Open("sqlite3", srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer src.Close()
+
+	dst, err := sql.Open("sqlite3", dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dstPath, err)
+	}
+	defer dst.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dstPath, err)
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			dstSQLite, ok := dstDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", dstDriver)
+			}
+			srcSQLite, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", srcDriver)
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			for {
+				done, err := backup.Step(pagesPerStep)
+				if err != nil {
+					backup.Finish()
+					return fmt.Errorf("backup failed: %w", err)
+				}
+				if done {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					backup.Finish()
+					return ctx.Err()
+				case <-time.After(stepPause):
+				}
+			}
+
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,270 @@
+// Package bookmarks keeps named collections of messages from any number of
+// channel databases, for curating findings across search sessions.
+package bookmarks
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+// DefaultCollection holds bookmarks added without a collection
+const DefaultCollection = "default"
+
+// Export formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Formats lists the supported collection export formats
+var Formats = []string{FormatMarkdown, FormatHTML}
+
+// Bookmark is a message saved to a collection. The message is copied when
+// it is bookmarked, so collections can be read and exported without the
+// databases they came from.
+type Bookmark struct {
+	Collection string    `json:"collection"`
+	Database   string    `json:"database"`
+	Timestamp  string    `json:"ts"`
+	ThreadTS   string    `json:"thread_ts,omitempty"`
+	ChannelID  string    `json:"channel_id,omitempty"`
+	UserName   string    `json:"user_name"`
+	Date       time.Time `json:"date"`
+	Text       string    `json:"text"`
+	Note       string    `json:"note,omitempty"`
+	Added      time.Time `json:"added"`
+}
+
+// NewBookmark copies a message from a database into a bookmark
+func NewBookmark(collection, database, channelID string, message *models.Message) *Bookmark {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+
+	userName := message.UserName
+	if message.UserRealName != "" {
+		userName = fmt.Sprintf("%s (%s)", message.UserRealName, message.UserName)
+	}
+	if userName == "" {
+		userName = message.UserID
+	}
+
+	return &Bookmark{
+		Collection: collection,
+		Database:   database,
+		Timestamp:  message.Timestamp,
+		ThreadTS:   message.ThreadTS,
+		ChannelID:  channelID,
+		UserName:   userName,
+		Date:       message.Date,
+		Text:       message.Text,
+		Added:      time.Now().UTC(),
+	}
+}
+
+// Summary describes a collection
+type Summary struct {
+	Name  string
+	Count int
+}
+
+// Store is the set of bookmarks saved in a file
+type Store struct {
+	path      string
+	Bookmarks []*Bookmark `json:"bookmarks"`
+}
+
+// Load reads the bookmarks file at path. A missing file yields an empty
+// store.
+func Load(path string) (*Store, error) {
+	store := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Save writes the bookmarks back to the file they were loaded from
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmarks: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create bookmarks directory: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write bookmarks: %w", err)
+	}
+
+	return nil
+}
+
+// Add saves a bookmark. A message already in the collection is replaced,
+// which updates its note, and Add reports false.
+func (s *Store) Add(bookmark *Bookmark) bool {
+	for i, existing := range s.Bookmarks {
+		if existing.Collection == bookmark.Collection && existing.Database == bookmark.Database && existing.Timestamp == bookmark.Timestamp {
+			s.Bookmarks[i] = bookmark
+			return false
+		}
+	}
+
+	s.Bookmarks = append(s.Bookmarks, bookmark)
+	return true
+}
+
+// Remove deletes a message from a collection, or from every collection
+// when collection is empty, and returns the number of bookmarks removed
+func (s *Store) Remove(collection, database, ts string) int {
+	kept := s.Bookmarks[:0]
+	removed := 0
+	for _, bookmark := range s.Bookmarks {
+		if (collection == "" || bookmark.Collection == collection) && bookmark.Database == database && bookmark.Timestamp == ts {
+			removed++
+			continue
+		}
+		kept = append(kept, bookmark)
+	}
+	s.Bookmarks = kept
+
+	return removed
+}
+
+// Collection returns the bookmarks in a collection, oldest message first
+func (s *Store) Collection(name string) []*Bookmark {
+	var bookmarks []*Bookmark
+	for _, bookmark := range s.Bookmarks {
+		if bookmark.Collection == name {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].Date.Before(bookmarks[j].Date)
+	})
+
+	return bookmarks
+}
+
+// Collections returns every collection with its size, by name
+func (s *Store) Collections() []Summary {
+	counts := make(map[string]int)
+	for _, bookmark := range s.Bookmarks {
+		counts[bookmark.Collection]++
+	}
+
+	var summaries []Summary
+	for name, count := range counts {
+		summaries = append(summaries, Summary{Name: name, Count: count})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+
+	return summaries
+}
+
+// ExportData is the data model passed to the HTML collection template
+type ExportData struct {
+	Collection  string
+	GeneratedAt time.Time
+	Bookmarks   []*Bookmark
+	ThemeCSS    template.CSS
+}
+
+// WriteMarkdown renders a collection as a Markdown document. Permalinks
+// are built from workspaceURL when the channel ID is known.
+func WriteMarkdown(w io.Writer, collection string, bookmarks []*Bookmark, workspaceURL string) error {
+	if _, err := fmt.Fprintf(w, "# %s\n\n%d bookmark(s)\n", collection, len(bookmarks)); err != nil {
+		return err
+	}
+
+	for _, bookmark := range bookmarks {
+		heading := fmt.Sprintf("%s - %s in %s", bookmark.UserName, bookmark.Date.Local().Format("2006-01-02 15:04"), bookmark.Database)
+		if link := bookmark.Permalink(workspaceURL); link != "" {
+			heading = fmt.Sprintf("[%s](%s)", heading, link)
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n## %s\n\n", heading)
+		if bookmark.Note != "" {
+			fmt.Fprintf(&b, "_%s_\n\n", bookmark.Note)
+		}
+		for _, line := range strings.Split(bookmark.Text, "\n") {
+			fmt.Fprintf(&b, "> %s\n", line)
+		}
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteHTML renders a collection as a standalone HTML page styled with css
+func WriteHTML(w io.Writer, collection string, bookmarks []*Bookmark, css template.CSS, workspaceURL string) error {
+	tmpl, err := template.New("collection.html").Funcs(template.FuncMap{
+		"inc": func(i int) int {
+			return i + 1
+		},
+		"formatDate": func(t time.Time) string {
+			return t.Local().Format("2006-01-02 15:04:05")
+		},
+		"permalink": func(bookmark *Bookmark) string {
+			return bookmark.Permalink(workspaceURL)
+		},
+	}).ParseFS(templates, "templates/collection.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	data := ExportData{
+		Collection:  collection,
+		GeneratedAt: time.Now(),
+		Bookmarks:   bookmarks,
+		ThemeCSS:    css,
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return nil
+}
+
+// Permalink returns the Slack link to the bookmarked message, or "" when
+// the channel ID is unknown
+func (b *Bookmark) Permalink(workspaceURL string) string {
+	if b.ChannelID == "" {
+		return ""
+	}
+	return slacktext.Permalink(workspaceURL, b.ChannelID, b.Timestamp, b.ThreadTS)
+}
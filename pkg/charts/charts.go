@@ -0,0 +1,203 @@
+// Package charts renders channel activity as PNG or SVG images, so stats
+// reports don't need external plotting tools
+package charts
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// Image formats
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Default image size in pixels
+const (
+	Width  = 1024
+	Height = 400
+)
+
+// FormatForPath picks the image format from a file extension
+func FormatForPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return FormatPNG, nil
+	case ".svg":
+		return FormatSVG, nil
+	default:
+		return "", fmt.Errorf("unsupported chart file %s: use a .png or .svg extension", path)
+	}
+}
+
+// WriteVolumeChart draws messages per month as a line chart
+func WriteVolumeChart(w io.Writer, format string, counts []models.DayCount) error {
+	months := monthlyCounts(counts)
+	if len(months) == 0 {
+		return fmt.Errorf("no messages to chart")
+	}
+
+	maxCount := 0
+	for _, month := range months {
+		if month.count > maxCount {
+			maxCount = month.count
+		}
+	}
+
+	series := chart.TimeSeries{
+		Name: "Messages per month",
+		Style: chart.Style{
+			StrokeColor: chart.ColorBlue,
+			FillColor:   chart.ColorBlue.WithAlpha(64),
+		},
+	}
+	for _, month := range months {
+		series.XValues = append(series.XValues, month.start)
+		series.YValues = append(series.YValues, float64(month.count))
+	}
+
+	graph := chart.Chart{
+		Title:  "Messages per month",
+		Width:  Width,
+		Height: Height,
+		Background: chart.Style{
+			Padding: chart.Box{Top: 50, Left: 20, Right: 20, Bottom: 20},
+		},
+		XAxis: chart.XAxis{
+			Ticks: monthTicks(months),
+		},
+		YAxis: chart.YAxis{
+			Range: countRange(maxCount),
+			Ticks: countTicks(maxCount),
+		},
+		Series: []chart.Series{series},
+	}
+
+	return graph.Render(renderer(format), w)
+}
+
+// WritePostersChart draws the top posters' message counts as a bar chart
+func WritePostersChart(w io.Writer, format string, entries []models.LeaderboardEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("no posters to chart")
+	}
+
+	maxCount := 0
+	for _, entry := range entries {
+		if entry.Count > maxCount {
+			maxCount = entry.Count
+		}
+	}
+
+	graph := chart.BarChart{
+		Title:  "Top posters",
+		Width:  Width,
+		Height: Height,
+		Background: chart.Style{
+			Padding: chart.Box{Top: 50, Bottom: 40},
+		},
+		BarWidth: Width / (len(entries) * 2),
+		XAxis:    chart.Style{TextRotationDegrees: 30},
+		YAxis: chart.YAxis{
+			Range: countRange(maxCount),
+			Ticks: countTicks(maxCount),
+		},
+	}
+	for _, entry := range entries {
+		label := entry.UserName
+		if label == "" {
+			label = entry.UserID
+		}
+		graph.Bars = append(graph.Bars, chart.Value{Label: label, Value: float64(entry.Count)})
+	}
+
+	return graph.Render(renderer(format), w)
+}
+
+func renderer(format string) chart.RendererProvider {
+	if format == FormatSVG {
+		return chart.SVG
+	}
+	return chart.PNG
+}
+
+// monthTicks labels at most 12 months, evenly spaced
+func monthTicks(months []monthCount) []chart.Tick {
+	step := (len(months) + 11) / 12
+	var ticks []chart.Tick
+	for i := 0; i < len(months); i += step {
+		ticks = append(ticks, chart.Tick{
+			Value: chart.TimeToFloat64(months[i].start),
+			Label: months[i].start.Format("2006-01"),
+		})
+	}
+	return ticks
+}
+
+// countTicks labels a count axis from zero in about five whole-number steps
+func countTicks(max int) []chart.Tick {
+	step := (max + 4) / 5
+	if step < 1 {
+		step = 1
+	}
+	var ticks []chart.Tick
+	for value := 0; value < max+step; value += step {
+		// The last tick is the first at or above max
+		ticks = append(ticks, chart.Tick{Value: float64(value), Label: fmt.Sprintf("%d", value)})
+	}
+	return ticks
+}
+
+// countRange spans zero to the last of countTicks
+func countRange(max int) *chart.ContinuousRange {
+	ticks := countTicks(max)
+	return &chart.ContinuousRange{Min: 0, Max: ticks[len(ticks)-1].Value}
+}
+
+type monthCount struct {
+	start time.Time
+	count int
+}
+
+// monthlyCounts totals daily counts by month, including empty months
+// between the first and last so gaps show as zero. A single month is
+// padded with the previous one, since a line needs two points.
+func monthlyCounts(counts []models.DayCount) []monthCount {
+	totals := make(map[time.Time]int)
+	var first, last time.Time
+	for _, count := range counts {
+		day, err := time.Parse("2006-01-02", count.Date)
+		if err != nil {
+			continue
+		}
+		month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		totals[month] += count.Count
+		if first.IsZero() || month.Before(first) {
+			first = month
+		}
+		if month.After(last) {
+			last = month
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+	if first.Equal(last) {
+		first = first.AddDate(0, -1, 0)
+	}
+
+	var months []monthCount
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		months = append(months, monthCount{start: month, count: totals[month]})
+	}
+
+	return months
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigPath overrides the location of the configuration file
+const EnvConfigPath = "K8S_SLACK_SEARCHER_CONFIG"
+
+// DefaultSlowQueryThreshold is how long a search takes before it is
+// written to the slow query log, unless slow_query_threshold is set
+const DefaultSlowQueryThreshold = time.Second
+
+// Config holds user preferences loaded from the configuration file
+type Config struct {
+	// DefaultDatabase is searched when no --database is given and no
+	// database has been selected with the use command
+	DefaultDatabase string `yaml:"default_database"`
+	// AuditLog is a file every search is appended to, for reviewing use of
+	// a shared archive
+	AuditLog string `yaml:"audit_log"`
+	// SlowQueryLog is a file searches taking at least SlowQueryThreshold
+	// are appended to, with their query plans
+	SlowQueryLog string `yaml:"slow_query_log"`
+	// SlowQueryThreshold is a duration such as 500ms; DefaultSlowQueryThreshold
+	// when empty
+	SlowQueryThreshold string `yaml:"slow_query_threshold"`
+	// Alerts are saved searches checked against the messages each ingest
+	// adds
+	Alerts []Alert `yaml:"alerts"`
+	// Cron configures the jobs run by the cron command
+	Cron Cron `yaml:"cron"`
+	// SQLite overrides the SQLite settings databases are opened with
+	SQLite SQLite `yaml:"sqlite"`
+}
+
+// SQLite holds SQLite settings for the two kinds of command
+type SQLite struct {
+	// Ingest applies to ingest and ingest-all
+	Ingest SQLiteTuning `yaml:"ingest"`
+	// Default applies to every other command
+	Default SQLiteTuning `yaml:"default"`
+}
+
+// SQLiteTuning overrides SQLite settings; those left unset keep their
+// defaults
+type SQLiteTuning struct {
+	CacheSizeMB *int `yaml:"cache_size_mb"`
+	// MmapSizeMB of 0 disables memory-mapped I/O
+	MmapSizeMB *int `yaml:"mmap_size_mb"`
+	// Synchronous is off, normal, full or extra
+	Synchronous string `yaml:"synchronous"`
+	// TempStore is default, file or memory
+	TempStore string `yaml:"temp_store"`
+	// JournalMode is delete or wal
+	JournalMode string `yaml:"journal_mode"`
+}
+
+// Alert notifies a webhook when new messages match a query
+type Alert struct {
+	Name string `yaml:"name"`
+	// Query uses the search syntax, including filters such as from:
+	Query    string `yaml:"query"`
+	Database string `yaml:"database"`
+	Webhook  string `yaml:"webhook"`
+	// Format is "json" (the default) or "slack" for Slack incoming webhooks
+	Format string `yaml:"format"`
+}
+
+// Cron lists scheduled jobs and where their runs are logged
+type Cron struct {
+	// LogFile receives a line for each run, in addition to stdout
+	LogFile string `yaml:"log_file"`
+	Jobs    []Job  `yaml:"jobs"`
+}
+
+// Job is a task run at a fixed interval
+type Job struct {
+	Name string `yaml:"name"`
+	// Task is sync, optimize, digest or alerts
+	Task string `yaml:"task"`
+	// Every is the interval between runs, such as 30m or 24h
+	Every    string `yaml:"every"`
+	Database string `yaml:"database"`
+	// Source is the export directory a sync job ingests from
+	Source         string `yaml:"source"`
+	IncludePrivate bool   `yaml:"include_private"`
+	// Output and Format control the files written by digest jobs
+	Output string `yaml:"output"`
+	Format string `yaml:"format"`
+}
+
+// SlowQueryDuration returns the slow query threshold as a duration
+func (c *Config) SlowQueryDuration() (time.Duration, error) {
+	if c.SlowQueryThreshold == "" {
+		return DefaultSlowQueryThreshold, nil
+	}
+	threshold, err := time.ParseDuration(c.SlowQueryThreshold)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("invalid slow_query_threshold %q: expected a duration such as 500ms", c.SlowQueryThreshold)
+	}
+	return threshold, nil
+}
+
+// Path returns the configuration file location: $K8S_SLACK_SEARCHER_CONFIG
+// if set, otherwise config.yaml in the user's configuration directory
+func Path() (string, error) {
+	if path := os.Getenv(EnvConfigPath); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+
+	return filepath.Join(dir, "k8s-slack-searcher", "config.yaml"), nil
+}
+
+// Load reads the configuration file. A missing file yields an empty config.
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
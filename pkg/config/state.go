@@ -0,0 +1,96 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// stateFile stores session state next to the databases it refers to
+var stateFile = storagepaths.Default.StatePath()
+
+// State holds session state persisted between CLI invocations
+type State struct {
+	// ActiveDatabase is the database selected with the use command
+	ActiveDatabase string `json:"active_database,omitempty"`
+	// LastResults is the most recent search, referenced by result number
+	// in follow-up commands
+	LastResults *ResultSet `json:"last_results,omitempty"`
+}
+
+// ResultSet records the messages returned by a search
+type ResultSet struct {
+	Database string      `json:"database"`
+	Query    string      `json:"query"`
+	Results  []ResultRef `json:"results"`
+}
+
+// ResultRef identifies a single search result
+type ResultRef struct {
+	ID        int    `json:"id"`
+	Timestamp string `json:"ts"`
+	// Database overrides the result set's database for searches that
+	// spanned several databases
+	Database string `json:"database,omitempty"`
+}
+
+// Result returns the database and reference for a 1-based result number
+// from the last search
+func (s *State) Result(n int) (string, ResultRef, error) {
+	if s.LastResults == nil || len(s.LastResults.Results) == 0 {
+		return "", ResultRef{}, fmt.Errorf("no saved search results: run a search first")
+	}
+
+	count := len(s.LastResults.Results)
+	if n < 1 || n > count {
+		return "", ResultRef{}, fmt.Errorf("result %d out of range: the last search for %q returned %d result(s)", n, s.LastResults.Query, count)
+	}
+
+	ref := s.LastResults.Results[n-1]
+	if ref.Database != "" {
+		return ref.Database, ref, nil
+	}
+
+	return s.LastResults.Database, ref, nil
+}
+
+// LoadState reads the session state. A missing file yields an empty state.
+func LoadState() (*State, error) {
+	state := &State{}
+
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse session state %s: %w", stateFile, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the session state
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	if err := os.WriteFile(stateFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session state: %w", err)
+	}
+
+	return nil
+}
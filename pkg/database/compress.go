@@ -0,0 +1,189 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the SQLite driver databases are opened with: go-sqlite3
+// with the message_text, bm25 and search_scanned functions registered and
+// the current Tuning applied on every connection
+const driverName = "sqlite3_searcher"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("message_text", messageText, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("bm25", bm25, true); err != nil {
+				return err
+			}
+			// Not deterministic, so SQLite calls it for every row
+			if err := conn.RegisterFunc("search_scanned", searchScanned, false); err != nil {
+				return err
+			}
+			for _, pragma := range CurrentTuning().pragmas() {
+				if _, err := conn.Exec(pragma, nil); err != nil {
+					return fmt.Errorf("failed to apply %s: %w", pragma, err)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+const (
+	// metadataTextCompression records the compression applied to message
+	// text stored from then on
+	metadataTextCompression = "text_compression"
+	// CompressionZstd compresses message text with zstd
+	CompressionZstd = "zstd"
+
+	// minCompressedLength is the shortest text worth compressing; shorter
+	// messages, most chat lines, rarely shrink once the frame header is
+	// added
+	minCompressedLength = 128
+)
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var (
+	codecOnce sync.Once
+	encoder   *zstd.Encoder
+	decoder   *zstd.Decoder
+	codecErr  error
+)
+
+// codec returns the shared zstd encoder and decoder; EncodeAll and
+// DecodeAll are safe for concurrent use
+func codec() (*zstd.Encoder, *zstd.Decoder, error) {
+	codecOnce.Do(func() {
+		encoder, codecErr = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+		if codecErr != nil {
+			return
+		}
+		decoder, codecErr = zstd.NewReader(nil)
+	})
+	return encoder, decoder, codecErr
+}
+
+// compressText returns the value to store in the messages text column:
+// the text itself, or a zstd-compressed blob when compress is set and that
+// is smaller
+func compressText(text string, compress bool) (interface{}, error) {
+	if !compress || len(text) < minCompressedLength {
+		return text, nil
+	}
+	enc, _, err := codec()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compressor: %w", err)
+	}
+	compressed := enc.EncodeAll([]byte(text), nil)
+	if len(compressed) >= len(text) {
+		return text, nil
+	}
+	return compressed, nil
+}
+
+// messageText is the SQL function message_text(text), which returns a
+// messages text column as text whether it was stored plain or compressed
+func messageText(value interface{}) (interface{}, error) {
+	blob, ok := value.([]byte)
+	if !ok || !bytes.HasPrefix(blob, zstdMagic) {
+		if ok {
+			return string(blob), nil
+		}
+		return value, nil
+	}
+	_, dec, err := codec()
+	if err != nil {
+		return nil, err
+	}
+	text, err := dec.DecodeAll(blob, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message text: %w", err)
+	}
+	return string(text), nil
+}
+
+// TextCompression returns the compression applied to message text stored
+// in the database, or "" when it is stored plain
+func (db *DB) TextCompression(ctx context.Context) (string, error) {
+	value, _, err := db.GetMetadata(ctx, metadataTextCompression)
+	return value, err
+}
+
+// SetTextCompression compresses the text of messages stored from now on
+// with zstd, or stores it plain when compress is false, and converts the
+// text already stored to match. It returns the number of messages
+// converted.
+func (db *DB) SetTextCompression(ctx context.Context, compress bool) (int, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT id, message_text(text), typeof(text) = 'blob' FROM messages`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read messages: %w", err)
+	}
+	type stored struct {
+		id   int
+		text string
+	}
+	var convert []stored
+	for rows.Next() {
+		var message stored
+		var compressed bool
+		if err := rows.Scan(&message.id, &message.text, &compressed); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan message: %w", err)
+		}
+		if compressed != compress {
+			convert = append(convert, message)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	converted := 0
+	for _, message := range convert {
+		value, err := compressText(message.text, compress)
+		if err != nil {
+			return 0, err
+		}
+		// Short messages stay plain either way
+		if _, plain := value.(string); plain && compress {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE messages SET text = ? WHERE id = ?`, value, message.id); err != nil {
+			return 0, fmt.Errorf("failed to update message %d: %w", message.id, err)
+		}
+		converted++
+	}
+
+	setting := ""
+	if compress {
+		setting = CompressionZstd
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)`, metadataTextCompression, setting); err != nil {
+		return 0, fmt.Errorf("failed to write metadata %s: %w", metadataTextCompression, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	db.compressText = compress
+
+	return converted, nil
+}
@@ -1,68 +1,272 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/raesene/k8s-slack-searcher/pkg/dbcrypt"
+	"github.com/raesene/k8s-slack-searcher/pkg/fingerprint"
+	"github.com/raesene/k8s-slack-searcher/pkg/identifiers"
+	"github.com/raesene/k8s-slack-searcher/pkg/logblock"
 	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type DB struct {
 	conn     *sql.DB
 	filename string
+	path     string
+	tx       *sql.Tx
+	// stmts holds the statements prepared in the current transaction by
+	// prepared, which are closed with it
+	stmts map[string]*sql.Stmt
+	// working is the decrypted copy of an encrypted database
+	working *dbcrypt.WorkingCopy
+	// metaPath is where the workspace's shared users and channels
+	// database would be
+	metaPath string
+	// compressText compresses the text of new messages (see
+	// SetTextCompression)
+	compressText bool
+	// tracer receives a trace of each search (see SetTracer)
+	tracer func(*models.SearchTrace)
+	// snippets is the number of snippets results show of messages
+	// matching in several places (see SetSnippets)
+	snippets int
 }
 
-// NewDB creates a new database connection
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// NewDB creates a new database connection. The name may be qualified with a
+// workspace as "workspace/channel", in which case the database lives in that
+// workspace's subdirectory of the databases directory.
 func NewDB(channelName string) (*DB, error) {
-	// Sanitize channel name for filename
-	filename := sanitizeFilename(channelName) + ".db"
-	
-	// Ensure databases directory exists
-	dbPath := filepath.Join("databases", filename)
+	return NewDBInDir("", channelName)
+}
+
+// NewDBInDir creates a new database connection for a database stored under
+// dir rather than the default databases directory
+func NewDBInDir(dir, channelName string) (*DB, error) {
+	layout := storagepaths.New(dir)
+	dbPath := layout.DatabasePath(channelName)
+	filename := filepath.Base(dbPath)
+
+	// Encrypted databases are decrypted to a private working copy, which
+	// is encrypted back when the database is closed
+	var working *dbcrypt.WorkingCopy
+	if !fileExists(dbPath) && layout.IsEncrypted(channelName) {
+		var err error
+		working, err = dbcrypt.OpenWorkingCopy(layout.EncryptedPath(channelName))
+		if err != nil {
+			return nil, err
+		}
+		dbPath = working.Path
+	}
+
+	// Ensure the workspace directory exists
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
 	
-	conn, err := sql.Open("sqlite3", dbPath)
+	// Dates are stored in UTC; _loc=auto converts them to the local time
+	// zone (see --tz) when read
+	conn, err := sql.Open(driverName, dbPath+"?_loc=auto")
 	if err != nil {
+		if working != nil {
+			working.Close()
+		}
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	db := &DB{
 		conn:     conn,
 		filename: filename,
+		path:     dbPath,
+		working:  working,
+		metaPath: layout.MetaPath(channelName),
 	}
 
 	if err := db.createTables(); err != nil {
-		conn.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	compression, err := db.TextCompression(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.compressText = compression == CompressionZstd
+
+	return db, nil
+}
+
+// NewWorkspaceMetaDB opens the shared users and channels database of the
+// workspace a database belongs to, stored under dir, creating it if needed.
+// It has the same schema as a channel database; only its users, channels
+// and channel_members tables are used.
+func NewWorkspaceMetaDB(dir, name string) (*DB, error) {
+	path := storagepaths.New(dir).MetaPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	conn, err := sql.Open(driverName, path+"?_loc=auto")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db := &DB{conn: conn, filename: filepath.Base(path), path: path}
+	if err := db.createTables(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
 	return db, nil
 }
 
-// Close closes the database connection
+// NewDBAtPath opens the database file at path, such as a copy kept outside
+// the databases directory
+func NewDBAtPath(path string) (*DB, error) {
+	// Database files are named after their sanitized channel name
+	name := strings.TrimSuffix(filepath.Base(path), ".db")
+	if filepath.Ext(path) != ".db" || storagepaths.Sanitize(name) != name {
+		return nil, fmt.Errorf("unsupported database file name: %s", path)
+	}
+
+	return NewDBInDir(filepath.Dir(path), name)
+}
+
+// Checkpoint writes any write-ahead log left beside the database file at
+// path back into the file, so the file alone holds the whole database
+func Checkpoint(path string) error {
+	conn, err := sql.Open(driverName, path)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	// SQLite removes the log when its last connection closes
+	return conn.Close()
+}
+
+// Path returns the database file path
+func (db *DB) Path() string {
+	return db.path
+}
+
+// Close closes the database connection, encrypting the working copy of an
+// encrypted database back into place if it changed
 func (db *DB) Close() error {
-	return db.conn.Close()
+	err := db.conn.Close()
+	if db.working != nil {
+		if cryptErr := db.working.Close(); err == nil {
+			err = cryptErr
+		}
+		db.working = nil
+	}
+	return err
 }
 
-// sanitizeFilename removes problematic characters from channel names
-func sanitizeFilename(name string) string {
-	// Replace problematic characters with underscores
-	replacer := strings.NewReplacer(
-		":", "_",
-		"/", "_",
-		"\\", "_",
-		"*", "_",
-		"?", "_",
-		"\"", "_",
-		"<", "_",
-		">", "_",
-		"|", "_",
-		" ", "_",
-	)
-	return replacer.Replace(name)
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Begin starts a transaction; inserts are made within it until Commit or
+// Rollback is called
+func (db *DB) Begin() error {
+	if db.tx != nil {
+		return fmt.Errorf("transaction already in progress")
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	db.tx = tx
+	db.stmts = make(map[string]*sql.Stmt)
+
+	return nil
+}
+
+// Commit commits the current transaction
+func (db *DB) Commit() error {
+	if db.tx == nil {
+		return nil
+	}
+
+	err := db.tx.Commit()
+	db.tx, db.stmts = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Rollback abandons the current transaction
+func (db *DB) Rollback() error {
+	if db.tx == nil {
+		return nil
+	}
+
+	err := db.tx.Rollback()
+	db.tx, db.stmts = nil, nil
+	return err
+}
+
+// exec returns the current transaction if one is active, otherwise the
+// underlying connection
+func (db *DB) exec() execer {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.conn
+}
+
+// prepared is exec for the statements run for every message at ingest.
+// Within a transaction each is prepared once and reused, rather than
+// parsed again for each of thousands of messages.
+func (db *DB) prepared() execer {
+	if db.tx == nil {
+		return db.conn
+	}
+	return stmtExecer{db}
+}
+
+// stmtExecer runs each query with the statement prepared for it in the
+// current transaction
+type stmtExecer struct {
+	db *DB
+}
+
+func (e stmtExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, ok := e.db.stmts[query]
+	if !ok {
+		var err error
+		stmt, err = e.db.tx.Prepare(query)
+		if err != nil {
+			return nil, err
+		}
+		e.db.stmts[query] = stmt
+	}
+	return stmt.Exec(args...)
 }
 
 // createTables creates the necessary tables and FTS index
@@ -84,9 +288,41 @@ func (db *DB) createTables() error {
 			name TEXT NOT NULL,
 			created INTEGER,
 			creator TEXT,
-			is_archived BOOLEAN DEFAULT FALSE
+			is_archived BOOLEAN DEFAULT FALSE,
+			topic TEXT,
+			purpose TEXT,
+			kind TEXT DEFAULT 'channel'
 		)`,
 		
+		// Channel membership table
+		`CREATE TABLE IF NOT EXISTS channel_members (
+			channel_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			PRIMARY KEY (channel_id, user_id)
+		)`,
+		
+		// FTS virtual table for channel discovery by name, topic and purpose
+		`CREATE VIRTUAL TABLE IF NOT EXISTS channels_fts USING fts4(
+			name,
+			topic,
+			purpose
+		)`,
+		
+		`CREATE TRIGGER IF NOT EXISTS channels_fts_insert AFTER INSERT ON channels BEGIN
+			INSERT INTO channels_fts(rowid, name, topic, purpose)
+			VALUES (new.rowid, new.name, COALESCE(new.topic, ''), COALESCE(new.purpose, ''));
+		END`,
+		
+		`CREATE TRIGGER IF NOT EXISTS channels_fts_delete AFTER DELETE ON channels BEGIN
+			DELETE FROM channels_fts WHERE rowid = old.rowid;
+		END`,
+		
+		`CREATE TRIGGER IF NOT EXISTS channels_fts_update AFTER UPDATE ON channels BEGIN
+			DELETE FROM channels_fts WHERE rowid = old.rowid;
+			INSERT INTO channels_fts(rowid, name, topic, purpose)
+			VALUES (new.rowid, new.name, COALESCE(new.topic, ''), COALESCE(new.purpose, ''));
+		END`,
+		
 		// Messages table
 		`CREATE TABLE IF NOT EXISTS messages (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -96,161 +332,2814 @@ func (db *DB) createTables() error {
 			subtype TEXT,
 			timestamp TEXT,
 			date DATETIME,
+			ts_micros INTEGER,
 			filename TEXT,
+			thread_ts TEXT,
+			reply_count INTEGER DEFAULT 0,
+			reaction_count INTEGER DEFAULT 0,
+			has_link BOOLEAN DEFAULT 0,
+			has_code BOOLEAN DEFAULT 0,
+			has_file BOOLEAN DEFAULT 0,
+			has_reaction BOOLEAN DEFAULT 0,
+			identifiers TEXT DEFAULT '',
+			logs TEXT DEFAULT '',
 			FOREIGN KEY (user_id) REFERENCES users (id)
 		)`,
 		
-		// FTS virtual table for full-text search
-		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts4(
-			text,
-			user_name,
-			user_real_name,
-			filename
+		// Ingest checkpoints: message files that have been fully indexed
+		`CREATE TABLE IF NOT EXISTS ingested_files (
+			filename TEXT PRIMARY KEY,
+			message_count INTEGER,
+			indexed_at DATETIME
 		)`,
 		
-		// Trigger to keep FTS table in sync
-		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
-			INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename)
-			SELECT 
-				new.id,
-				new.text,
-				COALESCE(u.name, ''),
-				COALESCE(u.real_name, ''),
-				new.filename
-			FROM users u WHERE u.id = new.user_id;
-		END`,
-		
-		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
-			DELETE FROM messages_fts WHERE rowid = old.id;
-		END`,
-		
-		`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE ON messages BEGIN
-			DELETE FROM messages_fts WHERE rowid = old.id;
-			INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename)
-			SELECT 
-				new.id,
-				new.text,
-				COALESCE(u.name, ''),
-				COALESCE(u.real_name, ''),
-				new.filename
-			FROM users u WHERE u.id = new.user_id;
-		END`,
+		// Cache of generated summaries keyed by a hash of model and prompt
+		`CREATE TABLE IF NOT EXISTS summaries (
+			cache_key TEXT PRIMARY KEY,
+			model TEXT NOT NULL,
+			summary TEXT NOT NULL,
+			created DATETIME
+		)`,
+
+		// Helpful (+1) or not helpful (-1) marks on search results, one per
+		// message, used to boost or demote messages and their threads
+		`CREATE TABLE IF NOT EXISTS feedback (
+			message_ts TEXT PRIMARY KEY,
+			thread_ts TEXT NOT NULL,
+			score INTEGER NOT NULL,
+			query TEXT,
+			updated DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_feedback_thread_ts ON feedback(thread_ts)`,
+
+		// Tags on messages, or on whole threads when thread is set, keyed
+		// by the message or thread starter's timestamp
+		`CREATE TABLE IF NOT EXISTS tags (
+			message_ts TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			thread BOOLEAN NOT NULL DEFAULT 0,
+			created DATETIME,
+			PRIMARY KEY (message_ts, tag)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_tags_tag ON tags(tag)`,
+
+		// Named result sets saved from searches, and their messages by
+		// timestamp in result order, so they outlive a rebuild
+		`CREATE TABLE IF NOT EXISTS result_sets (
+			name TEXT PRIMARY KEY,
+			query TEXT NOT NULL,
+			created DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS result_set_messages (
+			set_name TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			message_ts TEXT NOT NULL,
+			PRIMARY KEY (set_name, position)
+		)`,
+
+		// Kubernetes minor versions mentioned in each message
+		`CREATE TABLE IF NOT EXISTS message_versions (
+			message_ts TEXT NOT NULL,
+			version TEXT NOT NULL,
+			PRIMARY KEY (message_ts, version)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_message_versions_version ON message_versions(version)`,
+
+		// Fingerprints of the error messages quoted in each message
+		`CREATE TABLE IF NOT EXISTS message_fingerprints (
+			message_ts TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			PRIMARY KEY (message_ts, fingerprint)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_message_fingerprints_fingerprint ON message_fingerprints(fingerprint)`,
+
+		// Each profile a user posted under, from the copy Slack keeps in
+		// their messages, and the period it was seen in as microseconds
+		// since the epoch
+		`CREATE TABLE IF NOT EXISTS user_profiles (
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			real_name TEXT NOT NULL,
+			display_name TEXT NOT NULL,
+			first_seen INTEGER NOT NULL,
+			last_seen INTEGER NOT NULL,
+			PRIMARY KEY (user_id, name, real_name, display_name)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_profiles_first_seen ON user_profiles(user_id, first_seen)`,
+
+		// Files shared in messages, for embedding in reports
+		`CREATE TABLE IF NOT EXISTS message_files (
+			message_ts TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			file_id TEXT DEFAULT '',
+			name TEXT DEFAULT '',
+			title TEXT DEFAULT '',
+			mimetype TEXT DEFAULT '',
+			size INTEGER DEFAULT 0,
+			url TEXT DEFAULT '',
+			thumbnail TEXT DEFAULT '',
+			permalink TEXT DEFAULT '',
+			preview TEXT DEFAULT '',
+			local_path TEXT DEFAULT '',
+			PRIMARY KEY (message_ts, position)
+		)`,
+
+		// Catalog of the files imported into the media store with
+		// ingest --media-dir, linked to message_files by file ID
+		`CREATE TABLE IF NOT EXISTS media_files (
+			sha256 TEXT NOT NULL,
+			name TEXT NOT NULL,
+			file_id TEXT DEFAULT '',
+			mimetype TEXT DEFAULT '',
+			size INTEGER DEFAULT 0,
+			stored_path TEXT NOT NULL,
+			cataloged DATETIME,
+			PRIMARY KEY (sha256, name)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_media_files_file_id ON media_files(file_id)`,
+
+		// Text extracted from shared files with ingest --extract-files,
+		// indexed with the message that shared each file
+		`CREATE TABLE IF NOT EXISTS file_contents (
+			message_ts TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			file_id TEXT DEFAULT '',
+			name TEXT DEFAULT '',
+			content TEXT NOT NULL,
+			extracted DATETIME,
+			PRIMARY KEY (message_ts, position)
+		)`,
+
+		// Source JSON of each message, kept when ingesting with --keep-raw
+		`CREATE TABLE IF NOT EXISTS message_raw (
+			message_ts TEXT PRIMARY KEY,
+			raw TEXT NOT NULL
+		)`,
+
+		// Apps and integrations whose messages were indexed with
+		// --include-bots; each also has a users row flagged is_bot
+		`CREATE TABLE IF NOT EXISTS bots (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			app_id TEXT DEFAULT '',
+			service TEXT DEFAULT ''
+		)`,
+
+		// Messages ingest skipped in each export file, counted by reason and
+		// with a few examples of each, for the skipped command
+		`CREATE TABLE IF NOT EXISTS skipped_counts (
+			filename TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			subtype TEXT NOT NULL DEFAULT '',
+			count INTEGER NOT NULL,
+			PRIMARY KEY (filename, reason, subtype)
+		)`,
+		`CREATE TABLE IF NOT EXISTS skipped_examples (
+			filename TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			subtype TEXT DEFAULT '',
+			message_ts TEXT DEFAULT '',
+			user_id TEXT DEFAULT '',
+			excerpt TEXT DEFAULT '',
+			PRIMARY KEY (filename, position)
+		)`,
+
+		// Per-database settings, such as the term statistics stopwords
+		`CREATE TABLE IF NOT EXISTS metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
 		
 		// Indexes for better performance
 		`CREATE INDEX IF NOT EXISTS idx_messages_user_id ON messages(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_date ON messages(date)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_filename ON messages(filename)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp)`,
 	}
 
+	queries = append(queries, messagesFTSTables...)
+	queries = append(queries, messagesFTSTriggers...)
+
 	for _, query := range queries {
 		if _, err := db.conn.Exec(query); err != nil {
 			return fmt.Errorf("failed to execute query: %s: %w", query, err)
 		}
 	}
 
-	return nil
+	return db.migrate()
 }
 
-// InsertUser inserts a user into the database
-func (db *DB) InsertUser(user *models.User) error {
-	query := `INSERT OR REPLACE INTO users (id, name, real_name, display_name, is_bot, deleted)
-			  VALUES (?, ?, ?, ?, ?, ?)`
-	
-	_, err := db.conn.Exec(query, user.ID, user.Name, user.RealName, user.DisplayName, user.IsBot, user.Deleted)
-	return err
-}
+// messagesFTSTables creates the message search index
+var messagesFTSTables = []string{
+	// The columns the search index is built from. The index keeps no copy
+	// of them; its columns and snippets are read back through this view.
+	`CREATE VIEW IF NOT EXISTS messages_fts_content AS
+		SELECT
+			m.id AS rowid,
+			message_text(m.text) AS text,
+			COALESCE(u.name, '') AS user_name,
+			COALESCE(u.real_name, '') AS user_real_name,
+			m.filename AS filename,
+			COALESCE(m.identifiers, '') AS identifiers,
+			COALESCE((SELECT group_concat(c.content, ' ') FROM file_contents c
+				WHERE c.message_ts = m.timestamp), '') AS files
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id`,
 
-// InsertChannel inserts a channel into the database
-func (db *DB) InsertChannel(channel *models.Channel) error {
-	query := `INSERT OR REPLACE INTO channels (id, name, created, creator, is_archived)
-			  VALUES (?, ?, ?, ?, ?)`
-	
-	_, err := db.conn.Exec(query, channel.ID, channel.Name, channel.Created, channel.Creator, channel.IsArchived)
-	return err
+	// FTS virtual table for full-text search, an external content table
+	// over messages_fts_content. identifiers holds the whole-token forms of
+	// identifiers such as kube-apiserver (see package identifiers), and
+	// files the text extracted from the files the message shared.
+	`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts4(
+		content="messages_fts_content",
+		text,
+		user_name,
+		user_real_name,
+		filename,
+		identifiers,
+		files
+	)`,
+
+	// Read-only view of the message index vocabulary, used for suggestions
+	`CREATE VIRTUAL TABLE IF NOT EXISTS messages_terms USING fts4aux(messages_fts)`,
 }
 
-// InsertMessage inserts a message into the database
-func (db *DB) InsertMessage(message *models.Message) error {
-	query := `INSERT INTO messages (user_id, text, type, subtype, timestamp, date, filename)
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`
-	
-	_, err := db.conn.Exec(query, message.UserID, message.Text, message.Type, message.Subtype, 
-						  message.Timestamp, message.Date, message.Filename)
-	return err
+// messagesFTSTriggers keep the message search index in sync with the
+// messages table. Index rows are written by InsertMessage rather than by an
+// insert trigger, so indexing a message never depends on other tables
+// (see RebuildFTS for bulk changes). The index removes a row by reading
+// back what it indexed, so rows are removed before their content changes.
+var messagesFTSTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS messages_fts_delete BEFORE DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.id;
+	END`,
+
+	// Authors' names are indexed with their messages
+	`CREATE TRIGGER IF NOT EXISTS messages_fts_author_rename BEFORE UPDATE OF name, real_name ON users
+	WHEN old.name IS NOT new.name OR old.real_name IS NOT new.real_name BEGIN
+		DELETE FROM messages_fts WHERE rowid IN (SELECT id FROM messages WHERE user_id = old.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS messages_fts_author_renamed AFTER UPDATE OF name, real_name ON users
+	WHEN old.name IS NOT new.name OR old.real_name IS NOT new.real_name BEGIN
+		INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename, identifiers, files)
+			SELECT rowid, text, user_name, user_real_name, filename, identifiers, files FROM messages_fts_content
+			WHERE rowid IN (SELECT id FROM messages WHERE user_id = new.id);
+	END`,
 }
 
-// SearchMessages performs full-text search on messages
-func (db *DB) SearchMessages(query string, limit int) ([]*models.SearchResult, error) {
-	sqlQuery := `
-		SELECT 
-			m.id,
-			m.user_id,
-			m.text,
-			m.type,
-			m.subtype,
-			m.timestamp,
-			m.date,
-			m.filename,
-			COALESCE(u.name, '') as user_name,
-			COALESCE(u.real_name, '') as user_real_name,
-			0.0 as rank,
-			snippet(messages_fts, '<mark>', '</mark>', '...', -1, 32) as snippet
-		FROM messages_fts fts
-		JOIN messages m ON m.id = fts.rowid
-		LEFT JOIN users u ON u.id = m.user_id
-		WHERE messages_fts MATCH ?
-		LIMIT ?`
+// migrate adds columns introduced after a database was first created, so
+// databases built by older versions keep working
+func (db *DB) migrate() error {
+	columns := []struct {
+		table      string
+		name       string
+		definition string
+		// backfill optionally populates the new column for existing rows
+		backfill string
+	}{
+		// Set for users created at ingest for message authors missing
+		// from users.json
+		{"users", "placeholder", "BOOLEAN DEFAULT 0", ""},
+		{"channels", "topic", "TEXT", ""},
+		{"channels", "purpose", "TEXT", ""},
+		{"channels", "kind", "TEXT DEFAULT 'channel'", ""},
+		{"messages", "thread_ts", "TEXT", ""},
+		{"messages", "reply_count", "INTEGER DEFAULT 0", ""},
+		{"messages", "reaction_count", "INTEGER DEFAULT 0", ""},
+		// Content flags are approximated from the indexed text; re-ingest
+		// for exact values
+		{"messages", "has_link", "BOOLEAN DEFAULT 0",
+			`UPDATE messages SET has_link = 1 WHERE text LIKE '%http://%' OR text LIKE '%https://%'`},
+		{"messages", "has_code", "BOOLEAN DEFAULT 0",
+			"UPDATE messages SET has_code = 1 WHERE text LIKE '%`%'"},
+		{"messages", "has_file", "BOOLEAN DEFAULT 0",
+			`UPDATE messages SET has_file = 1 WHERE text LIKE '%[file: %'`},
+		{"messages", "has_reaction", "BOOLEAN DEFAULT 0",
+			`UPDATE messages SET has_reaction = 1 WHERE reaction_count > 0`},
+		// The fraction is padded to six digits, as parseSlackTimestamp
+		// does, so ".5" is 500000 microseconds
+		{"messages", "ts_micros", "INTEGER",
+			`UPDATE messages SET ts_micros = CAST(substr(timestamp, 1, instr(timestamp, '.') - 1) AS INTEGER) * 1000000
+				+ CAST(substr(substr(timestamp, instr(timestamp, '.') + 1) || '000000', 1, 6) AS INTEGER)
+			WHERE instr(timestamp, '.') > 0`},
+		// Populated with the search index by migrateIdentifiers
+		{"messages", "identifiers", "TEXT DEFAULT ''", ""},
+		// Populated by the schema version 4 backfill
+		{"messages", "logs", "TEXT DEFAULT ''", ""},
+	}
+
+	for _, column := range columns {
+		exists, err := db.columnExists(column.table, column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.name, column.definition)
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", column.table, column.name, err)
+		}
+
+		if column.backfill != "" {
+			if _, err := db.conn.Exec(column.backfill); err != nil {
+				return fmt.Errorf("failed to populate column %s.%s: %w", column.table, column.name, err)
+			}
+		}
+	}
+
+	// Indexes on migrated columns can only be created once the columns exist
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_messages_thread_ts ON messages(thread_ts)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_ts_micros ON messages(ts_micros)`,
+	}
+	for _, query := range indexes {
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %s: %w", query, err)
+		}
+	}
+
+	// Older versions stored message dates in the time zone of the machine
+	// that ran ingest; normalize them to UTC once
+	var version int
+	if err := db.conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version < 1 {
+		_, err := db.conn.Exec(`UPDATE messages SET date = strftime('%Y-%m-%d %H:%M:%S+00:00', date)
+			WHERE date NOT LIKE '%+00:00'`)
+		if err != nil {
+			return fmt.Errorf("failed to normalize message dates to UTC: %w", err)
+		}
+		if _, err := db.conn.Exec("PRAGMA user_version = 1"); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+
+	// Kubernetes version mentions were first recorded in schema version 2
+	if version < 2 {
+		if err := db.backfill(recordVersions); err != nil {
+			return err
+		}
+		if _, err := db.conn.Exec("PRAGMA user_version = 2"); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+
+	// Error fingerprints were first recorded in schema version 3
+	if version < 3 {
+		if err := db.backfill(recordFingerprints); err != nil {
+			return err
+		}
+		if _, err := db.conn.Exec("PRAGMA user_version = 3"); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+
+	// Log pastes and stack traces were first recorded in schema version 4
+	if version < 4 {
+		if err := db.backfill(recordLogs); err != nil {
+			return err
+		}
+		if _, err := db.conn.Exec("PRAGMA user_version = 4"); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+
+	if err := db.migrateIdentifiers(); err != nil {
+		return err
+	}
+
+	// Before schema version 5 the search index triggers skipped messages
+	// whose author had no users row. Those authors get placeholder users
+	// and their messages are indexed.
+	if version < 5 {
+		if err := db.repairMissingAuthors(); err != nil {
+			return err
+		}
+		if _, err := db.conn.Exec("PRAGMA user_version = 5"); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+
+	// From schema version 6 InsertMessage writes search index rows itself
+	if version < 6 {
+		queries := []string{
+			`DROP TRIGGER IF EXISTS messages_fts_insert`,
+			`DROP TRIGGER IF EXISTS messages_fts_update`,
+			`PRAGMA user_version = 6`,
+		}
+		for _, query := range queries {
+			if _, err := db.conn.Exec(query); err != nil {
+				return fmt.Errorf("failed to remove search index triggers: %w", err)
+			}
+		}
+	}
+
+	// From schema version 7 the search index reads the message text from
+	// the messages table instead of keeping its own copy
+	if version < 7 {
+		if err := db.migrateExternalContent(); err != nil {
+			return err
+		}
+		if _, err := db.conn.Exec("PRAGMA user_version = 7"); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
 
-	rows, err := db.conn.Query(sqlQuery, query, limit)
+	// The search index's files column was added after schema version 7.
+	// Indexes migrated above were recreated with it; older external-content
+	// indexes need their view recreated before the index is.
+	if version >= 7 {
+		if err := db.migrateContentView(); err != nil {
+			return err
+		}
+	}
+	exists, err := db.columnExists("messages_fts", "files")
 	if err != nil {
-		return nil, fmt.Errorf("search query failed: %w", err)
+		return err
+	}
+	if !exists {
+		if err := db.migrateExternalContent(); err != nil {
+			return err
+		}
 	}
-	defer rows.Close()
 
-	var results []*models.SearchResult
-	for rows.Next() {
-		result := &models.SearchResult{}
-		err := rows.Scan(
-			&result.ID,
-			&result.UserID,
-			&result.Text,
-			&result.Type,
-			&result.Subtype,
-			&result.Timestamp,
-			&result.Date,
-			&result.Filename,
-			&result.UserName,
-			&result.UserRealName,
-			&result.Rank,
-			&result.Snippet,
-		)
+	// Populate the channel FTS index for databases created before it existed
+	var indexed, channels int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM channels_fts").Scan(&indexed); err != nil {
+		return fmt.Errorf("failed to count indexed channels: %w", err)
+	}
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM channels").Scan(&channels); err != nil {
+		return fmt.Errorf("failed to count channels: %w", err)
+	}
+	if indexed == 0 && channels > 0 {
+		_, err := db.conn.Exec(`INSERT INTO channels_fts(rowid, name, topic, purpose)
+			SELECT rowid, name, COALESCE(topic, ''), COALESCE(purpose, '') FROM channels`)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan result: %w", err)
+			return fmt.Errorf("failed to index channels: %w", err)
 		}
-		results = append(results, result)
 	}
 
-	return results, nil
+	return nil
 }
 
-// GetStats returns basic statistics about the database
-func (db *DB) GetStats() (map[string]int, error) {
-	stats := make(map[string]int)
-	
-	queries := map[string]string{
-		"users":    "SELECT COUNT(*) FROM users",
-		"channels": "SELECT COUNT(*) FROM channels", 
-		"messages": "SELECT COUNT(*) FROM messages",
+// migrateIdentifiers recreates the search index of databases built before
+// it had the identifiers column, filling the column in for every message
+func (db *DB) migrateIdentifiers() error {
+	exists, err := db.columnExists("messages_fts", "identifiers")
+	if err != nil || exists {
+		return err
 	}
-	
-	for key, query := range queries {
-		var count int
-		err := db.conn.QueryRow(query).Scan(&count)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get %s count: %w", key, err)
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`DROP TRIGGER IF EXISTS messages_fts_insert`,
+		`DROP TRIGGER IF EXISTS messages_fts_delete`,
+		`DROP TRIGGER IF EXISTS messages_fts_update`,
+		`DROP TABLE IF EXISTS messages_terms`,
+		`DROP TABLE IF EXISTS messages_fts`,
+	}
+	for _, query := range append(queries, messagesFTSTables...) {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to recreate search index: %w", err)
 		}
-		stats[key] = count
 	}
-	
-	return stats, nil
-}
\ No newline at end of file
+
+	rows, err := tx.Query(`SELECT id, message_text(text) FROM messages`)
+	if err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+	updates := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan message: %w", err)
+		}
+		if tokens := identifiers.Expand(text); len(tokens) > 0 {
+			updates[id] = strings.Join(tokens, " ")
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	// The triggers are recreated last, so these updates don't reindex each
+	// message; the index is filled in for all messages at once instead
+	for id, tokens := range updates {
+		if _, err := tx.Exec(`UPDATE messages SET identifiers = ? WHERE id = ?`, tokens, id); err != nil {
+			return fmt.Errorf("failed to index identifiers: %w", err)
+		}
+	}
+	queries = append([]string{
+		`INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename, identifiers)
+			SELECT m.id, message_text(m.text), COALESCE(u.name, ''), COALESCE(u.real_name, ''), m.filename, COALESCE(m.identifiers, '')
+			FROM messages m
+			LEFT JOIN users u ON u.id = m.user_id`,
+	}, messagesFTSTriggers...)
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit search index migration: %w", err)
+	}
+	return nil
+}
+
+// migrateContentView recreates messages_fts_content in databases built
+// before it had the files column. The search index reads its columns from
+// the view by name, so an index without the column keeps working until it
+// is recreated. Indexes that keep their own copy of the text have a shadow
+// table of that name instead, dropped with the index by
+// migrateExternalContent, and are left alone.
+func (db *DB) migrateContentView() error {
+	var kind, definition string
+	err := db.conn.QueryRow(`SELECT type, sql FROM sqlite_master WHERE name = 'messages_fts_content'`).Scan(&kind, &definition)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read search index view: %w", err)
+	}
+	if kind != "view" || strings.Contains(definition, "AS files") {
+		return nil
+	}
+
+	queries := []string{`DROP VIEW messages_fts_content`, messagesFTSTables[0]}
+	for _, query := range queries {
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to recreate search index view: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateExternalContent recreates the search index of databases built
+// before it read the message text from messages_fts_content, when it kept
+// its own copy, or before it had the files column. The space the copy took
+// is only returned to the file system by VACUUM (see Optimize).
+func (db *DB) migrateExternalContent() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`DROP TRIGGER IF EXISTS messages_fts_delete`,
+		`DROP TABLE IF EXISTS messages_terms`,
+		`DROP TABLE IF EXISTS messages_fts`,
+	}
+	queries = append(queries, messagesFTSTables...)
+	queries = append(queries, `INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`)
+	queries = append(queries, messagesFTSTriggers...)
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to recreate search index: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit search index migration: %w", err)
+	}
+	return nil
+}
+
+// repairMissingAuthors adds placeholder users for message authors with no
+// users row and indexes the messages the old triggers skipped
+func (db *DB) repairMissingAuthors() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`INSERT OR IGNORE INTO users (id, name, placeholder)
+			SELECT DISTINCT user_id, user_id, 1 FROM messages
+			WHERE user_id != '' AND user_id NOT IN (SELECT id FROM users)`,
+		`INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename, identifiers)
+			SELECT m.id, message_text(m.text), COALESCE(u.name, ''), COALESCE(u.real_name, ''), m.filename, COALESCE(m.identifiers, '')
+			FROM messages m
+			LEFT JOIN users u ON u.id = m.user_id
+			WHERE m.id NOT IN (SELECT rowid FROM messages_fts)`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to index messages from missing users: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// backfill runs record over every stored message, for details first
+// recorded at ingest by a newer version
+func (db *DB) backfill(record func(e execer, ts, text string) error) error {
+	rows, err := db.conn.Query(`SELECT timestamp, message_text(text) FROM messages`)
+	if err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+	texts := make(map[string]string)
+	for rows.Next() {
+		var ts, text string
+		if err := rows.Scan(&ts, &text); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan message: %w", err)
+		}
+		texts[ts] = text
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for ts, text := range texts {
+		if err := record(tx, ts, text); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recordVersions records the Kubernetes versions a message mentions
+func recordVersions(e execer, ts, text string) error {
+	for _, version := range identifiers.KubernetesVersions(text) {
+		_, err := e.Exec(`INSERT OR IGNORE INTO message_versions (message_ts, version) VALUES (?, ?)`, ts, version)
+		if err != nil {
+			return fmt.Errorf("failed to record version mention: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordLogs stores the log pastes and stack traces in a message stored
+// before they were recorded at ingest
+func recordLogs(e execer, ts, text string) error {
+	logs := logblock.Extract(text)
+	if len(logs) == 0 {
+		return nil
+	}
+	_, err := e.Exec(`UPDATE messages SET logs = ? WHERE timestamp = ?`, strings.Join(logs, "\n\n"), ts)
+	if err != nil {
+		return fmt.Errorf("failed to record logs: %w", err)
+	}
+	return nil
+}
+
+// recordFingerprints records the fingerprints of the errors a message
+// quotes
+func recordFingerprints(e execer, ts, text string) error {
+	for _, fp := range fingerprint.Fingerprints(text) {
+		_, err := e.Exec(`INSERT OR IGNORE INTO message_fingerprints (message_ts, fingerprint) VALUES (?, ?)`, ts, fp)
+		if err != nil {
+			return fmt.Errorf("failed to record error fingerprint: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetVersionMentions returns every mention of a Kubernetes minor version
+// with the date of the message it is in, oldest first
+func (db *DB) GetVersionMentions(ctx context.Context) ([]models.VersionMention, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT v.version, m.date
+		FROM message_versions v
+		JOIN messages m ON m.timestamp = v.message_ts
+		GROUP BY v.message_ts, v.version
+		ORDER BY m.ts_micros`)
+	if err != nil {
+		return nil, fmt.Errorf("version query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var mentions []models.VersionMention
+	for rows.Next() {
+		var mention models.VersionMention
+		if err := rows.Scan(&mention.Version, &mention.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan version mention: %w", err)
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+// columnExists reports whether a table has a column with the given name
+func (db *DB) columnExists(table, column string) (bool, error) {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to read schema for %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, fmt.Errorf("failed to scan schema for %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// InsertUser inserts a user into the database, or updates them. Names left
+// empty, as exports do for some deleted users, keep their stored values.
+func (db *DB) InsertUser(user *models.User) error {
+	query := `INSERT INTO users (id, name, real_name, display_name, is_bot, deleted, placeholder)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(id) DO UPDATE SET
+			  	name = COALESCE(NULLIF(excluded.name, ''), users.name),
+			  	real_name = COALESCE(NULLIF(excluded.real_name, ''), users.real_name),
+			  	display_name = COALESCE(NULLIF(excluded.display_name, ''), users.display_name),
+			  	is_bot = excluded.is_bot,
+			  	deleted = excluded.deleted,
+			  	placeholder = excluded.placeholder`
+	
+	_, err := db.exec().Exec(query, user.ID, user.Name, user.RealName, user.DisplayName, user.IsBot, user.Deleted, user.Placeholder)
+	return err
+}
+
+// InsertBot inserts or updates a bot, along with the users row its messages
+// are attributed to. Details left empty keep their stored values.
+func (db *DB) InsertBot(bot *models.Bot) error {
+	_, err := db.exec().Exec(`INSERT INTO bots (id, name, app_id, service) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = COALESCE(NULLIF(excluded.name, ''), bots.name),
+			app_id = COALESCE(NULLIF(excluded.app_id, ''), bots.app_id),
+			service = COALESCE(NULLIF(excluded.service, ''), bots.service)`,
+		bot.ID, bot.Name, bot.AppID, bot.Service)
+	if err != nil {
+		return fmt.Errorf("failed to insert bot %s: %w", bot.ID, err)
+	}
+
+	_, err = db.exec().Exec(`INSERT INTO users (id, name, real_name, display_name, is_bot)
+		SELECT id, name, service, name, 1 FROM bots WHERE id = ?
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			real_name = excluded.real_name,
+			display_name = excluded.display_name,
+			is_bot = 1,
+			placeholder = 0`, bot.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert user for bot %s: %w", bot.ID, err)
+	}
+	return nil
+}
+
+// RecordUserProfile records a profile a user posted under, widening the
+// period it was seen in to include profile's
+func (db *DB) RecordUserProfile(profile *models.UserProfile) error {
+	_, err := db.exec().Exec(`INSERT INTO user_profiles (user_id, name, real_name, display_name, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, name, real_name, display_name) DO UPDATE SET
+			first_seen = MIN(first_seen, excluded.first_seen),
+			last_seen = MAX(last_seen, excluded.last_seen)`,
+		profile.UserID, profile.Name, profile.RealName, profile.DisplayName,
+		profile.FirstSeen.UnixMicro(), profile.LastSeen.UnixMicro())
+	if err != nil {
+		return fmt.Errorf("failed to record profile of user %s: %w", profile.UserID, err)
+	}
+	return nil
+}
+
+// GetUserProfiles returns the profiles recorded for the users with an ID,
+// or a current or former name, matching user, by user and oldest first
+func (db *DB) GetUserProfiles(ctx context.Context, user string) ([]*models.UserProfile, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT user_id, name, real_name, display_name, first_seen, last_seen
+		FROM user_profiles
+		WHERE user_id IN (
+			SELECT id FROM users
+			WHERE id = ?1 OR name = ?1 COLLATE NOCASE OR real_name = ?1 COLLATE NOCASE OR display_name = ?1 COLLATE NOCASE
+			UNION
+			SELECT user_id FROM user_profiles
+			WHERE name = ?1 COLLATE NOCASE OR real_name = ?1 COLLATE NOCASE OR display_name = ?1 COLLATE NOCASE)
+		ORDER BY user_id, first_seen`, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []*models.UserProfile
+	for rows.Next() {
+		profile := &models.UserProfile{}
+		var firstSeen, lastSeen int64
+		if err := rows.Scan(&profile.UserID, &profile.Name, &profile.RealName, &profile.DisplayName, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan user profile: %w", err)
+		}
+		profile.FirstSeen = time.UnixMicro(firstSeen).UTC()
+		profile.LastSeen = time.UnixMicro(lastSeen).UTC()
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// InsertPlaceholderUser adds a placeholder user for a message author
+// missing from the export's user list, unless the user already exists. It
+// reports whether the placeholder was added.
+func (db *DB) InsertPlaceholderUser(user *models.User) (bool, error) {
+	result, err := db.exec().Exec(`INSERT OR IGNORE INTO users (id, name, real_name, display_name, placeholder)
+		VALUES (?, ?, ?, ?, 1)`, user.ID, user.Name, user.RealName, user.DisplayName)
+	if err != nil {
+		return false, err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return added > 0, nil
+}
+
+// InsertChannel inserts or updates a channel and its membership in the database
+func (db *DB) InsertChannel(channel *models.Channel) error {
+	// Upsert rather than replace so the row keeps its rowid and the FTS
+	// update trigger fires
+	query := `INSERT INTO channels (id, name, created, creator, is_archived, topic, purpose, kind)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(id) DO UPDATE SET
+			  	name = excluded.name,
+			  	created = excluded.created,
+			  	creator = excluded.creator,
+			  	is_archived = excluded.is_archived,
+			  	topic = excluded.topic,
+			  	purpose = excluded.purpose,
+			  	kind = excluded.kind`
+	
+	kind := channel.Kind
+	if kind == "" {
+		kind = models.KindChannel
+	}
+
+	_, err := db.exec().Exec(query, channel.ID, channel.Name, channel.Created, channel.Creator, channel.IsArchived,
+						  channel.Topic, channel.Purpose, kind)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range channel.Members {
+		_, err := db.exec().Exec(`INSERT OR IGNORE INTO channel_members (channel_id, user_id) VALUES (?, ?)`,
+			channel.ID, member)
+		if err != nil {
+			return fmt.Errorf("failed to insert member %s: %w", member, err)
+		}
+	}
+
+	return nil
+}
+
+// InsertMessage inserts a message into the database along with its search
+// index entry
+func (db *DB) InsertMessage(message *models.Message) error {
+	query := `INSERT INTO messages (user_id, text, type, subtype, timestamp, date, ts_micros, filename, thread_ts, reply_count, reaction_count,
+			  has_link, has_code, has_file, has_reaction, identifiers, logs)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	
+	tokens := strings.Join(identifiers.Expand(message.Text), " ")
+	text, err := compressText(message.Text, db.compressText)
+	if err != nil {
+		return err
+	}
+	result, err := db.prepared().Exec(query, message.UserID, text, message.Type, message.Subtype, 
+						  message.Timestamp, message.Date.UTC(), message.TSMicros, message.Filename, message.ThreadTS, message.ReplyCount,
+						  message.ReactionCount, message.HasLink, message.HasCode, message.HasFile, message.ReactionCount > 0,
+						  tokens, strings.Join(logblock.Extract(message.Text), "\n\n"))
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.prepared().Exec(`INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename, identifiers, files)
+		VALUES (?, ?,
+			-- The author's names are looked up with subqueries rather than a
+			-- join, so a message whose author has no users row is still indexed
+			COALESCE((SELECT name FROM users WHERE id = ?), ''), COALESCE((SELECT real_name FROM users WHERE id = ?), ''),
+			?, ?,
+			-- Text extracted from the message's files before it was reinserted
+			-- is indexed with it, as messages_fts_content reads it back
+			COALESCE((SELECT group_concat(content, ' ') FROM file_contents WHERE message_ts = ?), ''))`,
+		id, message.Text, message.UserID, message.UserID, message.Filename, tokens, message.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to index message: %w", err)
+	}
+
+	if err := recordVersions(db.prepared(), message.Timestamp, message.Text); err != nil {
+		return err
+	}
+	return recordFingerprints(db.prepared(), message.Timestamp, message.Text)
+}
+
+// MarkFileIndexed records that a message file has been fully indexed
+func (db *DB) MarkFileIndexed(filename string, messageCount int) error {
+	_, err := db.exec().Exec(`INSERT OR REPLACE INTO ingested_files (filename, message_count, indexed_at) VALUES (?, ?, ?)`,
+		filename, messageCount, time.Now())
+	return err
+}
+
+// IndexedFiles returns the set of message files already fully indexed
+func (db *DB) IndexedFiles(ctx context.Context) (map[string]bool, error) {
+	rows, err := db.conn.QueryContext(ctx, "SELECT filename FROM ingested_files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingest checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]bool)
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, fmt.Errorf("failed to scan ingest checkpoint: %w", err)
+		}
+		files[filename] = true
+	}
+
+	return files, rows.Err()
+}
+
+// SearchMessages performs full-text search on messages
+func (db *DB) SearchMessages(ctx context.Context, query string, limit int) ([]*models.SearchResult, error) {
+	return db.SearchMessagesFiltered(ctx, query, models.SearchFilter{}, limit)
+}
+
+// SearchMessagesFiltered performs a full-text search restricted by filter.
+// An empty query matches every message that passes the filter, without
+// snippets. Results are ordered by filter.Sort, breaking ties by date and
+// then ID so the order is the same on every run.
+func (db *DB) SearchMessagesFiltered(ctx context.Context, query string, filter models.SearchFilter, limit int) ([]*models.SearchResult, error) {
+	var results []*models.SearchResult
+	err := db.StreamMessagesFiltered(ctx, query, filter, limit, func(result *models.SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// StreamMessagesFiltered runs the same search as SearchMessagesFiltered but
+// passes each result to fn as it is read, so large result sets need not be
+// held in memory. A limit of zero or less returns every match. An error
+// from fn stops the search and is returned.
+func (db *DB) StreamMessagesFiltered(ctx context.Context, query string, filter models.SearchFilter, limit int, fn func(*models.SearchResult) error) (err error) {
+	var scanID int64
+	var finish func(string, []interface{}, int, error)
+	if db.tracer != nil {
+		scanID, finish = db.traceSearch(ctx, query, filter, limit)
+	}
+	sqlQuery, args := searchSQL(query, filter, limit, scanID)
+	results := 0
+	if finish != nil {
+		defer func() { finish(sqlQuery, args, results, err) }()
+	}
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("search query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		result := &models.SearchResult{}
+		var offsets string
+		err := rows.Scan(
+			&result.ID,
+			&result.UserID,
+			&result.Text,
+			&result.Type,
+			&result.Subtype,
+			&result.Timestamp,
+			&result.Date,
+			&result.TSMicros,
+			&result.Filename,
+			&result.ThreadTS,
+			&result.UserName,
+			&result.UserRealName,
+			&result.Rank,
+			&result.Snippet,
+			&offsets,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan result: %w", err)
+		}
+		if offsets != "" {
+			matches := matchOffsets(offsets)
+			result.Hits = len(matches)
+			if db.snippets > 1 {
+				if snippet := extractSnippets(result.Text, matches, db.snippets); snippet != "" {
+					result.Snippet = snippet
+				}
+			}
+		}
+		results++
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("search query failed: %w", err)
+	}
+	return nil
+}
+
+// ExplainSearch describes how StreamMessagesFiltered would run a search
+// without running it: the FTS match expression after rewriting, the SQL and
+// its arguments, and SQLite's query plan
+func (db *DB) ExplainSearch(ctx context.Context, query string, filter models.SearchFilter, limit int) (*models.SearchExplanation, error) {
+	sqlQuery, args := searchSQL(query, filter, limit, 0)
+
+	explanation := &models.SearchExplanation{
+		SQL:  strings.TrimSpace(sqlQuery),
+		Args: args,
+	}
+	if query != "" {
+		explanation.Match = identifiers.RewriteQuery(query, "identifiers")
+	}
+
+	plan, err := db.queryPlan(ctx, sqlQuery, args)
+	if err != nil {
+		return nil, err
+	}
+	explanation.Plan = plan
+
+	return explanation, nil
+}
+
+// searchSQL builds the statement and arguments for a filtered full-text
+// search. An empty query matches every message that passes the filter. A
+// non-zero scanID counts the rows the search reads (see traceSearch).
+func searchSQL(query string, filter models.SearchFilter, limit int, scanID int64) (string, []interface{}) {
+	// SQLite treats a negative limit as no limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	conditions, args := filterConditions(filter)
+	if scanID != 0 {
+		conditions = append([]string{"search_scanned(?, m.id)"}, conditions...)
+		args = append([]interface{}{scanID}, args...)
+	}
+
+	// Full-text matches are ranked by BM25 with the feedback boost added;
+	// without a query only the feedback ranks
+	rank := feedbackRank
+	if query != "" {
+		rank = `(` + matchRank + ` + ` + feedbackRank + `)`
+	}
+
+	// Messages quoting more of the errors being looked for rank higher
+	var rankArgs []interface{}
+	if len(filter.Fingerprints) > 0 {
+		rank = `(` + rank + ` + (SELECT COUNT(*) FROM message_fingerprints fp
+			WHERE fp.message_ts = m.timestamp AND fp.fingerprint IN (?` + strings.Repeat(", ?", len(filter.Fingerprints)-1) + `)))`
+		for _, fp := range filter.Fingerprints {
+			rankArgs = append(rankArgs, fp)
+		}
+	}
+
+	var sqlQuery string
+	if query != "" {
+		query = identifiers.RewriteQuery(query, "identifiers")
+		conditions = append([]string{"messages_fts MATCH ?"}, conditions...)
+		args = append([]interface{}{query}, args...)
+		sqlQuery = `
+		SELECT 
+			m.id,
+			m.user_id,
+			message_text(m.text),
+			m.type,
+			m.subtype,
+			m.timestamp,
+			m.date,
+			COALESCE(m.ts_micros, 0),
+			m.filename,
+			COALESCE(m.thread_ts, ''),
+			` + authorNameAt + ` as user_name,
+			` + authorRealNameAt + ` as user_real_name,
+			` + rank + ` as rank,
+			-- Snippets come from the text only, never the identifiers column
+			snippet(messages_fts, '<mark>', '</mark>', '...', 0, 32) as snippet,
+			-- Matches are counted, and further snippets cut, from their offsets
+			offsets(messages_fts) as offsets
+		FROM messages_fts fts
+		JOIN messages m ON m.id = fts.rowid
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + orderBy(filter.Sort) + `
+		LIMIT ?`
+	} else {
+		where := ""
+		if len(conditions) > 0 {
+			where = "WHERE " + strings.Join(conditions, " AND ")
+		}
+		sqlQuery = `
+		SELECT 
+			m.id,
+			m.user_id,
+			message_text(m.text),
+			m.type,
+			m.subtype,
+			m.timestamp,
+			m.date,
+			COALESCE(m.ts_micros, 0),
+			m.filename,
+			COALESCE(m.thread_ts, ''),
+			` + authorNameAt + ` as user_name,
+			` + authorRealNameAt + ` as user_real_name,
+			` + rank + ` as rank,
+			'' as snippet,
+			'' as offsets
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		` + where + `
+		ORDER BY ` + orderBy(filter.Sort) + `
+		LIMIT ?`
+	}
+	// The rank is selected before the conditions, so its arguments come first
+	args = append(append(rankArgs, args...), limit)
+
+	return sqlQuery, args
+}
+
+// CountMessages returns the number of messages matching a full-text query
+// and filter without fetching them. An empty query counts every message
+// that passes the filter.
+func (db *DB) CountMessages(ctx context.Context, query string, filter models.SearchFilter) (int, error) {
+	conditions, args := filterConditions(filter)
+	if query != "" {
+		query = identifiers.RewriteQuery(query, "identifiers")
+	}
+
+	var sqlQuery string
+	switch {
+	case query != "" && len(conditions) == 0:
+		// The FTS index alone can answer an unfiltered count
+		sqlQuery = `SELECT COUNT(*) FROM messages_fts WHERE messages_fts MATCH ?`
+		args = []interface{}{query}
+	case query != "":
+		conditions = append([]string{"messages_fts MATCH ?"}, conditions...)
+		args = append([]interface{}{query}, args...)
+		sqlQuery = `
+		SELECT COUNT(*)
+		FROM messages_fts fts
+		JOIN messages m ON m.id = fts.rowid
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE ` + strings.Join(conditions, " AND ")
+	default:
+		where := ""
+		if len(conditions) > 0 {
+			where = "WHERE " + strings.Join(conditions, " AND ")
+		}
+		sqlQuery = `
+		SELECT COUNT(*)
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		` + where
+	}
+
+	var count int
+	if err := db.conn.QueryRowContext(ctx, sqlQuery, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count query failed: %w", err)
+	}
+
+	return count, nil
+}
+
+// matchRank scores how well a message matches a full-text query, by BM25
+// over the search index's columns (see bm25)
+const matchRank = `bm25(matchinfo(messages_fts, 'pcnalx'))`
+
+// feedbackRank scores a message (m) by the feedback on it, plus half the
+// feedback on other messages in its thread, so marking one answer helpful
+// also lifts the rest of the discussion
+const feedbackRank = `(
+			COALESCE((SELECT f.score FROM feedback f WHERE f.message_ts = m.timestamp), 0) +
+			0.5 * COALESCE((SELECT SUM(f.score) FROM feedback f
+				WHERE f.thread_ts = COALESCE(NULLIF(m.thread_ts, ''), m.timestamp) AND f.message_ts != m.timestamp), 0))`
+
+// authorNameAt and authorRealNameAt select the name and real name the
+// author of a message (m) went by when it was posted, from their recorded
+// profiles, falling back to their users row (u)
+const (
+	authorNameAt = `COALESCE((SELECT p.name FROM user_profiles p
+				WHERE p.user_id = m.user_id AND p.first_seen <= m.ts_micros
+				ORDER BY p.first_seen DESC LIMIT 1), u.name, '')`
+	authorRealNameAt = `COALESCE((SELECT p.real_name FROM user_profiles p
+				WHERE p.user_id = m.user_id AND p.first_seen <= m.ts_micros
+				ORDER BY p.first_seen DESC LIMIT 1), u.real_name, '')`
+)
+
+// orderBy returns the ORDER BY clause for a search sort order. Every order
+// ends with the message ID so ties are broken the same way on every run.
+func orderBy(sort string) string {
+	switch sort {
+	case models.SortNewest:
+		return "m.ts_micros DESC, m.id DESC"
+	case models.SortOldest:
+		return "m.ts_micros ASC, m.id ASC"
+	case models.SortRandom:
+		return "RANDOM()"
+	default:
+		return "rank DESC, m.ts_micros DESC, m.id DESC"
+	}
+}
+
+// filterConditions translates a search filter into SQL conditions on the
+// messages (m) and users (u) tables
+func filterConditions(filter models.SearchFilter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(filter.Users) > 0 {
+		var users []string
+		for _, user := range filter.Users {
+			// Names the user went by earlier match too
+			users = append(users, `(m.user_id = ? OR u.name = ? COLLATE NOCASE OR u.display_name = ? COLLATE NOCASE OR u.real_name = ? COLLATE NOCASE
+				OR m.user_id IN (SELECT p.user_id FROM user_profiles p
+					WHERE p.name = ? COLLATE NOCASE OR p.display_name = ? COLLATE NOCASE OR p.real_name = ? COLLATE NOCASE))`)
+			args = append(args, user, user, user, user, user, user, user)
+		}
+		conditions = append(conditions, "("+strings.Join(users, " OR ")+")")
+	}
+
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "m.date >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "m.date < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	for _, has := range filter.Has {
+		switch has {
+		case models.HasLink:
+			conditions = append(conditions, "m.has_link = 1")
+		case models.HasCode:
+			conditions = append(conditions, "m.has_code = 1")
+		case models.HasFile:
+			conditions = append(conditions, "m.has_file = 1")
+		case models.HasReaction:
+			conditions = append(conditions, "m.has_reaction = 1")
+		case models.HasLogs:
+			conditions = append(conditions, "m.logs != ''")
+		}
+	}
+
+	// A thread tag applies to the starter and every reply
+	for _, tag := range filter.Tags {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM tags t WHERE t.tag = ? AND
+			(t.message_ts = m.timestamp OR (t.thread AND t.message_ts = NULLIF(m.thread_ts, ''))))`)
+		args = append(args, tag)
+	}
+
+	if filter.K8sVersion != "" {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM message_versions v
+			WHERE v.message_ts = m.timestamp AND v.version = ?)`)
+		args = append(args, filter.K8sVersion)
+	}
+
+	if len(filter.Fingerprints) > 0 {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM message_fingerprints fp
+			WHERE fp.message_ts = m.timestamp AND fp.fingerprint IN (?`+strings.Repeat(", ?", len(filter.Fingerprints)-1)+`))`)
+		for _, fp := range filter.Fingerprints {
+			args = append(args, fp)
+		}
+	}
+
+	if filter.AfterID > 0 {
+		conditions = append(conditions, "m.id > ?")
+		args = append(args, filter.AfterID)
+	}
+
+	// Thread starters carry their own timestamp as thread_ts; replies carry
+	// the starter's
+	switch filter.Scope {
+	case models.ScopeThreadStarters:
+		conditions = append(conditions, "m.thread_ts = m.timestamp")
+	case models.ScopeThreadReplies:
+		conditions = append(conditions, "m.thread_ts != '' AND m.thread_ts != m.timestamp")
+	case models.ScopeTopLevel:
+		conditions = append(conditions, "(m.thread_ts IS NULL OR m.thread_ts = '' OR m.thread_ts = m.timestamp)")
+	}
+
+	return conditions, args
+}
+
+// SuggestTerms returns indexed message terms starting with prefix, most
+// frequent first. Stopwords and terms shorter than the minimum term length
+// are left out.
+func (db *DB) SuggestTerms(ctx context.Context, prefix string, limit int) ([]models.TermSuggestion, error) {
+	filter, err := db.GetTermFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := termFilterConditions(filter)
+
+	// The tokenizer lowercases terms, and a range scan on the vocabulary is
+	// far cheaper than LIKE
+	prefix = strings.ToLower(prefix)
+	sqlQuery := `
+		SELECT term, documents, occurrences
+		FROM messages_terms
+		WHERE col = 0 AND term >= ? AND term < ?` + conditions + `
+		ORDER BY occurrences DESC, term
+		LIMIT ?`
+
+	args = append([]interface{}{prefix, prefix + "\U0010FFFF"}, args...)
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("suggestion query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var suggestions []models.TermSuggestion
+	for rows.Next() {
+		var suggestion models.TermSuggestion
+		if err := rows.Scan(&suggestion.Term, &suggestion.Documents, &suggestion.Occurrences); err != nil {
+			return nil, fmt.Errorf("failed to scan suggestion: %w", err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// GetTopTerms returns the indexed message terms found in the most messages,
+// skipping stopwords, terms shorter than the minimum term length and terms
+// containing digits
+func (db *DB) GetTopTerms(ctx context.Context, limit int) ([]models.TermSuggestion, error) {
+	filter, err := db.GetTermFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := termFilterConditions(filter)
+
+	sqlQuery := `
+		SELECT term, documents, occurrences
+		FROM messages_terms
+		WHERE col = 0 AND term NOT GLOB '*[0-9]*'` + conditions + `
+		ORDER BY documents DESC, term
+		LIMIT ?`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("term query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var terms []models.TermSuggestion
+	for rows.Next() {
+		var term models.TermSuggestion
+		if err := rows.Scan(&term.Term, &term.Documents, &term.Occurrences); err != nil {
+			return nil, fmt.Errorf("failed to scan term: %w", err)
+		}
+		terms = append(terms, term)
+	}
+
+	return terms, rows.Err()
+}
+
+// DefaultStopwords are the frequent English and chat words left out of term
+// statistics until a database is given its own list
+var DefaultStopwords = []string{
+	"about", "after", "again", "all", "also", "and", "any", "are", "because",
+	"been", "before", "being", "but", "can", "could", "did", "does", "doing",
+	"don", "each", "even", "for", "from", "get", "got", "had", "has", "have",
+	"here", "how", "into", "its", "just", "know", "like", "make", "maybe",
+	"more", "most", "much", "need", "not", "now", "one", "only", "other",
+	"our", "out", "over", "really", "same", "see", "should", "some", "still",
+	"such", "sure", "than", "thank", "thanks", "that", "the", "their", "them",
+	"then", "there", "these", "they", "thing", "think", "this", "those",
+	"through", "use", "using", "very", "want", "was", "way", "were", "what",
+	"when", "where", "which", "while", "who", "why", "will", "with", "would",
+	"yeah", "yes", "you", "your", "http", "https", "www", "com",
+}
+
+// DefaultMinTermLength is the shortest term counted in term statistics
+// until a database is given its own minimum
+const DefaultMinTermLength = 3
+
+// Metadata keys holding the term filter
+const (
+	metadataStopwords     = "stopwords"
+	metadataMinTermLength = "min_term_length"
+)
+
+// metadataWorkspaceMeta marks a database whose users and channels tables
+// hold only its message authors and its own channel, the rest of the
+// workspace directory being in the shared workspace-meta.db
+const metadataWorkspaceMeta = "workspace_meta"
+
+// MarkWorkspaceMeta records that the database keeps the workspace's users
+// and channels in the shared workspace-meta.db
+func (db *DB) MarkWorkspaceMeta(ctx context.Context) error {
+	return db.SetMetadata(ctx, metadataWorkspaceMeta, "1")
+}
+
+// UsesWorkspaceMeta reports whether the database keeps the workspace's
+// users and channels in the shared workspace-meta.db
+func (db *DB) UsesWorkspaceMeta(ctx context.Context) (bool, error) {
+	_, ok, err := db.GetMetadata(ctx, metadataWorkspaceMeta)
+	return ok, err
+}
+
+// querier is implemented by both *sql.DB and *sql.Conn
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// workspaceUsers combines a database's users with the shared
+// workspace-meta.db's, attached as meta, preferring the database's own rows
+const workspaceUsers = `(SELECT id, name, real_name, display_name, is_bot, deleted, placeholder FROM main.users
+	UNION ALL
+	SELECT id, name, real_name, display_name, is_bot, deleted, placeholder FROM meta.users
+	WHERE id NOT IN (SELECT id FROM main.users))`
+
+// directory runs fn for a query over the whole workspace directory, passing
+// the table expression to select users from and the schema prefix of the
+// channels, channels_fts and channel_members tables. For a database that
+// keeps its directory in the shared workspace-meta.db, that database is
+// attached as meta while fn runs; if it is missing, the database's own
+// tables are used.
+func (db *DB) directory(ctx context.Context, fn func(q querier, users, schema string) error) error {
+	shared, err := db.UsesWorkspaceMeta(ctx)
+	if err != nil {
+		return err
+	}
+	if !shared || db.metaPath == "" || !fileExists(db.metaPath) {
+		return fn(db.conn, "users", "")
+	}
+
+	// ATTACH applies to a single connection, so hold one for the query
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS meta", db.metaPath); err != nil {
+		return fmt.Errorf("failed to attach %s: %w", db.metaPath, err)
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE meta")
+
+	return fn(conn, workspaceUsers, "meta.")
+}
+
+// GetMetadata returns a database setting and whether it is set
+func (db *DB) GetMetadata(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := db.conn.QueryRowContext(ctx, `SELECT value FROM metadata WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read metadata %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// SetMetadata stores a database setting, replacing any previous value
+func (db *DB) SetMetadata(ctx context.Context, key, value string) error {
+	_, err := db.conn.ExecContext(ctx, `INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to write metadata %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteMetadata removes a database setting
+func (db *DB) DeleteMetadata(ctx context.Context, key string) error {
+	if _, err := db.conn.ExecContext(ctx, `DELETE FROM metadata WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete metadata %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetTermFilter returns the stopwords and minimum term length applied to
+// term statistics, falling back to the defaults for settings not made
+func (db *DB) GetTermFilter(ctx context.Context) (*models.TermFilter, error) {
+	filter := &models.TermFilter{
+		Stopwords: append([]string(nil), DefaultStopwords...),
+		MinLength: DefaultMinTermLength,
+	}
+
+	if value, ok, err := db.GetMetadata(ctx, metadataStopwords); err != nil {
+		return nil, err
+	} else if ok {
+		filter.Stopwords = strings.Fields(value)
+	} else {
+		filter.Default = true
+	}
+
+	if value, ok, err := db.GetMetadata(ctx, metadataMinTermLength); err != nil {
+		return nil, err
+	} else if ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum term length in metadata: %q", value)
+		}
+		filter.MinLength = n
+	}
+
+	sort.Strings(filter.Stopwords)
+	return filter, nil
+}
+
+// SetTermFilter stores the stopwords and minimum term length applied to
+// term statistics. A nil filter restores the defaults, and a filter marked
+// Default keeps the built-in stopwords.
+func (db *DB) SetTermFilter(ctx context.Context, filter *models.TermFilter) error {
+	if filter == nil {
+		if err := db.DeleteMetadata(ctx, metadataStopwords); err != nil {
+			return err
+		}
+		return db.DeleteMetadata(ctx, metadataMinTermLength)
+	}
+
+	if filter.Default {
+		if err := db.DeleteMetadata(ctx, metadataStopwords); err != nil {
+			return err
+		}
+		return db.SetMetadata(ctx, metadataMinTermLength, strconv.Itoa(filter.MinLength))
+	}
+
+	words := make([]string, 0, len(filter.Stopwords))
+	for _, word := range filter.Stopwords {
+		words = append(words, strings.ToLower(word))
+	}
+	sort.Strings(words)
+	if err := db.SetMetadata(ctx, metadataStopwords, strings.Join(words, " ")); err != nil {
+		return err
+	}
+	return db.SetMetadata(ctx, metadataMinTermLength, strconv.Itoa(filter.MinLength))
+}
+
+// termFilterConditions returns SQL conditions on messages_terms.term
+// applying a term filter, each starting with AND
+func termFilterConditions(filter *models.TermFilter) (string, []interface{}) {
+	var conditions string
+	var args []interface{}
+
+	if filter.MinLength > 1 {
+		conditions += " AND length(term) >= ?"
+		args = append(args, filter.MinLength)
+	}
+	if len(filter.Stopwords) > 0 {
+		conditions += " AND term NOT IN (?" + strings.Repeat(", ?", len(filter.Stopwords)-1) + ")"
+		for _, word := range filter.Stopwords {
+			args = append(args, word)
+		}
+	}
+
+	return conditions, args
+}
+
+// SearchUsers finds users whose name, real name or display name contains the
+// pattern (case-insensitive)
+func (db *DB) SearchUsers(ctx context.Context, pattern string, limit int) ([]*models.User, error) {
+	var users []*models.User
+	err := db.directory(ctx, func(q querier, usersTable, _ string) error {
+		sqlQuery := `
+		SELECT id, name, COALESCE(real_name, ''), COALESCE(display_name, ''), is_bot, deleted
+		FROM ` + usersTable + `
+		WHERE name LIKE ? ESCAPE '\'
+		   OR real_name LIKE ? ESCAPE '\'
+		   OR display_name LIKE ? ESCAPE '\'
+		   OR id IN (SELECT user_id FROM user_profiles
+		   	WHERE name LIKE ? ESCAPE '\' OR real_name LIKE ? ESCAPE '\' OR display_name LIKE ? ESCAPE '\')
+		ORDER BY name
+		LIMIT ?`
+
+		like := "%" + escapeLike(pattern) + "%"
+		rows, err := q.QueryContext(ctx, sqlQuery, like, like, like, like, like, like, limit)
+		if err != nil {
+			return fmt.Errorf("user search query failed: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user := &models.User{}
+			if err := rows.Scan(&user.ID, &user.Name, &user.RealName, &user.DisplayName, &user.IsBot, &user.Deleted); err != nil {
+				return fmt.Errorf("failed to scan user: %w", err)
+			}
+			users = append(users, user)
+		}
+		return rows.Err()
+	})
+
+	return users, err
+}
+
+// escapeLike escapes LIKE wildcards so patterns are matched literally
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		"%", "\\%",
+		"_", "\\_",
+	)
+	return replacer.Replace(s)
+}
+
+// SearchChannels performs full-text search on channel names, topics and purposes
+func (db *DB) SearchChannels(ctx context.Context, query string, limit int) ([]*models.ChannelSearchResult, error) {
+	var results []*models.ChannelSearchResult
+	err := db.directory(ctx, func(q querier, _, schema string) error {
+		sqlQuery := `
+		SELECT 
+			c.id,
+			c.name,
+			COALESCE(c.created, 0),
+			COALESCE(c.creator, ''),
+			c.is_archived,
+			COALESCE(c.topic, ''),
+			COALESCE(c.purpose, ''),
+			(SELECT COUNT(*) FROM ` + schema + `channel_members cm WHERE cm.channel_id = c.id) as member_count,
+			snippet(channels_fts, '<mark>', '</mark>', '...', -1, 16) as snippet
+		FROM ` + schema + `channels_fts
+		JOIN ` + schema + `channels c ON c.rowid = channels_fts.rowid
+		WHERE channels_fts MATCH ?
+		ORDER BY member_count DESC, c.name
+		LIMIT ?`
+
+		rows, err := q.QueryContext(ctx, sqlQuery, query, limit)
+		if err != nil {
+			return fmt.Errorf("channel search query failed: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			result := &models.ChannelSearchResult{}
+			err := rows.Scan(
+				&result.ID,
+				&result.Name,
+				&result.Created,
+				&result.Creator,
+				&result.IsArchived,
+				&result.Topic,
+				&result.Purpose,
+				&result.MemberCount,
+				&result.Snippet,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to scan channel: %w", err)
+			}
+			results = append(results, result)
+		}
+		return rows.Err()
+	})
+
+	return results, err
+}
+
+// GetChannelInfo returns metadata for the named channel along with the
+// range of indexed message dates
+func (db *DB) GetChannelInfo(ctx context.Context, name string) (*models.ChannelInfo, error) {
+	info := &models.ChannelInfo{}
+
+	err := db.directory(ctx, func(q querier, users, schema string) error {
+		query := `
+		SELECT c.id, c.name, COALESCE(c.created, 0), COALESCE(c.creator, ''), c.is_archived,
+			COALESCE(c.topic, ''), COALESCE(c.purpose, ''), COALESCE(c.kind, 'channel'),
+			COALESCE(NULLIF(u.real_name, ''), u.name, '')
+		FROM ` + schema + `channels c
+		LEFT JOIN ` + users + ` u ON u.id = c.creator
+		WHERE c.name = ?`
+
+		err := q.QueryRowContext(ctx, query, name).Scan(
+			&info.ID,
+			&info.Name,
+			&info.Created,
+			&info.Creator,
+			&info.IsArchived,
+			&info.Topic,
+			&info.Purpose,
+			&info.Kind,
+			&info.CreatorName,
+		)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("channel not found: %s", name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get channel: %w", err)
+		}
+
+		if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+schema+"channel_members WHERE channel_id = ?", info.ID).Scan(&info.MemberCount); err != nil {
+			return fmt.Errorf("failed to count members: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages").Scan(&info.MessageCount); err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	if info.MessageCount > 0 {
+		if err := db.conn.QueryRowContext(ctx, "SELECT date FROM messages ORDER BY date ASC LIMIT 1").Scan(&info.FirstMessage); err != nil {
+			return nil, fmt.Errorf("failed to get first message date: %w", err)
+		}
+		if err := db.conn.QueryRowContext(ctx, "SELECT date FROM messages ORDER BY date DESC LIMIT 1").Scan(&info.LastMessage); err != nil {
+			return nil, fmt.Errorf("failed to get last message date: %w", err)
+		}
+	}
+
+	return info, nil
+}
+
+// GetFirstReplies returns every thread's start and the time of its first
+// reply by someone other than the starter's author, oldest thread first.
+// FirstReply is zero for threads nobody else has answered.
+func (db *DB) GetFirstReplies(ctx context.Context) ([]models.ThreadReply, error) {
+	sqlQuery := `
+		SELECT s.timestamp, s.ts_micros,
+			(SELECT MIN(r.ts_micros) FROM messages r
+			 WHERE r.thread_ts = s.timestamp AND r.timestamp != s.timestamp AND r.user_id != s.user_id)
+		FROM messages s
+		WHERE s.thread_ts = s.timestamp
+		ORDER BY s.ts_micros`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thread replies: %w", err)
+	}
+	defer rows.Close()
+
+	var threads []models.ThreadReply
+	for rows.Next() {
+		var thread models.ThreadReply
+		var started int64
+		var firstReply sql.NullInt64
+		if err := rows.Scan(&thread.ThreadTS, &started, &firstReply); err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
+		}
+		thread.Started = time.UnixMicro(started)
+		if firstReply.Valid {
+			thread.FirstReply = time.UnixMicro(firstReply.Int64)
+		}
+		threads = append(threads, thread)
+	}
+
+	return threads, rows.Err()
+}
+
+// GetStats returns basic statistics about the database
+func (db *DB) GetStats(ctx context.Context) (map[string]int, error) {
+	stats := make(map[string]int)
+	
+	queries := map[string]string{
+		"users":    "SELECT COUNT(*) FROM users",
+		"channels": "SELECT COUNT(*) FROM channels", 
+		"messages": "SELECT COUNT(*) FROM messages",
+	}
+	
+	for key, query := range queries {
+		var count int
+		err := db.conn.QueryRowContext(ctx, query).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s count: %w", key, err)
+		}
+		stats[key] = count
+	}
+	
+	return stats, nil
+}
+
+// GetLeaderboard returns the most active posters, thread starters and
+// repliers, optionally restricted to a single year (0 for all time)
+func (db *DB) GetLeaderboard(ctx context.Context, year, limit int) (*models.Leaderboard, error) {
+	leaderboard := &models.Leaderboard{Year: year}
+
+	var err error
+	leaderboard.TopPosters, err = db.topUsers(ctx, "1 = 1", year, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top posters: %w", err)
+	}
+
+	leaderboard.TopThreadStarters, err = db.topUsers(ctx, "m.thread_ts = m.timestamp", year, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top thread starters: %w", err)
+	}
+
+	leaderboard.TopRepliers, err = db.topUsers(ctx, "m.thread_ts != '' AND m.thread_ts != m.timestamp", year, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top repliers: %w", err)
+	}
+
+	return leaderboard, nil
+}
+
+// GetDailyMessageCounts returns the number of messages posted on each day
+// that has any in the local time zone, oldest first
+func (db *DB) GetDailyMessageCounts(ctx context.Context) ([]models.DayCount, error) {
+	// Dates are stored in UTC, so count per quarter hour (every time zone
+	// offset is a multiple of 15 minutes) and assign those to local days
+	sqlQuery := `
+		SELECT substr(m.date, 1, 14) || printf('%02d', CAST(substr(m.date, 15, 2) AS INTEGER) / 15 * 15) as slot,
+			COUNT(*)
+		FROM messages m
+		GROUP BY slot
+		ORDER BY slot`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages per day: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []models.DayCount{}
+	for rows.Next() {
+		var slot string
+		var count int
+		if err := rows.Scan(&slot, &count); err != nil {
+			return nil, err
+		}
+
+		start, err := time.ParseInLocation("2006-01-02 15:04", slot, time.UTC)
+		if err != nil {
+			continue
+		}
+		day := start.Local().Format("2006-01-02")
+
+		// Slots are in time order, so each day's slots are contiguous
+		if n := len(counts); n > 0 && counts[n-1].Date == day {
+			counts[n-1].Count += count
+		} else {
+			counts = append(counts, models.DayCount{Date: day, Count: count})
+		}
+	}
+
+	return counts, rows.Err()
+}
+
+// topUsers counts messages per user matching the given condition
+func (db *DB) topUsers(ctx context.Context, condition string, year, limit int) ([]models.LeaderboardEntry, error) {
+	sqlQuery := `
+		SELECT 
+			m.user_id,
+			COALESCE(u.name, '') as user_name,
+			COALESCE(u.real_name, '') as user_real_name,
+			COUNT(*) as message_count
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE ` + condition + `
+		  AND (? = 0 OR substr(m.date, 1, 4) = printf('%04d', ?))
+		GROUP BY m.user_id
+		ORDER BY message_count DESC, m.user_id
+		LIMIT ?`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, year, year, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		if err := rows.Scan(&entry.UserID, &entry.UserName, &entry.UserRealName, &entry.Count); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// messageColumns is the column list used when loading full messages joined
+// with their author
+const messageColumns = `
+			m.id,
+			m.user_id,
+			message_text(m.text),
+			COALESCE(m.type, ''),
+			COALESCE(m.subtype, ''),
+			COALESCE(m.timestamp, ''),
+			m.date,
+			COALESCE(m.ts_micros, 0),
+			COALESCE(m.filename, ''),
+			COALESCE(m.thread_ts, ''),
+			COALESCE(m.reply_count, 0),
+			COALESCE(m.reaction_count, 0),
+			` + authorNameAt + ` as user_name,
+			` + authorRealNameAt + ` as user_real_name`
+
+// scanMessage scans the current row selected with messageColumns
+func scanMessage(rows *sql.Rows) (*models.Message, error) {
+	message := &models.Message{}
+	err := rows.Scan(
+		&message.ID,
+		&message.UserID,
+		&message.Text,
+		&message.Type,
+		&message.Subtype,
+		&message.Timestamp,
+		&message.Date,
+		&message.TSMicros,
+		&message.Filename,
+		&message.ThreadTS,
+		&message.ReplyCount,
+		&message.ReactionCount,
+		&message.UserName,
+		&message.UserRealName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan message: %w", err)
+	}
+
+	return message, nil
+}
+
+// scanMessages scans all rows selected with messageColumns
+func scanMessages(rows *sql.Rows) ([]*models.Message, error) {
+	var messages []*models.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// GetMessagesInRange returns all messages posted in [start, end), oldest first
+func (db *DB) GetMessagesInRange(ctx context.Context, start, end time.Time) ([]*models.Message, error) {
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.date >= ? AND m.date < ?
+		ORDER BY m.ts_micros, m.id`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// GetAdjacentMessageDates returns the date of the last message before start
+// and of the first message at or after end. Either is zero when there is no
+// such message.
+func (db *DB) GetAdjacentMessageDates(ctx context.Context, start, end time.Time) (time.Time, time.Time, error) {
+	var previous, next time.Time
+	err := db.conn.QueryRowContext(ctx, `SELECT date FROM messages WHERE date < ? ORDER BY date DESC LIMIT 1`,
+		start.UTC()).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to find the previous message: %w", err)
+	}
+	err = db.conn.QueryRowContext(ctx, `SELECT date FROM messages WHERE date >= ? ORDER BY date LIMIT 1`,
+		end.UTC()).Scan(&next)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to find the next message: %w", err)
+	}
+	return previous, next, nil
+}
+
+// GetMostReactedMessages returns the messages in [start, end) with the most
+// reactions
+func (db *DB) GetMostReactedMessages(ctx context.Context, start, end time.Time, limit int) ([]*models.Message, error) {
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.date >= ? AND m.date < ? AND m.reaction_count > 0
+		ORDER BY m.reaction_count DESC, m.ts_micros
+		LIMIT ?`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, start.UTC(), end.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reacted messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// GetActiveThreads returns the threads with the most messages posted in
+// [start, end), along with each thread's starting message
+func (db *DB) GetActiveThreads(ctx context.Context, start, end time.Time, limit int) ([]models.ThreadActivity, error) {
+	sqlQuery := `
+		SELECT
+			t.thread_ts,
+			COALESCE(p.date, t.first_date),
+			COALESCE(u.name, ''),
+			COALESCE(u.real_name, ''),
+			COALESCE(message_text(p.text), ''),
+			t.replies
+		FROM (
+			SELECT thread_ts, COUNT(*) - SUM(thread_ts = timestamp) as replies, MIN(date) as first_date
+			FROM messages
+			WHERE date >= ? AND date < ? AND thread_ts != ''
+			GROUP BY thread_ts
+		) t
+		LEFT JOIN messages p ON p.timestamp = t.thread_ts
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE t.replies > 0
+		GROUP BY t.thread_ts
+		ORDER BY t.replies DESC, t.thread_ts
+		LIMIT ?`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, start.UTC(), end.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active threads: %w", err)
+	}
+	defer rows.Close()
+
+	threads := []models.ThreadActivity{}
+	for rows.Next() {
+		var thread models.ThreadActivity
+		var date interface{}
+		err := rows.Scan(&thread.ThreadTS, &date, &thread.UserName, &thread.UserRealName, &thread.Text, &thread.Replies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
+		}
+		thread.Date = parseDBTime(date)
+		threads = append(threads, thread)
+	}
+
+	return threads, rows.Err()
+}
+
+// parseDBTime converts a date value read from an expression (which loses the
+// DATETIME column type) back into a time.Time
+func parseDBTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Local()
+	case string:
+		for _, layout := range sqlite3.SQLiteTimestampFormats {
+			if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+				return t.Local()
+			}
+		}
+	case []byte:
+		return parseDBTime(string(v))
+	}
+
+	return time.Time{}
+}
+
+// GetThreadMessages returns the starter and replies of a thread, oldest first
+func (db *DB) GetThreadMessages(ctx context.Context, threadTS string) ([]*models.Message, error) {
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.thread_ts = ? OR m.timestamp = ?
+		ORDER BY m.ts_micros, m.id`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, threadTS, threadTS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thread: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// GetMessage returns a single message by its ID
+func (db *DB) GetMessage(ctx context.Context, id int) (*models.Message, error) {
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.id = ?`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("message %d not found", id)
+	}
+
+	return messages[0], nil
+}
+
+// GetMessageByTimestamp returns a single message by its Slack timestamp
+func (db *DB) GetMessageByTimestamp(ctx context.Context, ts string) (*models.Message, error) {
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.timestamp = ?
+		ORDER BY m.id
+		LIMIT 1`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no message with timestamp %s", ts)
+	}
+
+	return messages[0], nil
+}
+
+// StoreRawMessage keeps the source JSON of a message
+func (db *DB) StoreRawMessage(ts string, raw []byte) error {
+	_, err := db.exec().Exec(`INSERT OR REPLACE INTO message_raw (message_ts, raw) VALUES (?, ?)`, ts, string(raw))
+	if err != nil {
+		return fmt.Errorf("failed to store raw message: %w", err)
+	}
+	return nil
+}
+
+// GetRawMessage returns the source JSON kept for a message, and whether
+// any was kept
+func (db *DB) GetRawMessage(ctx context.Context, ts string) (string, bool, error) {
+	var raw string
+	err := db.conn.QueryRowContext(ctx, `SELECT raw FROM message_raw WHERE message_ts = ?`, ts).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query raw message: %w", err)
+	}
+	return raw, true, nil
+}
+
+// GetCachedSummary returns a previously stored summary, if any
+func (db *DB) GetCachedSummary(ctx context.Context, key string) (string, bool, error) {
+	var summary string
+	err := db.conn.QueryRowContext(ctx, "SELECT summary FROM summaries WHERE cache_key = ?", key).Scan(&summary)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read cached summary: %w", err)
+	}
+
+	return summary, true, nil
+}
+
+// SaveSummary stores a generated summary in the cache
+func (db *DB) SaveSummary(ctx context.Context, key, model, summary string) error {
+	_, err := db.conn.ExecContext(ctx, `INSERT OR REPLACE INTO summaries (cache_key, model, summary, created) VALUES (?, ?, ?, ?)`,
+		key, model, summary, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to cache summary: %w", err)
+	}
+
+	return nil
+}
+
+// SetFeedback records a helpful (positive) or not helpful (negative) mark
+// on a message, replacing any earlier mark. A score of zero clears it.
+func (db *DB) SetFeedback(ctx context.Context, message *models.Message, score int, query string) error {
+	if score == 0 {
+		if _, err := db.conn.ExecContext(ctx, `DELETE FROM feedback WHERE message_ts = ?`, message.Timestamp); err != nil {
+			return fmt.Errorf("failed to clear feedback: %w", err)
+		}
+		return nil
+	}
+
+	threadTS := message.ThreadTS
+	if threadTS == "" {
+		threadTS = message.Timestamp
+	}
+	_, err := db.conn.ExecContext(ctx, `INSERT OR REPLACE INTO feedback (message_ts, thread_ts, score, query, updated) VALUES (?, ?, ?, ?, ?)`,
+		message.Timestamp, threadTS, score, query, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeedback returns the feedback recorded on messages, most recent first
+func (db *DB) GetFeedback(ctx context.Context) ([]*models.Feedback, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT f.message_ts, f.score, COALESCE(f.query, ''), f.updated,
+			COALESCE(message_text(m.text), ''), COALESCE(u.name, m.user_id, '')
+		FROM feedback f
+		LEFT JOIN messages m ON m.timestamp = f.message_ts
+		LEFT JOIN users u ON u.id = m.user_id
+		GROUP BY f.message_ts
+		ORDER BY f.updated DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var feedback []*models.Feedback
+	for rows.Next() {
+		f := &models.Feedback{}
+		if err := rows.Scan(&f.Timestamp, &f.Score, &f.Query, &f.Updated, &f.Text, &f.UserName); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		feedback = append(feedback, f)
+	}
+
+	return feedback, rows.Err()
+}
+
+// AddTag tags a message, or with thread set the whole thread it belongs
+// to. Tagging a thread replaces a tag on its starter alone.
+func (db *DB) AddTag(ctx context.Context, message *models.Message, tag string, thread bool) error {
+	ts := message.Timestamp
+	if thread && message.ThreadTS != "" {
+		ts = message.ThreadTS
+	}
+
+	_, err := db.conn.ExecContext(ctx, `INSERT OR REPLACE INTO tags (message_ts, tag, thread, created) VALUES (?, ?, ?, ?)`,
+		ts, tag, thread, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add tag: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveTag removes a tag from a message and from the thread it belongs
+// to, returning the number of tags removed
+func (db *DB) RemoveTag(ctx context.Context, message *models.Message, tag string) (int, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM tags WHERE tag = ? AND (message_ts = ? OR (thread AND message_ts = ?))`,
+		tag, message.Timestamp, message.ThreadTS)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove tag: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+// GetTags returns every tag in use with the number of messages and threads
+// carrying it, by name
+func (db *DB) GetTags(ctx context.Context) ([]models.TagCount, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT tag, SUM(NOT thread), SUM(thread)
+		FROM tags
+		GROUP BY tag
+		ORDER BY tag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []models.TagCount
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Messages, &tag.Threads); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetTaggedMessages returns the messages carrying a tag, directly or
+// through their thread, oldest first
+func (db *DB) GetTaggedMessages(ctx context.Context, tag string) ([]*models.Message, error) {
+	conditions, args := filterConditions(models.SearchFilter{Tags: []string{tag}})
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY m.ts_micros, m.id`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tagged messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// GetMessageTags returns the tags on a message, including those on its
+// thread
+func (db *DB) GetMessageTags(ctx context.Context, message *models.Message) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT DISTINCT tag FROM tags
+		WHERE message_ts = ? OR (thread AND message_ts = ?)
+		ORDER BY tag`, message.Timestamp, message.ThreadTS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Merge copies the users, channels and messages of src into this database.
+// Messages are de-duplicated by Slack timestamp; for messages in both, the
+// higher reply and reaction counts are kept, since a later export has seen
+// more activity. It returns the number of messages added. Call RebuildFTS
+// once all sources are merged.
+func (db *DB) Merge(ctx context.Context, src *DB) (int, error) {
+	if srcInfo, err := os.Stat(src.path); err == nil {
+		if dstInfo, err := os.Stat(db.path); err == nil && os.SameFile(srcInfo, dstInfo) {
+			return 0, fmt.Errorf("cannot merge a database into itself")
+		}
+	}
+
+	// ATTACH applies to a single connection, so hold one for the merge
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS src", src.path); err != nil {
+		return 0, fmt.Errorf("failed to attach %s: %w", src.path, err)
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE src")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		// Real users replace placeholders for the same ID
+		`INSERT INTO users (id, name, real_name, display_name, is_bot, deleted, placeholder)
+			SELECT id, name, real_name, display_name, is_bot, deleted, placeholder FROM src.users WHERE true
+			ON CONFLICT(id) DO UPDATE SET
+				name = excluded.name,
+				real_name = excluded.real_name,
+				display_name = excluded.display_name,
+				is_bot = excluded.is_bot,
+				deleted = excluded.deleted,
+				placeholder = 0
+			WHERE users.placeholder = 1 AND excluded.placeholder = 0`,
+		`INSERT OR IGNORE INTO message_raw (message_ts, raw)
+			SELECT message_ts, raw FROM src.message_raw`,
+		`INSERT OR IGNORE INTO media_files (sha256, name, file_id, mimetype, size, stored_path, cataloged)
+			SELECT sha256, name, file_id, mimetype, size, stored_path, cataloged FROM src.media_files`,
+		`INSERT OR IGNORE INTO message_files (message_ts, position, file_id, name, title, mimetype, size, url, thumbnail, permalink, preview, local_path)
+			SELECT message_ts, position, file_id, name, title, mimetype, size, url, thumbnail, permalink, preview, local_path FROM src.message_files`,
+		`INSERT OR IGNORE INTO file_contents (message_ts, position, file_id, name, content, extracted)
+			SELECT message_ts, position, file_id, name, content, extracted FROM src.file_contents`,
+		`INSERT OR IGNORE INTO bots (id, name, app_id, service)
+			SELECT id, name, app_id, service FROM src.bots`,
+		`INSERT OR IGNORE INTO skipped_counts (filename, reason, subtype, count)
+			SELECT filename, reason, subtype, count FROM src.skipped_counts`,
+		`INSERT OR IGNORE INTO skipped_examples (filename, position, reason, subtype, message_ts, user_id, excerpt)
+			SELECT filename, position, reason, subtype, message_ts, user_id, excerpt FROM src.skipped_examples`,
+		`INSERT OR IGNORE INTO channels (id, name, created, creator, is_archived, topic, purpose, kind)
+			SELECT id, name, created, creator, is_archived, topic, purpose, kind FROM src.channels`,
+		`INSERT OR IGNORE INTO channel_members (channel_id, user_id)
+			SELECT channel_id, user_id FROM src.channel_members`,
+		`INSERT OR IGNORE INTO ingested_files (filename, message_count, indexed_at)
+			SELECT filename, message_count, indexed_at FROM src.ingested_files`,
+		`INSERT OR IGNORE INTO message_versions (message_ts, version)
+			SELECT message_ts, version FROM src.message_versions`,
+		`INSERT OR IGNORE INTO message_fingerprints (message_ts, fingerprint)
+			SELECT message_ts, fingerprint FROM src.message_fingerprints`,
+		`INSERT OR IGNORE INTO tags (message_ts, tag, thread, created)
+			SELECT message_ts, tag, thread, created FROM src.tags`,
+		`INSERT OR IGNORE INTO feedback (message_ts, thread_ts, score, query, updated)
+			SELECT message_ts, thread_ts, score, query, updated FROM src.feedback`,
+		// A result set already saved under the same name is kept as it is,
+		// rather than having the other set's messages mixed into it
+		`INSERT OR IGNORE INTO result_set_messages (set_name, position, message_ts)
+			SELECT set_name, position, message_ts FROM src.result_set_messages
+			WHERE set_name NOT IN (SELECT name FROM main.result_sets)`,
+		`INSERT OR IGNORE INTO result_sets (name, query, created)
+			SELECT name, query, created FROM src.result_sets`,
+		`INSERT OR IGNORE INTO summaries (cache_key, model, summary, created)
+			SELECT cache_key, model, summary, created FROM src.summaries`,
+		`INSERT INTO user_profiles (user_id, name, real_name, display_name, first_seen, last_seen)
+			SELECT user_id, name, real_name, display_name, first_seen, last_seen FROM src.user_profiles WHERE true
+			ON CONFLICT(user_id, name, real_name, display_name) DO UPDATE SET
+				first_seen = MIN(first_seen, excluded.first_seen),
+				last_seen = MAX(last_seen, excluded.last_seen)`,
+		`UPDATE messages SET
+				reply_count = MAX(messages.reply_count, s.reply_count),
+				reaction_count = MAX(messages.reaction_count, s.reaction_count),
+				has_reaction = MAX(messages.reaction_count, s.reaction_count) > 0
+			FROM src.messages s
+			WHERE s.timestamp = messages.timestamp
+				AND (s.reply_count > messages.reply_count OR s.reaction_count > messages.reaction_count)`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return 0, fmt.Errorf("failed to merge %s: %w", src.path, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO messages (user_id, text, type, subtype, timestamp, date, ts_micros, filename, thread_ts,
+			reply_count, reaction_count, has_link, has_code, has_file, has_reaction, identifiers, logs)
+		SELECT user_id, text, type, subtype, timestamp, date, ts_micros, filename, thread_ts,
+			reply_count, reaction_count, has_link, has_code, has_file, has_reaction, identifiers, logs
+		FROM src.messages s
+		WHERE s.id IN (SELECT MIN(id) FROM src.messages GROUP BY timestamp)
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.timestamp = s.timestamp)
+		ORDER BY s.ts_micros, s.id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge messages from %s: %w", src.path, err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	return int(added), nil
+}
+
+// RebuildFTS recreates the message and channel search indexes from the
+// messages and channels tables
+func (db *DB) RebuildFTS(ctx context.Context) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`,
+		`INSERT INTO messages_fts(messages_fts) VALUES ('optimize')`,
+		`DELETE FROM channels_fts`,
+		`INSERT INTO channels_fts(rowid, name, topic, purpose)
+			SELECT rowid, name, COALESCE(topic, ''), COALESCE(purpose, '') FROM channels`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// unindexedSampleSize is how many unindexed messages VerifyFTS lists
+const unindexedSampleSize = 10
+
+// VerifyFTS compares the messages and channels tables with their search
+// indexes, and runs the message index's own integrity check
+func (db *DB) VerifyFTS(ctx context.Context) (*models.FTSReport, error) {
+	report := &models.FTSReport{}
+	// Reading messages_fts itself reads messages_fts_content, so the rows
+	// actually indexed are counted from the index's document sizes
+	counts := []struct {
+		query string
+		value *int
+	}{
+		{`SELECT COUNT(*) FROM messages`, &report.Messages},
+		{`SELECT COUNT(*) FROM messages_fts_docsize`, &report.IndexedMessages},
+		{`SELECT COUNT(*) FROM messages WHERE id NOT IN (SELECT docid FROM messages_fts_docsize)`, &report.Unindexed},
+		{`SELECT COUNT(*) FROM messages_fts_docsize WHERE docid NOT IN (SELECT id FROM messages)`, &report.Orphaned},
+		{`SELECT COUNT(*) FROM channels`, &report.Channels},
+		{`SELECT COUNT(*) FROM channels_fts`, &report.IndexedChannels},
+	}
+	for _, count := range counts {
+		if err := db.conn.QueryRowContext(ctx, count.query).Scan(count.value); err != nil {
+			return nil, fmt.Errorf("failed to check search index: %w", err)
+		}
+	}
+
+	if report.Unindexed > 0 {
+		rows, err := db.conn.QueryContext(ctx, `SELECT timestamp FROM messages
+			WHERE id NOT IN (SELECT docid FROM messages_fts_docsize) ORDER BY id LIMIT ?`, unindexedSampleSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list unindexed messages: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var ts string
+			if err := rows.Scan(&ts); err != nil {
+				return nil, fmt.Errorf("failed to scan unindexed message: %w", err)
+			}
+			report.UnindexedSample = append(report.UnindexedSample, ts)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list unindexed messages: %w", err)
+		}
+	}
+
+	// The check fails with an error when the index is inconsistent
+	if _, err := db.conn.ExecContext(ctx, `INSERT INTO messages_fts(messages_fts) VALUES ('integrity-check')`); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		report.IndexError = err.Error()
+	}
+
+	return report, nil
+}
+
+// IntegrityCheck runs SQLite's integrity check on the database, returning
+// the problems found; a sound database returns none
+func (db *DB) IntegrityCheck(ctx context.Context) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to scan integrity check: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+	return problems, nil
+}
+
+// Optimize merges the search index's segments, refreshes the query
+// planner's statistics and reclaims free pages
+func (db *DB) Optimize(ctx context.Context) error {
+	queries := []string{
+		`INSERT INTO messages_fts(messages_fts) VALUES ('optimize')`,
+		`ANALYZE`,
+		`VACUUM`,
+	}
+	for _, query := range queries {
+		if _, err := db.conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to optimize database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LastMessageID returns the ID of the most recently stored message, or 0
+// when there are none
+func (db *DB) LastMessageID(ctx context.Context) (int, error) {
+	var id int
+	if err := db.conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM messages").Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to find last message: %w", err)
+	}
+	return id, nil
+}
+
+// MessageTimestamps returns the Slack timestamp of every message, for
+// comparing the contents of two databases
+func (db *DB) MessageTimestamps(ctx context.Context) (map[string]bool, error) {
+	rows, err := db.conn.QueryContext(ctx, "SELECT timestamp FROM messages")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message timestamps: %w", err)
+	}
+	defer rows.Close()
+
+	timestamps := make(map[string]bool)
+	for rows.Next() {
+		var ts string
+		if err := rows.Scan(&ts); err != nil {
+			return nil, fmt.Errorf("failed to scan message timestamp: %w", err)
+		}
+		timestamps[ts] = true
+	}
+
+	return timestamps, rows.Err()
+}
+
+// ForEachMessage calls fn for every message, grouped by thread with each
+// thread's messages in timestamp order. Iteration stops at the first error.
+func (db *DB) ForEachMessage(ctx context.Context, fn func(*models.Message) error) error {
+	sqlQuery := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		ORDER BY COALESCE(NULLIF(m.thread_ts, ''), m.timestamp), m.ts_micros, m.id`
+
+	rows, err := db.conn.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(message); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// readOnlyStatements are the statements ReadOnlyQuery accepts, by first
+// keyword
+var readOnlyStatements = map[string]bool{"SELECT": true, "WITH": true, "VALUES": true, "EXPLAIN": true}
+
+// CheckReadOnlyQuery returns an error unless query is a single SELECT,
+// WITH, VALUES or EXPLAIN statement. Comments and a trailing semicolon are
+// allowed.
+func CheckReadOnlyQuery(query string) error {
+	var code strings.Builder
+	statements := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			code.WriteByte(' ')
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("unterminated comment in query")
+			}
+			i += end + 3
+			code.WriteByte(' ')
+		case statements > 0 && !unicode.IsSpace(rune(c)):
+			return fmt.Errorf("only a single statement can be run")
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(query[i+1:], closing)
+			if end < 0 {
+				return fmt.Errorf("unterminated quote in query")
+			}
+			// Doubled quotes inside a string are picked up as the start of
+			// the next quoted run
+			code.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == ';':
+			statements++
+			code.WriteByte(' ')
+		default:
+			code.WriteByte(c)
+		}
+	}
+
+	fields := strings.Fields(code.String())
+	if len(fields) == 0 {
+		return fmt.Errorf("empty query")
+	}
+	keyword := strings.ToUpper(strings.TrimLeft(fields[0], "("))
+	if !readOnlyStatements[keyword] {
+		return fmt.Errorf("only SELECT, WITH, VALUES and EXPLAIN statements can be run, not %s", keyword)
+	}
+	// WITH can also begin an INSERT, UPDATE or DELETE
+	if keyword == "WITH" {
+		if main := withStatement(code.String()); main != "SELECT" && main != "VALUES" {
+			return fmt.Errorf("WITH can only lead to a SELECT or VALUES statement")
+		}
+	}
+	return nil
+}
+
+// withStatement returns the keyword of the statement a WITH clause leads
+// to: the first statement keyword outside the parentheses of its common
+// table expressions, or "" if there is none. Quoted names are skipped,
+// since they may be keywords.
+func withStatement(code string) string {
+	depth := 0
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			if end := strings.IndexByte(code[i+1:], closing); end >= 0 {
+				i += end + 1
+			}
+		case depth == 0 && (unicode.IsLetter(rune(c)) || c == '_'):
+			end := i
+			for end < len(code) && (unicode.IsLetter(rune(code[end])) || unicode.IsDigit(rune(code[end])) || code[end] == '_') {
+				end++
+			}
+			switch word := strings.ToUpper(code[i:end]); word {
+			case "SELECT", "VALUES", "INSERT", "REPLACE", "UPDATE", "DELETE":
+				return word
+			}
+			i = end - 1
+		}
+	}
+	return ""
+}
+
+// ReadOnlyQuery runs a single read-only statement (see CheckReadOnlyQuery)
+// and calls fn with the column names and values of each row, stopping at
+// the first error. The statement runs on a connection with query_only set,
+// so even a statement that slips past the check cannot change the database.
+// It returns the column names, which are known even when there are no rows.
+func (db *DB) ReadOnlyQuery(ctx context.Context, query string, fn func(columns []string, values []interface{}) error) ([]string, error) {
+	if err := CheckReadOnlyQuery(query); err != nil {
+		return nil, err
+	}
+
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, fmt.Errorf("failed to make the connection read-only: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := fn(columns, values); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return columns, nil
+}
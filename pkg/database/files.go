@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// InsertMessageFiles records the files shared in a message, replacing any
+// recorded before
+func (db *DB) InsertMessageFiles(ts string, files []models.MessageFile) error {
+	if _, err := db.exec().Exec(`DELETE FROM message_files WHERE message_ts = ?`, ts); err != nil {
+		return fmt.Errorf("failed to store message files: %w", err)
+	}
+	for i, file := range files {
+		// A file already in the media store is linked to its stored copy
+		_, err := db.exec().Exec(`INSERT INTO message_files (message_ts, position, file_id, name, title, mimetype, size, url, thumbnail, permalink, preview, local_path)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+				COALESCE((SELECT stored_path FROM media_files WHERE file_id = ? AND file_id != '' LIMIT 1), ?))`,
+			ts, i, file.FileID, file.Name, file.Title, file.Mimetype, file.Size, file.URL, file.Thumbnail, file.Permalink, file.Preview,
+			file.FileID, file.LocalPath)
+		if err != nil {
+			return fmt.Errorf("failed to store message files: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetMessageFiles returns the files shared in the messages with the given
+// timestamps, by message timestamp, in the order they were shared
+func (db *DB) GetMessageFiles(ctx context.Context, timestamps []string) (map[string][]models.MessageFile, error) {
+	files := make(map[string][]models.MessageFile)
+	// Look the messages up in batches to stay under SQLite's variable limit
+	const batch = 500
+	for start := 0; start < len(timestamps); start += batch {
+		end := min(start+batch, len(timestamps))
+		args := make([]interface{}, 0, end-start)
+		for _, ts := range timestamps[start:end] {
+			args = append(args, ts)
+		}
+
+		rows, err := db.conn.QueryContext(ctx, `
+			SELECT message_ts, position, file_id, name, title, mimetype, size, url, thumbnail, permalink, preview, local_path
+			FROM message_files
+			WHERE message_ts IN (?`+strings.Repeat(", ?", len(args)-1)+`)
+			ORDER BY message_ts, position`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query message files: %w", err)
+		}
+		for rows.Next() {
+			var file models.MessageFile
+			if err := rows.Scan(&file.MessageTS, &file.Position, &file.FileID, &file.Name, &file.Title, &file.Mimetype, &file.Size,
+				&file.URL, &file.Thumbnail, &file.Permalink, &file.Preview, &file.LocalPath); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan message file: %w", err)
+			}
+			files[file.MessageTS] = append(files[file.MessageTS], file)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query message files: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// CatalogMediaFile records a file imported into the media store. A file
+// saved without its Slack file ID is matched by name, when exactly one
+// shared file has that name. Messages sharing the file are linked to the
+// stored copy; linked reports whether there were any.
+func (db *DB) CatalogMediaFile(ctx context.Context, file models.MediaFile) (bool, error) {
+	if file.FileID == "" {
+		rows, err := db.conn.QueryContext(ctx, `SELECT DISTINCT file_id FROM message_files WHERE name = ? AND file_id != ''`, file.Name)
+		if err != nil {
+			return false, fmt.Errorf("failed to query message files: %w", err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return false, fmt.Errorf("failed to scan message file: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if len(ids) == 1 {
+			file.FileID = ids[0]
+		}
+	}
+
+	_, err := db.conn.ExecContext(ctx, `INSERT OR REPLACE INTO media_files (sha256, name, file_id, mimetype, size, stored_path, cataloged)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		file.SHA256, file.Name, file.FileID, file.Mimetype, file.Size, file.StoredPath, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to catalog media file: %w", err)
+	}
+	if file.FileID == "" {
+		return false, nil
+	}
+
+	result, err := db.conn.ExecContext(ctx, `UPDATE message_files SET local_path = ? WHERE file_id = ?`, file.StoredPath, file.FileID)
+	if err != nil {
+		return false, fmt.Errorf("failed to link media file: %w", err)
+	}
+	linked, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return linked > 0, nil
+}
+
+// GetMediaFiles returns the files in the media catalog, by name, with the
+// first message sharing each
+func (db *DB) GetMediaFiles(ctx context.Context) ([]models.MediaFile, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT m.sha256, m.name, m.file_id, m.mimetype, m.size, m.stored_path, m.cataloged,
+			COALESCE((SELECT MIN(f.message_ts) FROM message_files f WHERE f.file_id = m.file_id AND m.file_id != ''), '')
+		FROM media_files m
+		ORDER BY m.name, m.sha256`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []models.MediaFile
+	for rows.Next() {
+		var file models.MediaFile
+		if err := rows.Scan(&file.SHA256, &file.Name, &file.FileID, &file.Mimetype, &file.Size, &file.StoredPath,
+			&file.Cataloged, &file.MessageTS); err != nil {
+			return nil, fmt.Errorf("failed to scan media file: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// GetFilesToExtract returns the shared files with a copy on disk whose text
+// hasn't been extracted yet
+func (db *DB) GetFilesToExtract(ctx context.Context) ([]models.MessageFile, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT f.message_ts, f.position, f.file_id, f.name, f.mimetype, f.size, f.local_path
+		FROM message_files f
+		WHERE f.local_path != ''
+			AND NOT EXISTS (SELECT 1 FROM file_contents c WHERE c.message_ts = f.message_ts AND c.position = f.position)
+		ORDER BY f.message_ts, f.position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []models.MessageFile
+	for rows.Next() {
+		var file models.MessageFile
+		if err := rows.Scan(&file.MessageTS, &file.Position, &file.FileID, &file.Name, &file.Mimetype,
+			&file.Size, &file.LocalPath); err != nil {
+			return nil, fmt.Errorf("failed to scan message file: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// StoreFileContent records the text extracted from a shared file and
+// reindexes the message that shared it, so searches match the text
+func (db *DB) StoreFileContent(ctx context.Context, file models.MessageFile, content string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to store file contents: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The index removes a row by reading back what it indexed, so the row
+	// is removed before the contents change
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`DELETE FROM messages_fts WHERE rowid IN (SELECT id FROM messages WHERE timestamp = ?)`,
+			[]interface{}{file.MessageTS}},
+		{`INSERT OR REPLACE INTO file_contents (message_ts, position, file_id, name, content, extracted)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			[]interface{}{file.MessageTS, file.Position, file.FileID, file.Name, content, time.Now().UTC()}},
+		{`INSERT INTO messages_fts(rowid, text, user_name, user_real_name, filename, identifiers, files)
+			SELECT rowid, text, user_name, user_real_name, filename, identifiers, files FROM messages_fts_content
+			WHERE rowid IN (SELECT id FROM messages WHERE timestamp = ?)`,
+			[]interface{}{file.MessageTS}},
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q.query, q.args...); err != nil {
+			return fmt.Errorf("failed to store file contents: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store file contents: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package database_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/testutil"
+)
+
+// The fixture thread: alice's question and two replies
+const fixtureThreadTS = "1709542800.000100"
+
+func TestFixtureIngest(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewFixtureDB(t)
+
+	stats, err := db.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	want := map[string]int{"messages": 7, "users": 3, "channels": 1}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("stats = %v, want %v", stats, want)
+	}
+
+	report, err := db.GetSkipReport(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to get skip report: %v", err)
+	}
+	if report.Indexed != 7 || report.Skipped != 2 {
+		t.Errorf("indexed %d and skipped %d message(s), want 7 and 2", report.Indexed, report.Skipped)
+	}
+	reasons := make(map[string]int)
+	for _, reason := range report.Reasons {
+		reasons[reason.Reason] = reason.Count
+		if len(reason.Examples) != 1 {
+			t.Errorf("%s: %d example(s), want 1", reason.Reason, len(reason.Examples))
+		}
+	}
+	if want := map[string]int{"bot message": 1, "empty text": 1}; !reflect.DeepEqual(reasons, want) {
+		t.Errorf("skipped by reason = %v, want %v", reasons, want)
+	}
+}
+
+func TestFixtureSearch(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewFixtureDB(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		filter models.SearchFilter
+		// want lists the results' timestamps in order
+		want []string
+	}{
+		{
+			name:  "relevance",
+			query: "kubelet",
+			// The pasted log mentions kubelet twice
+			want: []string{"1709715600.000100", "1709543100.000200", fixtureThreadTS},
+		},
+		{
+			name:   "oldest",
+			query:  "kubelet",
+			filter: models.SearchFilter{Sort: models.SortOldest},
+			want:   []string{fixtureThreadTS, "1709543100.000200", "1709715600.000100"},
+		},
+		{
+			name:   "author and date",
+			query:  "rbac",
+			filter: models.SearchFilter{Users: []string{"alice"}, Since: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+			want:   []string{"1709632800.000200"},
+		},
+		{
+			name:   "thread replies",
+			query:  "kubelet OR csr",
+			filter: models.SearchFilter{Scope: models.ScopeThreadReplies, Sort: models.SortOldest},
+			want:   []string{"1709543100.000200", "1709543400.000300"},
+		},
+		{
+			name:  "identifier",
+			query: "rotate-certificates",
+			want:  []string{"1709543100.000200"},
+		},
+		{
+			name:  "bot messages are not indexed",
+			query: "periodic",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := db.SearchMessagesFiltered(ctx, tt.query, tt.filter, 0)
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Timestamp)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixtureThread(t *testing.T) {
+	db := testutil.NewFixtureDB(t)
+
+	messages, err := db.GetThreadMessages(context.Background(), fixtureThreadTS)
+	if err != nil {
+		t.Fatalf("failed to get thread: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("thread has %d message(s), want 3", len(messages))
+	}
+	if messages[0].Timestamp != fixtureThreadTS || messages[0].UserName != "alice" {
+		t.Errorf("thread starts with %s by %s, want %s by alice", messages[0].Timestamp, messages[0].UserName, fixtureThreadTS)
+	}
+	if !strings.Contains(messages[2].Text, "Rotation works now") {
+		t.Errorf("last reply = %q, want the resolution", messages[2].Text)
+	}
+}
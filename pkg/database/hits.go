@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/identifiers"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// threadKey is the timestamp identifying the thread of a message (m): its
+// starter's, or its own for a message outside any thread
+const threadKey = `COALESCE(NULLIF(m.thread_ts, ''), m.timestamp)`
+
+// CountThreadHits sets the ThreadHits of each result to the number of times
+// the terms of a full-text query occur in the text of the result's thread,
+// counting every message in it, whether or not it passes the search's
+// filters
+func (db *DB) CountThreadHits(ctx context.Context, query string, results []*models.SearchResult) error {
+	if query == "" || len(results) == 0 {
+		return nil
+	}
+
+	threads := make(map[string]int)
+	args := []interface{}{identifiers.RewriteQuery(query, "identifiers")}
+	for _, result := range results {
+		key := result.ThreadTS
+		if key == "" {
+			key = result.Timestamp
+		}
+		if _, ok := threads[key]; !ok {
+			threads[key] = 0
+			args = append(args, key)
+		}
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT `+threadKey+`, offsets(messages_fts)
+		FROM messages_fts fts
+		JOIN messages m ON m.id = fts.rowid
+		WHERE messages_fts MATCH ? AND `+threadKey+` IN (?`+strings.Repeat(", ?", len(args)-2)+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to count thread matches: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, offsets string
+		if err := rows.Scan(&key, &offsets); err != nil {
+			return fmt.Errorf("failed to scan thread matches: %w", err)
+		}
+		threads[key] += len(matchOffsets(offsets))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to count thread matches: %w", err)
+	}
+
+	for _, result := range results {
+		key := result.ThreadTS
+		if key == "" {
+			key = result.Timestamp
+		}
+		result.ThreadHits = threads[key]
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package database_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/testutil"
+)
+
+// newEmptyDB returns a new database named name in a temporary directory,
+// closed when the test finishes
+func newEmptyDB(t *testing.T, name string) *database.DB {
+	t.Helper()
+
+	db, err := database.NewDBInDir(t.TempDir(), name)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// merge merges src into dst and rebuilds dst's search index, as the merge
+// command does, returning the number of messages added
+func merge(t *testing.T, dst, src *database.DB) int {
+	t.Helper()
+
+	ctx := context.Background()
+	added, err := dst.Merge(ctx, src)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if err := dst.RebuildFTS(ctx); err != nil {
+		t.Fatalf("failed to rebuild search index: %v", err)
+	}
+	return added
+}
+
+func TestMergeDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	fixture := testutil.NewFixtureDB(t)
+	const messages = 7
+
+	count := func(t *testing.T, db *database.DB, query string) int {
+		t.Helper()
+		n, err := db.CountMessages(ctx, query, models.SearchFilter{})
+		if err != nil {
+			t.Fatalf("count of %q failed: %v", query, err)
+		}
+		return n
+	}
+
+	t.Run("into an empty database", func(t *testing.T) {
+		dst := newEmptyDB(t, "merged")
+		if added := merge(t, dst, fixture); added != messages {
+			t.Errorf("added %d message(s), want %d", added, messages)
+		}
+		if added := merge(t, dst, fixture); added != 0 {
+			t.Errorf("merging again added %d message(s), want 0", added)
+		}
+		if n := count(t, dst, ""); n != messages {
+			t.Errorf("merged database holds %d message(s), want %d", n, messages)
+		}
+		if n := count(t, dst, "kubelet"); n != 3 {
+			t.Errorf("search for kubelet found %d message(s), want 3", n)
+		}
+	})
+
+	t.Run("into another ingest of the same export", func(t *testing.T) {
+		dst := testutil.NewFixtureDB(t)
+		if added := merge(t, dst, fixture); added != 0 {
+			t.Errorf("added %d message(s), want 0", added)
+		}
+		if n := count(t, dst, ""); n != messages {
+			t.Errorf("merged database holds %d message(s), want %d", n, messages)
+		}
+		if n := count(t, dst, "kubelet"); n != 3 {
+			t.Errorf("search for kubelet found %d message(s), want 3", n)
+		}
+	})
+
+	t.Run("into itself", func(t *testing.T) {
+		if _, err := fixture.Merge(ctx, fixture); err == nil {
+			t.Error("merging a database into itself succeeded, want an error")
+		}
+	})
+}
+
+func TestMergeKeepsAnnotations(t *testing.T) {
+	ctx := context.Background()
+	src := testutil.NewFixtureDB(t)
+
+	message, err := src.GetMessageByTimestamp(ctx, fixtureThreadTS)
+	if err != nil {
+		t.Fatalf("failed to get message: %v", err)
+	}
+	if err := src.AddTag(ctx, message, "needs-doc", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetFeedback(ctx, message, 1, "kubelet"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveResultSet(ctx, "triage", "kubelet", []string{fixtureThreadTS}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveSummary(ctx, "key", "model", "a summary"); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newEmptyDB(t, "merged")
+	// A set of the same name in the destination is kept as it is
+	if err := dst.SaveResultSet(ctx, "kept", "other", []string{"1.000100"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveResultSet(ctx, "kept", "kubelet", []string{fixtureThreadTS}); err != nil {
+		t.Fatal(err)
+	}
+	merge(t, dst, src)
+
+	tagged, err := dst.SearchMessagesFiltered(ctx, "", models.SearchFilter{Tags: []string{"needs-doc"}}, 0)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(tagged) != 1 || tagged[0].Timestamp != fixtureThreadTS {
+		t.Errorf("got %d message(s) tagged needs-doc, want the thread starter", len(tagged))
+	}
+
+	feedback, err := dst.GetFeedback(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feedback) != 1 {
+		t.Errorf("got %d feedback mark(s), want 1", len(feedback))
+	}
+
+	for name, want := range map[string][]string{"triage": {fixtureThreadTS}, "kept": {"1.000100"}} {
+		_, timestamps, err := dst.GetResultSet(ctx, name)
+		if err != nil {
+			t.Fatalf("failed to get result set %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(timestamps, want) {
+			t.Errorf("result set %s holds %q, want %q", name, timestamps, want)
+		}
+	}
+
+	if summary, ok, err := dst.GetCachedSummary(ctx, "key"); err != nil || !ok || summary != "a summary" {
+		t.Errorf("cached summary = %q, %v, %v; want the source's", summary, ok, err)
+	}
+}
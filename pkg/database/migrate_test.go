@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// TestMigrateTSMicros checks the ts_micros backfill for databases built
+// before the column existed agrees with the indexer on every fraction
+func TestMigrateTSMicros(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		timestamp string
+		want      int64
+	}{
+		{"1709550000.087600", 1709550000087600},
+		{"1709550000.5", 1709550000500000},
+		{"1709550000.08", 1709550000080000},
+		{"1709550000.0876009", 1709550000087600},
+	}
+	for _, tt := range tests {
+		message := &models.Message{UserID: "U1", Text: "kubelet restart loop", Type: "message",
+			Timestamp: tt.timestamp, Filename: "2024-03-04.json"}
+		if err := db.InsertMessage(message); err != nil {
+			t.Fatalf("failed to insert message: %v", err)
+		}
+	}
+
+	// Make the database look like one built before ts_micros
+	for _, query := range []string{
+		`DROP INDEX idx_messages_ts_micros`,
+		`ALTER TABLE messages DROP COLUMN ts_micros`,
+	} {
+		if _, err := db.conn.Exec(query); err != nil {
+			t.Fatalf("failed to remove ts_micros: %v", err)
+		}
+	}
+	if err := db.migrate(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		var got int64
+		err := db.conn.QueryRow(`SELECT ts_micros FROM messages WHERE timestamp = ?`, tt.timestamp).Scan(&got)
+		if err != nil {
+			t.Fatalf("failed to read ts_micros for %s: %v", tt.timestamp, err)
+		}
+		if got != tt.want {
+			t.Errorf("ts_micros for %s = %d, want %d", tt.timestamp, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// BM25 parameters: bm25K1 limits how much repeating a term raises the
+// score, and bm25B how much longer columns are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25Weights weigh matches in each messages_fts column, in column order:
+// text, user_name, user_real_name, filename, identifiers and files. A
+// match in the message itself counts most; one in a shared file's text or
+// the export file's name counts less.
+var bm25Weights = []float64{1.0, 0.5, 0.5, 0.25, 1.0, 0.5}
+
+// bm25 scores a full-text match from matchinfo(messages_fts, 'pcnalx'):
+// higher is a better match. FTS4 has no built-in ranking function, so
+// SQLite calls this for every matching row.
+func bm25(info []byte) float64 {
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 3 {
+		return 0
+	}
+
+	phrases, columns, rows := int(values[0]), int(values[1]), float64(values[2])
+	averages := values[3 : 3+columns]
+	lengths := values[3+columns : 3+2*columns]
+	hits := values[3+2*columns:]
+	if len(hits) < 3*phrases*columns {
+		return 0
+	}
+
+	score := 0.0
+	for phrase := 0; phrase < phrases; phrase++ {
+		for column := 0; column < columns; column++ {
+			hit := hits[3*(phrase*columns+column):]
+			frequency, documents := float64(hit[0]), float64(hit[2])
+			if frequency == 0 {
+				continue
+			}
+
+			// Terms in more than half the messages would have a negative
+			// weight; they still count a little
+			idf := math.Log((rows - documents + 0.5) / (documents + 0.5))
+			if idf <= 0 {
+				idf = 1e-6
+			}
+			norm := 1.0
+			if averages[column] > 0 {
+				norm = 1 - bm25B + bm25B*float64(lengths[column])/float64(averages[column])
+			}
+			weight := 1.0
+			if column < len(bm25Weights) {
+				weight = bm25Weights[column]
+			}
+			score += weight * idf * frequency * (bm25K1 + 1) / (frequency + bm25K1*norm)
+		}
+	}
+	return score
+}
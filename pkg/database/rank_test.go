@@ -0,0 +1,107 @@
+package database
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// bm25Hit is one phrase's matches in one column
+type bm25Hit struct {
+	frequency uint32 // in this row
+	documents uint32 // rows with a match in the column
+}
+
+// matchinfo encodes matchinfo(messages_fts, 'pcnalx') for a single phrase
+// over columns with the given average and row lengths
+func matchinfo(rows uint32, averages, lengths []uint32, hits []bm25Hit) []byte {
+	values := []uint32{1, uint32(len(averages)), rows}
+	values = append(values, averages...)
+	values = append(values, lengths...)
+	for _, hit := range hits {
+		values = append(values, hit.frequency, hit.frequency, hit.documents)
+	}
+
+	info := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.NativeEndian.PutUint32(info[4*i:], value)
+	}
+	return info
+}
+
+func TestBM25(t *testing.T) {
+	// A single text column of 10 tokens on average
+	score := func(rows, length uint32, hit bm25Hit) float64 {
+		return bm25(matchinfo(rows, []uint32{10}, []uint32{length}, []bm25Hit{hit}))
+	}
+	base := score(1000, 10, bm25Hit{1, 10})
+
+	tests := []struct {
+		name   string
+		score  float64
+		higher bool
+	}{
+		{"more matches in the message", score(1000, 10, bm25Hit{3, 10}), true},
+		{"a rarer term", score(1000, 10, bm25Hit{1, 2}), true},
+		{"a shorter message", score(1000, 4, bm25Hit{1, 10}), true},
+		{"a longer message", score(1000, 40, bm25Hit{1, 10}), false},
+		{"a commoner term", score(1000, 10, bm25Hit{1, 200}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.score > base; got != tt.higher {
+				t.Errorf("score %v against %v: higher = %v, want %v", tt.score, base, got, tt.higher)
+			}
+		})
+	}
+}
+
+func TestBM25Columns(t *testing.T) {
+	averages := []uint32{10, 1, 2, 1, 3, 0}
+	lengths := []uint32{10, 1, 2, 1, 3, 0}
+	inColumn := func(column int) float64 {
+		hits := make([]bm25Hit, len(averages))
+		hits[column] = bm25Hit{1, 10}
+		return bm25(matchinfo(1000, averages, lengths, hits))
+	}
+
+	text, filename := inColumn(0), inColumn(3)
+	if text <= filename {
+		t.Errorf("a match in the text scored %v, not above %v in the file name", text, filename)
+	}
+	if files := inColumn(5); files <= 0 {
+		t.Errorf("a match in a column with no average length scored %v, want above 0", files)
+	}
+}
+
+func TestBM25CommonTerms(t *testing.T) {
+	// A term in every message would have a negative weight
+	got := bm25(matchinfo(100, []uint32{10}, []uint32{10}, []bm25Hit{{1, 100}}))
+	if got <= 0 {
+		t.Errorf("a term in every message scored %v, want above 0", got)
+	}
+	if none := bm25(matchinfo(100, []uint32{10}, []uint32{10}, []bm25Hit{{0, 100}})); none != 0 {
+		t.Errorf("a row without matches scored %v, want 0", none)
+	}
+}
+
+func TestBM25Malformed(t *testing.T) {
+	full := matchinfo(1000, []uint32{10, 1}, []uint32{10, 1}, []bm25Hit{{1, 10}, {1, 10}})
+
+	tests := []struct {
+		name string
+		info []byte
+	}{
+		{"empty", nil},
+		{"header only", full[:8]},
+		{"hits cut short", full[:len(full)-4]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bm25(tt.info); got != 0 {
+				t.Errorf("bm25 = %v, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"SELECT * FROM messages", true},
+		{"select count(*) from messages;", true},
+		{"  SELECT 1 ;  ", true},
+		{"(SELECT 1)", true},
+		{"VALUES (1), (2)", true},
+		{"EXPLAIN QUERY PLAN SELECT * FROM messages", true},
+		{"WITH recent AS (SELECT * FROM messages) SELECT count(*) FROM recent", true},
+		{"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM n WHERE x < 5) SELECT x FROM n", true},
+		{`WITH "delete" AS (SELECT 1) SELECT * FROM "delete"`, true},
+		{"-- how many\nSELECT count(*) FROM messages", true},
+		{"/* how many */ SELECT count(*) FROM messages", true},
+		{"SELECT 'DROP TABLE messages; --' AS text", true},
+		{"SELECT 1 -- trailing; DELETE FROM messages", true},
+
+		// More than one statement
+		{"SELECT 1; DELETE FROM messages", false},
+		{"SELECT 1;; ", false},
+		{"SELECT 1 /* */ ; /* */ SELECT 2", false},
+		{"SELECT ';'; DROP TABLE messages", false},
+
+		// WITH leading to a write
+		{"WITH old AS (SELECT id FROM messages) DELETE FROM messages WHERE id IN old", false},
+		{"WITH x AS (SELECT 1) INSERT INTO users (id) SELECT * FROM x", false},
+		{"WITH x AS (SELECT 1) UPDATE users SET name = 'x'", false},
+		{"WITH x AS (SELECT 1) REPLACE INTO users (id) VALUES ('x')", false},
+		{"WITH x AS (SELECT 1)", false},
+
+		// Comments hiding the keyword
+		{"/* SELECT */ DELETE FROM messages", false},
+		{"-- SELECT\nDELETE FROM messages", false},
+		{"/* SELECT * FROM messages", false},
+
+		// Other statements
+		{"PRAGMA query_only = OFF", false},
+		{"PRAGMA table_info(messages)", false},
+		{"ATTACH DATABASE '/tmp/other.db' AS other", false},
+		{"DETACH DATABASE meta", false},
+		{"DELETE FROM messages", false},
+		{"DROP TABLE messages", false},
+		{"VACUUM", false},
+		{"", false},
+		{"  -- nothing\n", false},
+		{"SELECT 'unterminated", false},
+	}
+
+	for _, tt := range tests {
+		err := CheckReadOnlyQuery(tt.query)
+		if tt.ok && err != nil {
+			t.Errorf("CheckReadOnlyQuery(%q) = %v, want it accepted", tt.query, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("CheckReadOnlyQuery(%q) accepted the query, want an error", tt.query)
+		}
+	}
+}
+
+func TestReadOnlyQuery(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	insertTiedMessages(t, db)
+
+	var count int64
+	columns, err := db.ReadOnlyQuery(ctx, "SELECT count(*) AS n FROM messages", func(_ []string, values []interface{}) error {
+		count = values[0].(int64)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if strings.Join(columns, ",") != "n" || count != 9 {
+		t.Errorf("got columns %v and count %d, want [n] and 9", columns, count)
+	}
+
+	// A rejected write leaves the messages as they were
+	if _, err := db.ReadOnlyQuery(ctx, "DELETE FROM messages", nil); err == nil {
+		t.Error("a DELETE was run")
+	}
+	var after int
+	if err := db.conn.QueryRowContext(ctx, "SELECT count(*) FROM messages").Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	if after != 9 {
+		t.Errorf("messages table holds %d rows after the query, want 9", after)
+	}
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Operations combining two result sets
+const (
+	SetUnion     = "union"
+	SetIntersect = "intersect"
+	SetDiff      = "diff"
+)
+
+// SetOperations lists the supported result set operations
+var SetOperations = []string{SetUnion, SetIntersect, SetDiff}
+
+// SaveResultSet saves the messages with the given timestamps, in order,
+// as a named result set, replacing any set of that name
+func (db *DB) SaveResultSet(ctx context.Context, name, query string, timestamps []string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM result_set_messages WHERE set_name = ?`, name); err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO result_sets (name, query, created) VALUES (?, ?, ?)`,
+		name, query, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO result_set_messages (set_name, position, message_ts) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+	defer stmt.Close()
+	for i, ts := range timestamps {
+		if _, err := stmt.ExecContext(ctx, name, i, ts); err != nil {
+			return fmt.Errorf("failed to save result set: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+	return nil
+}
+
+// GetResultSets returns the saved result sets, by name
+func (db *DB) GetResultSets(ctx context.Context) ([]models.ResultSet, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT s.name, s.query, s.created, COUNT(r.message_ts)
+		FROM result_sets s
+		LEFT JOIN result_set_messages r ON r.set_name = s.name
+		GROUP BY s.name
+		ORDER BY s.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query result sets: %w", err)
+	}
+	defer rows.Close()
+
+	var sets []models.ResultSet
+	for rows.Next() {
+		var set models.ResultSet
+		if err := rows.Scan(&set.Name, &set.Query, &set.Created, &set.Messages); err != nil {
+			return nil, fmt.Errorf("failed to scan result set: %w", err)
+		}
+		sets = append(sets, set)
+	}
+
+	return sets, rows.Err()
+}
+
+// GetResultSet returns a saved result set and the timestamps of its
+// messages in order
+func (db *DB) GetResultSet(ctx context.Context, name string) (*models.ResultSet, []string, error) {
+	set := &models.ResultSet{Name: name}
+	err := db.conn.QueryRowContext(ctx, `SELECT query, created FROM result_sets WHERE name = ?`, name).
+		Scan(&set.Query, &set.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("no result set named %q", name)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query result set: %w", err)
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT message_ts FROM result_set_messages WHERE set_name = ? ORDER BY position`, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query result set: %w", err)
+	}
+	defer rows.Close()
+
+	var timestamps []string
+	for rows.Next() {
+		var ts string
+		if err := rows.Scan(&ts); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan result set: %w", err)
+		}
+		timestamps = append(timestamps, ts)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query result set: %w", err)
+	}
+	set.Messages = len(timestamps)
+
+	return set, timestamps, nil
+}
+
+// GetMessagesByTimestamps returns the messages with the given timestamps,
+// in the order given. Timestamps with no message, such as those of
+// messages removed by a rebuild, are skipped.
+func (db *DB) GetMessagesByTimestamps(ctx context.Context, timestamps []string) ([]*models.Message, error) {
+	var messages []*models.Message
+	for _, ts := range timestamps {
+		message, err := db.GetMessageByTimestamp(ctx, ts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// DeleteResultSet removes a saved result set, reporting whether it existed
+func (db *DB) DeleteResultSet(ctx context.Context, name string) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete result set: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM result_set_messages WHERE set_name = ?`, name); err != nil {
+		return false, fmt.Errorf("failed to delete result set: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM result_sets WHERE name = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete result set: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to delete result set: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// CombineResultSets applies a set operation to the message timestamps of
+// two result sets. Results keep the order of a, followed for a union by
+// those only in b, in b's order.
+func CombineResultSets(op string, a, b []string) ([]string, error) {
+	inB := make(map[string]bool, len(b))
+	for _, ts := range b {
+		inB[ts] = true
+	}
+
+	var combined []string
+	seen := make(map[string]bool)
+	add := func(ts string) {
+		if !seen[ts] {
+			seen[ts] = true
+			combined = append(combined, ts)
+		}
+	}
+
+	switch op {
+	case SetUnion:
+		for _, ts := range a {
+			add(ts)
+		}
+		for _, ts := range b {
+			add(ts)
+		}
+	case SetIntersect:
+		for _, ts := range a {
+			if inB[ts] {
+				add(ts)
+			}
+		}
+	case SetDiff:
+		for _, ts := range a {
+			if !inB[ts] {
+				add(ts)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown set operation: %s", op)
+	}
+
+	return combined, nil
+}
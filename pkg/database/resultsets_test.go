@@ -0,0 +1,43 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCombineResultSets(t *testing.T) {
+	a := []string{"3", "1", "2", "1"}
+	b := []string{"4", "2", "5", "3"}
+
+	tests := []struct {
+		op   string
+		a, b []string
+		want []string
+	}{
+		// a's order first, then what only b has, each once
+		{SetUnion, a, b, []string{"3", "1", "2", "4", "5"}},
+		{SetIntersect, a, b, []string{"3", "2"}},
+		{SetDiff, a, b, []string{"1"}},
+		{SetDiff, b, a, []string{"4", "5"}},
+		{SetUnion, nil, b, []string{"4", "2", "5", "3"}},
+		{SetIntersect, a, nil, nil},
+		{SetDiff, a, nil, []string{"3", "1", "2"}},
+		{SetUnion, nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			got, err := CombineResultSets(tt.op, tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("CombineResultSets(%s) failed: %v", tt.op, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CombineResultSets(%s, %q, %q) = %q, want %q", tt.op, tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+
+	if got, err := CombineResultSets("xor", a, b); err == nil {
+		t.Errorf("CombineResultSets(xor) = %q, want an error", got)
+	}
+}
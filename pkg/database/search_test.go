@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// newTestDB returns an empty database in a temporary directory, closed
+// when the test finishes
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := NewDBInDir(t.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// insertTiedMessages stores messages with the same text, so they have the
+// same rank, posted in pairs within the same microsecond. Every search
+// order has ties only the message ID can break.
+func insertTiedMessages(t *testing.T, db *DB) {
+	t.Helper()
+
+	if err := db.InsertUser(&models.User{ID: "U1", Name: "alice"}); err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 8; i++ {
+		posted := base.Add(time.Duration(i/2) * time.Minute)
+		message := &models.Message{
+			UserID:    "U1",
+			Text:      "kubelet restart loop",
+			Type:      "message",
+			Timestamp: fmt.Sprintf("%d.%06d", posted.Unix(), i),
+			Date:      posted,
+			TSMicros:  posted.UnixMicro(),
+			Filename:  "2024-03-04.json",
+		}
+		if err := db.InsertMessage(message); err != nil {
+			t.Fatalf("failed to insert message: %v", err)
+		}
+	}
+	other := &models.Message{UserID: "U1", Text: "unrelated chatter", Type: "message",
+		Timestamp: "1709550000.000100", Date: base, TSMicros: base.UnixMicro(), Filename: "2024-03-04.json"}
+	if err := db.InsertMessage(other); err != nil {
+		t.Fatalf("failed to insert message: %v", err)
+	}
+}
+
+func resultIDs(results []*models.SearchResult) []int {
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+func TestSearchOrderIsStable(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	insertTiedMessages(t, db)
+
+	tests := []struct {
+		sort string
+		// before reports whether a must come before b
+		before func(a, b *models.SearchResult) bool
+	}{
+		{models.SortRelevance, func(a, b *models.SearchResult) bool {
+			return a.TSMicros > b.TSMicros || (a.TSMicros == b.TSMicros && a.ID > b.ID)
+		}},
+		{models.SortNewest, func(a, b *models.SearchResult) bool {
+			return a.TSMicros > b.TSMicros || (a.TSMicros == b.TSMicros && a.ID > b.ID)
+		}},
+		{models.SortOldest, func(a, b *models.SearchResult) bool {
+			return a.TSMicros < b.TSMicros || (a.TSMicros == b.TSMicros && a.ID < b.ID)
+		}},
+	}
+	for _, tt := range tests {
+		for _, query := range []string{"kubelet", ""} {
+			t.Run(fmt.Sprintf("%s/%q", tt.sort, query), func(t *testing.T) {
+				filter := models.SearchFilter{Sort: tt.sort}
+				first, err := db.SearchMessagesFiltered(ctx, query, filter, 0)
+				if err != nil {
+					t.Fatalf("search failed: %v", err)
+				}
+				second, err := db.SearchMessagesFiltered(ctx, query, filter, 0)
+				if err != nil {
+					t.Fatalf("search failed: %v", err)
+				}
+
+				want := 8
+				if query == "" {
+					want = 9
+				}
+				if len(first) != want {
+					t.Fatalf("got %d results, want %d", len(first), want)
+				}
+				if !reflect.DeepEqual(resultIDs(first), resultIDs(second)) {
+					t.Errorf("order changed between runs: %v then %v", resultIDs(first), resultIDs(second))
+				}
+				for i := 1; i < len(first); i++ {
+					if !tt.before(first[i-1], first[i]) {
+						t.Errorf("result %d (ID %d) is before result %d (ID %d); order %v",
+							i-1, first[i-1].ID, i, first[i].ID, resultIDs(first))
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSearchRelevanceRanksBeforeDate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	insertTiedMessages(t, db)
+
+	// An older message repeating the term outranks the newer ones
+	posted := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	strong := &models.Message{UserID: "U1", Text: "kubelet kubelet kubelet", Type: "message",
+		Timestamp: "1709283600.000100", Date: posted, TSMicros: posted.UnixMicro(), Filename: "2024-03-01.json"}
+	if err := db.InsertMessage(strong); err != nil {
+		t.Fatalf("failed to insert message: %v", err)
+	}
+
+	results, err := db.SearchMessagesFiltered(ctx, "kubelet", models.SearchFilter{}, 0)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) == 0 || results[0].Timestamp != strong.Timestamp {
+		t.Fatalf("first result is not the strongest match: %v", resultIDs(results))
+	}
+	if results[0].Rank <= results[1].Rank {
+		t.Errorf("strongest match ranks %v, no higher than the next (%v)", results[0].Rank, results[1].Rank)
+	}
+}
+
+func TestSearchRandomOrder(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	insertTiedMessages(t, db)
+	filter := models.SearchFilter{Sort: models.SortRandom}
+
+	all, err := db.SearchMessagesFiltered(ctx, "kubelet", models.SearchFilter{}, 0)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	want := resultIDs(all)
+	sort.Ints(want)
+
+	t.Run("returns every match", func(t *testing.T) {
+		for run := 0; run < 2; run++ {
+			results, err := db.SearchMessagesFiltered(ctx, "kubelet", filter, 0)
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			got := resultIDs(results)
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("run %d returned %v, want the matches %v", run, got, want)
+			}
+		}
+	})
+
+	t.Run("limit returns distinct matches", func(t *testing.T) {
+		results, err := db.SearchMessagesFiltered(ctx, "kubelet", filter, 3)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if len(results) != 3 {
+			t.Fatalf("got %d results, want 3", len(results))
+		}
+		seen := make(map[int]bool)
+		for _, result := range results {
+			if seen[result.ID] {
+				t.Errorf("message %d returned twice", result.ID)
+			}
+			seen[result.ID] = true
+			if result.Text != "kubelet restart loop" {
+				t.Errorf("message %d doesn't match: %q", result.ID, result.Text)
+			}
+		}
+	})
+
+	t.Run("without a query", func(t *testing.T) {
+		results, err := db.SearchMessagesFiltered(ctx, "", filter, 0)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if len(results) != 9 {
+			t.Errorf("got %d results, want every message (9)", len(results))
+		}
+	})
+
+	t.Run("order varies", func(t *testing.T) {
+		first, err := db.SearchMessagesFiltered(ctx, "kubelet", filter, 0)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		// The chance of 20 more shuffles of 8 messages all matching is
+		// negligible
+		for run := 0; run < 20; run++ {
+			results, err := db.SearchMessagesFiltered(ctx, "kubelet", filter, 0)
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			if !reflect.DeepEqual(resultIDs(results), resultIDs(first)) {
+				return
+			}
+		}
+		t.Errorf("random order returned %v on every run", resultIDs(first))
+	})
+}
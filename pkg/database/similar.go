@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Messages similar to another are found with a full-text search for its
+// salient terms: the words it uses that are rare in the rest of the
+// archive. Candidates are scored by the share of those terms' weight they
+// contain.
+const (
+	// maxSimilarCandidates bounds the matches scored for a similarity search
+	maxSimilarCandidates = 5000
+	// maxTermShare leaves out terms found in more than this share of
+	// messages, which say little about what a message is about; terms in
+	// up to minTermLimit messages are kept, for small channels
+	maxTermShare = 0.1
+	minTermLimit = 10
+)
+
+// SimilarMessages returns up to limit messages resembling message, most
+// similar first, and the salient terms they were found by, at most
+// maxTerms of them. Messages in the same thread are left out, since
+// similar messages are looked for to find other discussions of the same
+// problem.
+func (db *DB) SimilarMessages(ctx context.Context, message *models.Message, maxTerms, limit int) ([]string, []*models.SimilarMessage, error) {
+	terms, weights, err := db.salientTerms(ctx, message.Text, maxTerms)
+	if err != nil || len(terms) == 0 {
+		return nil, nil, err
+	}
+
+	var totalWeight float64
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `"` + term + `"`
+		totalWeight += weights[i]
+	}
+
+	thread := message.ThreadTS
+	if thread == "" {
+		thread = message.Timestamp
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT m.id, offsets(messages_fts), snippet(messages_fts, '<mark>', '</mark>', '...', 0, 32)
+		FROM messages_fts fts
+		JOIN messages m ON m.id = fts.rowid
+		WHERE messages_fts MATCH ? AND `+threadKey+` != ?
+		LIMIT ?`, strings.Join(phrases, " OR "), thread, maxSimilarCandidates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("similarity search failed: %w", err)
+	}
+	defer rows.Close()
+
+	// A result must share two terms, unless only one was found
+	minShared := 2
+	if len(terms) < minShared {
+		minShared = len(terms)
+	}
+
+	var similar []*models.SimilarMessage
+	for rows.Next() {
+		var id int
+		var offsets, snippet string
+		if err := rows.Scan(&id, &offsets, &snippet); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan similar message: %w", err)
+		}
+
+		shared := sharedTerms(offsets, len(terms))
+		if len(shared) < minShared {
+			continue
+		}
+		result := &models.SimilarMessage{}
+		result.ID = id
+		result.Snippet = snippet
+		for _, i := range shared {
+			result.Similarity += weights[i] / totalWeight
+			result.SharedTerms = append(result.SharedTerms, terms[i])
+		}
+		similar = append(similar, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("similarity search failed: %w", err)
+	}
+
+	// Most similar first, then newest (the highest ID)
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Similarity != similar[j].Similarity {
+			return similar[i].Similarity > similar[j].Similarity
+		}
+		return similar[i].ID > similar[j].ID
+	})
+	if limit > 0 && len(similar) > limit {
+		similar = similar[:limit]
+	}
+
+	for _, result := range similar {
+		found, err := db.GetMessage(ctx, result.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		result.Message = *found
+		result.Filename = found.Filename
+		result.Rank = result.Similarity
+	}
+
+	return terms, similar, nil
+}
+
+// salientTerms returns up to limit of the terms of text that best
+// distinguish it, with their weights, highest first. Each term is weighted
+// by how often text uses it and how rare it is in the archive. Stopwords,
+// short terms, terms containing digits, which are mostly IDs and
+// timestamps, and terms no other message uses are left out.
+func (db *DB) salientTerms(ctx context.Context, text string, limit int) ([]string, []float64, error) {
+	filter, err := db.GetTermFilter(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	stopwords := make(map[string]bool, len(filter.Stopwords))
+	for _, word := range filter.Stopwords {
+		stopwords[word] = true
+	}
+
+	counts := make(map[string]int)
+	var candidates []interface{}
+	for _, token := range tokenizeSnippetText(text) {
+		term := strings.ToLower(text[token.start:token.end])
+		if len(term) < filter.MinLength || stopwords[term] || strings.ContainsAny(term, "0123456789") {
+			continue
+		}
+		if counts[term] == 0 {
+			candidates = append(candidates, term)
+		}
+		counts[term]++
+	}
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+
+	var total int
+	if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages`).Scan(&total); err != nil {
+		return nil, nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	maxDocuments := math.Max(maxTermShare*float64(total), minTermLimit)
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT term, documents
+		FROM messages_terms
+		WHERE col = 0 AND term IN (?`+strings.Repeat(", ?", len(candidates)-1)+`)`, candidates...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("term query failed: %w", err)
+	}
+	defer rows.Close()
+
+	type weighted struct {
+		term   string
+		weight float64
+	}
+	var scored []weighted
+	for rows.Next() {
+		var term string
+		var documents int
+		if err := rows.Scan(&term, &documents); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan term: %w", err)
+		}
+		if documents < 2 || float64(documents) > maxDocuments {
+			continue
+		}
+		weight := (1 + math.Log(float64(counts[term]))) * math.Log(float64(total)/float64(documents))
+		scored = append(scored, weighted{term, weight})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("term query failed: %w", err)
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].weight != scored[j].weight {
+			return scored[i].weight > scored[j].weight
+		}
+		return scored[i].term < scored[j].term
+	})
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	terms := make([]string, len(scored))
+	weights := make([]float64, len(scored))
+	for i, s := range scored {
+		terms[i] = s.term
+		weights[i] = s.weight
+	}
+	return terms, weights, nil
+}
+
+// sharedTerms returns the indexes of the query phrases that offsets()
+// reports matching the text column, each once, in order
+func sharedTerms(offsets string, phrases int) []int {
+	fields := strings.Fields(offsets)
+	seen := make([]bool, phrases)
+	for i := 0; i+3 < len(fields); i += 4 {
+		if fields[i] != "0" {
+			continue
+		}
+		if phrase, err := strconv.Atoi(fields[i+1]); err == nil && phrase >= 0 && phrase < phrases {
+			seen[phrase] = true
+		}
+	}
+
+	var shared []int
+	for i, ok := range seen {
+		if ok {
+			shared = append(shared, i)
+		}
+	}
+	return shared
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// RecordSkipped records the messages skipped in an export file, replacing
+// what was recorded for the file before
+func (db *DB) RecordSkipped(filename string, counts []models.SkipCount, examples []models.SkippedMessage) error {
+	for _, table := range []string{"skipped_counts", "skipped_examples"} {
+		if _, err := db.exec().Exec(`DELETE FROM `+table+` WHERE filename = ?`, filename); err != nil {
+			return fmt.Errorf("failed to record skipped messages: %w", err)
+		}
+	}
+
+	for _, count := range counts {
+		_, err := db.exec().Exec(`INSERT INTO skipped_counts (filename, reason, subtype, count) VALUES (?, ?, ?, ?)`,
+			filename, count.Reason, count.Subtype, count.Count)
+		if err != nil {
+			return fmt.Errorf("failed to record skipped messages: %w", err)
+		}
+	}
+	for _, example := range examples {
+		_, err := db.exec().Exec(`INSERT OR REPLACE INTO skipped_examples (filename, position, reason, subtype, message_ts, user_id, excerpt)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			filename, example.Position, example.Reason, example.Subtype, example.Timestamp, example.UserID, example.Excerpt)
+		if err != nil {
+			return fmt.Errorf("failed to record skipped messages: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetSkipReport summarizes the messages skipped by every ingest of the
+// database, most skipped reason first, with up to examples examples of
+// each from the earliest files
+func (db *DB) GetSkipReport(ctx context.Context, examples int) (*models.SkipReport, error) {
+	report := &models.SkipReport{}
+	if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages`).Scan(&report.Indexed); err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT reason, subtype, SUM(count) FROM skipped_counts GROUP BY reason, subtype`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query skipped messages: %w", err)
+	}
+	byReason := make(map[string]*models.SkipReason)
+	var order []string
+	for rows.Next() {
+		var reason, subtype string
+		var count int
+		if err := rows.Scan(&reason, &subtype, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan skipped messages: %w", err)
+		}
+		entry, ok := byReason[reason]
+		if !ok {
+			entry = &models.SkipReason{Reason: reason}
+			byReason[reason] = entry
+			order = append(order, reason)
+		}
+		entry.Count += count
+		if subtype != "" {
+			if entry.Subtypes == nil {
+				entry.Subtypes = make(map[string]int)
+			}
+			entry.Subtypes[subtype] += count
+		}
+		report.Skipped += count
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query skipped messages: %w", err)
+	}
+
+	for _, reason := range order {
+		entry := byReason[reason]
+		if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(DISTINCT filename) FROM skipped_counts WHERE reason = ?`,
+			reason).Scan(&entry.Files); err != nil {
+			return nil, fmt.Errorf("failed to count files: %w", err)
+		}
+		if examples > 0 {
+			if entry.Examples, err = db.skippedExamples(ctx, reason, examples); err != nil {
+				return nil, err
+			}
+		}
+		report.Reasons = append(report.Reasons, *entry)
+	}
+	sortSkipReasons(report.Reasons)
+	return report, nil
+}
+
+// skippedExamples returns up to limit examples of the messages skipped for
+// a reason, from the earliest files
+func (db *DB) skippedExamples(ctx context.Context, reason string, limit int) ([]models.SkippedMessage, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT filename, position, reason, subtype, message_ts, user_id, excerpt
+		FROM skipped_examples
+		WHERE reason = ?
+		ORDER BY filename, position
+		LIMIT ?`, reason, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query skipped messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.SkippedMessage
+	for rows.Next() {
+		var message models.SkippedMessage
+		if err := rows.Scan(&message.Filename, &message.Position, &message.Reason, &message.Subtype,
+			&message.Timestamp, &message.UserID, &message.Excerpt); err != nil {
+			return nil, fmt.Errorf("failed to scan skipped message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// sortSkipReasons orders reasons by the messages skipped, most first, ties
+// by name
+func sortSkipReasons(reasons []models.SkipReason) {
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Search results normally carry the one 32-token window snippet() picks.
+// With more snippets requested, a message matching in several places has
+// its snippet built here instead, from the match offsets FTS reports for
+// the full text.
+const (
+	// snippetWindow is the number of tokens in each extracted window
+	snippetWindow = 24
+	// snippetLead is the number of tokens shown before a window's first match
+	snippetLead = 4
+	// snippetMaxSpan caps the tokens in windows merged together, so a
+	// message dense with matches still gives several snippets
+	snippetMaxSpan = 2 * snippetWindow
+)
+
+// SetSnippets sets how many snippets search results show of messages
+// matching in several places. Windows close enough to overlap are merged,
+// and up to n of those with the most matches are joined with "...". One or
+// less keeps the single snippet SQLite picks.
+func (db *DB) SetSnippets(n int) {
+	db.snippets = n
+}
+
+// snippetToken is a token's byte range in a message's text
+type snippetToken struct {
+	start, end int
+}
+
+// tokenizeSnippetText splits text into tokens as FTS's simple tokenizer
+// does: runs of ASCII letters and digits and of non-ASCII bytes
+func tokenizeSnippetText(text string) []snippetToken {
+	var tokens []snippetToken
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && isTokenByte(text[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, snippetToken{start, i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+func isTokenByte(b byte) bool {
+	return b >= 0x80 || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// matchOffsets returns the byte offsets of the matches in the text column
+// from the value of offsets(messages_fts): groups of four integers giving
+// the column, term, byte offset and size of each match
+func matchOffsets(offsets string) []int {
+	fields := strings.Fields(offsets)
+	var matches []int
+	for i := 0; i+3 < len(fields); i += 4 {
+		if fields[i] != "0" {
+			continue
+		}
+		offset, err := strconv.Atoi(fields[i+2])
+		if err != nil {
+			continue
+		}
+		matches = append(matches, offset)
+	}
+	sort.Ints(matches)
+	return matches
+}
+
+// snippetSpan is a window of tokens [first, last] and the matches in it
+type snippetSpan struct {
+	first, last int
+	matches     []int
+}
+
+// extractSnippets builds up to n snippets of text around the matches at the
+// given byte offsets, marking each match as snippet() does. It returns ""
+// when the matches fit in one window, leaving the SQL snippet in place.
+func extractSnippets(text string, offsets []int, n int) string {
+	tokens := tokenizeSnippetText(text)
+	if len(tokens) == 0 || len(offsets) == 0 {
+		return ""
+	}
+
+	// The index of the token each match starts
+	var matched []int
+	for _, offset := range offsets {
+		i := sort.Search(len(tokens), func(i int) bool { return tokens[i].end > offset })
+		if i < len(tokens) && (len(matched) == 0 || matched[len(matched)-1] != i) {
+			matched = append(matched, i)
+		}
+	}
+
+	// Cover the matches with windows in text order, merging those that
+	// overlap or touch up to snippetMaxSpan tokens
+	var spans []snippetSpan
+	for _, token := range matched {
+		var prev *snippetSpan
+		if len(spans) > 0 {
+			prev = &spans[len(spans)-1]
+		}
+		if prev != nil && token <= prev.last {
+			prev.matches = append(prev.matches, token)
+			continue
+		}
+		first := token - snippetLead
+		if first < 0 {
+			first = 0
+		}
+		last := first + snippetWindow - 1
+		if last >= len(tokens) {
+			last = len(tokens) - 1
+		}
+		if prev != nil && first <= prev.last+1 {
+			if last-prev.first < snippetMaxSpan {
+				prev.last = last
+				prev.matches = append(prev.matches, token)
+				continue
+			}
+			first = prev.last + 1
+		}
+		spans = append(spans, snippetSpan{first: first, last: last, matches: []int{token}})
+	}
+	if len(spans) < 2 {
+		return ""
+	}
+
+	// Keep the windows with the most matches, shown in text order
+	if len(spans) > n {
+		sort.SliceStable(spans, func(i, j int) bool { return len(spans[i].matches) > len(spans[j].matches) })
+		spans = spans[:n]
+		sort.Slice(spans, func(i, j int) bool { return spans[i].first < spans[j].first })
+	}
+
+	var snippet strings.Builder
+	for i, span := range spans {
+		switch {
+		case i > 0 && span.first == spans[i-1].last+1:
+			// Windows split by snippetMaxSpan that were both kept
+			snippet.WriteString(text[tokens[spans[i-1].last].end:tokens[span.first].start])
+		case i > 0 || span.first > 0:
+			snippet.WriteString("...")
+		}
+		pos := tokens[span.first].start
+		for _, token := range span.matches {
+			snippet.WriteString(text[pos:tokens[token].start])
+			snippet.WriteString("<mark>" + text[tokens[token].start:tokens[token].end] + "</mark>")
+			pos = tokens[token].end
+		}
+		snippet.WriteString(text[pos:tokens[span.last].end])
+	}
+	if spans[len(spans)-1].last < len(tokens)-1 {
+		snippet.WriteString("...")
+	}
+
+	return snippet.String()
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/identifiers"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Traced searches count the rows they read with the SQL function
+// search_scanned(id, message_id), which is added to their WHERE clause
+// ahead of the filters. It is true for every row and counts the calls under
+// the search's id.
+var (
+	scanCountersMu sync.Mutex
+	scanCounters   = make(map[int64]*int64)
+	lastScanID     int64
+)
+
+// newScanCounter returns the id a traced search passes to search_scanned
+// and the counter it increments
+func newScanCounter() (int64, *int64) {
+	id := atomic.AddInt64(&lastScanID, 1)
+	counter := new(int64)
+	scanCountersMu.Lock()
+	scanCounters[id] = counter
+	scanCountersMu.Unlock()
+	return id, counter
+}
+
+func releaseScanCounter(id int64) {
+	scanCountersMu.Lock()
+	delete(scanCounters, id)
+	scanCountersMu.Unlock()
+}
+
+// searchScanned is the SQL function search_scanned(id, message_id)
+func searchScanned(id, messageID int64) bool {
+	scanCountersMu.Lock()
+	counter := scanCounters[id]
+	scanCountersMu.Unlock()
+	if counter != nil {
+		atomic.AddInt64(counter, 1)
+	}
+	return true
+}
+
+// SetTracer makes every search by StreamMessagesFiltered, and so
+// SearchMessagesFiltered, pass fn a trace of how it ran once it finishes,
+// whether or not it succeeded. A nil fn stops tracing.
+func (db *DB) SetTracer(fn func(*models.SearchTrace)) {
+	db.tracer = fn
+}
+
+// traceSearch starts a trace of a search, returning the id its SQL passes
+// to search_scanned and a function that completes the trace and hands it
+// to the tracer
+func (db *DB) traceSearch(ctx context.Context, query string, filter models.SearchFilter, limit int) (int64, func(sqlQuery string, args []interface{}, results int, err error)) {
+	id, counter := newScanCounter()
+	start := time.Now()
+
+	return id, func(sqlQuery string, args []interface{}, results int, err error) {
+		duration := time.Since(start)
+		releaseScanCounter(id)
+
+		trace := &models.SearchTrace{
+			Time:        start.UTC(),
+			Filters:     filter.Describe(),
+			Limit:       limit,
+			Duration:    duration,
+			RowsScanned: int(atomic.LoadInt64(counter)),
+			Results:     results,
+		}
+		if query != "" {
+			trace.Match = identifiers.RewriteQuery(query, "identifiers")
+		}
+		if err != nil {
+			trace.Error = err.Error()
+			trace.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		}
+
+		// The search's own context may have expired, which is when its plan
+		// is most wanted
+		plan, planErr := db.queryPlan(context.Background(), sqlQuery, args)
+		if planErr == nil {
+			trace.Plan = plan
+		}
+
+		db.tracer(trace)
+	}
+}
+
+// queryPlan returns SQLite's plan for running a statement
+func (db *DB) queryPlan(ctx context.Context, sqlQuery string, args []interface{}) ([]models.QueryPlanStep, error) {
+	rows, err := db.conn.QueryContext(ctx, "EXPLAIN QUERY PLAN "+sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain search: %w", err)
+	}
+	defer rows.Close()
+
+	var plan []models.QueryPlanStep
+	for rows.Next() {
+		var step models.QueryPlanStep
+		var unused int
+		if err := rows.Scan(&step.ID, &step.Parent, &unused, &step.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan query plan: %w", err)
+		}
+		plan = append(plan, step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to explain search: %w", err)
+	}
+
+	return plan, nil
+}
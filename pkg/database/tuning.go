@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Tuning holds the SQLite settings applied to every database connection
+type Tuning struct {
+	// CacheSizeMB is the page cache of each connection
+	CacheSizeMB int
+	// MmapSizeMB is how much of the database file is read through
+	// memory-mapped I/O; 0 disables it
+	MmapSizeMB int
+	// Synchronous is one of SynchronousModes: how often SQLite waits for
+	// writes to reach the disk
+	Synchronous string
+	// TempStore is one of TempStores: where temporary tables and indexes,
+	// such as those built for sorting, are kept
+	TempStore string
+	// JournalMode is one of JournalModes, or empty to keep the database's
+	// own. The mode is stored in the database, so it lasts beyond the
+	// connection that sets it.
+	JournalMode string
+}
+
+// SynchronousModes are the accepted Tuning.Synchronous values, least
+// durable first
+var SynchronousModes = []string{"off", "normal", "full", "extra"}
+
+// TempStores are the accepted Tuning.TempStore values
+var TempStores = []string{"default", "file", "memory"}
+
+// JournalModes are the accepted Tuning.JournalMode values besides empty
+var JournalModes = []string{"delete", "wal"}
+
+var (
+	// DefaultTuning is used by commands that search and read databases.
+	// Writes wait for the disk as in SQLite's defaults; the larger cache
+	// and memory-mapped reads speed up searches.
+	DefaultTuning = Tuning{CacheSizeMB: 16, MmapSizeMB: 256, Synchronous: "full", TempStore: "default"}
+
+	// IngestTuning is used by ingest. Databases are switched to WAL mode,
+	// where commits only wait for the disk at checkpoints; a crash of the
+	// machine can lose the last commits, which a rerun ingests again, but
+	// can't corrupt the database.
+	IngestTuning = Tuning{CacheSizeMB: 64, MmapSizeMB: 256, Synchronous: "normal", TempStore: "memory", JournalMode: "wal"}
+)
+
+var (
+	tuningMu sync.RWMutex
+	tuning   = DefaultTuning
+)
+
+// Validate checks that the settings are within range
+func (t Tuning) Validate() error {
+	if t.CacheSizeMB < 1 {
+		return fmt.Errorf("invalid cache size %d MB: must be at least 1", t.CacheSizeMB)
+	}
+	if t.MmapSizeMB < 0 {
+		return fmt.Errorf("invalid mmap size %d MB: must be 0 or more", t.MmapSizeMB)
+	}
+	if !contains(SynchronousModes, t.Synchronous) {
+		return fmt.Errorf("invalid synchronous mode %q (expected %s)", t.Synchronous, strings.Join(SynchronousModes, ", "))
+	}
+	if !contains(TempStores, t.TempStore) {
+		return fmt.Errorf("invalid temp store %q (expected %s)", t.TempStore, strings.Join(TempStores, ", "))
+	}
+	if t.JournalMode != "" && !contains(JournalModes, t.JournalMode) {
+		return fmt.Errorf("invalid journal mode %q (expected %s)", t.JournalMode, strings.Join(JournalModes, ", "))
+	}
+	return nil
+}
+
+// pragmas returns the statements that apply the settings to a connection
+func (t Tuning) pragmas() []string {
+	pragmas := []string{
+		// A negative cache size is in KiB
+		fmt.Sprintf("PRAGMA cache_size = -%d", t.CacheSizeMB*1024),
+		fmt.Sprintf("PRAGMA mmap_size = %d", int64(t.MmapSizeMB)<<20),
+		"PRAGMA synchronous = " + t.Synchronous,
+		"PRAGMA temp_store = " + t.TempStore,
+	}
+	if t.JournalMode != "" {
+		pragmas = append(pragmas, "PRAGMA journal_mode = "+t.JournalMode)
+	}
+	return pragmas
+}
+
+// SetTuning changes the settings applied to database connections opened
+// from now on. Connections already open keep theirs.
+func SetTuning(t Tuning) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	tuningMu.Lock()
+	defer tuningMu.Unlock()
+	tuning = t
+	return nil
+}
+
+// CurrentTuning returns the settings applied to new database connections
+func CurrentTuning() Tuning {
+	tuningMu.RLock()
+	defer tuningMu.RUnlock()
+	return tuning
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,386 @@
+// Package dbcrypt encrypts database files at rest. Files are sealed with
+// AES-256-GCM in fixed-size chunks, under a key derived with scrypt from a
+// passphrase or key file named in the environment. An encrypted database
+// is used through a locked, decrypted working copy beside it.
+package dbcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Environment variables holding the passphrase, or the path of a file
+// containing it
+const (
+	KeyEnv     = "K8S_SLACK_SEARCHER_KEY"
+	KeyFileEnv = "K8S_SLACK_SEARCHER_KEY_FILE"
+)
+
+// ErrNoKey is returned when an encrypted database is opened without a key
+var ErrNoKey = fmt.Errorf("no encryption key: set %s or %s", KeyEnv, KeyFileEnv)
+
+// ErrWrongKey is returned when a file cannot be decrypted with the key
+var ErrWrongKey = errors.New("wrong encryption key or corrupted file")
+
+var magic = []byte("KSSENC01")
+
+const (
+	saltSize   = 16
+	prefixSize = 8
+	chunkSize  = 64 * 1024
+)
+
+// LoadKey reads the passphrase from KeyEnv, or from the file named by
+// KeyFileEnv
+func LoadKey() ([]byte, error) {
+	if key := os.Getenv(KeyEnv); key != "" {
+		return []byte(key), nil
+	}
+
+	path := os.Getenv(KeyFileEnv)
+	if path == "" {
+		return nil, ErrNoKey
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key = bytes.TrimRight(key, "\r\n")
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+
+	return key, nil
+}
+
+// Encrypt reads plaintext from r and writes it encrypted to w. The output
+// starts with a header holding the scrypt salt and nonce prefix, followed by
+// chunks of up to 64 KiB, each sealed with its index and whether it is the
+// last, so reordered or truncated files fail to decrypt.
+func Encrypt(w io.Writer, r io.Reader, passphrase []byte) error {
+	salt := make([]byte, saltSize)
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	header := append(append(append([]byte{}, magic...), salt...), prefix...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(r, chunkSize)
+	buf := make([]byte, chunkSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		// The chunk is the last when nothing follows it
+		final := byte(0)
+		if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+			final = 1
+		}
+
+		sealed := aead.Seal(nil, nonce(prefix, counter), buf[:n], []byte{final})
+		var length [5]byte
+		length[0] = final
+		binary.BigEndian.PutUint32(length[1:], uint32(len(sealed)))
+		if _, err := w.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+
+		if final == 1 {
+			return nil
+		}
+	}
+}
+
+// Decrypt reads a file written by Encrypt from r and writes the plaintext
+// to w
+func Decrypt(w io.Writer, r io.Reader, passphrase []byte) error {
+	header := make([]byte, len(magic)+saltSize+prefixSize)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(magic)], magic) {
+		return fmt.Errorf("not an encrypted database")
+	}
+	salt := header[len(magic) : len(magic)+saltSize]
+	prefix := header[len(magic)+saltSize:]
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	for counter := uint32(0); ; counter++ {
+		var length [5]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return ErrWrongKey
+		}
+		final := length[0]
+		size := binary.BigEndian.Uint32(length[1:])
+		if final > 1 || size > chunkSize+uint32(aead.Overhead()) {
+			return ErrWrongKey
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(r, sealed); err != nil {
+			return ErrWrongKey
+		}
+		plain, err := aead.Open(nil, nonce(prefix, counter), sealed, []byte{final})
+		if err != nil {
+			return ErrWrongKey
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+
+		if final == 1 {
+			return nil
+		}
+	}
+}
+
+// EncryptFile encrypts the file at src into dst, replacing dst only once
+// the encrypted copy is complete
+func EncryptFile(src, dst string, passphrase []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFile(dst, func(w io.Writer) error {
+		return Encrypt(w, in, passphrase)
+	})
+}
+
+// CreateEmpty writes an encrypted empty file to dst, which SQLite opens as
+// a new database
+func CreateEmpty(dst string, passphrase []byte) error {
+	return writeFile(dst, func(w io.Writer) error {
+		return Encrypt(w, strings.NewReader(""), passphrase)
+	})
+}
+
+// DecryptFile decrypts the file at src into dst
+func DecryptFile(src, dst string, passphrase []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFile(dst, func(w io.Writer) error {
+		return Decrypt(w, in, passphrase)
+	})
+}
+
+// writeFile writes dst through a temporary file in the same directory,
+// readable only by the owner, and renames it into place
+func writeFile(dst string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	if err := write(writer); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce combines the file's random prefix with a chunk counter
+func nonce(prefix []byte, counter uint32) []byte {
+	n := make([]byte, 12)
+	copy(n, prefix)
+	binary.BigEndian.PutUint32(n[prefixSize:], counter)
+	return n
+}
+
+// WorkingCopy is a decrypted copy of an encrypted file, kept in a private
+// directory beside it while in use. The encrypted file is locked for as
+// long as the copy is open, so processes take turns rather than each
+// writing back its own copy; within a process, the copy is shared.
+type WorkingCopy struct {
+	// Path is the decrypted copy
+	Path      string
+	encrypted string
+	key       []byte
+	dir       string
+	lock      *os.File
+	size      int64
+	modTime   int64
+	// refs counts the opens of the copy not yet closed
+	refs int
+}
+
+var (
+	workingMu sync.Mutex
+	// working holds the open working copies by encrypted file
+	working = make(map[string]*WorkingCopy)
+)
+
+// WorkDir returns the private directory beside an encrypted file that
+// holds its working copy
+func WorkDir(encrypted string) string {
+	return filepath.Join(filepath.Dir(encrypted), "."+filepath.Base(encrypted)+".work")
+}
+
+// OpenWorkingCopy decrypts the file at encrypted, using the key from
+// LoadKey, into its WorkDir, readable only by the owner. It waits while
+// another process has the file open. A copy left behind by a process that
+// was killed is deleted first; any changes it held are lost.
+func OpenWorkingCopy(encrypted string) (*WorkingCopy, error) {
+	encrypted, err := filepath.Abs(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	workingMu.Lock()
+	defer workingMu.Unlock()
+
+	if c, ok := working[encrypted]; ok {
+		c.refs++
+		return c, nil
+	}
+
+	key, err := LoadKey()
+	if err != nil {
+		return nil, fmt.Errorf("%s is encrypted: %w", encrypted, err)
+	}
+
+	lockPath := filepath.Join(filepath.Dir(encrypted), "."+filepath.Base(encrypted)+".lock")
+	lock, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", encrypted, err)
+	}
+
+	// Holding the lock, any copy already there is stale
+	dir := WorkDir(encrypted)
+	if err := os.RemoveAll(dir); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to remove stale copy of %s: %w", encrypted, err)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(encrypted), filepath.Ext(encrypted))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	c := &WorkingCopy{
+		Path:      filepath.Join(dir, name),
+		encrypted: encrypted,
+		key:       key,
+		dir:       dir,
+		lock:      lock,
+		refs:      1,
+	}
+	if err := DecryptFile(encrypted, c.Path, key); err != nil {
+		c.release()
+		return nil, fmt.Errorf("failed to decrypt %s: %w", encrypted, err)
+	}
+
+	c.size, c.modTime, err = stat(c.Path)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	working[encrypted] = c
+	return c, nil
+}
+
+// Close encrypts the working copy back over the original if it was
+// modified, then deletes it and unlocks the original. When the copy was
+// opened more than once, only the last Close does so.
+func (c *WorkingCopy) Close() error {
+	workingMu.Lock()
+	defer workingMu.Unlock()
+
+	if c.refs--; c.refs > 0 {
+		return nil
+	}
+	delete(working, c.encrypted)
+	defer c.release()
+
+	size, modTime, err := stat(c.Path)
+	if err != nil {
+		return err
+	}
+	if size == c.size && modTime == c.modTime {
+		return nil
+	}
+
+	if err := EncryptFile(c.Path, c.encrypted, c.key); err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", c.encrypted, err)
+	}
+
+	return nil
+}
+
+// release deletes the working copy and unlocks the original
+func (c *WorkingCopy) release() {
+	os.RemoveAll(c.dir)
+	c.lock.Close()
+}
+
+func stat(path string) (int64, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return info.Size(), info.ModTime().UnixNano(), nil
+}
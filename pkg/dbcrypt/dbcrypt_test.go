@@ -0,0 +1,202 @@
+package dbcrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testKey = []byte("correct horse battery staple")
+
+func encrypt(t *testing.T, plain, key []byte) []byte {
+	t.Helper()
+
+	var sealed bytes.Buffer
+	if err := Encrypt(&sealed, bytes.NewReader(plain), key); err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	return sealed.Bytes()
+}
+
+func TestKeyDerivation(t *testing.T) {
+	salt := bytes.Repeat([]byte{1}, saltSize)
+	otherSalt := bytes.Repeat([]byte{2}, saltSize)
+	nonce := make([]byte, 12)
+	plain := []byte("kubelet certificate rotation")
+
+	seal := func(passphrase, salt []byte) []byte {
+		aead, err := newAEAD(passphrase, salt)
+		if err != nil {
+			t.Fatalf("failed to derive key: %v", err)
+		}
+		return aead.Seal(nil, nonce, plain, nil)
+	}
+
+	same := seal(testKey, salt)
+	if !bytes.Equal(same, seal(testKey, salt)) {
+		t.Error("the same passphrase and salt derived different keys")
+	}
+	if bytes.Equal(same, seal(testKey, otherSalt)) {
+		t.Error("different salts derived the same key")
+	}
+	if bytes.Equal(same, seal([]byte("wrong"), salt)) {
+		t.Error("different passphrases derived the same key")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 7}
+
+	for _, size := range sizes {
+		plain := make([]byte, size)
+		if _, err := rand.Read(plain); err != nil {
+			t.Fatal(err)
+		}
+
+		sealed := encrypt(t, plain, testKey)
+		if size >= 16 && bytes.Contains(sealed, plain) {
+			t.Errorf("size %d: plaintext appears in the encrypted output", size)
+		}
+
+		var out bytes.Buffer
+		if err := Decrypt(&out, bytes.NewReader(sealed), testKey); err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("size %d: decrypted %d bytes that differ from the plaintext", size, out.Len())
+		}
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	plain := bytes.Repeat([]byte("kubelet "), chunkSize/4)
+	sealed := encrypt(t, plain, testKey)
+	header := len(magic) + saltSize + prefixSize
+	firstChunk := 5 + chunkSize + 16
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	// Drop the final chunk, leaving a file that ends on a complete chunk
+	truncated := sealed[:header+firstChunk]
+
+	// Swap the first two chunks, both full
+	reordered := append([]byte{}, sealed[:header]...)
+	reordered = append(reordered, sealed[header+firstChunk:header+2*firstChunk]...)
+	reordered = append(reordered, sealed[header:header+firstChunk]...)
+	reordered = append(reordered, sealed[header+2*firstChunk:]...)
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+	}{
+		{"wrong passphrase", sealed, []byte("wrong passphrase")},
+		{"tampered", tampered, testKey},
+		{"truncated", truncated, testKey},
+		{"reordered", reordered, testKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Decrypt(&bytes.Buffer{}, bytes.NewReader(tt.sealed), tt.key)
+			if !errors.Is(err, ErrWrongKey) {
+				t.Errorf("decrypt returned %v, want ErrWrongKey", err)
+			}
+		})
+	}
+
+	if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(plain), testKey); err == nil || errors.Is(err, ErrWrongKey) {
+		t.Errorf("decrypting a plaintext file returned %v, want not an encrypted database", err)
+	}
+}
+
+func TestWorkingCopy(t *testing.T) {
+	t.Setenv(KeyEnv, string(testKey))
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "sig-auth.db.enc")
+	if err := CreateEmpty(encrypted, testKey); err != nil {
+		t.Fatalf("failed to create encrypted file: %v", err)
+	}
+
+	// A copy left behind by a killed process is removed on open
+	stale := filepath.Join(WorkDir(encrypted), "sig-auth")
+	if err := os.MkdirAll(filepath.Dir(stale), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("stale plaintext"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := OpenWorkingCopy(encrypted)
+	if err != nil {
+		t.Fatalf("failed to open working copy: %v", err)
+	}
+	if got, err := os.ReadFile(c.Path); err != nil || len(got) != 0 {
+		t.Fatalf("working copy holds %q (%v), want the empty decrypted file", got, err)
+	}
+	info, err := os.Stat(WorkDir(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("working copy directory has mode %o, want 700", perm)
+	}
+
+	// A second open in the same process shares the copy
+	again, err := OpenWorkingCopy(encrypted)
+	if err != nil {
+		t.Fatalf("failed to reopen working copy: %v", err)
+	}
+	if again != c {
+		t.Error("reopening gave a second working copy")
+	}
+
+	if err := os.WriteFile(c.Path, []byte("changed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := os.Stat(c.Path); err != nil {
+		t.Fatalf("working copy removed while still open: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := os.Stat(WorkDir(encrypted)); !os.IsNotExist(err) {
+		t.Errorf("working copy directory left behind: %v", err)
+	}
+
+	var out bytes.Buffer
+	in, err := os.Open(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if err := Decrypt(&out, in, testKey); err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if out.String() != "changed" {
+		t.Errorf("encrypted file holds %q, want the working copy's changes", out.String())
+	}
+}
+
+func TestWorkingCopyWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "sig-auth.db.enc")
+	if err := CreateEmpty(encrypted, testKey); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(KeyEnv, "wrong passphrase")
+	if _, err := OpenWorkingCopy(encrypted); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("open returned %v, want ErrWrongKey", err)
+	}
+	if _, err := os.Stat(WorkDir(encrypted)); !os.IsNotExist(err) {
+		t.Errorf("working copy directory left behind: %v", err)
+	}
+}
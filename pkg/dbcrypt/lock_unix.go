@@ -0,0 +1,19 @@
+//go:build unix
+
+package dbcrypt
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on f, waiting while another process
+// holds it. The lock is released when f is closed, or the process exits.
+func lockFile(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package dbcrypt
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// Another process opening the database must wait for the working copy to
+// be closed. flock locks belong to an open file, so a second open of the
+// lock file stands in for the other process.
+func TestWorkingCopyLocks(t *testing.T) {
+	t.Setenv(KeyEnv, string(testKey))
+	encrypted := filepath.Join(t.TempDir(), "sig-auth.db.enc")
+	if err := CreateEmpty(encrypted, testKey); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := OpenWorkingCopy(encrypted)
+	if err != nil {
+		t.Fatalf("failed to open working copy: %v", err)
+	}
+
+	other, err := os.Open(filepath.Join(filepath.Dir(encrypted), ".sig-auth.db.enc.lock"))
+	if err != nil {
+		t.Fatalf("lock file missing: %v", err)
+	}
+	defer other.Close()
+
+	if err := syscall.Flock(int(other.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != syscall.EWOULDBLOCK {
+		t.Errorf("locking an open database returned %v, want EWOULDBLOCK", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := syscall.Flock(int(other.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Errorf("locking a closed database failed: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// DatasetteDatabase describes one database file
+type DatasetteDatabase struct {
+	Description string                    `json:"description,omitempty"`
+	Tables      map[string]DatasetteTable `json:"tables,omitempty"`
+	Queries     map[string]DatasetteQuery `json:"queries,omitempty"`
+}
+
+// DatasetteTable describes how Datasette presents a table
+type DatasetteTable struct {
+	Description string            `json:"description,omitempty"`
+	LabelColumn string            `json:"label_column,omitempty"`
+	SortDesc    string            `json:"sort_desc,omitempty"`
+	FTSTable    string            `json:"fts_table,omitempty"`
+	FTSPK       string            `json:"fts_pk,omitempty"`
+	Facets      []string          `json:"facets,omitempty"`
+	Hidden      bool              `json:"hidden,omitempty"`
+	Columns     map[string]string `json:"columns,omitempty"`
+}
+
+// DatasetteQuery is a canned query. Named parameters such as :query become
+// form fields.
+type DatasetteQuery struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	SQL         string `json:"sql"`
+}
+
+// DatasetteName is the name Datasette gives a database: its file name
+// without the extension
+func DatasetteName(channelName string) string {
+	return strings.TrimSuffix(filepath.Base(storagepaths.DatabasePath(channelName)), ".db")
+}
+
+// DatasetteDatabase describes the exporter's database for Datasette, with
+// full-text search enabled on messages and canned queries for common
+// questions
+func (e *Exporter) DatasetteDatabase(ctx context.Context) DatasetteDatabase {
+	_, channel := storagepaths.SplitName(e.channelName)
+	description := fmt.Sprintf("Messages from #%s.", channel)
+	if info, err := e.db.GetChannelInfo(ctx, channel); err == nil {
+		if info.MessageCount > 0 {
+			description = fmt.Sprintf("%d messages from #%s, %s to %s.", info.MessageCount, channel,
+				info.FirstMessage.Format("2006-01-02"), info.LastMessage.Format("2006-01-02"))
+		}
+		if info.Topic != "" {
+			description += " Topic: " + info.Topic
+		}
+	}
+
+	return DatasetteDatabase{
+		Description: description,
+		Tables: map[string]DatasetteTable{
+			"messages": {
+				Description: "One row per message. Replies share their thread starter's thread_ts.",
+				LabelColumn: "text",
+				SortDesc:    "date",
+				FTSTable:    "messages_fts",
+				FTSPK:       "id",
+				Facets:      []string{"user_id", "has_link", "has_code", "has_file"},
+				Columns: map[string]string{
+					"user_id":        "Author, see users",
+					"timestamp":      "Slack timestamp, unique within the channel",
+					"date":           "When the message was posted, in UTC",
+					"thread_ts":      "Timestamp of the thread starter; equal to timestamp for starters",
+					"reply_count":    "Replies to a thread starter",
+					"reaction_count": "Reactions across all emoji",
+					"filename":       "Export file the message came from",
+				},
+			},
+			"users": {
+				Description: "Everyone in the workspace export",
+				LabelColumn: "name",
+			},
+			"channels": {
+				Description: "Conversations listed in the export",
+				LabelColumn: "name",
+			},
+			"channel_members":      {Description: "Members of each conversation"},
+			"ingested_files":       {Hidden: true},
+			"summaries":            {Hidden: true},
+			"feedback":             {Description: "Search results marked helpful (1) or not helpful (-1)"},
+			"tags":                 {Description: "Tags on messages, or on whole threads when thread is set"},
+			"metadata":             {Hidden: true},
+			"messages_terms":       {Hidden: true},
+			"message_fingerprints": {Hidden: true},
+		},
+		Queries: datasetteQueries,
+	}
+}
+
+// datasetteQueries are offered on every exported database
+var datasetteQueries = map[string]DatasetteQuery{
+	"search_messages": {
+		Title:       "Search messages",
+		Description: "Full-text search using SQLite FTS syntax: words, \"phrases\", prefix*, OR, NOT",
+		SQL: `SELECT m.date, COALESCE(u.name, m.user_id) AS user, m.text, m.thread_ts, m.reply_count
+FROM messages_fts
+JOIN messages m ON m.id = messages_fts.rowid
+LEFT JOIN users u ON u.id = m.user_id
+WHERE messages_fts MATCH :query
+ORDER BY m.date DESC
+LIMIT 200`,
+	},
+	"thread": {
+		Title:       "Thread",
+		Description: "Every message in a thread, given the thread starter's timestamp",
+		SQL: `SELECT m.date, COALESCE(u.name, m.user_id) AS user, m.text
+FROM messages m
+LEFT JOIN users u ON u.id = m.user_id
+WHERE m.thread_ts = :thread_ts OR m.timestamp = :thread_ts
+ORDER BY m.ts_micros`,
+	},
+	"user_messages": {
+		Title:       "Messages by user",
+		Description: "Messages posted by a user name",
+		SQL: `SELECT m.date, m.text, m.thread_ts
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE u.name = :user
+ORDER BY m.date DESC
+LIMIT 500`,
+	},
+	"top_posters": {
+		Title: "Top posters",
+		SQL: `SELECT COALESCE(u.name, m.user_id) AS user, COUNT(*) AS messages,
+  SUM(m.thread_ts = m.timestamp) AS threads_started
+FROM messages m
+LEFT JOIN users u ON u.id = m.user_id
+GROUP BY m.user_id
+ORDER BY messages DESC
+LIMIT 50`,
+	},
+	"monthly_activity": {
+		Title: "Monthly activity",
+		SQL: `SELECT strftime('%Y-%m', date) AS month, COUNT(*) AS messages,
+  COUNT(DISTINCT user_id) AS people
+FROM messages
+GROUP BY month
+ORDER BY month`,
+	},
+	"busiest_threads": {
+		Title: "Busiest threads",
+		SQL: `SELECT m.date, COALESCE(u.name, m.user_id) AS user, m.reply_count, m.text, m.timestamp AS thread_ts
+FROM messages m
+LEFT JOIN users u ON u.id = m.user_id
+WHERE m.thread_ts = m.timestamp
+ORDER BY m.reply_count DESC
+LIMIT 50`,
+	},
+	"shared_links": {
+		Title: "Messages sharing links",
+		SQL: `SELECT m.date, COALESCE(u.name, m.user_id) AS user, m.text
+FROM messages m
+LEFT JOIN users u ON u.id = m.user_id
+WHERE m.has_link
+ORDER BY m.date DESC
+LIMIT 500`,
+	},
+}
+
+// WriteDatasetteMetadata adds the exporter's database to the Datasette
+// metadata.json file at path. Everything else already in the file, such as
+// other databases' entries or a license, is kept, so one file can describe
+// a whole directory of channel databases.
+func (e *Exporter) WriteDatasetteMetadata(ctx context.Context, path string) error {
+	metadata := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return fmt.Errorf("failed to parse existing %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	databases := make(map[string]json.RawMessage)
+	if raw, ok := metadata["databases"]; ok {
+		if err := json.Unmarshal(raw, &databases); err != nil {
+			return fmt.Errorf("failed to parse databases in %s: %w", path, err)
+		}
+	}
+	if _, ok := metadata["title"]; !ok {
+		metadata["title"], _ = json.Marshal("Slack archives")
+	}
+
+	database := e.DatasetteDatabase(ctx)
+	if databases[DatasetteName(e.channelName)], err = json.Marshal(database); err != nil {
+		return err
+	}
+	if metadata["databases"], err = json.Marshal(databases); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+)
+
+type Exporter struct {
+	db          *database.DB
+	channelName string
+}
+
+// NewExporter creates a new exporter for a specific database
+func NewExporter(channelName string) (*Exporter, error) {
+	db, err := database.NewDB(channelName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Exporter{db: db, channelName: channelName}, nil
+}
+
+// Close closes the exporter and database connection
+func (e *Exporter) Close() error {
+	return e.db.Close()
+}
@@ -0,0 +1,204 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// RAGOptions controls how messages are chunked for RAG export
+type RAGOptions struct {
+	// ChunkSize is the maximum number of words in each chunk
+	ChunkSize int
+	// ChunkOverlap is the number of words repeated between consecutive chunks
+	ChunkOverlap int
+	// WorkspaceURL is used to build permalinks, e.g. https://kubernetes.slack.com
+	WorkspaceURL string
+}
+
+// RAGChunk is a single JSONL record in a RAG export
+type RAGChunk struct {
+	ID         string    `json:"id"`
+	Channel    string    `json:"channel"`
+	ChannelID  string    `json:"channel_id"`
+	ThreadTS   string    `json:"thread_ts"`
+	Chunk      int       `json:"chunk"`
+	Chunks     int       `json:"chunks"`
+	Users      []string  `json:"users"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Permalink  string    `json:"permalink,omitempty"`
+	Text       string    `json:"text"`
+	MessageIDs []int     `json:"message_ids"`
+}
+
+// ExportRAG writes thread-aggregated, chunked documents as JSONL. Each
+// thread (or standalone message) becomes one document, split into chunks
+// of at most ChunkSize words with ChunkOverlap words of overlap.
+func (e *Exporter) ExportRAG(ctx context.Context, w io.Writer, opts RAGOptions) (int, error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 300
+	}
+	if opts.ChunkOverlap < 0 || opts.ChunkOverlap >= opts.ChunkSize {
+		return 0, fmt.Errorf("chunk overlap must be between 0 and chunk size (%d)", opts.ChunkSize)
+	}
+
+	channelID := ""
+	_, channel := storagepaths.SplitName(e.channelName)
+	if info, err := e.db.GetChannelInfo(ctx, channel); err == nil {
+		channelID = info.ID
+	}
+
+	encoder := json.NewEncoder(w)
+	written := 0
+
+	var thread []*models.Message
+	flush := func() error {
+		if len(thread) == 0 {
+			return nil
+		}
+		chunks := buildRAGChunks(channel, channelID, thread, opts)
+		for _, chunk := range chunks {
+			if err := encoder.Encode(chunk); err != nil {
+				return fmt.Errorf("failed to write chunk: %w", err)
+			}
+		}
+		written += len(chunks)
+		thread = nil
+		return nil
+	}
+
+	err := e.db.ForEachMessage(ctx, func(message *models.Message) error {
+		if len(thread) > 0 && threadKey(thread[0]) != threadKey(message) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		thread = append(thread, message)
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	return written, flush()
+}
+
+// threadKey identifies the document a message belongs to
+func threadKey(message *models.Message) string {
+	if message.ThreadTS != "" {
+		return message.ThreadTS
+	}
+	return message.Timestamp
+}
+
+// buildRAGChunks turns a thread's messages into one or more chunks
+func buildRAGChunks(channel, channelID string, messages []*models.Message, opts RAGOptions) []RAGChunk {
+	first := messages[0]
+	last := messages[len(messages)-1]
+	key := threadKey(first)
+
+	seen := make(map[string]bool)
+	var users []string
+	var ids []int
+	var lines []string
+	for _, message := range messages {
+		author := message.UserName
+		if author == "" {
+			author = message.UserID
+		}
+		if !seen[author] {
+			seen[author] = true
+			users = append(users, author)
+		}
+		ids = append(ids, message.ID)
+		lines = append(lines, fmt.Sprintf("%s: %s", author, strings.Join(strings.Fields(message.Text), " ")))
+	}
+	sort.Strings(users)
+
+	permalink := slacktext.Permalink(opts.WorkspaceURL, channelID, key, "")
+	header := fmt.Sprintf("Channel: #%s\nUsers: %s\nDates: %s to %s\n",
+		channel, strings.Join(users, ", "),
+		first.Date.Format("2006-01-02 15:04"), last.Date.Format("2006-01-02 15:04"))
+	if permalink != "" {
+		header += fmt.Sprintf("Permalink: %s\n", permalink)
+	}
+
+	bodies := chunkWords(strings.Join(lines, "\n"), opts.ChunkSize, opts.ChunkOverlap)
+
+	chunks := make([]RAGChunk, len(bodies))
+	for i, body := range bodies {
+		chunks[i] = RAGChunk{
+			ID:         fmt.Sprintf("%s-%s-%d", channel, key, i),
+			Channel:    channel,
+			ChannelID:  channelID,
+			ThreadTS:   key,
+			Chunk:      i,
+			Chunks:     len(bodies),
+			Users:      users,
+			Start:      first.Date,
+			End:        last.Date,
+			Permalink:  permalink,
+			Text:       header + "\n" + body,
+			MessageIDs: ids,
+		}
+	}
+
+	return chunks
+}
+
+// chunkWords splits text into windows of at most size words, each starting
+// size-overlap words after the previous one. Line breaks are preserved.
+func chunkWords(text string, size, overlap int) []string {
+	type word struct {
+		text      string
+		lineStart bool
+	}
+
+	var words []word
+	for _, line := range strings.Split(text, "\n") {
+		for i, field := range strings.Fields(line) {
+			words = append(words, word{text: field, lineStart: i == 0})
+		}
+	}
+
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	var chunks []string
+	step := size - overlap
+	for start := 0; start < len(words); start += step {
+		end := start + size
+		if end > len(words) {
+			end = len(words)
+		}
+
+		var chunk strings.Builder
+		for i, w := range words[start:end] {
+			if i > 0 {
+				if w.lineStart {
+					chunk.WriteString("\n")
+				} else {
+					chunk.WriteString(" ")
+				}
+			}
+			chunk.WriteString(w.text)
+		}
+		chunks = append(chunks, chunk.String())
+
+		if end == len(words) {
+			break
+		}
+	}
+
+	return chunks
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// Tagged message export formats
+const (
+	TaggedFormatMarkdown = "markdown"
+	TaggedFormatJSON     = "json"
+	TaggedFormatCSV      = "csv"
+)
+
+// TaggedFormats lists the supported tagged message export formats
+var TaggedFormats = []string{TaggedFormatMarkdown, TaggedFormatJSON, TaggedFormatCSV}
+
+// TaggedMessage is a message carrying a tag, directly or through its thread
+type TaggedMessage struct {
+	Channel   string    `json:"channel"`
+	Timestamp string    `json:"ts"`
+	ThreadTS  string    `json:"thread_ts,omitempty"`
+	UserName  string    `json:"user_name"`
+	Date      time.Time `json:"date"`
+	Permalink string    `json:"permalink,omitempty"`
+	Text      string    `json:"text"`
+}
+
+// ExportTagged writes every message carrying tag, oldest first, and
+// returns the number written. Messages in a tagged thread are included.
+func (e *Exporter) ExportTagged(ctx context.Context, w io.Writer, tag, format, workspaceURL string) (int, error) {
+	if format != TaggedFormatMarkdown && format != TaggedFormatJSON && format != TaggedFormatCSV {
+		return 0, fmt.Errorf("unsupported format: %s (supported: %s)", format, strings.Join(TaggedFormats, ", "))
+	}
+
+	_, channel := storagepaths.SplitName(e.channelName)
+	channelID := ""
+	if info, err := e.db.GetChannelInfo(ctx, channel); err == nil {
+		channelID = info.ID
+	}
+
+	tagged, err := e.db.GetTaggedMessages(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+
+	messages := make([]TaggedMessage, 0, len(tagged))
+	for _, message := range tagged {
+		userName := message.UserName
+		if userName == "" {
+			userName = message.UserID
+		}
+		messages = append(messages, TaggedMessage{
+			Channel:   channel,
+			Timestamp: message.Timestamp,
+			ThreadTS:  message.ThreadTS,
+			UserName:  userName,
+			Date:      message.Date,
+			Permalink: slacktext.Permalink(workspaceURL, channelID, message.Timestamp, message.ThreadTS),
+			Text:      message.Text,
+		})
+	}
+
+	switch format {
+	case TaggedFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(messages)
+	case TaggedFormatCSV:
+		err = writeTaggedCSV(w, messages)
+	default:
+		err = writeTaggedMarkdown(w, channel, tag, messages)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(messages), nil
+}
+
+func writeTaggedCSV(w io.Writer, messages []TaggedMessage) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"channel", "ts", "thread_ts", "user_name", "date", "permalink", "text"}); err != nil {
+		return err
+	}
+	for _, message := range messages {
+		record := []string{message.Channel, message.Timestamp, message.ThreadTS, message.UserName,
+			message.Date.UTC().Format(time.RFC3339), message.Permalink, message.Text}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeTaggedMarkdown(w io.Writer, channel, tag string, messages []TaggedMessage) error {
+	if _, err := fmt.Fprintf(w, "# #%s messages tagged %s\n\n%d message(s)\n", channel, tag, len(messages)); err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		heading := fmt.Sprintf("%s - %s", message.UserName, message.Date.Local().Format("2006-01-02 15:04:05"))
+		if message.Permalink != "" {
+			heading = fmt.Sprintf("[%s](%s)", heading, message.Permalink)
+		}
+		if _, err := fmt.Fprintf(w, "\n## %s\n\n%s\n", heading, message.Text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
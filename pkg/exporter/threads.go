@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// Thread export formats
+const (
+	ThreadFormatMarkdown = "markdown"
+	ThreadFormatJSON     = "json"
+)
+
+// ThreadFormats lists the supported thread export formats
+var ThreadFormats = []string{ThreadFormatMarkdown, ThreadFormatJSON}
+
+// ThreadDocument is the JSON form of an exported thread
+type ThreadDocument struct {
+	Channel  string          `json:"channel"`
+	ThreadTS string          `json:"thread_ts"`
+	Messages []ThreadMessage `json:"messages"`
+}
+
+// ThreadMessage is a single message in an exported thread
+type ThreadMessage struct {
+	Timestamp string    `json:"ts"`
+	UserID    string    `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	RealName  string    `json:"user_real_name,omitempty"`
+	Date      time.Time `json:"date"`
+	Text      string    `json:"text"`
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// ExportThreads writes the complete thread of each message that belongs to
+// one into dir, one file per thread named by date, starter and timestamp.
+// Messages outside threads are skipped, and each thread is written once
+// however many of its messages are given. It returns the paths written.
+func (e *Exporter) ExportThreads(ctx context.Context, dir string, messageIDs []int, format string) ([]string, error) {
+	if format != ThreadFormatMarkdown && format != ThreadFormatJSON {
+		return nil, fmt.Errorf("unsupported thread format: %s (supported: %s)", format, strings.Join(ThreadFormats, ", "))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create thread export directory: %w", err)
+	}
+
+	_, channel := storagepaths.SplitName(e.channelName)
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, id := range messageIDs {
+		message, err := e.db.GetMessage(ctx, id)
+		if err != nil {
+			return paths, err
+		}
+		if message.ThreadTS == "" || seen[message.ThreadTS] {
+			continue
+		}
+		seen[message.ThreadTS] = true
+
+		messages, err := e.db.GetThreadMessages(ctx, message.ThreadTS)
+		if err != nil {
+			return paths, err
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		path := filepath.Join(dir, threadFilename(messages, format))
+		if err := writeThreadFile(path, channel, message.ThreadTS, messages, format); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// threadFilename names a thread file after its first message, e.g.
+// 2023-05-15-liggitt-1684141200.000100.md
+func threadFilename(messages []*models.Message, format string) string {
+	starter := messages[0]
+	user := starter.UserName
+	if user == "" {
+		user = starter.UserID
+	}
+	if user == "" {
+		user = "unknown"
+	}
+
+	ext := ".md"
+	if format == ThreadFormatJSON {
+		ext = ".json"
+	}
+
+	name := fmt.Sprintf("%s-%s-%s", starter.Date.Format("2006-01-02"), user, starter.Timestamp)
+	return unsafeFilenameChars.ReplaceAllString(name, "_") + ext
+}
+
+func writeThreadFile(path, channel, threadTS string, messages []*models.Message, format string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create thread file: %w", err)
+	}
+	defer file.Close()
+
+	if format == ThreadFormatJSON {
+		err = writeThreadJSON(file, channel, threadTS, messages)
+	} else {
+		err = writeThreadMarkdown(file, channel, messages)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write thread file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func writeThreadMarkdown(w io.Writer, channel string, messages []*models.Message) error {
+	starter := messages[0]
+	if _, err := fmt.Fprintf(w, "# #%s thread, %s\n\n", channel, starter.Date.Format("2006-01-02 15:04")); err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		author := message.UserName
+		if message.UserRealName != "" {
+			author = fmt.Sprintf("%s (%s)", message.UserRealName, message.UserName)
+		}
+		if author == "" {
+			author = message.UserID
+		}
+
+		_, err := fmt.Fprintf(w, "**%s** - %s\n\n%s\n\n", author, message.Date.Format("2006-01-02 15:04:05"), message.Text)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeThreadJSON(w io.Writer, channel, threadTS string, messages []*models.Message) error {
+	document := ThreadDocument{Channel: channel, ThreadTS: threadTS}
+	for _, message := range messages {
+		document.Messages = append(document.Messages, ThreadMessage{
+			Timestamp: message.Timestamp,
+			UserID:    message.UserID,
+			UserName:  message.UserName,
+			RealName:  message.UserRealName,
+			Date:      message.Date,
+			Text:      message.Text,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,132 @@
+// Package extract pulls the text out of files shared in messages, plain
+// text files and PDFs, so their contents can be searched with the messages
+// that shared them
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// MaxFileSize is the largest file whose text is extracted
+const MaxFileSize = 20 << 20
+
+// MaxTextLength is the most text kept from one file, in bytes; the rest is
+// left out of the search index
+const MaxTextLength = 1 << 20
+
+// ErrUnsupported is returned for files that are neither text nor PDF
+var ErrUnsupported = errors.New("unsupported file type")
+
+// textExtensions are the extensions of text files shared with a generic or
+// missing MIME type, such as logs and manifests
+var textExtensions = map[string]bool{
+	".txt": true, ".log": true, ".md": true, ".csv": true, ".tsv": true,
+	".json": true, ".yaml": true, ".yml": true, ".xml": true, ".toml": true,
+	".ini": true, ".conf": true, ".sh": true, ".go": true, ".py": true,
+	".diff": true, ".patch": true,
+}
+
+// Supported reports whether the text of a file with the given name and
+// MIME type can be extracted
+func Supported(name, mimetype string) bool {
+	return isPDF(name, mimetype) || isText(name, mimetype)
+}
+
+func isPDF(name, mimetype string) bool {
+	return mimetype == "application/pdf" || strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+func isText(name, mimetype string) bool {
+	return strings.HasPrefix(mimetype, "text/") || textExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// Text returns the text of the file at path, shared under the given name
+// and MIME type. Whitespace runs are collapsed, and the text is cut to
+// MaxTextLength.
+func Text(path, name, mimetype string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if info.Size() > MaxFileSize {
+		return "", fmt.Errorf("%s is larger than %d MB", name, MaxFileSize>>20)
+	}
+
+	var text string
+	switch {
+	case isPDF(name, mimetype):
+		text, err = pdfText(path, info.Size())
+	case isText(name, mimetype):
+		text, err = plainText(path)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from %s: %w", name, err)
+	}
+	return truncate(strings.Join(strings.Fields(text), " ")), nil
+}
+
+// plainText reads a text file, refusing binary files shared with a text
+// name
+func plainText(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", fmt.Errorf("not a UTF-8 text file")
+	}
+	return string(data), nil
+}
+
+// pdfText reads the text of every page of a PDF
+func pdfText(path string, size int64) (text string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// The PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(file, size)
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(plain, MaxTextLength*2))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(data), ""), nil
+}
+
+// truncate cuts text to MaxTextLength bytes without splitting a character
+func truncate(text string) string {
+	if len(text) <= MaxTextLength {
+		return text
+	}
+	cut := MaxTextLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
@@ -0,0 +1,189 @@
+// Package fingerprint reduces error messages to fingerprints that stay the
+// same when the details vary, so a pasted error can be matched against
+// earlier discussions of it.
+//
+// Error lines are normalized by dropping klog headers and replacing
+// timestamps, UUIDs, hex IDs, IP addresses, pod name hashes and numbers with
+// placeholders. Go-style error chains such as "failed to sync: context
+// deadline exceeded" are also fingerprinted from each ": " onwards, and
+// lines from their first error word onwards, so a message quoting only the
+// end of an error, or prefixing it with chat, still matches.
+package fingerprint
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+const (
+	// minWords is the fewest words a normalized error, or the end of an
+	// error chain, needs to be fingerprinted; shorter ones such as
+	// "permission denied" match too many unrelated errors
+	minWords = 3
+	// maxFingerprints bounds the fingerprints kept for one message, so a
+	// pasted log doesn't flood the table
+	maxFingerprints = 50
+)
+
+// errorWords are the words and phrases that mark a line as reporting an
+// error, in lower case
+var errorWords = []string{"errors", "error", "err", "failed", "failure", "fatal", "panic", "exception",
+	"unable", "cannot", "can't", "couldn't", "denied", "forbidden", "unauthorized", "refused",
+	"timed out", "timeout", "deadline exceeded", "invalid", "not found", "no such",
+	"crashloopbackoff", "imagepullbackoff", "errimagepull", "oomkilled", "back-off", "x509"}
+
+var (
+	// errorPattern picks out the lines of a message that report an error
+	errorPattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(errorWords, "|") + `)\b`)
+
+	// klog prefixes lines with severity, date, time, thread and source,
+	// e.g. E0515 10:23:45.123456   12345 reflector.go:138]
+	klogPattern = regexp.MustCompile(`^[IWEF]\d{4} \d{2}:\d{2}:\d{2}\.\d+\s+\d+\s+\S+:\d+\]\s*`)
+
+	// Replacements run in order, most specific first, on lowercased text
+	replacements = []struct {
+		pattern     *regexp.Regexp
+		placeholder string
+	}{
+		{regexp.MustCompile(`\d{4}-\d{2}-\d{2}(?:[t ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:z|[+-]\d{2}:?\d{2})?)?`), "<time>"},
+		{regexp.MustCompile(`\b\d{1,2}:\d{2}:\d{2}(?:\.\d+)?\b`), "<time>"},
+		{regexp.MustCompile(`\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+		{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<ip>"},
+		// Deployment pods: name-<replicaset hash>-<5 characters>
+		{regexp.MustCompile(`\b([a-z0-9]+(?:-[a-z0-9]+)*?)-[0-9a-f]{8,10}-[a-z0-9]{5}\b`), "$1-<pod>"},
+		{regexp.MustCompile(`\b(?:0x)?[0-9a-f]{8,}\b`), "<hex>"},
+		{regexp.MustCompile(`\b\d+(?:\.\d+)*\b`), "<n>"},
+	}
+
+	// StatefulSet and DaemonSet pods end in five characters from
+	// Kubernetes' random name alphabet, which has no vowels
+	podSuffixPattern = regexp.MustCompile(`\b([a-z0-9]+(?:-[a-z0-9]+)*)-([bcdfghjklmnpqrstvwxz2456789]{5})\b`)
+	digitPattern     = regexp.MustCompile(`\d`)
+	spacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// Normalize returns the form of an error line that fingerprints are taken
+// from: lowercased, without a klog header, with variable details replaced
+// by placeholders and runs of whitespace collapsed
+func Normalize(line string) string {
+	line = klogPattern.ReplaceAllString(strings.TrimSpace(line), "")
+	line = strings.ToLower(line)
+	for _, r := range replacements {
+		line = r.pattern.ReplaceAllString(line, r.placeholder)
+	}
+
+	// Only treat a five character suffix as random when it has a digit,
+	// so words such as kube-proxy are kept
+	line = podSuffixPattern.ReplaceAllStringFunc(line, func(name string) string {
+		match := podSuffixPattern.FindStringSubmatch(name)
+		if !digitPattern.MatchString(match[2]) {
+			return name
+		}
+		return match[1] + "-<pod>"
+	})
+
+	return strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+}
+
+// ErrorLines returns the normalized form of each line of text that reports
+// an error, without duplicates
+func ErrorLines(text string) []string {
+	return lines(text, true)
+}
+
+// Fingerprints returns the fingerprints of the errors in text, without
+// duplicates, for storing at ingest
+func Fingerprints(text string) []string {
+	return fingerprints(ErrorLines(text))
+}
+
+// Query returns the fingerprints to search for given pasted error output.
+// When no line looks like an error, every line is used, since the user has
+// said the text is an error.
+func Query(text string) []string {
+	if found := Fingerprints(text); len(found) > 0 {
+		return found
+	}
+	return fingerprints(lines(text, false))
+}
+
+// lines returns the normalized lines of text, only those reporting an
+// error when errorsOnly is set
+func lines(text string, errorsOnly bool) []string {
+	// Code block fences and inline code markers are not part of the error
+	text = strings.ReplaceAll(text, "`", "\n")
+
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, line := range strings.Split(text, "\n") {
+		if errorsOnly && !reportsError(line) {
+			continue
+		}
+		line = Normalize(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		normalized = append(normalized, line)
+	}
+	return normalized
+}
+
+// reportsError reports whether line has an error word. The words are
+// looked for as plain text first, since matching errorPattern against
+// every line of chat at ingest is slow and most lines have none of them.
+func reportsError(line string) bool {
+	lower := strings.ToLower(line)
+	for _, word := range errorWords {
+		if strings.Contains(lower, word) {
+			return errorPattern.MatchString(line)
+		}
+	}
+	return false
+}
+
+// fingerprints hashes each line, each end of an error chain within it and
+// the line from its first error word on, where they have enough words to be
+// distinctive
+func fingerprints(lines []string) []string {
+	seen := make(map[string]bool)
+	var found []string
+	for _, line := range lines {
+		parts := chain(line)
+		if loc := errorPattern.FindStringIndex(line); loc != nil && loc[0] > 0 {
+			parts = append(parts, chain(line[loc[0]:])...)
+		}
+		for _, part := range parts {
+			if len(strings.Fields(part)) < minWords {
+				continue
+			}
+			sum := sha1.Sum([]byte(part))
+			fingerprint := hex.EncodeToString(sum[:8])
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			found = append(found, fingerprint)
+			if len(found) == maxFingerprints {
+				return found
+			}
+		}
+	}
+	return found
+}
+
+// chain returns an error line followed by what follows each ": " in it,
+// e.g. "a: b: c", "b: c" and "c"
+func chain(line string) []string {
+	parts := []string{line}
+	for rest := line; ; {
+		i := strings.Index(rest, ": ")
+		if i < 0 {
+			return parts
+		}
+		rest = rest[i+2:]
+		parts = append(parts, rest)
+	}
+}
@@ -0,0 +1,144 @@
+package fingerprint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`E0515 10:23:45.123456   12345 reflector.go:138] failed to list pods: dial tcp 10.96.0.1:443: i/o timeout`,
+			"failed to list pods: dial tcp <ip>: i/o timeout"},
+		{"Error from server: pod coredns-5d78c9869d-x7k2p not found at 2024-05-15T10:23:45Z",
+			"error from server: pod coredns-<pod> not found at <time>"},
+		{"error: uid 3f2504e0-4f89-11d3-9a0c-0305e82c3301 object 0xdeadbeef12 failed",
+			"error: uid <uuid> object <hex> failed"},
+		{"retry 3 of 5 after 10:23:45", "retry <n> of <n> after <time>"},
+		// Five character suffixes are only pod hashes when they have a digit
+		{"kube-proxy-x8z2q and web-abcde stuck", "kube-proxy-<pod> and web-abcde stuck"},
+		{"  Too   many\tspaces  ", "too many spaces"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Normalize(tt.line); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorLines(t *testing.T) {
+	text := "hi all, seeing this on upgrade\n" +
+		"Error: failed to pull image\n" +
+		"```ERROR: failed to pull image```\n" +
+		"the terrorists are winning\n" +
+		"thanks"
+	want := []string{"error: failed to pull image"}
+
+	if got := ErrorLines(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("ErrorLines = %q, want %q", got, want)
+	}
+}
+
+func TestReportsError(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"Failed to pull image", true},
+		{"x509: certificate signed by unknown authority", true},
+		{"Back-off restarting failed container", true},
+		{"pod is in CrashLoopBackOff", true},
+		{"the request TIMED OUT", true},
+		// Error words within other words don't count
+		{"the terrorists are winning", false},
+		{"an errand to run", false},
+		{"looks good to me", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := reportsError(tt.line); got != tt.want {
+				t.Errorf("reportsError(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+			if got := errorPattern.MatchString(tt.line); got != tt.want {
+				t.Errorf("errorPattern matches %q = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		matches bool
+	}{
+		{"details vary",
+			`E0515 10:23:45.123456 1 reflector.go:138] failed to list pods: dial tcp 10.96.0.1:443: i/o timeout`,
+			`E0601 08:00:01.000001 7 reflector.go:138] failed to list pods: dial tcp 10.0.0.12:6443: i/o timeout`,
+			true},
+		{"pod names vary",
+			"Back-off pulling image for pod coredns-5d78c9869d-x7k2p",
+			"Back-off pulling image for pod coredns-7f9c8d6b54-q2w9z",
+			true},
+		{"end of an error chain",
+			"failed to sync pod: context deadline exceeded while waiting",
+			"context deadline exceeded while waiting",
+			true},
+		{"chat before the error",
+			"anyone seen this? failed to create pod sandbox after upgrade",
+			"failed to create pod sandbox after upgrade",
+			true},
+		{"different errors",
+			"failed to pull image: not found",
+			"failed to mount volume: permission denied",
+			false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Fingerprints(tt.a), Fingerprints(tt.b)
+			if len(a) == 0 || len(b) == 0 {
+				t.Fatalf("got %d and %d fingerprints, want some for each", len(a), len(b))
+			}
+			if got := shareAny(a, b); got != tt.matches {
+				t.Errorf("fingerprints shared = %v, want %v", got, tt.matches)
+			}
+		})
+	}
+}
+
+func TestFingerprintsSkipsShortErrors(t *testing.T) {
+	for _, text := range []string{"permission denied", "Error: Forbidden", "lgtm, thanks!"} {
+		if got := Fingerprints(text); len(got) != 0 {
+			t.Errorf("Fingerprints(%q) = %q, want none", text, got)
+		}
+	}
+}
+
+func TestQueryWithoutErrorWords(t *testing.T) {
+	text := "the node went away during the rollout"
+	if Fingerprints(text) != nil {
+		t.Fatalf("Fingerprints(%q) found an error line", text)
+	}
+	if got := Query(text); len(got) != 1 {
+		t.Errorf("Query(%q) = %q, want one fingerprint of the whole line", text, got)
+	}
+}
+
+func shareAny(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
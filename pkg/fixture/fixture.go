@@ -0,0 +1,294 @@
+// Package fixture generates synthetic Slack exports of a chosen size, for
+// benchmarking ingest and search and testing large-scale behavior without
+// real, possibly sensitive, archives
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// recentThreads is the number of the latest threads of a day a reply can
+// join, so replies cluster around recent conversations as in real channels
+const recentThreads = 5
+
+// Options sets the size and shape of a generated export
+type Options struct {
+	// Channel is the channel's name and the directory of its day files
+	Channel string
+	Users   int
+	Days    int
+	// Messages is the total number of messages, spread evenly over the
+	// days
+	Messages int
+	// ThreadRatio is the share of messages that are thread replies, from 0
+	// up to but not including 1
+	ThreadRatio float64
+	// Start is the first day of the export, in UTC
+	Start time.Time
+	// Seed seeds the generator; the same options always give the same
+	// export
+	Seed int64
+}
+
+// Summary counts what Generate wrote
+type Summary struct {
+	Users    int
+	Days     int
+	Messages int
+	Threads  int
+	Replies  int
+}
+
+// message is a message as Slack exports it, leaving out empty fields
+type message struct {
+	Type         string     `json:"type"`
+	User         string     `json:"user"`
+	Text         string     `json:"text"`
+	TS           string     `json:"ts"`
+	ThreadTS     string     `json:"thread_ts,omitempty"`
+	ReplyCount   int        `json:"reply_count,omitempty"`
+	ParentUserID string     `json:"parent_user_id,omitempty"`
+	Reactions    []reaction `json:"reactions,omitempty"`
+}
+
+type reaction struct {
+	Name  string   `json:"name"`
+	Users []string `json:"users"`
+	Count int      `json:"count"`
+}
+
+// Validate checks options before anything is written
+func (o Options) Validate() error {
+	switch {
+	case o.Channel == "" || strings.ContainsAny(o.Channel, `/\`) || o.Channel == "." || o.Channel == "..":
+		return fmt.Errorf("invalid channel name: %q", o.Channel)
+	case o.Users < 1:
+		return fmt.Errorf("users must be at least 1")
+	case o.Days < 1:
+		return fmt.Errorf("days must be at least 1")
+	case o.Messages < 0:
+		return fmt.Errorf("messages can't be negative")
+	case o.Messages > o.Days*1000000:
+		return fmt.Errorf("at most 1000000 messages a day can be generated")
+	case o.ThreadRatio < 0 || o.ThreadRatio >= 1:
+		return fmt.Errorf("thread ratio must be at least 0 and less than 1")
+	}
+	return nil
+}
+
+// Generate writes an export to dir: users.json, channels.json and a file
+// of messages for each day in the channel's directory. It refuses to write
+// over an existing export.
+func Generate(dir string, opts Options) (*Summary, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"users.json", "channels.json", opts.Channel} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return nil, fmt.Errorf("%s already contains %s; choose an empty output directory", dir, name)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, opts.Channel), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	start := time.Date(opts.Start.Year(), opts.Start.Month(), opts.Start.Day(), 0, 0, 0, 0, time.UTC)
+
+	users := generateUsers(rng, opts.Users)
+	if err := writeJSON(filepath.Join(dir, "users.json"), users); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	channel := models.ChannelJSON{
+		ID:      "C0GEN00001",
+		Name:    opts.Channel,
+		Created: start.Unix(),
+		Creator: ids[0],
+		Topic:   models.ChannelTopic{Value: "Synthetic channel generated by gen-fixture", Creator: ids[0], LastSet: start.Unix()},
+		Purpose: models.ChannelTopic{Value: "Benchmarking and large-scale testing", Creator: ids[0], LastSet: start.Unix()},
+		Members: ids,
+	}
+	if err := writeJSON(filepath.Join(dir, "channels.json"), []models.ChannelJSON{channel}); err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{Users: len(users), Days: opts.Days}
+	for day := 0; day < opts.Days; day++ {
+		count := opts.Messages / opts.Days
+		if day < opts.Messages%opts.Days {
+			count++
+		}
+		date := start.AddDate(0, 0, day)
+		messages := generateDay(rng, date, count, ids, opts.ThreadRatio, summary)
+		path := filepath.Join(dir, opts.Channel, date.Format("2006-01-02")+".json")
+		if err := writeJSON(path, messages); err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
+
+// generateUsers returns count users with unique names built from the name
+// lists
+func generateUsers(rng *rand.Rand, count int) []models.UserJSON {
+	users := make([]models.UserJSON, count)
+	for i := range users {
+		first := firstNames[rng.Intn(len(firstNames))]
+		last := lastNames[rng.Intn(len(lastNames))]
+		name := fmt.Sprintf("%s.%s%d", strings.ToLower(first), strings.ToLower(last), i+1)
+		users[i] = models.UserJSON{
+			ID:   fmt.Sprintf("U0GEN%05d", i+1),
+			Name: name,
+			Profile: models.Profile{
+				RealName:    first + " " + last,
+				DisplayName: name,
+			},
+		}
+	}
+	return users
+}
+
+// generateDay returns count messages spread over a day. Timestamps are
+// unique and ascending, so a reply always follows its thread's parent.
+func generateDay(rng *rand.Rand, date time.Time, count int, users []string, threadRatio float64, summary *Summary) []*message {
+	messages := make([]*message, 0, count)
+	var threads []*message
+	for i := 0; i < count; i++ {
+		seconds := date.Unix() + int64(i)*86400/int64(count)
+		msg := &message{
+			Type: "message",
+			User: users[rng.Intn(len(users))],
+			Text: generateText(rng, users),
+			TS:   fmt.Sprintf("%d.%06d", seconds, i%1000000),
+		}
+
+		if len(threads) > 0 && rng.Float64() < threadRatio {
+			recent := threads
+			if len(recent) > recentThreads {
+				recent = recent[len(recent)-recentThreads:]
+			}
+			parent := recent[rng.Intn(len(recent))]
+			parent.ThreadTS = parent.TS
+			if parent.ReplyCount == 0 {
+				summary.Threads++
+			}
+			parent.ReplyCount++
+			msg.ThreadTS = parent.TS
+			msg.ParentUserID = parent.User
+			summary.Replies++
+		} else {
+			threads = append(threads, msg)
+		}
+
+		if rng.Intn(10) == 0 {
+			msg.Reactions = generateReactions(rng, users)
+		}
+		messages = append(messages, msg)
+		summary.Messages++
+	}
+	return messages
+}
+
+// generateText returns a Kubernetes-flavored message, sometimes with code,
+// a link or a mention
+func generateText(rng *rand.Rand, users []string) string {
+	text := templates[rng.Intn(len(templates))]
+	text = strings.ReplaceAll(text, "{component}", components[rng.Intn(len(components))])
+	text = strings.ReplaceAll(text, "{resource}", resources[rng.Intn(len(resources))])
+	text = strings.ReplaceAll(text, "{version}", fmt.Sprintf("1.%d", 24+rng.Intn(8)))
+
+	switch rng.Intn(12) {
+	case 0:
+		text += fmt.Sprintf(" `kubectl get %s -n kube-system`", resources[rng.Intn(len(resources))])
+	case 1:
+		text += fmt.Sprintf(" <https://github.com/kubernetes/kubernetes/pull/%d>", 100000+rng.Intn(30000))
+	case 2:
+		text = fmt.Sprintf("<@%s> %s", users[rng.Intn(len(users))], text)
+	}
+	return text
+}
+
+func generateReactions(rng *rand.Rand, users []string) []reaction {
+	count := 1 + rng.Intn(3)
+	if count > len(users) {
+		count = len(users)
+	}
+	reacted := make([]string, 0, count)
+	for _, i := range rng.Perm(len(users))[:count] {
+		reacted = append(reacted, users[i])
+	}
+	return []reaction{{Name: emoji[rng.Intn(len(emoji))], Users: reacted, Count: count}}
+}
+
+// writeJSON writes v as indented JSON, leaving <, > and & unescaped as
+// Slack does
+func writeJSON(path string, v interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+var firstNames = []string{
+	"Alex", "Blake", "Casey", "Dana", "Eli", "Frankie", "Gray", "Harper",
+	"Indira", "Jun", "Kai", "Lee", "Morgan", "Noor", "Oli", "Priya",
+	"Quinn", "Ravi", "Sam", "Taylor", "Uma", "Vic", "Wren", "Yuki",
+}
+
+var lastNames = []string{
+	"Abara", "Bergstrom", "Chen", "Diaz", "Eriksen", "Fischer", "Garcia",
+	"Haddad", "Ito", "Jensen", "Kowalski", "Lopez", "Mensah", "Novak",
+	"Okafor", "Patel", "Rossi", "Silva", "Tanaka", "Weber",
+}
+
+var components = []string{
+	"kubelet", "kube-apiserver", "kube-scheduler", "kube-controller-manager",
+	"kube-proxy", "etcd", "CoreDNS", "containerd", "the CSI driver",
+	"the CNI plugin", "the cloud controller manager", "kubeadm",
+}
+
+var resources = []string{
+	"pods", "deployments", "nodes", "services", "configmaps", "secrets",
+	"daemonsets", "statefulsets", "ingresses", "leases", "events",
+	"persistentvolumeclaims",
+}
+
+var emoji = []string{"+1", "eyes", "tada", "thinking_face", "white_check_mark", "pray"}
+
+var templates = []string{
+	"Has anyone seen {component} restart in a loop after upgrading to {version}?",
+	"{component} logs show a timeout when listing {resource}",
+	"We're seeing high memory use in {component} on large clusters",
+	"Is there a KEP for changing how {component} handles {resource}?",
+	"The e2e tests for {resource} are flaky again on {version}",
+	"PTAL at the fix for {component}, it only touches {resource}",
+	"Does {component} still need the feature gate in {version}?",
+	"Watching {resource} through {component} got much slower since {version}",
+	"Reminder: the SIG meeting is tomorrow, agenda doc is open for topics",
+	"Thanks, that fixed it. Restarting {component} cleared the stale {resource}",
+	"What's the recommended way to back up {resource} before upgrading to {version}?",
+	"RBAC denies {component} access to {resource} after the upgrade",
+	"Can someone help me debug why {resource} are stuck pending?",
+	"The release notes for {version} mention a change in {component}",
+	"I think this is a regression in {component}, filing an issue now",
+}
@@ -0,0 +1,180 @@
+// Package identifiers recognises Kubernetes-style identifiers such as
+// kube-apiserver, v1.28.2, CVE-2023-1234 and pod/nginx-abc123, so they can be
+// searched as whole tokens as well as by their parts.
+//
+// The full-text index splits text on punctuation, which makes the parts of
+// an identifier searchable but loses the identifier itself: a search for
+// kube-apiserver matches any message mentioning both kube and apiserver. At
+// ingest each identifier is also indexed in a normalized whole-token form,
+// and RewriteQuery turns identifiers in a query into matches on that form.
+//
+// KubernetesVersions picks out mentions of Kubernetes releases, which are
+// stored at ingest for filtering and reporting by version.
+package identifiers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxLength bounds the identifiers indexed, leaving out long paths such as
+// URLs that nobody searches for whole
+const maxLength = 100
+
+var (
+	// An identifier is a run of letters and digits joined by - . _ or /
+	identifierPattern = regexp.MustCompile(`[A-Za-z0-9]+(?:[-._/][A-Za-z0-9]+)+`)
+	wholePattern      = regexp.MustCompile(`^[A-Za-z0-9]+(?:[-._/][A-Za-z0-9]+)+$`)
+	separatorPattern  = regexp.MustCompile(`[-._/]`)
+)
+
+// Normalize returns the whole-token form of an identifier: lowercased, with
+// its separators removed, so the index tokenizer keeps it as one term
+func Normalize(identifier string) string {
+	return strings.ToLower(separatorPattern.ReplaceAllString(identifier, ""))
+}
+
+// Expand returns the whole-token forms of the identifiers in text, without
+// duplicates. Each part of a path such as pod/nginx-abc123 is included on
+// its own too, so nginx-abc123 finds it.
+func Expand(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+
+	add := func(identifier string) {
+		token := Normalize(identifier)
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, identifier := range identifierPattern.FindAllString(text, -1) {
+		if len(identifier) > maxLength {
+			continue
+		}
+		add(identifier)
+		if strings.Contains(identifier, "/") {
+			for _, part := range strings.Split(identifier, "/") {
+				if wholePattern.MatchString(part) {
+					add(part)
+				}
+			}
+		}
+	}
+
+	return tokens
+}
+
+// RewriteQuery rewrites each bare identifier in an FTS query into a match
+// on its whole-token form in column, together with the phrase of its parts
+// so the parts are highlighted in snippets. A trailing * keeps prefix
+// matching. Quoted phrases, column filters and operators are left as they
+// are.
+func RewriteQuery(query, column string) string {
+	var b strings.Builder
+	inQuote := false
+	start := -1
+
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		b.WriteString(rewriteTerm(query[start:end], column))
+		start = -1
+	}
+
+	for i, r := range query {
+		switch {
+		case r == '"':
+			flush(i)
+			inQuote = !inQuote
+			b.WriteRune(r)
+		case inQuote:
+			b.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n' || r == '(' || r == ')':
+			flush(i)
+			b.WriteRune(r)
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	flush(len(query))
+
+	return b.String()
+}
+
+// rewriteTerm rewrites a single query term if it is an identifier
+func rewriteTerm(term, column string) string {
+	identifier := strings.TrimSuffix(term, "*")
+	prefix := len(identifier) < len(term)
+	if !wholePattern.MatchString(identifier) || strings.HasPrefix(identifier, "NEAR/") {
+		return term
+	}
+
+	star := ""
+	if prefix {
+		star = "*"
+	}
+	parts := separatorPattern.Split(identifier, -1)
+	return "(" + column + ":" + Normalize(identifier) + star + ` "` + strings.Join(parts, " ") + star + `")`
+}
+
+var (
+	// Kubernetes versions are written v1.27 or v1.27.3, or without the v
+	// after the project's name, as in "Kubernetes 1.27" or "k8s 1.27.3"
+	versionPattern = regexp.MustCompile(`(?i)(?:\bv|\b(?:kubernetes|k8s|kube)[ -]?v?)1\.(\d{1,2})(?:\.\d+)?\b`)
+	minorPattern   = regexp.MustCompile(`^v?1\.(\d{1,2})(?:\.\d+)?$`)
+)
+
+// KubernetesVersions returns the Kubernetes minor versions, such as 1.27,
+// mentioned in text, without duplicates. Patch versions count towards
+// their minor version.
+func KubernetesVersions(text string) []string {
+	// Every version has a "1.", and most messages mention none
+	if !strings.Contains(text, "1.") {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var versions []string
+	for _, match := range versionPattern.FindAllStringSubmatch(text, -1) {
+		version := "1." + strings.TrimLeft(match[1], "0")
+		if version == "1." {
+			version = "1.0"
+		}
+		if !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// ParseVersion validates a Kubernetes version given on the command line,
+// such as 1.27, v1.27 or 1.27.3, and returns its minor version
+func ParseVersion(value string) (string, error) {
+	match := minorPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", fmt.Errorf("invalid Kubernetes version %q, expected a version such as 1.27", value)
+	}
+	minor := strings.TrimLeft(match[1], "0")
+	if minor == "" {
+		minor = "0"
+	}
+	return "1." + minor, nil
+}
+
+// CompareVersions orders minor versions numerically, so 1.9 sorts before
+// 1.10. It returns a negative number, zero or a positive number.
+func CompareVersions(a, b string) int {
+	minor := func(v string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(v, "1."))
+		return n
+	}
+	return minor(a) - minor(b)
+}
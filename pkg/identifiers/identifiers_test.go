@@ -0,0 +1,89 @@
+package identifiers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"kube-apiserver crashed on v1.28.2", []string{"kubeapiserver", "v1282"}},
+		{"CVE-2023-1234 and snake_case_name", []string{"cve20231234", "snakecasename"}},
+		// Paths are indexed whole and by each part that is an identifier
+		{"see pod/nginx-abc123", []string{"podnginxabc123", "nginxabc123"}},
+		{"kube-apiserver and Kube-APIServer", []string{"kubeapiserver"}},
+		// A trailing separator isn't part of the identifier
+		{"restart kube-proxy.", []string{"kubeproxy"}},
+		{"plain words only", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Expand(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expand(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandSkipsLongIdentifiers(t *testing.T) {
+	long := "https://github.com/kubernetes/kubernetes/blob/master/pkg/kubelet/" +
+		"certificate/bootstrap/bootstrap_test.go"
+	for _, token := range Expand(long) {
+		if len(token) > maxLength {
+			t.Errorf("Expand kept a %d character token", len(token))
+		}
+	}
+}
+
+func TestRewriteQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"kube-apiserver", `(identifiers:kubeapiserver "kube apiserver")`},
+		{"kube-api*", `(identifiers:kubeapi* "kube api*")`},
+		{"v1.28 upgrade", `(identifiers:v128 "v1 28") upgrade`},
+		{"(kube-proxy OR kubelet)", `((identifiers:kubeproxy "kube proxy") OR kubelet)`},
+		// Quoted phrases, column filters and operators are left alone
+		{`"kube-apiserver restart"`, `"kube-apiserver restart"`},
+		{"text:kube-apiserver", "text:kube-apiserver"},
+		{"kubelet NEAR/5 certificate", "kubelet NEAR/5 certificate"},
+		{"kubelet", "kubelet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := RewriteQuery(tt.query, "identifiers"); got != tt.want {
+				t.Errorf("RewriteQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubernetesVersions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"upgraded to v1.28.2 from Kubernetes 1.27", []string{"1.28", "1.27"}},
+		{"k8s 1.29 and kube-v1.30.0", []string{"1.29", "1.30"}},
+		{"v1.28 then v1.28.3", []string{"1.28"}},
+		{"on v1.05", []string{"1.5"}},
+		// A bare number isn't a version
+		{"version 1.28, 1.5 GB", nil},
+		{"no versions here", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := KubernetesVersions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KubernetesVersions(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/extract"
+)
+
+// ExtractFiles extracts the text of the shared text files and PDFs with a
+// copy on disk, from the export or the media store, and indexes it with
+// the messages that shared them. Files whose text was extracted before are
+// skipped. It returns the number of files extracted and the errors of those
+// that couldn't be read.
+func (idx *Indexer) ExtractFiles(ctx context.Context) (int, []error, error) {
+	files, err := idx.db.GetFilesToExtract(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	extracted := 0
+	var failures []error
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return extracted, failures, ErrInterrupted
+		}
+		if !extract.Supported(file.Name, file.Mimetype) {
+			continue
+		}
+
+		text, err := extract.Text(file.LocalPath, file.Name, file.Mimetype)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("message %s: %w", file.MessageTS, err))
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		if err := idx.db.StoreFileContent(ctx, file, text); err != nil {
+			return extracted, failures, err
+		}
+		extracted++
+	}
+	return extracted, failures, nil
+}
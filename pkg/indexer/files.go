@@ -0,0 +1,66 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// uploadsDir is where export tools such as slackdump save the files shared
+// in messages, each at __uploads/<file ID>/<name>
+const uploadsDir = "__uploads"
+
+// storeFiles records the files shared in a message, noting any copy of a
+// file saved alongside the export
+func (idx *Indexer) storeFiles(msg *models.SlackMessage) error {
+	shared := msg.Files
+	if msg.File != nil {
+		shared = append(shared, *msg.File)
+	}
+
+	var files []models.MessageFile
+	for _, file := range shared {
+		// Files deleted before the export are listed with only an ID
+		if file.Name == "" && file.URLPrivate == "" {
+			continue
+		}
+		thumbnail := file.Thumb480
+		if thumbnail == "" {
+			thumbnail = file.Thumb360
+		}
+		files = append(files, models.MessageFile{
+			FileID:    file.ID,
+			Name:      file.Name,
+			Title:     file.Title,
+			Mimetype:  file.Mimetype,
+			Size:      file.Size,
+			URL:       file.URLPrivate,
+			Thumbnail: thumbnail,
+			Permalink: file.Permalink,
+			Preview:   file.Preview,
+			LocalPath: idx.localFile(file),
+		})
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	return idx.db.InsertMessageFiles(msg.TS, files)
+}
+
+// localFile returns the absolute path of the copy of a shared file saved
+// with the export, or an empty string when there is none
+func (idx *Indexer) localFile(file models.SlackFile) string {
+	// IDs and names holding a path could point outside the uploads
+	if file.ID == "" || file.Name == "" || filepath.Base(file.ID) != file.ID || filepath.Base(file.Name) != file.Name {
+		return ""
+	}
+	path, err := filepath.Abs(filepath.Join(idx.sourceDir, uploadsDir, file.ID, file.Name))
+	if err != nil {
+		return ""
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,195 @@
+package indexer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Export formats the indexer can read
+const (
+	FormatSlack  = "slack"
+	FormatZulip  = "zulip"
+	FormatMatrix = "matrix"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Formats lists the supported export formats
+var Formats = []string{FormatSlack, FormatZulip, FormatMatrix, FormatNDJSON, FormatCSV}
+
+// importer parses one export file from a chat system other than Slack.
+// Unlike a Slack export, each file carries its own users and conversation
+// details alongside the messages. Generic formats are read through a
+// field map; the others ignore it.
+type importer func(path string, fields *FieldMap) (*conversation, error)
+
+var importers = map[string]importer{
+	FormatZulip:  parseZulip,
+	FormatMatrix: parseMatrix,
+	FormatNDJSON: parseNDJSON,
+	FormatCSV:    parseCSV,
+}
+
+// fileExtensions lists the message file extensions read for each format
+var fileExtensions = map[string][]string{
+	FormatNDJSON: {".ndjson", ".jsonl", ".json"},
+	FormatCSV:    {".csv"},
+}
+
+// NeedsFieldMap reports whether a format is read through a field map
+func NeedsFieldMap(format string) bool {
+	return format == FormatNDJSON || format == FormatCSV
+}
+
+// conversation is the content of an export file mapped onto the database's
+// model
+type conversation struct {
+	channel  *models.Channel
+	users    []*models.User
+	messages []*models.Message
+	// malformed counts records that could not be read
+	malformed int
+}
+
+// ValidFormat reports an error for an unsupported export format
+func ValidFormat(format string) error {
+	if format == FormatSlack {
+		return nil
+	}
+	if _, ok := importers[format]; ok {
+		return nil
+	}
+	return fmt.Errorf("unsupported format: %s (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+// importFile indexes an export file read by the indexer's importer and
+// returns the number of messages inserted
+func (idx *Indexer) importFile(path, filename string) (int, error) {
+	parseStart := time.Now()
+	conv, err := idx.importer(path, idx.fields)
+	idx.metrics.ParseTime += time.Since(parseStart)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s export: %w", idx.format, err)
+	}
+
+	if conv.malformed > 0 {
+		idx.skipMalformed(conv.malformed)
+	}
+
+	writeStart := time.Now()
+	defer func() { idx.metrics.WriteTime += time.Since(writeStart) }()
+
+	if conv.channel != nil {
+		if err := idx.db.InsertChannel(conv.channel); err != nil {
+			return 0, fmt.Errorf("failed to insert channel %s: %w", conv.channel.ID, err)
+		}
+	}
+
+	// Users go in first, since a message's search entry is built from its
+	// author's row
+	for _, user := range conv.users {
+		if err := idx.db.InsertUser(user); err != nil {
+			return 0, fmt.Errorf("failed to insert user %s: %w", user.ID, err)
+		}
+		idx.knownUsers[user.ID] = true
+	}
+
+	inserted := 0
+	for i, message := range conv.messages {
+		if message.UserID == "" || strings.TrimSpace(message.Text) == "" {
+			reason := SkipEmptyText
+			if message.UserID == "" {
+				reason = SkipNoUser
+			}
+			idx.skip(models.SkippedMessage{
+				Filename:  filename,
+				Position:  i + 1,
+				Reason:    skipReason(message.Subtype, reason),
+				Subtype:   message.Subtype,
+				Timestamp: message.Timestamp,
+				UserID:    message.UserID,
+				Excerpt:   message.Text,
+			})
+			continue
+		}
+
+		message.Filename = filename
+		message.TSMicros = message.Date.UnixMicro()
+		if err := idx.ensureUser(message.UserID, nil); err != nil {
+			return 0, err
+		}
+		if err := idx.db.InsertMessage(message); err != nil {
+			return 0, fmt.Errorf("failed to insert message: %w", err)
+		}
+		inserted++
+	}
+
+	return inserted, nil
+}
+
+// isMessageFile reports whether a file in the channel directory holds
+// messages in the indexer's format
+func (idx *Indexer) isMessageFile(path string) bool {
+	extensions, ok := fileExtensions[idx.format]
+	if !ok {
+		return strings.HasSuffix(path, ".json")
+	}
+	for _, extension := range extensions {
+		if strings.EqualFold(filepath.Ext(path), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// newConversation creates the channel for an export file, named after the
+// directory holding it until the export says otherwise
+func newConversation(path string) *conversation {
+	name := filepath.Base(filepath.Dir(path))
+	return &conversation{
+		channel: &models.Channel{ID: name, Name: name, Kind: models.KindChannel},
+	}
+}
+
+// formatTimestamp renders a time as a Slack-style timestamp, which the
+// database uses to identify messages and threads
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+// linkThreads marks the messages that replies point to as thread starters
+// and counts their replies. Replies whose starter is not in the file are
+// left as top-level messages.
+func linkThreads(messages []*models.Message) {
+	starters := make(map[string]*models.Message)
+	for _, message := range messages {
+		starters[message.Timestamp] = message
+	}
+
+	for _, message := range messages {
+		if message.ThreadTS == "" || message.ThreadTS == message.Timestamp {
+			continue
+		}
+		starter, ok := starters[message.ThreadTS]
+		if !ok {
+			message.ThreadTS = ""
+			continue
+		}
+		starter.ThreadTS = starter.Timestamp
+		starter.ReplyCount++
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date.Before(messages[j].Date)
+	})
+}
+
+// hasURL reports whether plain text contains a web link
+func hasURL(text string) bool {
+	return strings.Contains(text, "http://") || strings.Contains(text, "https://")
+}
@@ -1,39 +1,108 @@
 package indexer
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/raesene/k8s-slack-searcher/pkg/database"
 	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/slacktext"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
 )
 
+// maxUsersListed is how many missing users a placeholder warning names
+const maxUsersListed = 10
+
+// ErrInterrupted is returned when an ingest is cancelled. Files committed
+// before the interruption are checkpointed, so re-running the ingest resumes
+// where it stopped.
+var ErrInterrupted = errors.New("ingest interrupted")
+
 type Indexer struct {
 	db           *database.DB
 	sourceDir    string
 	channelName  string
 	totalFiles   int
 	processedFiles int
+	skippedFiles int
+	failedFiles  int
+	metrics      *Metrics
+	dataDir      string
+	logger       Logger
+	progress     func(ProgressEvent)
+	format       string
+	importer     importer
+	fields       *FieldMap
+	// knownUsers holds the IDs of users known to have a users row
+	knownUsers   map[string]bool
+	// profiles collects the author profiles seen in the file being
+	// indexed, recorded when the file is committed
+	profiles     map[models.UserProfile]*models.UserProfile
+	// skips collects the messages skipped in the file being indexed
+	skips        *fileSkips
+	includeBots  bool
+	keepRaw      bool
+	// metadata is the export's users, conversations and integrations when
+	// parsed by the caller, for indexing several channels in parallel
+	metadata     *SourceMetadata
+	// workspaceMeta keeps the export's users and channels in the shared
+	// workspace-meta.db, with directory holding the users by ID so message
+	// authors can be copied into the channel database
+	workspaceMeta bool
+	directory    map[string]*models.User
+	// setCompression changes whether message text is stored compressed
+	// to compressText
+	setCompression bool
+	compressText   bool
+	// bots and appServices hold what integration_logs.json says about
+	// bots, by bot ID, and the services apps provide, by app ID
+	bots         map[string]*models.Bot
+	appServices  map[string]string
 }
 
 // NewIndexer creates a new indexer for a given channel directory
-func NewIndexer(sourceDir, channelName string) (*Indexer, error) {
-	db, err := database.NewDB(channelName)
+func NewIndexer(sourceDir, channelName string, opts ...Option) (*Indexer, error) {
+	idx := &Indexer{
+		sourceDir:   sourceDir,
+		channelName: channelName,
+		metrics:     newMetrics(),
+		logger:      discardLogger{},
+		knownUsers:  make(map[string]bool),
+		bots:        make(map[string]*models.Bot),
+		appServices: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(idx)
+	}
+
+	db, err := database.NewDBInDir(idx.dataDir, channelName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database: %w", err)
 	}
+	idx.db = db
 
-	return &Indexer{
-		db:          db,
-		sourceDir:   sourceDir,
-		channelName: channelName,
-	}, nil
+	return idx, nil
+}
+
+// Metrics returns timing and skip statistics for the ingest so far
+func (idx *Indexer) Metrics() *Metrics {
+	return idx.metrics
+}
+
+// LastMessageID returns the ID of the most recently stored message, so
+// callers can find the messages an ingest adds
+func (idx *Indexer) LastMessageID(ctx context.Context) (int, error) {
+	return idx.db.LastMessageID(ctx)
 }
 
 // Close closes the indexer and database connection
@@ -41,107 +110,296 @@ func (idx *Indexer) Close() error {
 	return idx.db.Close()
 }
 
-// IndexChannel indexes all data for a specific channel
-func (idx *Indexer) IndexChannel() error {
-	fmt.Printf("Indexing channel: %s\n", idx.channelName)
+// IndexChannel indexes all data for a specific channel. Cancelling ctx stops
+// the ingest cleanly after the file currently being indexed is committed.
+func (idx *Indexer) IndexChannel(ctx context.Context) error {
+	idx.logger.Printf("Indexing channel: %s\n", idx.channelName)
 
-	// First, load users and channels data
-	if err := idx.loadUsers(); err != nil {
-		return fmt.Errorf("failed to load users: %w", err)
+	if idx.setCompression {
+		converted, err := idx.db.SetTextCompression(ctx, idx.compressText)
+		if err != nil {
+			return fmt.Errorf("failed to set text compression: %w", err)
+		}
+		if converted > 0 {
+			idx.logger.Printf("Converted the text of %d stored messages\n", converted)
+		}
 	}
 
-	if err := idx.loadChannels(); err != nil {
-		return fmt.Errorf("failed to load channels: %w", err)
+	// First, load users and channels data, unless the caller parsed them
+	// already. Other formats carry them in each export file.
+	if idx.importer == nil {
+		metadata := idx.metadata
+		if metadata == nil {
+			var err error
+			if metadata, err = LoadSourceMetadata(idx.sourceDir, idx.includeBots); err != nil {
+				return err
+			}
+		}
+
+		if idx.workspaceMeta {
+			// Metadata passed in is stored by the caller, once for
+			// every channel
+			if idx.metadata == nil {
+				if err := StoreWorkspaceMeta(idx.dataDir, idx.channelName, metadata); err != nil {
+					return err
+				}
+			}
+			if err := idx.useWorkspaceMeta(ctx, metadata); err != nil {
+				return err
+			}
+		} else {
+			if err := idx.loadUsers(metadata.users); err != nil {
+				return fmt.Errorf("failed to load users: %w", err)
+			}
+
+			if err := idx.loadChannels(metadata.conversations); err != nil {
+				return fmt.Errorf("failed to load channels: %w", err)
+			}
+		}
+
+		if idx.includeBots {
+			idx.bots = metadata.bots
+			idx.appServices = metadata.appServices
+		}
 	}
 
 	// Then process message files in the channel directory
-	channelDir := filepath.Join(idx.sourceDir, idx.channelName)
-	if err := idx.processMessageFiles(channelDir); err != nil {
+	_, channel := storagepaths.SplitName(idx.channelName)
+	channelDir := filepath.Join(idx.sourceDir, channel)
+	if err := idx.processMessageFiles(ctx, channelDir); err != nil {
+		if errors.Is(err, ErrInterrupted) {
+			return err
+		}
 		return fmt.Errorf("failed to process message files: %w", err)
 	}
 
 	// Print completion statistics
-	stats, err := idx.db.GetStats()
+	stats, err := idx.db.GetStats(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get stats: %w", err)
 	}
 
-	fmt.Printf("Indexing complete!\n")
-	fmt.Printf("- Users: %d\n", stats["users"])
-	fmt.Printf("- Channels: %d\n", stats["channels"])
-	fmt.Printf("- Messages: %d\n", stats["messages"])
-	fmt.Printf("- Files processed: %d\n", idx.processedFiles)
+	idx.logger.Printf("Indexing complete!\n")
+	idx.logger.Printf("- Users: %d\n", stats["users"])
+	idx.logger.Printf("- Channels: %d\n", stats["channels"])
+	idx.logger.Printf("- Messages: %d\n", stats["messages"])
+	idx.logger.Printf("- Files processed: %d\n", idx.processedFiles)
+	if idx.skippedFiles > 0 {
+		idx.logger.Printf("- Files skipped (already indexed): %d\n", idx.skippedFiles)
+	}
+	idx.warnPlaceholderUsers()
+
+	idx.metrics.Elapsed = time.Since(idx.metrics.Started)
 
 	return nil
 }
 
-// loadUsers loads users from users.json
-func (idx *Indexer) loadUsers() error {
-	usersFile := filepath.Join(idx.sourceDir, "users.json")
-	
-	data, err := os.ReadFile(usersFile)
+// readUsers reads the users listed in users.json
+func readUsers(sourceDir string) ([]*models.User, error) {
+	data, err := os.ReadFile(filepath.Join(sourceDir, "users.json"))
 	if err != nil {
-		return fmt.Errorf("failed to read users.json: %w", err)
+		return nil, fmt.Errorf("failed to read users.json: %w", err)
 	}
 
 	var usersJSON []models.UserJSON
 	if err := json.Unmarshal(data, &usersJSON); err != nil {
-		return fmt.Errorf("failed to parse users.json: %w", err)
+		return nil, fmt.Errorf("failed to parse users.json: %w", err)
 	}
 
-	fmt.Printf("Loading %d users...\n", len(usersJSON))
-
+	users := make([]*models.User, 0, len(usersJSON))
 	for _, userJSON := range usersJSON {
-		user := &models.User{
+		users = append(users, &models.User{
 			ID:          userJSON.ID,
 			Name:        userJSON.Name,
 			RealName:    userJSON.Profile.RealName,
 			DisplayName: userJSON.Profile.DisplayName,
 			IsBot:       userJSON.IsBot,
 			Deleted:     userJSON.Deleted,
-		}
+		})
+	}
+	return users, nil
+}
+
+// loadUsers stores the export's users
+func (idx *Indexer) loadUsers(users []*models.User) error {
+	idx.logger.Printf("Loading %d users...\n", len(users))
 
+	if err := idx.db.Begin(); err != nil {
+		return err
+	}
+	defer idx.db.Rollback()
+
+	for _, user := range users {
 		if err := idx.db.InsertUser(user); err != nil {
 			return fmt.Errorf("failed to insert user %s: %w", user.ID, err)
 		}
+		idx.knownUsers[user.ID] = true
 	}
 
-	return nil
+	return idx.db.Commit()
+}
+
+// readIntegrationLogs reads the bot names, by bot ID, and integration
+// services, by app ID, from integration_logs.json, which not every export
+// includes
+func readIntegrationLogs(sourceDir string) (map[string]*models.Bot, map[string]string, error) {
+	bots := make(map[string]*models.Bot)
+	appServices := make(map[string]string)
+
+	data, err := os.ReadFile(filepath.Join(sourceDir, "integration_logs.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return bots, appServices, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read integration_logs.json: %w", err)
+	}
+
+	var logs []models.SlackIntegrationLog
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse integration_logs.json: %w", err)
+	}
+
+	for _, entry := range logs {
+		if entry.AppID != "" && entry.ServiceType != "" {
+			appServices[entry.AppID] = entry.ServiceType
+		}
+		if entry.BotID == "" {
+			continue
+		}
+		bot := bots[entry.BotID]
+		if bot == nil {
+			bot = &models.Bot{ID: entry.BotID}
+			bots[entry.BotID] = bot
+		}
+		// Later entries describe the bot as it is now
+		if entry.BotName != "" {
+			bot.Name = entry.BotName
+		}
+		if entry.AppID != "" {
+			bot.AppID = entry.AppID
+		}
+		if entry.ServiceType != "" {
+			bot.Service = entry.ServiceType
+		}
+	}
+
+	return bots, appServices, nil
 }
 
-// loadChannels loads channels from channels.json
-func (idx *Indexer) loadChannels() error {
-	channelsFile := filepath.Join(idx.sourceDir, "channels.json")
-	
-	data, err := os.ReadFile(channelsFile)
+// conversationFiles lists the export metadata files describing
+// conversations. Only channels.json is present in every export; the others
+// appear in exports that include private conversations.
+var conversationFiles = []struct {
+	name string
+	kind string
+}{
+	{"channels.json", models.KindChannel},
+	{"groups.json", models.KindGroup},
+	{"mpims.json", models.KindMPIM},
+	{"dms.json", models.KindDM},
+}
+
+// readConversations reads every conversation metadata file present in the
+// source directory
+func readConversations(sourceDir string) ([]*models.Channel, error) {
+	var conversations []*models.Channel
+	found := false
+
+	for _, file := range conversationFiles {
+		data, err := os.ReadFile(filepath.Join(sourceDir, file.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.name, err)
+		}
+		found = true
+
+		var channelsJSON []models.ChannelJSON
+		if err := json.Unmarshal(data, &channelsJSON); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.name, err)
+		}
+
+		for _, channelJSON := range channelsJSON {
+			// Direct messages have no name; their directory is named by ID
+			name := channelJSON.Name
+			if name == "" {
+				name = channelJSON.ID
+			}
+
+			conversations = append(conversations, &models.Channel{
+				ID:         channelJSON.ID,
+				Name:       name,
+				Created:    channelJSON.Created,
+				Creator:    channelJSON.Creator,
+				IsArchived: channelJSON.IsArchived,
+				Topic:      channelJSON.Topic.Value,
+				Purpose:    channelJSON.Purpose.Value,
+				Kind:       file.kind,
+				Members:    channelJSON.Members,
+			})
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no channels.json, groups.json, mpims.json or dms.json found in %s", sourceDir)
+	}
+
+	return conversations, nil
+}
+
+// ConversationKind returns the kind of the conversation stored in a channel
+// directory (see models.KindChannel and friends), so callers can confirm
+// before indexing private conversations. Directories not listed in any
+// metadata file are reported as public channels.
+func ConversationKind(sourceDir, channelName string) (string, error) {
+	conversations, err := readConversations(sourceDir)
 	if err != nil {
-		return fmt.Errorf("failed to read channels.json: %w", err)
+		return "", err
 	}
 
-	var channels []models.Channel
-	if err := json.Unmarshal(data, &channels); err != nil {
-		return fmt.Errorf("failed to parse channels.json: %w", err)
+	for _, conversation := range conversations {
+		if conversation.Name == channelName || conversation.ID == channelName {
+			return conversation.Kind, nil
+		}
 	}
 
-	fmt.Printf("Loading %d channels...\n", len(channels))
+	return models.KindChannel, nil
+}
+
+// loadChannels stores the conversations listed in channels.json and, when
+// present, groups.json, mpims.json and dms.json
+func (idx *Indexer) loadChannels(conversations []*models.Channel) error {
+	idx.logger.Printf("Loading %d channels...\n", len(conversations))
 
-	for _, channel := range channels {
-		if err := idx.db.InsertChannel(&channel); err != nil {
+	if err := idx.db.Begin(); err != nil {
+		return err
+	}
+	defer idx.db.Rollback()
+
+	for _, channel := range conversations {
+		if err := idx.db.InsertChannel(channel); err != nil {
 			return fmt.Errorf("failed to insert channel %s: %w", channel.ID, err)
 		}
 	}
 
-	return nil
+	return idx.db.Commit()
 }
 
 // processMessageFiles processes all JSON message files in the channel directory
-func (idx *Indexer) processMessageFiles(channelDir string) error {
+func (idx *Indexer) processMessageFiles(ctx context.Context, channelDir string) error {
+	// Files indexed by a previous, interrupted run are skipped
+	indexed, err := idx.db.IndexedFiles(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Count total files first
-	err := filepath.WalkDir(channelDir, func(path string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(channelDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !d.IsDir() && strings.HasSuffix(path, ".json") {
+		if !d.IsDir() && idx.isMessageFile(path) {
 			idx.totalFiles++
 		}
 		return nil
@@ -150,7 +408,11 @@ func (idx *Indexer) processMessageFiles(channelDir string) error {
 		return fmt.Errorf("failed to count files: %w", err)
 	}
 
-	fmt.Printf("Processing %d message files...\n", idx.totalFiles)
+	idx.logger.Printf("Processing %d message files...\n", idx.totalFiles)
+	idx.emit(ProgressEvent{Type: EventStarted})
+	if len(indexed) > 0 {
+		idx.logger.Printf("Resuming: %d files were already indexed\n", len(indexed))
+	}
 
 	// Process each JSON file
 	err = filepath.WalkDir(channelDir, func(path string, d fs.DirEntry, err error) error {
@@ -158,97 +420,481 @@ func (idx *Indexer) processMessageFiles(channelDir string) error {
 			return err
 		}
 
-		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+		if d.IsDir() || !idx.isMessageFile(path) {
 			return nil
 		}
 
+		// Stop between files so every committed file is complete
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+
 		filename := filepath.Base(path)
-		if err := idx.processMessageFile(path, filename); err != nil {
-			fmt.Printf("Warning: failed to process %s: %v\n", filename, err)
+		if indexed[filename] {
+			idx.skippedFiles++
+			idx.emit(ProgressEvent{Type: EventFileSkipped, File: filename})
+			return nil
+		}
+
+		idx.emit(ProgressEvent{Type: EventFileStarted, File: filename})
+		count, err := idx.indexFile(path, filename)
+		if err != nil {
+			idx.failedFiles++
+			idx.logger.Printf("Warning: failed to process %s: %v\n", filename, err)
+			idx.emit(ProgressEvent{Type: EventFileFailed, File: filename, Err: err})
 		} else {
 			idx.processedFiles++
 			if idx.processedFiles%50 == 0 {
-				fmt.Printf("Processed %d/%d files...\n", idx.processedFiles, idx.totalFiles)
+				idx.logger.Printf("Processed %d/%d files...\n", idx.processedFiles, idx.totalFiles)
 			}
+			idx.emit(ProgressEvent{Type: EventFileDone, File: filename, FileMessages: count})
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	idx.emit(ProgressEvent{Type: EventFinished, Err: ctx.Err()})
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w after %d/%d files", ErrInterrupted, idx.processedFiles+idx.skippedFiles, idx.totalFiles)
+	}
 
-	return err
+	return nil
 }
 
-// processMessageFile processes a single message file
-func (idx *Indexer) processMessageFile(filepath, filename string) error {
-	data, err := os.ReadFile(filepath)
+// indexFile indexes a single message file in its own transaction and
+// records a checkpoint for it, returning the number of messages indexed
+func (idx *Indexer) indexFile(path, filename string) (int, error) {
+	start := time.Now()
+
+	if err := idx.db.Begin(); err != nil {
+		return 0, err
+	}
+	defer idx.db.Rollback()
+
+	process := idx.processMessageFile
+	if idx.importer != nil {
+		process = idx.importFile
+	}
+	idx.profiles = make(map[models.UserProfile]*models.UserProfile)
+	idx.skips = newFileSkips()
+	count, err := process(path, filename)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return 0, err
+	}
+
+	writeStart := time.Now()
+	for _, profile := range idx.profiles {
+		if err := idx.db.RecordUserProfile(profile); err != nil {
+			return 0, err
+		}
+	}
+	if err := idx.db.RecordSkipped(filename, idx.skips.list(), idx.skips.examples); err != nil {
+		return 0, err
+	}
+	if err := idx.db.MarkFileIndexed(filename, count); err != nil {
+		return 0, fmt.Errorf("failed to record checkpoint: %w", err)
 	}
 
-	var messages []json.RawMessage
-	if err := json.Unmarshal(data, &messages); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+	if err := idx.db.Commit(); err != nil {
+		return 0, err
 	}
+	idx.metrics.WriteTime += time.Since(writeStart)
+	idx.metrics.Inserted += count
 
-	// Parse date from filename (format: YYYY-MM-DD.json)
-	dateStr := strings.TrimSuffix(filename, ".json")
-	date, err := time.Parse("2006-01-02", dateStr)
+	stat := FileStat{Name: filename, Messages: count, Duration: time.Since(start)}
+	if info, err := os.Stat(path); err == nil {
+		stat.Size = info.Size()
+	}
+	idx.metrics.addFile(stat)
+
+	return count, nil
+}
+
+// processMessageFile processes a single message file and returns the number
+// of messages inserted. The file is decoded one message at a time so large
+// daily files are never held in memory as a whole.
+func (idx *Indexer) processMessageFile(path, filename string) (int, error) {
+	// The file's date, from its name, stands in for a message timestamp
+	// that can't be read
+	date := fileDate(filename)
+
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to parse date from filename %s: %w", filename, err)
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return 0, fmt.Errorf("failed to parse JSON: expected an array of messages")
 	}
 
-	for _, rawMsg := range messages {
-		var msgMap map[string]interface{}
-		if err := json.Unmarshal(rawMsg, &msgMap); err != nil {
-			continue // Skip malformed messages
+	inserted := 0
+	position := 0
+	for decoder.More() {
+		position++
+		parseStart := time.Now()
+		var msg models.SlackMessage
+		var raw json.RawMessage
+		var err error
+		if idx.keepRaw {
+			if err = decoder.Decode(&raw); err == nil {
+				err = json.Unmarshal(raw, &msg)
+			}
+		} else {
+			err = decoder.Decode(&msg)
+		}
+		idx.metrics.ParseTime += time.Since(parseStart)
+		if err != nil {
+			// A field of an unexpected type leaves the rest of the message
+			// decoded, and an element that is not an object is skipped;
+			// anything else leaves the decoder unable to continue
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				return 0, fmt.Errorf("failed to parse JSON: %w", err)
+			}
+			if typeErr.Field == "" {
+				idx.skip(models.SkippedMessage{Filename: filename, Position: position, Reason: SkipMalformed})
+				continue // Skip malformed messages
+			}
 		}
 
-		// Only process human messages (skip bot messages and system messages)
-		if subtype, ok := msgMap["subtype"].(string); ok {
-			if subtype == "bot_message" {
+		// Only process human messages (skip bot messages and system
+		// messages) unless bots were asked for
+		if msg.Subtype == "bot_message" {
+			if !idx.includeBots {
+				idx.skip(skippedSlackMessage(filename, position, SkipBotMessage, &msg, msg.Text))
 				continue
 			}
+			if err := idx.attributeBot(&msg); err != nil {
+				return 0, err
+			}
 		}
 
 		// Skip messages without user ID or text
-		userID, hasUser := msgMap["user"].(string)
-		text, hasText := msgMap["text"].(string)
-		if !hasUser || !hasText || strings.TrimSpace(text) == "" {
+		if msg.User == "" {
+			idx.skip(skippedSlackMessage(filename, position, skipReason(msg.Subtype, SkipNoUser), &msg, messageText(&msg)))
+			continue
+		}
+		text := messageText(&msg)
+		if strings.TrimSpace(text) == "" {
+			idx.skip(skippedSlackMessage(filename, position, skipReason(msg.Subtype, SkipEmptyText), &msg, text))
 			continue
 		}
-
-		// Parse timestamp to get time of day
-		timestamp, _ := msgMap["ts"].(string)
-		msgType, _ := msgMap["type"].(string)
-		subtype, _ := msgMap["subtype"].(string)
 
 		// Create message with parsed timestamp
 		msgTime := date
-		if timestamp != "" {
-			if ts, err := parseSlackTimestamp(timestamp); err == nil {
+		if msg.TS != "" {
+			if ts, err := parseSlackTimestamp(msg.TS); err == nil {
 				msgTime = ts
 			}
 		}
+		if msgTime.IsZero() {
+			idx.skip(skippedSlackMessage(filename, position, SkipNoDate, &msg, text))
+			continue
+		}
 
 		message := &models.Message{
-			UserID:    userID,
-			Text:      text,
-			Type:      msgType,
-			Subtype:   subtype,
-			Timestamp: timestamp,
-			Date:      msgTime,
-			Filename:  filename,
+			UserID:        msg.User,
+			Text:          text,
+			Type:          msg.Type,
+			Subtype:       msg.Subtype,
+			Timestamp:     msg.TS,
+			Date:          msgTime,
+			Filename:      filename,
+			ThreadTS:      msg.ThreadTS,
+			ReplyCount:    msg.ReplyCount,
+			ReactionCount: msg.ReactionCount(),
+			TSMicros:      msgTime.UnixMicro(),
+			HasLink:       hasLink(&msg, text),
+			HasCode:       strings.Contains(text, "`"),
+			HasFile:       len(msg.Files) > 0 || msg.File != nil,
 		}
 
+		writeStart := time.Now()
+		if err := idx.ensureUser(msg.User, msg.UserProfile); err != nil {
+			return 0, err
+		}
+		idx.seeProfile(msg.User, msg.UserProfile, msgTime)
 		if err := idx.db.InsertMessage(message); err != nil {
-			return fmt.Errorf("failed to insert message: %w", err)
+			return 0, fmt.Errorf("failed to insert message: %w", err)
+		}
+		if idx.keepRaw {
+			if err := idx.storeRaw(msg.TS, raw); err != nil {
+				return 0, err
+			}
+		}
+		if message.HasFile {
+			if err := idx.storeFiles(&msg); err != nil {
+				return 0, err
+			}
+		}
+		idx.metrics.WriteTime += time.Since(writeStart)
+		inserted++
+	}
+
+	return inserted, nil
+}
+
+// ensureUser adds a placeholder user for a message author missing from the
+// export's user list, named from the profile Slack copies into messages
+// when there is one, so the author's messages can still be found by user
+func (idx *Indexer) ensureUser(id string, profile *models.SlackUserProfile) error {
+	if idx.knownUsers[id] {
+		if _, missing := idx.metrics.PlaceholderUsers[id]; missing {
+			idx.metrics.PlaceholderUsers[id]++
+		}
+		return nil
+	}
+
+	// With a shared workspace directory, authors are copied in as they
+	// are first seen
+	if user := idx.directory[id]; user != nil {
+		if err := idx.db.InsertUser(user); err != nil {
+			return fmt.Errorf("failed to insert user %s: %w", id, err)
+		}
+		idx.knownUsers[id] = true
+		return nil
+	}
+
+	user := &models.User{ID: id, Name: id}
+	if profile != nil {
+		if profile.Name != "" {
+			user.Name = profile.Name
 		}
+		user.RealName = profile.RealName
+		user.DisplayName = profile.DisplayName
+	}
+	added, err := idx.db.InsertPlaceholderUser(user)
+	if err != nil {
+		return fmt.Errorf("failed to insert placeholder user %s: %w", id, err)
 	}
+	idx.knownUsers[id] = true
+	if added {
+		idx.metrics.PlaceholderUsers[id] = 1
+	}
+	return nil
+}
 
+// attributeBot attributes a bot message to its bot, recording the bot the
+// first time it is seen. The name the message was posted under, which
+// webhooks can set per message, is kept as the author's profile at the
+// time. Messages with no bot ID are left without a user.
+func (idx *Indexer) attributeBot(msg *models.SlackMessage) error {
+	id := msg.BotID
+	if id == "" && msg.BotProfile != nil {
+		id = msg.BotProfile.ID
+	}
+	if id == "" {
+		return nil
+	}
+
+	bot := models.Bot{ID: id}
+	if known := idx.bots[id]; known != nil {
+		bot = *known
+	}
+	if profile := msg.BotProfile; profile != nil {
+		if profile.Name != "" {
+			bot.Name = profile.Name
+		}
+		if profile.AppID != "" {
+			bot.AppID = profile.AppID
+		}
+	}
+	if bot.Service == "" {
+		bot.Service = idx.appServices[bot.AppID]
+	}
+	if bot.Name == "" {
+		bot.Name = msg.Username
+	}
+	if bot.Name == "" {
+		bot.Name = id
+	}
+
+	if !idx.knownUsers[id] {
+		if err := idx.db.InsertBot(&bot); err != nil {
+			return err
+		}
+		idx.knownUsers[id] = true
+	}
+
+	msg.User = id
+	if msg.UserProfile == nil {
+		name := msg.Username
+		if name == "" {
+			name = bot.Name
+		}
+		msg.UserProfile = &models.SlackUserProfile{Name: name, RealName: bot.Service, DisplayName: name}
+	}
 	return nil
 }
 
-// parseSlackTimestamp converts Slack timestamp to time.Time
+// seeProfile notes the profile an author posted a message under at a time,
+// widening the period the profile was seen in
+func (idx *Indexer) seeProfile(id string, profile *models.SlackUserProfile, at time.Time) {
+	if profile == nil || profile.Name == "" {
+		return
+	}
+
+	key := models.UserProfile{
+		UserID:      id,
+		Name:        profile.Name,
+		RealName:    profile.RealName,
+		DisplayName: profile.DisplayName,
+	}
+	seen, ok := idx.profiles[key]
+	if !ok {
+		seen = &models.UserProfile{}
+		*seen = key
+		seen.FirstSeen, seen.LastSeen = at, at
+		idx.profiles[key] = seen
+		return
+	}
+	if at.Before(seen.FirstSeen) {
+		seen.FirstSeen = at
+	}
+	if at.After(seen.LastSeen) {
+		seen.LastSeen = at
+	}
+}
+
+// warnPlaceholderUsers reports the message authors missing from the
+// export's user list that placeholder users were added for
+func (idx *Indexer) warnPlaceholderUsers() {
+	users := idx.metrics.PlaceholderUsers
+	if len(users) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(users))
+	messages := 0
+	for id, count := range users {
+		ids = append(ids, id)
+		messages += count
+	}
+	sort.Strings(ids)
+	if len(ids) > maxUsersListed {
+		ids = append(ids[:maxUsersListed], "...")
+	}
+
+	idx.logger.Printf("Warning: %d message(s) from %d user(s) missing from the export's user list were indexed under placeholder users: %s\n",
+		messages, len(users), strings.Join(ids, ", "))
+}
+
+// hasLink reports whether a message links anywhere, in its text or through
+// an unfurled attachment
+func hasLink(msg *models.SlackMessage, text string) bool {
+	if len(slacktext.ExtractLinks(text)) > 0 {
+		return true
+	}
+	for _, attachment := range msg.Attachments {
+		if attachment.FromURL != "" || attachment.TitleLink != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// messageText returns the text to index for a message, reconstructing it
+// from blocks when the text field is empty or only an app's fallback, and
+// adding the titles and captions of shared files. Attachments such as link
+// unfurls are only used when the message has no text of its own.
+func messageText(msg *models.SlackMessage) string {
+	text := msg.Text
+	if len(msg.Blocks) > 0 && (strings.TrimSpace(text) == "" || slacktext.HasLayoutBlocks(msg.Blocks)) {
+		if blocks := slacktext.FlattenBlocks(msg.Blocks); strings.TrimSpace(blocks) != "" {
+			text = blocks
+		}
+	}
+
+	lines := []string{}
+	if strings.TrimSpace(text) != "" {
+		lines = append(lines, text)
+	}
+
+	files := msg.Files
+	if msg.File != nil {
+		files = append(files, *msg.File)
+	}
+	for _, file := range files {
+		lines = append(lines, fileText(file)...)
+	}
+
+	if len(lines) == 0 {
+		for _, attachment := range msg.Attachments {
+			lines = append(lines, attachmentText(attachment)...)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// fileText describes a shared file by its title and name, with any caption
+func fileText(file models.SlackFile) []string {
+	var lines []string
+
+	label := file.Title
+	if file.Name != "" && file.Name != file.Title {
+		if label != "" {
+			label += " (" + file.Name + ")"
+		} else {
+			label = file.Name
+		}
+	}
+	if label != "" {
+		lines = append(lines, "[file: "+label+"]")
+	}
+
+	if file.InitialComment != nil && strings.TrimSpace(file.InitialComment.Comment) != "" {
+		lines = append(lines, file.InitialComment.Comment)
+	}
+
+	return lines
+}
+
+// attachmentText returns the readable parts of a legacy attachment
+func attachmentText(attachment models.SlackAttachment) []string {
+	var lines []string
+	for _, part := range []string{attachment.Pretext, attachment.Title, attachment.Text} {
+		if strings.TrimSpace(part) != "" {
+			lines = append(lines, part)
+		}
+	}
+
+	if len(lines) == 0 && strings.TrimSpace(attachment.Fallback) != "" {
+		lines = append(lines, attachment.Fallback)
+	}
+
+	return lines
+}
+
+// fileDate returns the day a message file holds from its name: YYYY-MM-DD.json
+// as Slack names them, or a name starting with the date such as the
+// partial-day splits 2023-05-15-2.json and 2023-05-15_part1.json. It returns
+// the zero time for other names, such as canvases, whose messages are dated
+// by their timestamps alone.
+func fileDate(filename string) time.Time {
+	name := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if len(name) < len("2006-01-02") {
+		return time.Time{}
+	}
+	prefix, rest := name[:len("2006-01-02")], name[len("2006-01-02"):]
+	if rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", prefix)
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// parseSlackTimestamp converts Slack timestamp to time.Time, keeping the
+// microseconds that order messages posted within the same second
 func parseSlackTimestamp(ts string) (time.Time, error) {
 	// Slack timestamps are Unix timestamps with microseconds
 	// Format: "1565852586.087600"
@@ -262,5 +908,16 @@ func parseSlackTimestamp(ts string) (time.Time, error) {
 		return time.Time{}, err
 	}
 
-	return time.Unix(seconds, 0), nil
+	// Normalise the fraction to exactly six digits
+	fraction := parts[1]
+	if len(fraction) > 6 {
+		fraction = fraction[:6]
+	}
+	fraction += strings.Repeat("0", 6-len(fraction))
+	micros, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(seconds, micros*int64(time.Microsecond)), nil
 }
\ No newline at end of file
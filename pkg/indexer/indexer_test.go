@@ -0,0 +1,263 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/fixture"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+func TestFileDate(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		filename string
+		want     time.Time
+	}{
+		{"2024-03-04.json", day(2024, time.March, 4)},
+		{"2024-03-04", day(2024, time.March, 4)},
+
+		// Partial-day splits and copies keep the day
+		{"2023-05-15-2.json", day(2023, time.May, 15)},
+		{"2023-05-15_part1.json", day(2023, time.May, 15)},
+		{"2024-03-04 (1).json", day(2024, time.March, 4)},
+		{"2024-03-04.part2.json", day(2024, time.March, 4)},
+
+		// A date running into more digits isn't a date
+		{"2024-02-0412.json", time.Time{}},
+
+		// Canvases and other names have no date
+		{"channel_canvas.json", time.Time{}},
+		{"canvas.json", time.Time{}},
+		{"F0123ABCD.json", time.Time{}},
+		{"notes.json", time.Time{}},
+		{"2024-3-4.json", time.Time{}},
+		{"2024-13-01.json", time.Time{}},
+		{"2024-02-30.json", time.Time{}},
+		{"", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := fileDate(tt.filename); !got.Equal(tt.want) {
+				t.Errorf("fileDate(%q) = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSlackTimestamp(t *testing.T) {
+	tests := []struct {
+		ts      string
+		want    int64
+		wantErr bool
+	}{
+		{"1565852586.087600", 1565852586087600, false},
+		{"1565852586.000001", 1565852586000001, false},
+		// Short fractions are padded, long ones cut to microseconds
+		{"1565852586.5", 1565852586500000, false},
+		{"1565852586.08", 1565852586080000, false},
+		{"1565852586.0876009", 1565852586087600, false},
+		{"1565852586.", 1565852586000000, false},
+
+		{"1565852586", 0, true},
+		{"1565852586.0876.1", 0, true},
+		{"abc.087600", 0, true},
+		{"1565852586.08x", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ts, func(t *testing.T) {
+			got, err := parseSlackTimestamp(tt.ts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSlackTimestamp(%q) = %v, want an error", tt.ts, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSlackTimestamp(%q) failed: %v", tt.ts, err)
+			}
+			if got.UnixMicro() != tt.want {
+				t.Errorf("parseSlackTimestamp(%q) = %d µs, want %d", tt.ts, got.UnixMicro(), tt.want)
+			}
+		})
+	}
+}
+
+// TestProcessMessageFileOddNames checks that files whose names aren't a
+// day are indexed: messages are dated by their timestamps, and the name's
+// date, when it has one, only stands in for a missing timestamp
+func TestProcessMessageFileOddNames(t *testing.T) {
+	ctx := context.Background()
+	source := t.TempDir()
+	channelDir := filepath.Join(source, "sig-test")
+	if err := os.MkdirAll(channelDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each file holds a message posted on 2024-01-10, a day its name
+	// doesn't give, and one without a timestamp
+	posted := time.Date(2024, time.January, 10, 10, 0, 0, 0, time.UTC)
+	files := []struct {
+		name string
+		// undated is the date the message without a timestamp takes;
+		// zero when it is skipped
+		undated time.Time
+	}{
+		{"2023-05-15-2.json", time.Date(2023, time.May, 15, 0, 0, 0, 0, time.UTC)},
+		{"2023-05-16_part1.json", time.Date(2023, time.May, 16, 0, 0, 0, 0, time.UTC)},
+		{"2024-03-04 (1).json", time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)},
+		{"channel_canvas.json", time.Time{}},
+		{"notes.json", time.Time{}},
+	}
+
+	idx, err := NewIndexer(source, "sig-test", WithDataDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to create indexer: %v", err)
+	}
+	defer idx.Close()
+
+	skipped := 0
+	for i, file := range files {
+		// Timestamps are unique across the files
+		ts := fmt.Sprintf("%d.%06d", posted.Unix(), i+1)
+		content := `[
+			{"type": "message", "user": "U1", "text": "timestamped message", "ts": "` + ts + `"},
+			{"type": "message", "user": "U1", "text": "message without a timestamp"}
+		]`
+		path := filepath.Join(channelDir, file.name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		want := 2
+		if file.undated.IsZero() {
+			want = 1
+			skipped++
+		}
+		count, err := idx.indexFile(path, file.name)
+		if err != nil {
+			t.Fatalf("%s failed to index: %v", file.name, err)
+		}
+		if count != want {
+			t.Errorf("%s: indexed %d message(s), want %d", file.name, count, want)
+		}
+
+		message, err := idx.db.GetMessageByTimestamp(ctx, ts)
+		if err != nil {
+			t.Fatalf("%s: timestamped message not stored: %v", file.name, err)
+		}
+		if !message.Date.Truncate(time.Second).Equal(posted) {
+			t.Errorf("%s: timestamped message dated %v, want %v", file.name, message.Date.UTC(), posted)
+		}
+	}
+
+	// Messages without a timestamp take the day from dated names only
+	messages, err := idx.db.GetMessagesInRange(ctx, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	undated := make(map[string]time.Time)
+	for _, message := range messages {
+		if message.Timestamp == "" {
+			undated[message.Filename] = message.Date.UTC()
+		}
+	}
+	for _, file := range files {
+		got, ok := undated[file.name]
+		if file.undated.IsZero() {
+			if ok {
+				t.Errorf("%s: message without a timestamp stored, dated %v", file.name, got)
+			}
+			continue
+		}
+		if !ok || !got.Equal(file.undated) {
+			t.Errorf("%s: message without a timestamp dated %v, want %v", file.name, got, file.undated)
+		}
+	}
+
+	report, err := idx.db.GetSkipReport(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Reasons) != 1 || report.Reasons[0].Reason != SkipNoDate || report.Reasons[0].Count != skipped {
+		t.Errorf("skip report = %+v, want %d message(s) skipped with %q", report.Reasons, skipped, SkipNoDate)
+	}
+}
+
+// BenchmarkProcessMessageFile indexes one busy day of 50,000 generated
+// messages, about 11 MB. Each run is rolled back, so every run indexes
+// into an empty database.
+//
+// Recorded figures per run:
+//
+//	decoding the whole file:            1.6-2.1 s  152.5 MB  2.37M allocs
+//	decoding one message at a time:                 99.2 MB  1.50M allocs
+//	plus identifiers, versions and
+//	error fingerprints:                 3.7-4.0 s    197 MB  3.34M allocs
+//
+// The slowdown is accepted: about a third of each run is matching the
+// identifier, version and error patterns, and most of the rest is the
+// larger full-text index row, identifiers column included.
+func BenchmarkProcessMessageFile(b *testing.B) {
+	source := b.TempDir()
+	_, err := fixture.Generate(source, fixture.Options{
+		Channel:     "sig-bench",
+		Users:       200,
+		Days:        1,
+		Messages:    50000,
+		ThreadRatio: 0.3,
+		Start:       time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Seed:        1,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	filename := "2024-01-01.json"
+	path := filepath.Join(source, "sig-bench", filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	idx, err := NewIndexer(source, "sig-bench", WithDataDir(b.TempDir()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer idx.Close()
+
+	b.SetBytes(info.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if err := idx.db.Begin(); err != nil {
+			b.Fatal(err)
+		}
+		idx.knownUsers = make(map[string]bool)
+		idx.profiles = make(map[models.UserProfile]*models.UserProfile)
+		idx.skips = newFileSkips()
+		b.StartTimer()
+
+		count, err := idx.processMessageFile(path, filename)
+
+		b.StopTimer()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if count != 50000 {
+			b.Fatalf("indexed %d messages, want 50000", count)
+		}
+		if err := idx.db.Rollback(); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
@@ -0,0 +1,354 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldMap says which fields of a generic message dump hold each part of a
+// message. For NDJSON, fields are keys of each line's object, with dots
+// reaching into nested objects ("author.login"); for CSV they are column
+// names from the header row.
+type FieldMap struct {
+	// User identifies the author; required
+	User string `yaml:"user"`
+	// UserName is the author's display name, if the dump has one
+	UserName string `yaml:"user_name"`
+	// Text is the message body; required
+	Text string `yaml:"text"`
+	// Timestamp is when the message was posted; required
+	Timestamp string `yaml:"timestamp"`
+	// TimestampFormat is unix, unix_ms, or a Go time layout. Left empty,
+	// numbers are read as Unix seconds (or milliseconds when large enough)
+	// and strings as RFC 3339 or "2006-01-02 15:04:05".
+	TimestampFormat string `yaml:"timestamp_format"`
+	// ID is the message's own identifier, which Thread values may refer to
+	ID string `yaml:"id"`
+	// Thread groups messages into threads. The message whose ID equals the
+	// value starts the thread, or else the earliest message with the value.
+	Thread string `yaml:"thread"`
+}
+
+// Timestamp formats for FieldMap.TimestampFormat
+const (
+	TimestampUnix   = "unix"
+	TimestampUnixMS = "unix_ms"
+)
+
+// LoadFieldMap reads a field map from a YAML file
+func LoadFieldMap(path string) (*FieldMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field map: %w", err)
+	}
+
+	fields := &FieldMap{}
+	if err := yaml.Unmarshal(data, fields); err != nil {
+		return nil, fmt.Errorf("failed to parse field map %s: %w", path, err)
+	}
+
+	var missing []string
+	for name, value := range map[string]string{"user": fields.User, "text": fields.Text, "timestamp": fields.Timestamp} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("field map %s must set %s", path, strings.Join(missing, ", "))
+	}
+
+	return fields, nil
+}
+
+// record reads a named field of one message in a dump
+type record func(field string) string
+
+// parseNDJSON reads a file with one JSON object per line
+func parseNDJSON(path string, fields *FieldMap) (*conversation, error) {
+	if fields == nil {
+		return nil, errors.New("a field map is required")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	builder := newRecordBuilder(path, fields)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			builder.conv.malformed++
+			continue
+		}
+		builder.add(func(field string) string {
+			return jsonField(object, field)
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return builder.finish(), nil
+}
+
+// parseCSV reads a CSV file with a header row naming its columns
+func parseCSV(path string, fields *FieldMap) (*conversation, error) {
+	if fields == nil {
+		return nil, errors.New("a field map is required")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header row: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, field := range []string{fields.User, fields.Text, fields.Timestamp} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("no %q column in header", field)
+		}
+	}
+
+	builder := newRecordBuilder(path, fields)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				builder.conv.malformed++
+				continue
+			}
+			return nil, err
+		}
+		builder.add(func(field string) string {
+			if i, ok := columns[field]; ok && field != "" && i < len(row) {
+				return row[i]
+			}
+			return ""
+		})
+	}
+
+	return builder.finish(), nil
+}
+
+// jsonField follows a dotted path through nested objects and renders the
+// value found as text
+func jsonField(object map[string]interface{}, field string) string {
+	if field == "" {
+		return ""
+	}
+
+	var value interface{} = object
+	for _, key := range strings.Split(field, ".") {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = nested[key]
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// recordBuilder turns mapped records into a conversation
+type recordBuilder struct {
+	fields  *FieldMap
+	conv    *conversation
+	users   map[string]bool
+	used    map[string]bool
+	ids     map[string]*models.Message
+	threads map[*models.Message]string
+}
+
+func newRecordBuilder(path string, fields *FieldMap) *recordBuilder {
+	return &recordBuilder{
+		fields:  fields,
+		conv:    newConversation(path),
+		users:   make(map[string]bool),
+		used:    make(map[string]bool),
+		ids:     make(map[string]*models.Message),
+		threads: make(map[*models.Message]string),
+	}
+}
+
+// add maps one record onto a message, counting it as malformed when its
+// timestamp can't be read
+func (b *recordBuilder) add(get record) {
+	date, err := parseRecordTime(get(b.fields.Timestamp), b.fields.TimestampFormat)
+	if err != nil {
+		b.conv.malformed++
+		return
+	}
+
+	// Timestamps identify messages, so nudge duplicates apart
+	ts := formatTimestamp(date)
+	for b.used[ts] {
+		date = date.Add(time.Microsecond)
+		ts = formatTimestamp(date)
+	}
+	b.used[ts] = true
+
+	user := strings.TrimSpace(get(b.fields.User))
+	if user != "" && !b.users[user] {
+		b.users[user] = true
+		name := strings.TrimSpace(get(b.fields.UserName))
+		b.conv.users = append(b.conv.users, &models.User{
+			ID:          user,
+			Name:        user,
+			RealName:    name,
+			DisplayName: name,
+		})
+	}
+
+	text := get(b.fields.Text)
+	message := &models.Message{
+		UserID:    user,
+		Text:      text,
+		Type:      "message",
+		Timestamp: ts,
+		Date:      date,
+		HasLink:   hasURL(text),
+		HasCode:   strings.Contains(text, "`"),
+	}
+	b.conv.messages = append(b.conv.messages, message)
+
+	if id := get(b.fields.ID); id != "" {
+		b.ids[id] = message
+	}
+	if thread := get(b.fields.Thread); thread != "" {
+		b.threads[message] = thread
+	}
+}
+
+// finish links messages sharing a thread value to the thread's starter
+func (b *recordBuilder) finish() *conversation {
+	sort.SliceStable(b.conv.messages, func(i, j int) bool {
+		return b.conv.messages[i].Date.Before(b.conv.messages[j].Date)
+	})
+
+	starters := make(map[string]*models.Message)
+	for _, message := range b.conv.messages {
+		thread, ok := b.threads[message]
+		if !ok {
+			continue
+		}
+		starter, ok := starters[thread]
+		if !ok {
+			// The message the thread value names starts it, when present
+			if named, found := b.ids[thread]; found {
+				starter = named
+			} else {
+				starter = message
+			}
+			starters[thread] = starter
+		}
+		if message != starter {
+			message.ThreadTS = starter.Timestamp
+		}
+	}
+
+	linkThreads(b.conv.messages)
+	return b.conv
+}
+
+// recordTimeLayouts are tried in order for string timestamps without a
+// configured format
+var recordTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// parseRecordTime reads a timestamp in the given format, or guesses it
+func parseRecordTime(value, format string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("no timestamp")
+	}
+
+	switch format {
+	case TimestampUnix, TimestampUnixMS:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if format == TimestampUnixMS {
+			number /= 1000
+		}
+		return unixTime(number), nil
+	case "":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			// Seconds since the epoch stay below 1e12 until the year 33658
+			if number >= 1e12 {
+				number /= 1000
+			}
+			return unixTime(number), nil
+		}
+		for _, layout := range recordTimeLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognised timestamp %q", value)
+	default:
+		return time.Parse(format, value)
+	}
+}
+
+// unixTime converts fractional seconds since the epoch, to the microsecond
+func unixTime(seconds float64) time.Time {
+	micros := int64(seconds*1e6 + 0.5)
+	return time.UnixMicro(micros)
+}
@@ -0,0 +1,182 @@
+package indexer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONField(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`{
+		"id": 42, "body": "hello", "draft": false, "big": 1700000000123,
+		"author": {"login": "alice", "profile": {"name": "Alice Example"}},
+		"labels": ["bug", "sig/node"]
+	}`))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"body", "hello"},
+		{"id", "42"},
+		// Large numbers are kept exactly rather than in float notation
+		{"big", "1700000000123"},
+		{"draft", "false"},
+		{"author.login", "alice"},
+		{"author.profile.name", "Alice Example"},
+		{"labels", `["bug","sig/node"]`},
+		{"author", `{"login":"alice","profile":{"name":"Alice Example"}}`},
+		{"missing", ""},
+		{"author.missing", ""},
+		{"body.length", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if got := jsonField(object, tt.field); got != tt.want {
+				t.Errorf("jsonField(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRecordTime(t *testing.T) {
+	want := time.Date(2023, time.November, 14, 22, 13, 20, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		format string
+		want   time.Time
+	}{
+		{"1700000000", "", want},
+		{"1700000000.5", "", want.Add(500 * time.Millisecond)},
+		// Large enough to be milliseconds
+		{"1700000000000", "", want},
+		{"2023-11-14T22:13:20Z", "", want},
+		{"2023-11-14T22:13:20.000001Z", "", want.Add(time.Microsecond)},
+		{"2023-11-14 22:13:20", "", want},
+		{"1700000000", TimestampUnix, want},
+		{"1700000000123", TimestampUnixMS, want.Add(123 * time.Millisecond)},
+		{"14/11/2023 22:13", "02/01/2006 15:04", want.Add(-20 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRecordTime(tt.value, tt.format)
+			if err != nil {
+				t.Fatalf("parseRecordTime(%q, %q) failed: %v", tt.value, tt.format, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseRecordTime(%q, %q) = %v, want %v", tt.value, tt.format, got, tt.want)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "  ", "yesterday", "2023-11-14"} {
+		if got, err := parseRecordTime(value, ""); err == nil {
+			t.Errorf("parseRecordTime(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	fields := &FieldMap{User: "author.login", UserName: "author.name", Text: "body",
+		Timestamp: "created", ID: "id", Thread: "parent"}
+	path := writeExport(t, "issues", "comments.ndjson", strings.Join([]string{
+		`{"id": "c2", "parent": "c1", "author": {"login": "bob"}, "body": "same here", "created": 1700000060}`,
+		`{"id": "c1", "author": {"login": "alice", "name": "Alice Example"}, "body": "kubelet crash", "created": 1700000000}`,
+		``,
+		`not json`,
+		`{"id": "c3", "author": {"login": "carol"}, "body": "no timestamp"}`,
+		// Posted in the same second as c1
+		`{"id": "c4", "author": {"login": "alice"}, "body": "see https://k8s.io", "created": 1700000000}`,
+	}, "\n"))
+
+	conv, err := parseNDJSON(path, fields)
+	if err != nil {
+		t.Fatalf("parseNDJSON failed: %v", err)
+	}
+	if conv.malformed != 2 {
+		t.Errorf("counted %d malformed record(s), want 2", conv.malformed)
+	}
+	if len(conv.users) != 2 || conv.users[1].ID != "alice" || conv.users[1].RealName != "Alice Example" {
+		t.Errorf("users = %+v, want bob and alice with her name", conv.users)
+	}
+	if len(conv.messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(conv.messages))
+	}
+
+	starter, same, reply := conv.messages[0], conv.messages[1], conv.messages[2]
+	if starter.Text != "kubelet crash" || starter.Timestamp != "1700000000.000000" {
+		t.Errorf("first message = %q at %s, want the thread starter", starter.Text, starter.Timestamp)
+	}
+	if same.Timestamp != "1700000000.000001" || !same.HasLink {
+		t.Errorf("second message at %s with link %v, want its timestamp nudged and a link", same.Timestamp, same.HasLink)
+	}
+	if reply.ThreadTS != starter.Timestamp || starter.ReplyCount != 1 {
+		t.Errorf("reply in thread %q, starter has %d replies; want the reply linked to c1", reply.ThreadTS, starter.ReplyCount)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	fields := &FieldMap{User: "user", Text: "text", Timestamp: "time", Thread: "thread"}
+	path := writeExport(t, "forum", "posts.csv", "user, text ,time,thread\n"+
+		"alice,\"first post, with a comma\",2023-11-14 22:13:20,t1\n"+
+		"bob,reply,2023-11-14 22:14:00,t1\n"+
+		"carol,short row\n"+
+		"dave,standalone,2023-11-14 22:15:00,\n")
+
+	conv, err := parseCSV(path, fields)
+	if err != nil {
+		t.Fatalf("parseCSV failed: %v", err)
+	}
+	if conv.malformed != 1 {
+		t.Errorf("counted %d malformed record(s), want 1", conv.malformed)
+	}
+	if len(conv.messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(conv.messages))
+	}
+	first, reply, standalone := conv.messages[0], conv.messages[1], conv.messages[2]
+	// Without an ID field, the earliest message with a thread value starts it
+	if first.Text != "first post, with a comma" || reply.ThreadTS != first.Timestamp || standalone.ThreadTS != "" {
+		t.Errorf("messages = %+v, want the reply threaded under the first post", conv.messages)
+	}
+
+	missing := &FieldMap{User: "author", Text: "text", Timestamp: "time"}
+	if _, err := parseCSV(path, missing); err == nil || !strings.Contains(err.Error(), "author") {
+		t.Errorf("parseCSV with a missing column returned %v, want an error naming it", err)
+	}
+}
+
+func TestLoadFieldMap(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	fields, err := LoadFieldMap(write("full.yaml", "user: author.login\ntext: body\ntimestamp: created\ntimestamp_format: unix_ms\n"))
+	if err != nil {
+		t.Fatalf("LoadFieldMap failed: %v", err)
+	}
+	if fields.User != "author.login" || fields.TimestampFormat != TimestampUnixMS {
+		t.Errorf("fields = %+v", fields)
+	}
+
+	_, err = LoadFieldMap(write("partial.yaml", "text: body\n"))
+	if err == nil || !strings.Contains(err.Error(), "must set timestamp, user") {
+		t.Errorf("LoadFieldMap of a partial map returned %v, want the missing fields named", err)
+	}
+}
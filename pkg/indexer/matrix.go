@@ -0,0 +1,217 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// matrixEvent is a room event as written by Element's JSON room export or
+// returned by the client-server API
+type matrixEvent struct {
+	Type           string        `json:"type"`
+	EventID        string        `json:"event_id"`
+	Sender         string        `json:"sender"`
+	RoomID         string        `json:"room_id"`
+	OriginServerTS int64         `json:"origin_server_ts"`
+	StateKey       *string       `json:"state_key"`
+	Content        matrixContent `json:"content"`
+}
+
+type matrixContent struct {
+	MsgType     string `json:"msgtype"`
+	Body        string `json:"body"`
+	DisplayName string `json:"displayname"`
+	Name        string `json:"name"`
+	Topic       string `json:"topic"`
+	Creator     string `json:"creator"`
+	RelatesTo   *struct {
+		RelType   string `json:"rel_type"`
+		EventID   string `json:"event_id"`
+		InReplyTo *struct {
+			EventID string `json:"event_id"`
+		} `json:"m.in_reply_to"`
+	} `json:"m.relates_to"`
+	NewContent *struct {
+		Body string `json:"body"`
+	} `json:"m.new_content"`
+}
+
+// matrixExport is Element's room export; API responses list events under
+// chunk instead
+type matrixExport struct {
+	RoomName string        `json:"room_name"`
+	Topic    string        `json:"topic"`
+	Creator  string        `json:"room_creator"`
+	Messages []matrixEvent `json:"messages"`
+	Chunk    []matrixEvent `json:"chunk"`
+}
+
+// parseMatrix reads an exported Matrix room: Element's JSON export, an API
+// response with a chunk of events, or a bare list of events. Messages in a
+// thread (m.thread relations) become replies to the thread root; edits
+// replace the text of the message they edit and reactions are counted on
+// the message they annotate.
+func parseMatrix(path string, _ *FieldMap) (*conversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export matrixExport
+	if err := json.Unmarshal(data, &export.Messages); err != nil {
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, fmt.Errorf("expected a room export or a list of events: %w", err)
+		}
+	}
+	events := append(export.Messages, export.Chunk...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].OriginServerTS < events[j].OriginServerTS
+	})
+
+	conv := newConversation(path)
+	if export.RoomName != "" {
+		conv.channel.Name = export.RoomName
+	}
+	conv.channel.Topic = export.Topic
+	conv.channel.Creator = export.Creator
+
+	// Room state, edits and reactions apply to other events, so gather
+	// them before building messages
+	displayNames := make(map[string]string)
+	edits := make(map[string]string)
+	reactions := make(map[string]int)
+	for _, event := range events {
+		if event.RoomID != "" {
+			conv.channel.ID = event.RoomID
+		}
+		relation := event.Content.RelatesTo
+
+		switch event.Type {
+		case "m.room.member":
+			if event.StateKey != nil && event.Content.DisplayName != "" {
+				displayNames[*event.StateKey] = event.Content.DisplayName
+			}
+		case "m.room.name":
+			if event.Content.Name != "" {
+				conv.channel.Name = event.Content.Name
+			}
+		case "m.room.topic":
+			conv.channel.Topic = event.Content.Topic
+		case "m.room.create":
+			conv.channel.Created = event.OriginServerTS / 1000
+			if event.Content.Creator != "" {
+				conv.channel.Creator = event.Content.Creator
+			} else {
+				conv.channel.Creator = event.Sender
+			}
+		case "m.reaction":
+			if relation != nil && relation.RelType == "m.annotation" {
+				reactions[relation.EventID]++
+			}
+		case "m.room.message":
+			if relation != nil && relation.RelType == "m.replace" && event.Content.NewContent != nil {
+				edits[relation.EventID] = event.Content.NewContent.Body
+			}
+		}
+	}
+
+	users := make(map[string]bool)
+	timestamps := make(map[string]string)
+	for _, event := range events {
+		if event.Type != "m.room.message" {
+			continue
+		}
+		relation := event.Content.RelatesTo
+		if relation != nil && relation.RelType == "m.replace" {
+			continue
+		}
+
+		if event.Sender != "" && !users[event.Sender] {
+			users[event.Sender] = true
+			conv.users = append(conv.users, matrixUser(event.Sender, displayNames[event.Sender]))
+		}
+
+		date := time.UnixMilli(event.OriginServerTS)
+		ts := formatTimestamp(date)
+		timestamps[event.EventID] = ts
+
+		body := event.Content.Body
+		if edited, ok := edits[event.EventID]; ok {
+			body = edited
+		}
+		if relation != nil && relation.InReplyTo != nil {
+			body = stripReplyFallback(body)
+		}
+
+		message := &models.Message{
+			UserID:        event.Sender,
+			Text:          body,
+			Type:          "message",
+			Subtype:       strings.TrimPrefix(event.Content.MsgType, "m."),
+			Timestamp:     ts,
+			Date:          date,
+			ReactionCount: reactions[event.EventID],
+			HasLink:       hasURL(body),
+			HasCode:       strings.Contains(body, "`"),
+		}
+		switch event.Content.MsgType {
+		case "m.text", "m.notice":
+			message.Subtype = ""
+		case "m.emote":
+			message.Text = "* " + matrixLocalpart(event.Sender) + " " + body
+		case "m.image", "m.file", "m.video", "m.audio":
+			message.Text = "[file: " + body + "]"
+			message.HasFile = true
+		}
+		if relation != nil && relation.RelType == "m.thread" {
+			// Roots appear before their replies, so their timestamps are
+			// known; linkThreads drops replies to roots missing from the file
+			message.ThreadTS = timestamps[relation.EventID]
+		}
+
+		conv.messages = append(conv.messages, message)
+	}
+
+	linkThreads(conv.messages)
+	return conv, nil
+}
+
+// matrixUser describes a Matrix user, named by the localpart of their ID
+func matrixUser(id, displayName string) *models.User {
+	name := matrixLocalpart(id)
+	return &models.User{
+		ID:          id,
+		Name:        name,
+		RealName:    displayName,
+		DisplayName: displayName,
+	}
+}
+
+// matrixLocalpart returns "alice" for "@alice:example.org"
+func matrixLocalpart(id string) string {
+	name := strings.TrimPrefix(id, "@")
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// stripReplyFallback removes the quoted copy of the replied-to message that
+// clients put at the start of a reply's body
+func stripReplyFallback(body string) string {
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], "> ") {
+		i++
+	}
+	if i == 0 {
+		return body
+	}
+	return strings.TrimLeft(strings.Join(lines[i:], "\n"), "\n")
+}
@@ -0,0 +1,124 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestParseMatrix(t *testing.T) {
+	// An Element room export with a thread, an edit, a reaction, a reply
+	// quoting its parent, an emote and an image
+	path := writeExport(t, "import", "room.json", `{
+		"room_name": "Kubernetes Users",
+		"topic": "Ask questions here",
+		"messages": [
+			{"type": "m.room.create", "sender": "@admin:k8s.io", "room_id": "!room:k8s.io",
+			 "origin_server_ts": 1700000000000, "state_key": "", "content": {}},
+			{"type": "m.room.member", "sender": "@alice:k8s.io", "origin_server_ts": 1700000001000,
+			 "state_key": "@alice:k8s.io", "content": {"displayname": "Alice Example"}},
+			{"type": "m.room.message", "event_id": "$root", "sender": "@alice:k8s.io",
+			 "origin_server_ts": 1700000002123, "content": {"msgtype": "m.text", "body": "kubelet won't start"}},
+			{"type": "m.room.message", "event_id": "$edit", "sender": "@alice:k8s.io",
+			 "origin_server_ts": 1700000003000, "content": {"msgtype": "m.text", "body": "* kubelet won't start on 1.29",
+			 "m.new_content": {"body": "kubelet won't start on 1.29"},
+			 "m.relates_to": {"rel_type": "m.replace", "event_id": "$root"}}},
+			{"type": "m.reaction", "sender": "@bob:k8s.io", "origin_server_ts": 1700000004000,
+			 "content": {"m.relates_to": {"rel_type": "m.annotation", "event_id": "$root"}}},
+			{"type": "m.room.message", "event_id": "$thread", "sender": "@bob:k8s.io",
+			 "origin_server_ts": 1700000005000, "content": {"msgtype": "m.text", "body": "check the logs",
+			 "m.relates_to": {"rel_type": "m.thread", "event_id": "$root"}}},
+			{"type": "m.room.message", "event_id": "$reply", "sender": "@bob:k8s.io",
+			 "origin_server_ts": 1700000006000, "content": {"msgtype": "m.text",
+			 "body": "> <@alice:k8s.io> kubelet won't start\n\nwhich runtime?",
+			 "m.relates_to": {"m.in_reply_to": {"event_id": "$root"}}}},
+			{"type": "m.room.message", "event_id": "$emote", "sender": "@bob:k8s.io",
+			 "origin_server_ts": 1700000007000, "content": {"msgtype": "m.emote", "body": "waves"}},
+			{"type": "m.room.message", "event_id": "$image", "sender": "@alice:k8s.io",
+			 "origin_server_ts": 1700000008000, "content": {"msgtype": "m.image", "body": "error.png"}}
+		]
+	}`)
+
+	conv, err := parseMatrix(path, nil)
+	if err != nil {
+		t.Fatalf("parseMatrix failed: %v", err)
+	}
+
+	channel := conv.channel
+	if channel.ID != "!room:k8s.io" || channel.Name != "Kubernetes Users" || channel.Topic != "Ask questions here" {
+		t.Errorf("channel = %+v, want the room's ID, name and topic", channel)
+	}
+	if channel.Creator != "@admin:k8s.io" || channel.Created != 1700000000 {
+		t.Errorf("channel created by %s at %d, want @admin:k8s.io at 1700000000", channel.Creator, channel.Created)
+	}
+	if len(conv.users) != 2 || conv.users[0].Name != "alice" || conv.users[0].DisplayName != "Alice Example" {
+		t.Errorf("users = %+v, want alice with her display name, then bob", conv.users)
+	}
+	if len(conv.messages) != 5 {
+		t.Fatalf("got %d messages, want 5: the edit is not a message of its own", len(conv.messages))
+	}
+
+	root, thread, reply, emote, image := conv.messages[0], conv.messages[1], conv.messages[2], conv.messages[3], conv.messages[4]
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"root timestamp", root.Timestamp, "1700000002.123000"},
+		{"root text", root.Text, "kubelet won't start on 1.29"},
+		{"root reactions", root.ReactionCount, 1},
+		{"root thread", root.ThreadTS, root.Timestamp},
+		{"root replies", root.ReplyCount, 1},
+		{"root subtype", root.Subtype, ""},
+		{"thread reply thread", thread.ThreadTS, root.Timestamp},
+		{"thread reply user", thread.UserID, "@bob:k8s.io"},
+		// Replies outside threads stay top level, without their quote
+		{"reply text", reply.Text, "which runtime?"},
+		{"reply thread", reply.ThreadTS, ""},
+		{"emote text", emote.Text, "* bob waves"},
+		{"emote subtype", emote.Subtype, "emote"},
+		{"image text", image.Text, "[file: error.png]"},
+		{"image has file", image.HasFile, true},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestParseMatrixEvents(t *testing.T) {
+	// A bare list of events, and an API response's chunk
+	for name, data := range map[string]string{
+		"list":  `[{"type": "m.room.message", "event_id": "$a", "sender": "@alice:k8s.io", "origin_server_ts": 1700000000000, "content": {"msgtype": "m.text", "body": "hello"}}]`,
+		"chunk": `{"chunk": [{"type": "m.room.message", "event_id": "$a", "sender": "@alice:k8s.io", "origin_server_ts": 1700000000000, "content": {"msgtype": "m.text", "body": "hello"}}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			conv, err := parseMatrix(writeExport(t, "sig-auth", "room.json", data), nil)
+			if err != nil {
+				t.Fatalf("parseMatrix failed: %v", err)
+			}
+			if conv.channel.Name != "sig-auth" {
+				t.Errorf("channel = %s, want the directory name", conv.channel.Name)
+			}
+			if len(conv.messages) != 1 || conv.messages[0].Text != "hello" {
+				t.Errorf("messages = %+v, want hello", conv.messages)
+			}
+		})
+	}
+}
+
+func TestStripReplyFallback(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"> <@alice:k8s.io> question\n\nanswer", "answer"},
+		{"> <@alice:k8s.io> line one\n> line two\n\nanswer\n> quoted later", "answer\n> quoted later"},
+		{"no quote", "no quote"},
+	}
+
+	for _, tt := range tests {
+		if got := stripReplyFallback(tt.body); got != tt.want {
+			t.Errorf("stripReplyFallback(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/mediastore"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// CatalogMedia copies the files under mediaDir into the media store at
+// storeDir and catalogs them, linking each to the messages that shared
+// it. It returns the number of files cataloged and how many of them were
+// linked to a message.
+func (idx *Indexer) CatalogMedia(ctx context.Context, mediaDir, storeDir string) (int, int, error) {
+	files, err := mediastore.Scan(mediaDir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	store := mediastore.New(storeDir)
+	cataloged, linked := 0, 0
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return cataloged, linked, ErrInterrupted
+		}
+
+		hash, stored, err := store.Put(file.Path)
+		if err != nil {
+			return cataloged, linked, err
+		}
+		ok, err := idx.db.CatalogMediaFile(ctx, models.MediaFile{
+			SHA256:     hash,
+			Name:       file.Name,
+			FileID:     file.FileID,
+			Mimetype:   file.Mimetype,
+			Size:       file.Size,
+			StoredPath: stored,
+		})
+		if err != nil {
+			return cataloged, linked, err
+		}
+		cataloged++
+		if ok {
+			linked++
+		}
+	}
+	return cataloged, linked, nil
+}
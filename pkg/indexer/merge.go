@@ -0,0 +1,50 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+)
+
+// Merge combines the database files at srcPaths into the database
+// channelName, creating it if needed, and rebuilds its search index.
+// Messages are de-duplicated by Slack timestamp, so overlapping exports of
+// the same channel can be merged. It returns the number of messages added
+// from each source.
+func Merge(ctx context.Context, channelName string, srcPaths []string, opts ...Option) ([]int, error) {
+	idx := &Indexer{logger: discardLogger{}}
+	for _, opt := range opts {
+		opt(idx)
+	}
+
+	dst, err := database.NewDBInDir(idx.dataDir, channelName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database: %w", err)
+	}
+	defer dst.Close()
+
+	var added []int
+	for _, path := range srcPaths {
+		// Opening the source first brings older schemas up to date
+		src, err := database.NewDBAtPath(path)
+		if err != nil {
+			return added, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		count, err := dst.Merge(ctx, src)
+		src.Close()
+		if err != nil {
+			return added, err
+		}
+		idx.logger.Printf("Merged %s: %d new message(s)\n", path, count)
+		added = append(added, count)
+	}
+
+	idx.logger.Printf("Rebuilding search index\n")
+	if err := dst.RebuildFTS(ctx); err != nil {
+		return added, err
+	}
+
+	return added, nil
+}
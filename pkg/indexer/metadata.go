@@ -0,0 +1,162 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// SourceMetadata is what a Slack export says about its users,
+// conversations and integrations, parsed once so that several channels can
+// be indexed from the export in parallel without each reading it again.
+// It is read-only once loaded and safe to share between indexers.
+type SourceMetadata struct {
+	users         []*models.User
+	conversations []*models.Channel
+	bots          map[string]*models.Bot
+	appServices   map[string]string
+}
+
+// LoadSourceMetadata parses an export's users.json and conversation
+// metadata files, and its integration_logs.json when bot messages are to be
+// indexed
+func LoadSourceMetadata(sourceDir string, includeBots bool) (*SourceMetadata, error) {
+	users, err := readUsers(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+
+	conversations, err := readConversations(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load channels: %w", err)
+	}
+
+	metadata := &SourceMetadata{
+		users:         users,
+		conversations: conversations,
+		bots:          make(map[string]*models.Bot),
+		appServices:   make(map[string]string),
+	}
+	if includeBots {
+		if metadata.bots, metadata.appServices, err = readIntegrationLogs(sourceDir); err != nil {
+			return nil, fmt.Errorf("failed to load integration logs: %w", err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// Users returns the number of users in the export
+func (m *SourceMetadata) Users() int {
+	return len(m.users)
+}
+
+// ConversationKind returns the kind of the conversation stored in a channel
+// directory, as the package-level ConversationKind does
+func (m *SourceMetadata) ConversationKind(channelName string) string {
+	for _, conversation := range m.conversations {
+		if conversation.Name == channelName || conversation.ID == channelName {
+			return conversation.Kind
+		}
+	}
+	return models.KindChannel
+}
+
+// WithSourceMetadata indexes a Slack export using metadata parsed by
+// LoadSourceMetadata instead of reading it from the source directory. The
+// metadata must have been loaded with bots included if WithBots is set.
+func WithSourceMetadata(metadata *SourceMetadata) Option {
+	return func(idx *Indexer) {
+		idx.metadata = metadata
+	}
+}
+
+// WithWorkspaceMeta keeps the export's users and channels in the shared
+// workspace-meta.db beside the workspace's channel databases instead of in
+// each of them. The channel database keeps only its own channel and the
+// users who posted in it, which is enough to search it. When metadata comes
+// from WithSourceMetadata, store it with StoreWorkspaceMeta first.
+func WithWorkspaceMeta(shared bool) Option {
+	return func(idx *Indexer) {
+		idx.workspaceMeta = shared
+	}
+}
+
+// StoreWorkspaceMeta writes an export's users and conversations to the
+// shared workspace-meta.db of the workspace the named database belongs to,
+// under dataDir
+func StoreWorkspaceMeta(dataDir, name string, metadata *SourceMetadata) error {
+	db, err := database.NewWorkspaceMetaDB(dataDir, name)
+	if err != nil {
+		return fmt.Errorf("failed to open workspace metadata database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.Begin(); err != nil {
+		return err
+	}
+	defer db.Rollback()
+
+	for _, user := range metadata.users {
+		if err := db.InsertUser(user); err != nil {
+			return fmt.Errorf("failed to insert user %s: %w", user.ID, err)
+		}
+	}
+	for _, channel := range metadata.conversations {
+		if err := db.InsertChannel(channel); err != nil {
+			return fmt.Errorf("failed to insert channel %s: %w", channel.ID, err)
+		}
+	}
+
+	return db.Commit()
+}
+
+// useWorkspaceMeta prepares a channel database whose workspace directory
+// is in workspace-meta.db: it keeps its own channel, without the member
+// list, and copies in users as their messages are indexed
+func (idx *Indexer) useWorkspaceMeta(ctx context.Context, metadata *SourceMetadata) error {
+	idx.logger.Printf("Keeping %d users and %d channels in %s\n",
+		len(metadata.users), len(metadata.conversations), storagepaths.New(idx.dataDir).MetaPath(idx.channelName))
+
+	idx.directory = make(map[string]*models.User, len(metadata.users))
+	for _, user := range metadata.users {
+		idx.directory[user.ID] = user
+	}
+
+	_, channelName := storagepaths.SplitName(idx.channelName)
+	for _, conversation := range metadata.conversations {
+		if conversation.Name != channelName && conversation.ID != channelName {
+			continue
+		}
+		channel := *conversation
+		channel.Members = nil
+		if err := idx.db.InsertChannel(&channel); err != nil {
+			return fmt.Errorf("failed to insert channel %s: %w", channel.ID, err)
+		}
+	}
+
+	return idx.db.MarkWorkspaceMeta(ctx)
+}
+
+// ChannelDirs returns the names of the conversation directories in a
+// source directory, sorted
+func ChannelDirs(sourceDir string) ([]string, error) {
+	entries, err := os.ReadDir(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source directory: %w", err)
+	}
+
+	var channels []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			channels = append(channels, entry.Name())
+		}
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
@@ -0,0 +1,116 @@
+package indexer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Reasons a message in the export is not indexed
+const (
+	SkipBotMessage = "bot message"
+	SkipNoUser     = "no user"
+	SkipEmptyText  = "empty text"
+	SkipMalformed  = "malformed JSON"
+	// SkipUnsupportedSubtype is a message with a subtype, such as an edit
+	// or deletion event, with no author or text to index
+	SkipUnsupportedSubtype = "unsupported subtype"
+	// SkipNoDate is a message whose timestamp can't be read in a file
+	// whose name gives no date
+	SkipNoDate = "no date"
+)
+
+// largestFilesShown is how many of the biggest files are kept in Metrics
+const largestFilesShown = 5
+
+// FileStat describes a single indexed message file
+type FileStat struct {
+	Name     string
+	Size     int64
+	Messages int
+	Duration time.Duration
+}
+
+// Metrics records where time went during an ingest and what was skipped
+type Metrics struct {
+	Started   time.Time
+	Elapsed   time.Duration
+	ParseTime time.Duration
+	WriteTime time.Duration
+	Inserted  int
+	Skipped   map[string]int
+	Largest   []FileStat
+	// PlaceholderUsers counts the messages indexed from each author
+	// missing from the export's user list
+	PlaceholderUsers map[string]int
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		Started:          time.Now(),
+		Skipped:          make(map[string]int),
+		PlaceholderUsers: make(map[string]int),
+	}
+}
+
+// skip counts a message that was not indexed
+func (m *Metrics) skip(reason string) {
+	m.Skipped[reason]++
+}
+
+// addFile records a file, keeping only the largest few
+func (m *Metrics) addFile(stat FileStat) {
+	m.Largest = append(m.Largest, stat)
+	sort.SliceStable(m.Largest, func(i, j int) bool {
+		return m.Largest[i].Size > m.Largest[j].Size
+	})
+	if len(m.Largest) > largestFilesShown {
+		m.Largest = m.Largest[:largestFilesShown]
+	}
+}
+
+// RowsPerSecond returns the message insert rate over the whole ingest
+func (m *Metrics) RowsPerSecond() float64 {
+	if m.Elapsed <= 0 {
+		return 0
+	}
+	return float64(m.Inserted) / m.Elapsed.Seconds()
+}
+
+// Print writes a human-readable summary of the metrics
+func (m *Metrics) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nIngest metrics:\n")
+	fmt.Fprintf(w, "- Elapsed: %s\n", m.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "- Messages inserted: %d (%.0f rows/second)\n", m.Inserted, m.RowsPerSecond())
+	fmt.Fprintf(w, "- JSON parse time: %s\n", m.ParseTime.Round(time.Millisecond))
+	fmt.Fprintf(w, "- Database write time: %s\n", m.WriteTime.Round(time.Millisecond))
+
+	if len(m.Skipped) > 0 {
+		reasons := make([]string, 0, len(m.Skipped))
+		for reason := range m.Skipped {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		fmt.Fprintf(w, "- Messages skipped:\n")
+		for _, reason := range reasons {
+			fmt.Fprintf(w, "    %-21s %d\n", reason+":", m.Skipped[reason])
+		}
+	}
+
+	if len(m.PlaceholderUsers) > 0 {
+		messages := 0
+		for _, count := range m.PlaceholderUsers {
+			messages += count
+		}
+		fmt.Fprintf(w, "- Placeholder users added: %d (%d messages)\n", len(m.PlaceholderUsers), messages)
+	}
+
+	if len(m.Largest) > 0 {
+		fmt.Fprintf(w, "- Largest files:\n")
+		for _, file := range m.Largest {
+			fmt.Fprintf(w, "    %-15s %8.1f KB  %5d messages  %s\n", file.Name, float64(file.Size)/1024, file.Messages, file.Duration.Round(time.Millisecond))
+		}
+	}
+}
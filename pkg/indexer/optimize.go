@@ -0,0 +1,26 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+)
+
+// Optimize compacts the search index of the database channelName and
+// refreshes its statistics. Searches are faster afterwards, particularly
+// after many incremental ingests.
+func Optimize(ctx context.Context, channelName string, opts ...Option) error {
+	idx := &Indexer{logger: discardLogger{}}
+	for _, opt := range opts {
+		opt(idx)
+	}
+
+	db, err := database.NewDBInDir(idx.dataDir, channelName)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return db.Optimize(ctx)
+}
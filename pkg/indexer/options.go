@@ -0,0 +1,89 @@
+package indexer
+
+// Logger receives the indexer's progress messages. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// discardLogger drops all messages; it is the default so the package is
+// quiet when embedded
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
+
+// Option configures an Indexer
+type Option func(*Indexer)
+
+// WithDataDir stores the database under dir instead of the default
+// databases directory relative to the working directory
+func WithDataDir(dir string) Option {
+	return func(idx *Indexer) {
+		idx.dataDir = dir
+	}
+}
+
+// WithFormat reads the channel directory as an export from another chat
+// system (see Formats) instead of a Slack export. Unknown formats are
+// treated as Slack; check them with ValidFormat.
+func WithFormat(format string) Option {
+	return func(idx *Indexer) {
+		idx.format = format
+		idx.importer = importers[format]
+	}
+}
+
+// WithFieldMap sets how records are mapped onto messages for the ndjson
+// and csv formats
+func WithFieldMap(fields *FieldMap) Option {
+	return func(idx *Indexer) {
+		idx.fields = fields
+	}
+}
+
+// WithBots indexes messages posted by bots, apps and integrations, which
+// are skipped by default, attributing each to its bot
+func WithBots(include bool) Option {
+	return func(idx *Indexer) {
+		idx.includeBots = include
+	}
+}
+
+// WithRawJSON keeps the source JSON of each message of a Slack export in
+// the database, for the raw command
+func WithRawJSON(keep bool) Option {
+	return func(idx *Indexer) {
+		idx.keepRaw = keep
+	}
+}
+
+// WithTextCompression stores message text compressed with zstd, or plain
+// when compress is false, converting the text already in the database.
+// Without it the database keeps its current setting.
+func WithTextCompression(compress bool) Option {
+	return func(idx *Indexer) {
+		idx.setCompression = true
+		idx.compressText = compress
+	}
+}
+
+// WithLogger sends progress messages to logger
+func WithLogger(logger Logger) Option {
+	return func(idx *Indexer) {
+		idx.logger = logger
+	}
+}
+
+// WithProgressCallback calls fn after each message file is processed with
+// the number of files done (including files skipped because they were
+// already indexed or failed) and the total number of files. Use
+// SetProgressFunc for detailed events.
+func WithProgressCallback(fn func(done, total int)) Option {
+	return func(idx *Indexer) {
+		idx.SetProgressFunc(func(event ProgressEvent) {
+			switch event.Type {
+			case EventFileDone, EventFileSkipped, EventFileFailed:
+				fn(event.FilesDone, event.FilesTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package indexer
+
+// ProgressEventType identifies what happened in a ProgressEvent
+type ProgressEventType string
+
+// Progress event types, in the order they occur during an ingest
+const (
+	// EventStarted is sent once the message files have been counted
+	EventStarted ProgressEventType = "started"
+	// EventFileStarted is sent before a message file is indexed
+	EventFileStarted ProgressEventType = "file_started"
+	// EventFileDone is sent after a message file is committed
+	EventFileDone ProgressEventType = "file_done"
+	// EventFileSkipped is sent for files indexed by an earlier run
+	EventFileSkipped ProgressEventType = "file_skipped"
+	// EventFileFailed is sent when a message file could not be indexed;
+	// Err holds the reason and the ingest carries on with the next file
+	EventFileFailed ProgressEventType = "file_failed"
+	// EventFinished is sent when every file has been visited or the ingest
+	// was interrupted
+	EventFinished ProgressEventType = "finished"
+)
+
+// ProgressEvent describes a step of an ingest
+type ProgressEvent struct {
+	Type ProgressEventType
+	// File is the message file the event refers to, if any
+	File string
+	// FileMessages is the number of messages indexed from File
+	FileMessages int
+	// FilesDone counts files indexed, skipped or failed so far
+	FilesDone  int
+	FilesTotal int
+	// Messages is the total number of messages indexed so far
+	Messages int
+	Err      error
+}
+
+// SetProgressFunc registers fn to receive structured progress events while
+// IndexChannel runs. Events are delivered synchronously, so fn should return
+// quickly.
+func (idx *Indexer) SetProgressFunc(fn func(ProgressEvent)) {
+	idx.progress = fn
+}
+
+// emit sends a progress event, filling in the running totals
+func (idx *Indexer) emit(event ProgressEvent) {
+	if idx.progress == nil {
+		return
+	}
+
+	event.FilesDone = idx.processedFiles + idx.skippedFiles + idx.failedFiles
+	event.FilesTotal = idx.totalFiles
+	event.Messages = idx.metrics.Inserted
+	idx.progress(event)
+}
@@ -0,0 +1,50 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// storeRaw keeps a message's source JSON, compacted
+func (idx *Indexer) storeRaw(ts string, raw json.RawMessage) error {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return fmt.Errorf("failed to compact raw message: %w", err)
+	}
+	return idx.db.StoreRawMessage(ts, compact.Bytes())
+}
+
+// FindRawMessage returns the source JSON of the message with Slack
+// timestamp ts in a Slack export's daily message file, for messages
+// ingested without keeping it
+func FindRawMessage(path, ts string) (json.RawMessage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("failed to parse %s: expected an array of messages", path)
+	}
+
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		var msg struct {
+			TS string `json:"ts"`
+		}
+		// Elements that are not messages can't match
+		if json.Unmarshal(raw, &msg) == nil && msg.TS == ts {
+			return raw, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no message with timestamp %s in %s", ts, path)
+}
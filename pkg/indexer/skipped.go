@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// skipExamplesPerFile is how many examples of each reason for skipping a
+// message are recorded per export file
+const skipExamplesPerFile = 3
+
+// skipExcerptLength is the number of characters of a skipped message's
+// text recorded with it
+const skipExcerptLength = 200
+
+// fileSkips collects the messages skipped in the file being indexed,
+// recorded when the file is committed
+type fileSkips struct {
+	counts   map[models.SkipCount]int
+	examples []models.SkippedMessage
+	// shown counts the examples kept for each reason
+	shown map[string]int
+}
+
+func newFileSkips() *fileSkips {
+	return &fileSkips{
+		counts: make(map[models.SkipCount]int),
+		shown:  make(map[string]int),
+	}
+}
+
+// skipReason is the reason a message with the given subtype is skipped:
+// messages with a subtype that lack an author or text are events, such as
+// edits and deletions, that ingest doesn't index
+func skipReason(subtype, reason string) string {
+	if subtype != "" {
+		return SkipUnsupportedSubtype
+	}
+	return reason
+}
+
+// skip records a message that was not indexed, keeping its details as an
+// example of the reason if there are few yet
+func (idx *Indexer) skip(message models.SkippedMessage) {
+	idx.metrics.skip(message.Reason)
+	if idx.skips == nil {
+		return
+	}
+
+	key := models.SkipCount{Reason: message.Reason}
+	if message.Reason == SkipUnsupportedSubtype {
+		key.Subtype = message.Subtype
+	}
+	idx.skips.counts[key]++
+
+	if idx.skips.shown[message.Reason] < skipExamplesPerFile {
+		idx.skips.shown[message.Reason]++
+		text := strings.Join(strings.Fields(message.Excerpt), " ")
+		if runes := []rune(text); len(runes) > skipExcerptLength {
+			text = string(runes[:skipExcerptLength]) + "..."
+		}
+		message.Excerpt = text
+		idx.skips.examples = append(idx.skips.examples, message)
+	}
+}
+
+// skipMalformed records records of a file that couldn't be read, which
+// have no details to keep
+func (idx *Indexer) skipMalformed(count int) {
+	idx.metrics.Skipped[SkipMalformed] += count
+	if idx.skips != nil {
+		idx.skips.counts[models.SkipCount{Reason: SkipMalformed}] += count
+	}
+}
+
+// list returns the counts of messages skipped for each reason
+func (s *fileSkips) list() []models.SkipCount {
+	counts := make([]models.SkipCount, 0, len(s.counts))
+	for key, count := range s.counts {
+		key.Count = count
+		counts = append(counts, key)
+	}
+	return counts
+}
+
+// skippedSlackMessage describes a message of a Slack export that was
+// skipped
+func skippedSlackMessage(filename string, position int, reason string, msg *models.SlackMessage, text string) models.SkippedMessage {
+	user := msg.User
+	if user == "" {
+		user = msg.BotID
+	}
+	return models.SkippedMessage{
+		Filename:  filename,
+		Position:  position,
+		Reason:    reason,
+		Subtype:   msg.Subtype,
+		Timestamp: msg.TS,
+		UserID:    user,
+		Excerpt:   text,
+	}
+}
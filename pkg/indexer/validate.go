@@ -0,0 +1,315 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// NoSubtype is the SourceReport.Subtypes key counting ordinary messages,
+// which have no subtype
+const NoSubtype = "(none)"
+
+// SourceReport describes a Slack export directory as ValidateSource found it
+type SourceReport struct {
+	Dir           string `json:"dir"`
+	Users         int    `json:"users"`
+	Conversations int    `json:"conversations"`
+	// Channels counts the conversation directories holding message files
+	Channels     int `json:"channels"`
+	MessageFiles int `json:"message_files"`
+	Messages     int `json:"messages"`
+	// Subtypes counts the messages of each subtype, NoSubtype for ordinary
+	// messages
+	Subtypes map[string]int `json:"subtypes"`
+	// Problems make ingest fail, or fail to index a file
+	Problems []string `json:"problems"`
+	// Warnings are things ingest skips or works around
+	Warnings []string `json:"warnings"`
+}
+
+// OK reports whether the export can be ingested without errors
+func (r *SourceReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// SubtypeNames returns the subtypes found, most common first
+func (r *SourceReport) SubtypeNames() []string {
+	names := make([]string, 0, len(r.Subtypes))
+	for name := range r.Subtypes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.Subtypes[names[i]] != r.Subtypes[names[j]] {
+			return r.Subtypes[names[i]] > r.Subtypes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func (r *SourceReport) problem(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+func (r *SourceReport) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// knownSourceFiles are the top-level export files other than the
+// conversation metadata files. Ingest reads users.json, and
+// integration_logs.json with --include-bots; the rest are expected but
+// unused.
+var knownSourceFiles = map[string]bool{
+	"users.json":            true,
+	"integration_logs.json": true,
+	"canvases.json":         true,
+	"org_users.json":        true,
+}
+
+// ValidateSource checks a Slack export directory before it is ingested:
+// that users.json and the conversation metadata files parse, that every
+// message file is named by date and holds an array of messages, and that
+// nothing unexpected sits among them. Message subtypes are counted along
+// the way.
+func ValidateSource(dir string) (*SourceReport, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source is not a directory: %s", dir)
+	}
+
+	report := &SourceReport{Dir: dir, Subtypes: make(map[string]int)}
+
+	validateUsers(report)
+	if err := validateIntegrationLogs(report); err != nil {
+		report.problem("integration_logs.json: %v; ingest --include-bots will fail", err)
+	}
+	listed := validateConversations(report)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			if !knownSourceFiles[name] && !isConversationFile(name) {
+				report.warn("%s: unexpected file, not read by ingest", name)
+			}
+			continue
+		}
+
+		if err := validateChannelDir(report, filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+		if !listed[name] {
+			report.warn("%s: not listed in channels.json, groups.json, mpims.json or dms.json; it will be indexed as a public channel with no metadata", name)
+		}
+	}
+
+	return report, nil
+}
+
+// validateUsers checks users.json, which ingest can't run without
+func validateUsers(report *SourceReport) {
+	data, err := os.ReadFile(filepath.Join(report.Dir, "users.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		report.problem("users.json: not found")
+		return
+	}
+	if err != nil {
+		report.problem("users.json: %v", err)
+		return
+	}
+
+	var users []models.UserJSON
+	if err := json.Unmarshal(data, &users); err != nil {
+		report.problem("users.json: failed to parse: %v", err)
+		return
+	}
+	report.Users = len(users)
+
+	missingID := 0
+	for _, user := range users {
+		if user.ID == "" {
+			missingID++
+		}
+	}
+	if missingID > 0 {
+		report.warn("users.json: %d user(s) without an id", missingID)
+	}
+}
+
+// validateIntegrationLogs checks integration_logs.json, when present
+func validateIntegrationLogs(report *SourceReport) error {
+	data, err := os.ReadFile(filepath.Join(report.Dir, "integration_logs.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var logs []models.SlackIntegrationLog
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return fmt.Errorf("failed to parse: %w", err)
+	}
+	return nil
+}
+
+// validateConversations checks each conversation metadata file present and
+// returns the directory names they list
+func validateConversations(report *SourceReport) map[string]bool {
+	listed := make(map[string]bool)
+	found := false
+
+	for _, file := range conversationFiles {
+		data, err := os.ReadFile(filepath.Join(report.Dir, file.name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		found = true
+		if err != nil {
+			report.problem("%s: %v", file.name, err)
+			continue
+		}
+
+		var channels []models.ChannelJSON
+		if err := json.Unmarshal(data, &channels); err != nil {
+			report.problem("%s: failed to parse: %v", file.name, err)
+			continue
+		}
+		report.Conversations += len(channels)
+		for _, channel := range channels {
+			// Direct message directories are named by ID
+			listed[channel.Name] = true
+			listed[channel.ID] = true
+		}
+	}
+
+	if !found {
+		report.problem("no channels.json, groups.json, mpims.json or dms.json found")
+	}
+	return listed
+}
+
+func isConversationFile(name string) bool {
+	for _, file := range conversationFiles {
+		if file.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateChannelDir checks every file under a conversation directory, as
+// ingest walks it
+func validateChannelDir(report *SourceReport, channelDir string) error {
+	channel := filepath.Base(channelDir)
+	files := 0
+
+	err := filepath.WalkDir(channelDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(report.Dir, path)
+		if !strings.HasSuffix(path, ".json") {
+			report.warn("%s: unexpected file type, skipped by ingest", rel)
+			return nil
+		}
+		files++
+
+		if fileDate(d.Name()).IsZero() {
+			report.warn("%s: file name is not a date (YYYY-MM-DD.json); its messages are dated by their timestamps alone", rel)
+		}
+		validateMessageFile(report, path, rel)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", channelDir, err)
+	}
+
+	report.MessageFiles += files
+	if files == 0 {
+		report.warn("%s: no message files", channel)
+	} else {
+		report.Channels++
+	}
+	return nil
+}
+
+// validateMessageFile decodes a daily message file the way ingest does,
+// counting its messages by subtype
+func validateMessageFile(report *SourceReport, path, rel string) {
+	file, err := os.Open(path)
+	if err != nil {
+		report.problem("%s: %v", rel, err)
+		return
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		report.problem("%s: not an array of messages; ingest will fail to index it", rel)
+		return
+	}
+
+	malformed := 0
+	fields := make(map[string]bool)
+	for decoder.More() {
+		var msg models.SlackMessage
+		if err := decoder.Decode(&msg); err != nil {
+			// As in ingest, a field of an unexpected type leaves the rest of
+			// the message decoded and an element that is not an object is
+			// skipped
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				report.problem("%s: failed to parse: %v; ingest will fail to index it", rel, err)
+				return
+			}
+			if typeErr.Field == "" {
+				malformed++
+				continue
+			}
+			fields[typeErr.Field] = true
+		}
+
+		report.Messages++
+		subtype := msg.Subtype
+		if subtype == "" {
+			subtype = NoSubtype
+		}
+		report.Subtypes[subtype]++
+	}
+	if _, err := decoder.Token(); err != nil && err != io.EOF {
+		report.problem("%s: failed to parse: %v; ingest will fail to index it", rel, err)
+		return
+	}
+
+	if malformed > 0 {
+		report.warn("%s: %d element(s) that are not messages, skipped by ingest", rel, malformed)
+	}
+	if len(fields) > 0 {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		report.warn("%s: unexpected value type for %s, ignored by ingest", rel, strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,152 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// zulipMessage is a stream message as returned by Zulip's messages API,
+// which stream export tools save as a JSON list
+type zulipMessage struct {
+	ID             int64  `json:"id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderFullName string `json:"sender_full_name"`
+	SenderEmail    string `json:"sender_email"`
+	Timestamp      int64  `json:"timestamp"`
+	Content        string `json:"content"`
+	ContentType    string `json:"content_type"`
+	Subject        string `json:"subject"`
+	StreamID       int64  `json:"stream_id"`
+	// DisplayRecipient is the stream name for stream messages
+	DisplayRecipient json.RawMessage `json:"display_recipient"`
+	Reactions        []struct {
+		EmojiName string `json:"emoji_name"`
+	} `json:"reactions"`
+}
+
+// parseZulip reads a Zulip stream export: either a bare list of messages
+// or an API response with a messages field. Each topic becomes a thread
+// started by its first message.
+func parseZulip(path string, _ *FieldMap) (*conversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []zulipMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		var response struct {
+			Messages []zulipMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("expected a list of messages or an object with a messages field: %w", err)
+		}
+		messages = response.Messages
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+
+	conv := newConversation(path)
+	users := make(map[string]bool)
+	topics := make(map[string]string)
+
+	for _, zm := range messages {
+		if zm.StreamID != 0 {
+			conv.channel.ID = "zulip-" + strconv.FormatInt(zm.StreamID, 10)
+		}
+		var stream string
+		if json.Unmarshal(zm.DisplayRecipient, &stream) == nil && stream != "" {
+			conv.channel.Name = stream
+		}
+
+		userID := ""
+		if zm.SenderID != 0 {
+			userID = strconv.FormatInt(zm.SenderID, 10)
+			if !users[userID] {
+				users[userID] = true
+				conv.users = append(conv.users, zulipUser(userID, zm))
+			}
+		}
+
+		// Zulip timestamps are whole seconds, so the message ID fills the
+		// fraction to keep timestamps unique and stable across imports
+		date := time.Unix(zm.Timestamp, (zm.ID%1000000)*int64(time.Microsecond))
+		ts := formatTimestamp(date)
+
+		text := zm.Content
+		hasCode := strings.Contains(text, "`")
+		hasLink := hasURL(text)
+		if zm.ContentType == "text/html" || (zm.ContentType == "" && strings.HasPrefix(text, "<")) {
+			hasCode = strings.Contains(text, "<code")
+			hasLink = strings.Contains(text, "<a href")
+			text = zulipHTMLText(text)
+		}
+
+		// The first message of a topic starts its thread and carries the
+		// topic name, so the topic is searchable
+		threadTS, ok := topics[zm.Subject]
+		if !ok {
+			topics[zm.Subject] = ts
+			threadTS = ""
+			if zm.Subject != "" {
+				text = "[topic: " + zm.Subject + "]\n" + text
+			}
+		}
+
+		conv.messages = append(conv.messages, &models.Message{
+			UserID:        userID,
+			Text:          text,
+			Type:          "message",
+			Timestamp:     ts,
+			Date:          date,
+			ThreadTS:      threadTS,
+			ReactionCount: len(zm.Reactions),
+			HasLink:       hasLink,
+			HasCode:       hasCode,
+		})
+	}
+
+	linkThreads(conv.messages)
+	return conv, nil
+}
+
+// zulipUser describes a message's sender, using the local part of their
+// email as the user name
+func zulipUser(id string, zm zulipMessage) *models.User {
+	name := zm.SenderFullName
+	if i := strings.Index(zm.SenderEmail, "@"); i > 0 {
+		name = zm.SenderEmail[:i]
+	}
+	return &models.User{
+		ID:          id,
+		Name:        name,
+		RealName:    zm.SenderFullName,
+		DisplayName: zm.SenderFullName,
+	}
+}
+
+var (
+	zulipLinks  = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	zulipBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</pre>|</blockquote>`)
+	zulipTags   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// zulipHTMLText converts rendered message HTML to plain text, keeping the
+// targets of links after their text
+func zulipHTMLText(content string) string {
+	text := zulipLinks.ReplaceAllString(content, "$2 ($1)")
+	text = zulipBreaks.ReplaceAllString(text, "\n")
+	text = zulipTags.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,126 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeExport writes data to a file in a channel directory named channel,
+// as export files are laid out, and returns its path
+func writeExport(t *testing.T, channel, name, data string) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), channel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseZulip(t *testing.T) {
+	// Out of order, as an API response, with rendered HTML
+	path := writeExport(t, "import", "messages.json", `{"messages": [
+		{"id": 1000103, "sender_id": 2, "sender_full_name": "Bob Example", "sender_email": "bob@example.org",
+		 "timestamp": 1700000300, "content": "unrelated", "content_type": "text/x-markdown",
+		 "subject": "flakes", "stream_id": 5, "display_recipient": "sig-node"},
+		{"id": 1000101, "sender_id": 1, "sender_full_name": "Alice Example", "sender_email": "alice@example.org",
+		 "timestamp": 1700000000, "content_type": "text/html", "subject": "upgrade", "stream_id": 5,
+		 "display_recipient": "sig-node", "reactions": [{"emoji_name": "+1"}, {"emoji_name": "eyes"}],
+		 "content": "<p>see <a href=\"https://kubernetes.io/docs\">the docs</a> &amp; run <code>kubectl drain</code></p>"},
+		{"id": 1000102, "sender_id": 2, "sender_full_name": "Bob Example", "sender_email": "bob@example.org",
+		 "timestamp": 1700000000, "content": "thanks, that worked", "subject": "upgrade", "stream_id": 5,
+		 "display_recipient": "sig-node"}
+	]}`)
+
+	conv, err := parseZulip(path, nil)
+	if err != nil {
+		t.Fatalf("parseZulip failed: %v", err)
+	}
+
+	if conv.channel.ID != "zulip-5" || conv.channel.Name != "sig-node" {
+		t.Errorf("channel = %s %s, want zulip-5 sig-node", conv.channel.ID, conv.channel.Name)
+	}
+	if len(conv.users) != 2 || conv.users[0].Name != "alice" || conv.users[0].RealName != "Alice Example" {
+		t.Errorf("users = %+v, want alice and bob named from their emails", conv.users)
+	}
+	if len(conv.messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(conv.messages))
+	}
+
+	starter, reply, other := conv.messages[0], conv.messages[1], conv.messages[2]
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		// Messages sent in the same second keep their order by ID
+		{"starter timestamp", starter.Timestamp, "1700000000.000101"},
+		{"reply timestamp", reply.Timestamp, "1700000000.000102"},
+		{"starter text", starter.Text,
+			"[topic: upgrade]\nsee the docs (https://kubernetes.io/docs) & run kubectl drain"},
+		{"starter has link", starter.HasLink, true},
+		{"starter has code", starter.HasCode, true},
+		{"starter reactions", starter.ReactionCount, 2},
+		{"starter thread", starter.ThreadTS, starter.Timestamp},
+		{"starter replies", starter.ReplyCount, 1},
+		{"reply text", reply.Text, "thanks, that worked"},
+		{"reply thread", reply.ThreadTS, starter.Timestamp},
+		{"reply user", reply.UserID, "2"},
+		// A topic of one message is not a thread
+		{"other text", other.Text, "[topic: flakes]\nunrelated"},
+		{"other thread", other.ThreadTS, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestParseZulipList(t *testing.T) {
+	path := writeExport(t, "sig-auth", "messages.json",
+		`[{"id": 7, "sender_id": 1, "timestamp": 1700000000, "content": "hello", "subject": ""}]`)
+
+	conv, err := parseZulip(path, nil)
+	if err != nil {
+		t.Fatalf("parseZulip failed: %v", err)
+	}
+	if conv.channel.Name != "sig-auth" {
+		t.Errorf("channel = %s, want the directory name", conv.channel.Name)
+	}
+	if len(conv.messages) != 1 || conv.messages[0].Text != "hello" {
+		t.Errorf("messages = %+v, want hello without a topic line", conv.messages)
+	}
+
+	bad := writeExport(t, "sig-auth", "messages.json", `{"messages": "none"}`)
+	if _, err := parseZulip(bad, nil); err == nil {
+		t.Error("parseZulip of a malformed file succeeded, want an error")
+	}
+}
+
+func TestZulipHTMLText(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<p>plain</p>", "plain"},
+		{`<p><a href="https://k8s.io">site</a></p>`, "site (https://k8s.io)"},
+		{"<p>one<br>two</p><p>three</p>", "one\ntwo\nthree"},
+		{"<ul><li>a</li><li>b</li></ul>", "a\nb"},
+		{"<pre><code>x &lt; y</code></pre>", "x < y"},
+		{"<blockquote><p>quoted</p></blockquote><p>reply</p>", "quoted\n\nreply"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.html, func(t *testing.T) {
+			if got := zulipHTMLText(tt.html); got != tt.want {
+				t.Errorf("zulipHTMLText(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
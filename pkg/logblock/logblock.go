@@ -0,0 +1,99 @@
+// Package logblock detects pasted logs and stack traces in messages, so
+// searches can target messages where someone shared diagnostic output
+// rather than only talked about a problem.
+//
+// Each code block, and each paragraph outside code blocks, is a candidate.
+// A candidate of two or more lines counts as a log paste when at least two
+// of its lines, and at least half of them, look like log lines (klog,
+// timestamped, levelled, logfmt, JSON or kubectl event lines) or stack
+// frames (Go, Python or Java).
+package logblock
+
+import (
+	"regexp"
+	"strings"
+)
+
+// linePatterns match a single trimmed line of a log or stack trace
+var linePatterns = []*regexp.Regexp{
+	// klog: E0515 10:23:45.123456
+	regexp.MustCompile(`^[IWEF]\d{4} \d{2}:\d{2}:\d{2}`),
+	// Timestamped: 2024-05-15T10:23:45, [2024-05-15 10:23:45], May 15 10:23:45, 10:23:45
+	regexp.MustCompile(`^\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}`),
+	regexp.MustCompile(`^[A-Z][a-z]{2} +\d{1,2} \d{2}:\d{2}:\d{2}`),
+	regexp.MustCompile(`^\[?\d{2}:\d{2}:\d{2}`),
+	// Levelled: ERROR ..., [warn] ..., level=error ..., {"level":"error",...}
+	regexp.MustCompile(`(?i)^\[?(?:trace|debug|info|warn|warning|error|fatal|panic)\]?[\s:]`),
+	regexp.MustCompile(`(?:^|\s)level=\w+`),
+	regexp.MustCompile(`^\{.*"(?:level|msg|ts|time)"\s*:`),
+	// kubectl events: Warning  FailedMount  3m  kubelet  ...
+	regexp.MustCompile(`^(?:Warning|Normal)\s+[A-Z]\w+\s+`),
+	// Go: goroutine 1 [running]:, panic: ..., main.main(), /app/main.go:12 +0x1d
+	regexp.MustCompile(`^goroutine \d+ \[`),
+	regexp.MustCompile(`^panic: `),
+	regexp.MustCompile(`\.go:\d+`),
+	regexp.MustCompile(`^[\w.*/()\[\]-]+\(.*\)$`),
+	// Python: Traceback (most recent call last):, File "x.py", line 3, ValueError: ...
+	regexp.MustCompile(`^Traceback \(most recent call last\)`),
+	regexp.MustCompile(`^File ".+", line \d+`),
+	regexp.MustCompile(`^\w+(?:Error|Exception)(?::|$)`),
+	// Java: at com.example.Foo.bar(Foo.java:12), Caused by: ..., ... 5 more
+	regexp.MustCompile(`^at [\w.$<>]+\(`),
+	regexp.MustCompile(`^Caused by: `),
+	regexp.MustCompile(`^Exception in thread `),
+	regexp.MustCompile(`^\.\.\. \d+ more$`),
+}
+
+// blankLinePattern separates paragraphs outside code blocks
+var blankLinePattern = regexp.MustCompile(`\n\s*\n`)
+
+// Extract returns the log pastes and stack traces in text, in order
+func Extract(text string) []string {
+	var blocks []string
+	// Odd-numbered parts between ``` fences are code blocks
+	for i, part := range strings.Split(text, "```") {
+		candidates := []string{part}
+		if i%2 == 0 {
+			candidates = blankLinePattern.Split(part, -1)
+		}
+		for _, candidate := range candidates {
+			candidate = strings.Trim(candidate, "\n")
+			if IsLog(candidate) {
+				blocks = append(blocks, candidate)
+			}
+		}
+	}
+	return blocks
+}
+
+// IsLog reports whether a block of text is a pasted log or stack trace
+func IsLog(block string) bool {
+	// Most messages are a single line of chat, which can't be a log paste
+	if !strings.Contains(block, "\n") {
+		return false
+	}
+
+	lines, matched := 0, 0
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines++
+		if logLine(line) {
+			matched++
+		}
+	}
+	return lines >= 2 && matched >= 2 && 2*matched >= lines
+}
+
+// logLine reports whether a trimmed line looks like part of a log or stack
+// trace
+func logLine(line string) bool {
+	for _, pattern := range linePatterns {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+// Package mediastore keeps the files shared in Slack messages in a
+// content-addressed store, so each file is kept once however many times it
+// was shared or imported, and finds the files in a directory of downloads
+package mediastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// fileIDPattern matches a Slack file ID, such as F05ABCD1234
+var fileIDPattern = regexp.MustCompile(`^F[A-Z0-9]{6,}$`)
+
+// Store is a directory of files named by the SHA-256 of their contents
+type Store struct {
+	Dir string
+}
+
+// New returns the store in dir
+func New(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Path returns where a file with the given hash and extension is stored
+func (s *Store) Path(hash, ext string) string {
+	return filepath.Join(s.Dir, hash[:2], hash+strings.ToLower(ext))
+}
+
+// Put copies a file into the store, returning its hash and the absolute
+// path of the stored copy. A file already in the store isn't copied again.
+func (s *Store) Put(path string) (string, string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create media store: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".import-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create media store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	stored, err := filepath.Abs(s.Path(hash, filepath.Ext(path)))
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := os.Stat(stored); err == nil {
+		return hash, stored, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(stored), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create media store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), stored); err != nil {
+		return "", "", fmt.Errorf("failed to store %s: %w", path, err)
+	}
+	return hash, stored, nil
+}
+
+// File is a file found in a directory of downloads
+type File struct {
+	Path string
+	// Name is the file's name as shared, without any file ID prefix
+	Name string
+	// FileID is the Slack file ID the file was saved under, empty when
+	// its path doesn't name one
+	FileID   string
+	Size     int64
+	Mimetype string
+}
+
+// Scan lists the files under dir. Downloads are matched to the files
+// shared in messages by the Slack file ID in their path, as either the
+// directory holding them (F05ABCD1234/diagram.png, the layout slackdump
+// writes) or a prefix of their name (F05ABCD1234-diagram.png or
+// F05ABCD1234_diagram.png). Hidden files are skipped.
+func Scan(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := File{Path: path, Name: d.Name(), Size: info.Size()}
+		if parent := filepath.Base(filepath.Dir(path)); fileIDPattern.MatchString(parent) {
+			file.FileID = parent
+		} else if i := strings.IndexAny(file.Name, "-_"); i > 0 && fileIDPattern.MatchString(file.Name[:i]) {
+			file.FileID = file.Name[:i]
+			file.Name = file.Name[i+1:]
+		}
+		file.Mimetype = mimetype(path)
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// mimetype gives a file's type from its extension, or from its contents
+// when the extension is unknown
+func mimetype(path string) string {
+	if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+		mediaType, _, err := mime.ParseMediaType(byExt)
+		if err == nil {
+			return mediaType
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType
+}
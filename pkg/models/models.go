@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // User represents a Slack user from users.json
 type User struct {
@@ -10,6 +14,29 @@ type User struct {
 	DisplayName string `json:"display_name" db:"display_name"`
 	IsBot       bool   `json:"is_bot" db:"is_bot"`
 	Deleted     bool   `json:"deleted" db:"deleted"`
+	// Placeholder is set for message authors missing from users.json
+	Placeholder bool `json:"placeholder,omitempty" db:"placeholder"`
+}
+
+// Bot is an app, integration or webhook that posts messages, from the
+// export's integration logs and the bot profiles in its messages
+type Bot struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	AppID string `json:"app_id,omitempty"`
+	// Service is the kind of integration, e.g. GitHub or Incoming WebHook
+	Service string `json:"service,omitempty"`
+}
+
+// UserProfile is a set of names a user posted under, and the period
+// messages were seen posted under it
+type UserProfile struct {
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	RealName    string    `json:"real_name"`
+	DisplayName string    `json:"display_name"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
 }
 
 // Profile represents the nested profile object in User
@@ -27,13 +54,60 @@ type UserJSON struct {
 	Deleted bool    `json:"deleted"`
 }
 
-// Channel represents a Slack channel from channels.json
+// Conversation kinds, named after the export metadata file that lists them
+const (
+	KindChannel = "channel" // public channel, channels.json
+	KindGroup   = "group"   // private channel, groups.json
+	KindMPIM    = "mpim"    // multi-person direct message, mpims.json
+	KindDM      = "dm"      // direct message, dms.json
+)
+
+// Channel represents a Slack conversation from channels.json, groups.json,
+// mpims.json or dms.json
 type Channel struct {
-	ID         string `json:"id" db:"id"`
-	Name       string `json:"name" db:"name"`
-	Created    int64  `json:"created" db:"created"`
-	Creator    string `json:"creator" db:"creator"`
-	IsArchived bool   `json:"is_archived" db:"is_archived"`
+	ID         string   `json:"id" db:"id"`
+	Name       string   `json:"name" db:"name"`
+	Created    int64    `json:"created" db:"created"`
+	Creator    string   `json:"creator" db:"creator"`
+	IsArchived bool     `json:"is_archived" db:"is_archived"`
+	Topic      string   `db:"topic"`
+	Purpose    string   `db:"purpose"`
+	Kind       string   `db:"kind"`
+	Members    []string `db:"-"`
+}
+
+// IsPrivate reports whether the conversation is not a public channel
+func (c *Channel) IsPrivate() bool {
+	return c.Kind != "" && c.Kind != KindChannel
+}
+
+// ChannelTopic represents the nested topic and purpose objects in a channel
+type ChannelTopic struct {
+	Value   string `json:"value"`
+	Creator string `json:"creator"`
+	LastSet int64  `json:"last_set"`
+}
+
+// ChannelJSON represents the full channel structure from the JSON file
+type ChannelJSON struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Created    int64        `json:"created"`
+	Creator    string       `json:"creator"`
+	IsArchived bool         `json:"is_archived"`
+	Topic      ChannelTopic `json:"topic"`
+	Purpose    ChannelTopic `json:"purpose"`
+	Members    []string     `json:"members"`
+}
+
+// ChannelInfo combines channel metadata with details about its indexed messages
+type ChannelInfo struct {
+	Channel
+	CreatorName  string
+	MemberCount  int
+	MessageCount int
+	FirstMessage time.Time
+	LastMessage  time.Time
 }
 
 // Message represents a Slack message from daily JSON files
@@ -46,15 +120,588 @@ type Message struct {
 	Timestamp string    `json:"ts" db:"timestamp"`
 	Date      time.Time `db:"date"`
 	Filename  string    `db:"filename"`
+	// TSMicros is Timestamp as microseconds since the epoch, for ordering
+	// messages posted within the same second
+	TSMicros int64 `db:"ts_micros"`
+	// Thread information; ThreadTS equals Timestamp for thread starters
+	ThreadTS   string `json:"thread_ts" db:"thread_ts"`
+	ReplyCount int    `json:"reply_count" db:"reply_count"`
+	// Total number of reactions across all emoji
+	ReactionCount int `db:"reaction_count"`
+	// Content flags set at ingest, used by has: filters
+	HasLink bool `db:"has_link"`
+	HasCode bool `db:"has_code"`
+	HasFile bool `db:"has_file"`
 	// User information joined from users table
 	UserName     string `db:"user_name"`
 	UserRealName string `db:"user_real_name"`
 }
 
+// ChannelSearchResult represents a channel matched by a discovery search
+type ChannelSearchResult struct {
+	Channel
+	MemberCount int    `db:"member_count"`
+	Snippet     string `db:"snippet"`
+}
+
+// Content types accepted in SearchFilter.Has
+const (
+	HasLink     = "link"
+	HasCode     = "code"
+	HasFile     = "file"
+	HasReaction = "reaction"
+	// HasLogs matches messages with a pasted log or stack trace
+	HasLogs = "logs"
+)
+
+// Search scopes select messages by their place in a thread
+const (
+	ScopeAll            = "all"
+	ScopeThreadStarters = "thread-starters"
+	ScopeThreadReplies  = "thread-replies"
+	ScopeTopLevel       = "top-level"
+)
+
+// Scopes lists the supported search scopes
+var Scopes = []string{ScopeAll, ScopeThreadStarters, ScopeThreadReplies, ScopeTopLevel}
+
+// Search result orders
+const (
+	// SortRelevance orders by rank, then newest first
+	SortRelevance = "relevance"
+	SortNewest    = "newest"
+	SortOldest    = "oldest"
+	// SortRandom returns matches in random order, for --sample
+	SortRandom = "random"
+)
+
+// Sorts lists the supported search result orders
+var Sorts = []string{SortRelevance, SortNewest, SortOldest}
+
+// SearchFilter restricts message searches beyond full-text matching
+type SearchFilter struct {
+	// Users matches messages by any of these user names, display names,
+	// real names or IDs
+	Users []string
+	// Since (inclusive) and Until (exclusive) bound message dates; zero
+	// values leave that side open
+	Since time.Time
+	Until time.Time
+	// Has lists content each message must contain
+	Has []string
+	// Tags lists tags each message must carry, directly or through its
+	// thread
+	Tags []string
+	// K8sVersion matches messages mentioning this Kubernetes minor
+	// version, such as 1.27
+	K8sVersion string
+	// Fingerprints matches messages quoting an error with any of these
+	// fingerprints, ranking those quoting more of them higher
+	Fingerprints []string
+	// Scope limits results to thread starters, replies or top-level
+	// messages; empty means ScopeAll
+	Scope string
+	// Sort orders the results; empty means SortRelevance
+	Sort string
+	// AfterID matches only messages stored after the one with this ID,
+	// such as those added by the latest ingest
+	AfterID int
+}
+
+// IsZero reports whether the filter matches every message, whatever the
+// sort order
+func (f SearchFilter) IsZero() bool {
+	return len(f.Users) == 0 && f.Since.IsZero() && f.Until.IsZero() && len(f.Has) == 0 && len(f.Tags) == 0 &&
+		f.K8sVersion == "" && len(f.Fingerprints) == 0 && (f.Scope == "" || f.Scope == ScopeAll) && f.AfterID == 0
+}
+
+// Key returns a stable string form of the filter, for use in cache keys
+func (f SearchFilter) Key() string {
+	if f.IsZero() && (f.Sort == "" || f.Sort == SortRelevance) {
+		return ""
+	}
+	var since, until string
+	if !f.Since.IsZero() {
+		since = f.Since.Format(time.RFC3339)
+	}
+	if !f.Until.IsZero() {
+		until = f.Until.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("from=%s;since=%s;until=%s;has=%s;tags=%s;version=%s;errors=%s;scope=%s;sort=%s;after=%d",
+		strings.Join(f.Users, ","), since, until, strings.Join(f.Has, ","), strings.Join(f.Tags, ","), f.K8sVersion,
+		strings.Join(f.Fingerprints, ","), f.Scope, f.Sort, f.AfterID)
+}
+
+// Describe returns a line for each restriction the filter applies, and its
+// sort order, in a readable form
+func (f SearchFilter) Describe() []string {
+	var lines []string
+	if len(f.Users) > 0 {
+		lines = append(lines, "from: "+strings.Join(f.Users, ", "))
+	}
+	if !f.Since.IsZero() {
+		lines = append(lines, "since: "+f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		lines = append(lines, "until: "+f.Until.Format(time.RFC3339)+" (exclusive)")
+	}
+	if len(f.Has) > 0 {
+		lines = append(lines, "has: "+strings.Join(f.Has, ", "))
+	}
+	if len(f.Tags) > 0 {
+		lines = append(lines, "tags: "+strings.Join(f.Tags, ", "))
+	}
+	if f.K8sVersion != "" {
+		lines = append(lines, "kubernetes version: "+f.K8sVersion)
+	}
+	if len(f.Fingerprints) > 0 {
+		lines = append(lines, "error fingerprints: "+strings.Join(f.Fingerprints, ", "))
+	}
+	if f.Scope != "" && f.Scope != ScopeAll {
+		lines = append(lines, "scope: "+f.Scope)
+	}
+	if f.AfterID != 0 {
+		lines = append(lines, fmt.Sprintf("after message ID: %d", f.AfterID))
+	}
+	sort := f.Sort
+	if sort == "" {
+		sort = SortRelevance
+	}
+	return append(lines, "sort: "+sort)
+}
+
+// DayCount is the number of messages posted on a day
+type DayCount struct {
+	// Date is the day as YYYY-MM-DD
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// ChannelStats summarises the contents of a channel database
+type ChannelStats struct {
+	Database   string             `json:"database"`
+	Users      int                `json:"users"`
+	Channels   int                `json:"channels"`
+	Messages   int                `json:"messages"`
+	ActiveDays int                `json:"active_days"`
+	FirstDay   string             `json:"first_day,omitempty"`
+	LastDay    string             `json:"last_day,omitempty"`
+	BusiestDay DayCount           `json:"busiest_day"`
+	Daily      []DayCount         `json:"daily"`
+	TopPosters []LeaderboardEntry `json:"top_posters"`
+}
+
+// TermSuggestion is an indexed term offered as a search suggestion
+type TermSuggestion struct {
+	Term        string `json:"term"`
+	Documents   int    `json:"documents"`
+	Occurrences int    `json:"occurrences"`
+}
+
+// TermFilter selects the index terms counted in term statistics, such as
+// suggestions and top terms
+type TermFilter struct {
+	Stopwords []string `json:"stopwords"`
+	// MinLength is the shortest term counted
+	MinLength int `json:"min_length"`
+	// Default is set when the database uses the built-in stopwords
+	Default bool `json:"default"`
+}
+
 // SearchResult represents a search result with context
 type SearchResult struct {
 	Message
 	Rank     float64 `db:"rank"`
 	Snippet  string  `db:"snippet"`
 	Filename string  `db:"filename"`
-}
\ No newline at end of file
+	// Database the result came from, set when several databases are searched
+	Database string `db:"-"`
+	// Hits is the number of times the query's terms occur in the message
+	// text
+	Hits int `db:"-"`
+	// ThreadHits is the number of times they occur in the message's whole
+	// thread, set by CountThreadHits
+	ThreadHits int `db:"-"`
+}
+
+// SimilarMessage is a message found to resemble another by the salient
+// terms they share
+type SimilarMessage struct {
+	SearchResult
+	// Similarity is the share of the other message's salient terms,
+	// weighted by their rarity, this one contains, from 0 to 1
+	Similarity float64
+	// SharedTerms lists the salient terms it contains
+	SharedTerms []string
+}
+
+// SearchExplanation describes how a search runs, for debugging queries
+// that return unexpected results or run slowly
+type SearchExplanation struct {
+	// Match is the FTS match expression after query rewriting; empty when
+	// only filters apply
+	Match string          `json:"match,omitempty"`
+	SQL   string          `json:"sql"`
+	Args  []interface{}   `json:"args"`
+	Plan  []QueryPlanStep `json:"plan"`
+}
+
+// SearchTrace records how a search ran, for finding slow searches
+type SearchTrace struct {
+	Time time.Time `json:"time"`
+	// Database is set by the caller, which knows the database's name
+	Database string `json:"database,omitempty"`
+	// Match is the FTS match expression after query rewriting; empty when
+	// only filters apply
+	Match    string        `json:"match,omitempty"`
+	Filters  []string      `json:"filters,omitempty"`
+	Limit    int           `json:"limit"`
+	Duration time.Duration `json:"duration_ns"`
+	// RowsScanned counts the rows read from the search index, or from the
+	// messages table when only filters apply, before the filters and limit
+	RowsScanned int             `json:"rows_scanned"`
+	Results     int             `json:"results"`
+	Plan        []QueryPlanStep `json:"plan,omitempty"`
+	// Error is set when the search failed
+	Error string `json:"error,omitempty"`
+	// TimedOut is set when the search failed by running out of time
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+// FTSReport compares a database's tables with their full-text search
+// indexes
+type FTSReport struct {
+	Messages        int `json:"messages"`
+	IndexedMessages int `json:"indexed_messages"`
+	// Unindexed counts messages with no index row, which full-text
+	// searches can't find
+	Unindexed int `json:"unindexed"`
+	// Orphaned counts index rows with no message
+	Orphaned int `json:"orphaned"`
+	// UnindexedSample holds the timestamps of the first few unindexed
+	// messages
+	UnindexedSample []string `json:"unindexed_sample,omitempty"`
+	Channels        int      `json:"channels"`
+	IndexedChannels int      `json:"indexed_channels"`
+	// IndexError is the failure reported by the message index's own
+	// integrity check
+	IndexError string `json:"index_error,omitempty"`
+}
+
+// Consistent reports whether every message and channel is indexed exactly
+// once and the message index passed its integrity check
+func (r *FTSReport) Consistent() bool {
+	return r.Unindexed == 0 && r.Orphaned == 0 && r.Messages == r.IndexedMessages &&
+		r.Channels == r.IndexedChannels && r.IndexError == ""
+}
+
+// QueryPlanStep is a row of SQLite's EXPLAIN QUERY PLAN output. Steps form
+// a tree through Parent, which is zero for top-level steps.
+type QueryPlanStep struct {
+	ID     int    `json:"id"`
+	Parent int    `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+// Feedback is a helpful or not helpful mark on a search result
+type Feedback struct {
+	Timestamp string    `json:"timestamp"`
+	Score     int       `json:"score"`
+	Query     string    `json:"query,omitempty"`
+	Updated   time.Time `json:"updated"`
+	Text      string    `json:"text"`
+	UserName  string    `json:"user_name"`
+}
+
+// TagCount is a tag and the number of messages and threads carrying it
+type TagCount struct {
+	Tag      string `json:"tag"`
+	Messages int    `json:"messages"`
+	Threads  int    `json:"threads"`
+}
+
+// ResultSet is a named set of search results saved in a database
+type ResultSet struct {
+	Name string `json:"name"`
+	// Query is the search the set was saved from, or the operation
+	// combining other sets
+	Query    string    `json:"query"`
+	Created  time.Time `json:"created"`
+	Messages int       `json:"messages"`
+}
+
+// MessageFile is a file shared in a message, as recorded at ingest
+type MessageFile struct {
+	MessageTS string `json:"message_ts"`
+	// Position is the file's place among those the message shared
+	Position int    `json:"position"`
+	FileID   string `json:"file_id"`
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Mimetype string `json:"mimetype"`
+	Size     int64  `json:"size"`
+	// URL is the file's download link, which needs a Slack login
+	URL       string `json:"url"`
+	Thumbnail string `json:"thumbnail"`
+	Permalink string `json:"permalink"`
+	// Preview is the start of a text file's contents
+	Preview string `json:"preview"`
+	// LocalPath is a copy of the file on disk, when one was found
+	LocalPath string `json:"local_path"`
+}
+
+// MediaFile is a file imported into the media store
+type MediaFile struct {
+	SHA256   string `json:"sha256"`
+	Name     string `json:"name"`
+	FileID   string `json:"file_id"`
+	Mimetype string `json:"mimetype"`
+	Size     int64  `json:"size"`
+	// StoredPath is the file's copy in the media store
+	StoredPath string    `json:"stored_path"`
+	Cataloged  time.Time `json:"cataloged"`
+	// MessageTS is the message the file was shared in, empty when no
+	// indexed message shares it
+	MessageTS string `json:"message_ts"`
+}
+
+// SkippedMessage is a message in an export file that ingest didn't index
+type SkippedMessage struct {
+	Filename string `json:"filename"`
+	// Position is the message's place in its file, counting from 1
+	Position  int    `json:"position"`
+	Reason    string `json:"reason"`
+	Subtype   string `json:"subtype,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	// Excerpt is the start of the message's text, if it had any
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+// SkipCount is the number of messages in an export file skipped for a
+// reason, and for unsupported subtypes the subtype
+type SkipCount struct {
+	Reason  string `json:"reason"`
+	Subtype string `json:"subtype,omitempty"`
+	Count   int    `json:"count"`
+}
+
+// SkipReport summarizes the messages ingest skipped, so how complete the
+// index is can be judged
+type SkipReport struct {
+	Indexed int          `json:"indexed"`
+	Skipped int          `json:"skipped"`
+	Reasons []SkipReason `json:"reasons"`
+}
+
+// SkipReason is the messages skipped for one reason
+type SkipReason struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+	// Files is the number of export files with messages skipped for the
+	// reason
+	Files int `json:"files"`
+	// Subtypes counts the unsupported subtypes skipped
+	Subtypes map[string]int   `json:"subtypes,omitempty"`
+	Examples []SkippedMessage `json:"examples"`
+}
+
+// LeaderboardEntry represents a user's position in an activity leaderboard
+type LeaderboardEntry struct {
+	UserID       string `json:"user_id"`
+	UserName     string `json:"user_name"`
+	UserRealName string `json:"user_real_name"`
+	Count        int    `json:"count"`
+}
+
+// Leaderboard contains the most active users in a channel database
+type Leaderboard struct {
+	Database          string             `json:"database"`
+	Year              int                `json:"year,omitempty"`
+	TopPosters        []LeaderboardEntry `json:"top_posters"`
+	TopThreadStarters []LeaderboardEntry `json:"top_thread_starters"`
+	TopRepliers       []LeaderboardEntry `json:"top_repliers"`
+}
+
+// InteractionGraph records who replies to whom in a channel's threads
+type InteractionGraph struct {
+	Database string      `json:"database"`
+	Year     int         `json:"year,omitempty"`
+	Nodes    []GraphNode `json:"nodes"`
+	Edges    []GraphEdge `json:"links"`
+}
+
+// GraphNode is a user taking part in threads
+type GraphNode struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	RealName       string `json:"real_name,omitempty"`
+	ThreadsStarted int    `json:"threads_started"`
+	Replies        int    `json:"replies"`
+}
+
+// GraphEdge counts the replies one user posted in threads another started
+type GraphEdge struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Replies int    `json:"value"`
+	Threads int    `json:"threads"`
+}
+
+// ThreadReply is when a thread started and first got a reply
+type ThreadReply struct {
+	ThreadTS   string
+	Started    time.Time
+	FirstReply time.Time
+}
+
+// ResponseTimes describes how quickly threads get their first reply from
+// someone other than the person who started them
+type ResponseTimes struct {
+	Database string `json:"database"`
+	ResponseSummary
+	// Buckets counts answered threads by time to first reply
+	Buckets []ResponseBucket `json:"buckets"`
+	// Months breaks the summary down by the month threads started in
+	Months []MonthlyResponses `json:"months"`
+}
+
+// ResponseSummary summarises the first-reply times of a set of threads
+type ResponseSummary struct {
+	Threads  int `json:"threads"`
+	Answered int `json:"answered"`
+	// Times to first reply, in seconds, over answered threads
+	MedianSeconds int64 `json:"median_seconds"`
+	P90Seconds    int64 `json:"p90_seconds"`
+	MeanSeconds   int64 `json:"mean_seconds"`
+}
+
+// ResponseBucket counts threads first answered within a time range
+type ResponseBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// MonthlyResponses summarises threads started in a month, as YYYY-MM
+type MonthlyResponses struct {
+	Month string `json:"month"`
+	ResponseSummary
+}
+
+// ChannelComparison sets two channels' activity, vocabulary and members
+// side by side
+type ChannelComparison struct {
+	Left  ChannelProfile `json:"left"`
+	Right ChannelProfile `json:"right"`
+	// Years counts each channel's messages per year, oldest first
+	Years []YearComparison `json:"years"`
+	// SharedTerms are the terms in both channels' top terms
+	SharedTerms []string    `json:"shared_terms"`
+	Users       UserOverlap `json:"users"`
+}
+
+// ChannelProfile summarises one side of a channel comparison
+type ChannelProfile struct {
+	Database     string           `json:"database"`
+	Messages     int              `json:"messages"`
+	Threads      int              `json:"threads"`
+	Users        int              `json:"users"`
+	FirstMessage time.Time        `json:"first_message"`
+	LastMessage  time.Time        `json:"last_message"`
+	TopTerms     []TermSuggestion `json:"top_terms"`
+}
+
+// YearComparison is the number of messages each channel had in a year
+type YearComparison struct {
+	Year  int `json:"year"`
+	Left  int `json:"left"`
+	Right int `json:"right"`
+}
+
+// UserOverlap describes how many users posted in one or both channels
+type UserOverlap struct {
+	LeftOnly  int `json:"left_only"`
+	RightOnly int `json:"right_only"`
+	Shared    int `json:"shared"`
+	// Jaccard is the shared users over all users in either channel
+	Jaccard float64 `json:"jaccard"`
+	// TopShared are the most active users posting in both channels
+	TopShared []SharedUser `json:"top_shared"`
+}
+
+// SharedUser is a user active in both compared channels
+type SharedUser struct {
+	UserID       string `json:"user_id"`
+	UserName     string `json:"user_name"`
+	UserRealName string `json:"user_real_name"`
+	Left         int    `json:"left"`
+	Right        int    `json:"right"`
+}
+
+// VersionMention is a message mentioning a Kubernetes minor version
+type VersionMention struct {
+	Version string
+	Date    time.Time
+}
+
+// VersionActivity describes how much a Kubernetes release was discussed
+type VersionActivity struct {
+	Version  string    `json:"version"`
+	Messages int       `json:"messages"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	// PeakMonth is the month, as YYYY-MM, with the most mentions
+	PeakMonth    string `json:"peak_month"`
+	PeakMessages int    `json:"peak_messages"`
+	// Months counts mentions in each month with any, oldest first
+	Months []MonthCount `json:"months"`
+}
+
+// MonthCount is the number of messages in a month, as YYYY-MM
+type MonthCount struct {
+	Month    string `json:"month"`
+	Messages int    `json:"messages"`
+}
+
+// VersionReport lists the Kubernetes releases discussed in a channel
+type VersionReport struct {
+	Database string            `json:"database"`
+	Versions []VersionActivity `json:"versions"`
+}
+
+// ThreadActivity summarises activity in a single thread over a period
+type ThreadActivity struct {
+	ThreadTS     string    `json:"thread_ts"`
+	Date         time.Time `json:"date"`
+	UserName     string    `json:"user_name"`
+	UserRealName string    `json:"user_real_name"`
+	Text         string    `json:"text"`
+	Replies      int       `json:"replies"`
+}
+
+// LinkCount represents how often a link was shared
+type LinkCount struct {
+	URL   string `json:"url"`
+	Count int    `json:"count"`
+}
+
+// Digest summarises a channel's activity over a week
+type Digest struct {
+	Database      string           `json:"database"`
+	Week          string           `json:"week"`
+	Start         time.Time        `json:"start"`
+	End           time.Time        `json:"end"`
+	MessageCount  int              `json:"message_count"`
+	ActiveThreads []ThreadActivity `json:"active_threads"`
+	TopLinks      []LinkCount      `json:"top_links"`
+	MostReacted   []*Message       `json:"most_reacted"`
+}
+
+// Cluster is a group of near-duplicate messages
+type Cluster struct {
+	Size           int        `json:"size"`
+	FirstSeen      time.Time  `json:"first_seen"`
+	LastSeen       time.Time  `json:"last_seen"`
+	Representative *Message   `json:"representative"`
+	Messages       []*Message `json:"messages"`
+}
@@ -0,0 +1,179 @@
+package models
+
+import "encoding/json"
+
+// SlackMessage represents a message as it appears in a Slack export's daily
+// message files
+type SlackMessage struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Username    string `json:"username"`
+	Text        string `json:"text"`
+	TS          string `json:"ts"`
+	ClientMsgID string `json:"client_msg_id"`
+	Team        string `json:"team"`
+
+	// Thread fields; ThreadTS equals TS for thread starters
+	ThreadTS        string       `json:"thread_ts"`
+	ParentUserID    string       `json:"parent_user_id"`
+	ReplyCount      int          `json:"reply_count"`
+	ReplyUsersCount int          `json:"reply_users_count"`
+	ReplyUsers      []string     `json:"reply_users"`
+	LatestReply     string       `json:"latest_reply"`
+	Replies         []SlackReply `json:"replies"`
+
+	Edited    *SlackEdit      `json:"edited"`
+	Reactions []SlackReaction `json:"reactions"`
+	Files     []SlackFile     `json:"files"`
+	// File is the single shared file carried by older file_share messages
+	File        *SlackFile        `json:"file"`
+	Attachments []SlackAttachment `json:"attachments"`
+	Blocks      []SlackBlock      `json:"blocks"`
+	UserProfile *SlackUserProfile `json:"user_profile"`
+	BotProfile  *SlackBotProfile  `json:"bot_profile"`
+}
+
+// ReactionCount returns the total number of reactions across all emoji
+func (m *SlackMessage) ReactionCount() int {
+	total := 0
+	for _, reaction := range m.Reactions {
+		total += reaction.Count
+	}
+	return total
+}
+
+// SlackReply identifies a reply listed on a thread starter
+type SlackReply struct {
+	User string `json:"user"`
+	TS   string `json:"ts"`
+}
+
+// SlackEdit records the last edit made to a message
+type SlackEdit struct {
+	User string `json:"user"`
+	TS   string `json:"ts"`
+}
+
+// SlackReaction is a single emoji reaction on a message
+type SlackReaction struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Users []string `json:"users"`
+}
+
+// SlackFile is a file shared in a message
+type SlackFile struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Title              string `json:"title"`
+	Mimetype           string `json:"mimetype"`
+	Filetype           string `json:"filetype"`
+	PrettyType         string `json:"pretty_type"`
+	Size               int64  `json:"size"`
+	URLPrivate         string `json:"url_private"`
+	URLPrivateDownload string `json:"url_private_download"`
+	Permalink          string `json:"permalink"`
+	Preview            string `json:"preview"`
+	// Thumbnails Slack generates for images, by width
+	Thumb360 string `json:"thumb_360"`
+	Thumb480 string `json:"thumb_480"`
+	// InitialComment is the caption posted with the file in older exports
+	InitialComment *SlackFileComment `json:"initial_comment"`
+}
+
+// SlackFileComment is a comment attached to a shared file
+type SlackFileComment struct {
+	User    string `json:"user"`
+	Comment string `json:"comment"`
+}
+
+// SlackAttachment is a legacy attachment, typically a link unfurl
+type SlackAttachment struct {
+	Fallback    string `json:"fallback"`
+	Pretext     string `json:"pretext"`
+	Title       string `json:"title"`
+	TitleLink   string `json:"title_link"`
+	Text        string `json:"text"`
+	FromURL     string `json:"from_url"`
+	ServiceName string `json:"service_name"`
+}
+
+// SlackBlock is a Block Kit layout block. Only the fields needed to recover
+// message text are decoded.
+type SlackBlock struct {
+	Type     string              `json:"type"`
+	BlockID  string              `json:"block_id"`
+	Text     *SlackTextObject    `json:"text"`
+	Fields   []SlackTextObject   `json:"fields"`
+	Elements []SlackBlockElement `json:"elements"`
+}
+
+// SlackTextObject is a Block Kit text object
+type SlackTextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SlackBlockElement is an element of a block. Rich text elements nest, so
+// containers such as rich_text_section hold further elements.
+type SlackBlockElement struct {
+	Type      string              `json:"type"`
+	Text      string              `json:"text"`
+	URL       string              `json:"url"`
+	UserID    string              `json:"user_id"`
+	ChannelID string              `json:"channel_id"`
+	Name      string              `json:"name"`
+	Range     string              `json:"range"`
+	Style     SlackStyle          `json:"style"`
+	Elements  []SlackBlockElement `json:"elements"`
+}
+
+// SlackUserProfile is the author profile Slack embeds in exported messages
+type SlackUserProfile struct {
+	Name         string `json:"name"`
+	RealName     string `json:"real_name"`
+	DisplayName  string `json:"display_name"`
+	IsRestricted bool   `json:"is_restricted"`
+}
+
+// SlackStyle is the style of a rich text element. Lists name their style
+// ("bullet" or "ordered") while text runs carry formatting flags.
+type SlackStyle struct {
+	Name   string
+	Bold   bool `json:"bold"`
+	Italic bool `json:"italic"`
+	Strike bool `json:"strike"`
+	Code   bool `json:"code"`
+}
+
+// UnmarshalJSON accepts either form of style
+func (s *SlackStyle) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &s.Name)
+	}
+
+	type flags SlackStyle
+	return json.Unmarshal(data, (*flags)(s))
+}
+
+// SlackBotProfile is the bot or app profile Slack embeds in messages posted
+// by integrations
+type SlackBotProfile struct {
+	ID      string `json:"id"`
+	AppID   string `json:"app_id"`
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted"`
+}
+
+// SlackIntegrationLog is an entry of integration_logs.json, recording an
+// app, bot or service being added to or removed from the workspace
+type SlackIntegrationLog struct {
+	BotID       string `json:"bot_id"`
+	BotName     string `json:"bot_name"`
+	AppID       string `json:"app_id"`
+	ServiceID   string `json:"service_id"`
+	ServiceType string `json:"service_type"`
+	ChangeType  string `json:"change_type"`
+}
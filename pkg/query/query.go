@@ -0,0 +1,215 @@
+// Package query parses Slack-style search strings such as
+//
+//	from:@liggitt in:#sig-auth after:2023-01-01 has:link "bound tokens"
+//
+// into full-text search terms plus structured filters.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Query is a parsed search string
+type Query struct {
+	// Text is the free text left after removing filters, in FTS syntax
+	Text string
+	// From lists user names, display names or IDs; a message matches if it
+	// was posted by any of them
+	From []string
+	// In lists databases (channels) to search
+	In []string
+	// Since and Until bound message dates; Since is inclusive and Until is
+	// exclusive. Zero values leave that side open.
+	Since time.Time
+	Until time.Time
+	// Has lists content a message must contain (see HasValues)
+	Has []string
+	// Tags lists tags a message, or the thread it belongs to, must carry
+	Tags []string
+}
+
+// HasValues are the content types accepted by has:
+var HasValues = []string{models.HasLink, models.HasCode, models.HasFile, models.HasReaction, models.HasLogs}
+
+// Parse splits a search string into free text and filters. Recognised
+// filters are from:, in:, after:, before:, on:, during:, has: and tag:. Anything
+// else, including quoted phrases and FTS operators, is kept as free text.
+func Parse(s string) (*Query, error) {
+	q := &Query{}
+
+	var text []string
+	for _, token := range tokenize(s) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || value == "" || strings.HasPrefix(key, `"`) {
+			text = append(text, token)
+			continue
+		}
+		value = strings.Trim(value, `"`)
+
+		var err error
+		switch strings.ToLower(key) {
+		case "from":
+			q.From = append(q.From, strings.TrimPrefix(value, "@"))
+		case "in":
+			q.In = append(q.In, strings.TrimPrefix(value, "#"))
+		case "after":
+			var day time.Time
+			if day, err = parseDay(key, value); err == nil {
+				q.Since = later(q.Since, day.AddDate(0, 0, 1))
+			}
+		case "before":
+			var day time.Time
+			if day, err = parseDay(key, value); err == nil {
+				q.Until = earlier(q.Until, day)
+			}
+		case "on":
+			var day time.Time
+			if day, err = parseDay(key, value); err == nil {
+				q.Since = later(q.Since, day)
+				q.Until = earlier(q.Until, day.AddDate(0, 0, 1))
+			}
+		case "during":
+			var start, end time.Time
+			if start, end, err = parsePeriod(value); err == nil {
+				q.Since = later(q.Since, start)
+				q.Until = earlier(q.Until, end)
+			}
+		case "has":
+			var has string
+			if has, err = ParseHas(value); err == nil {
+				q.Has = append(q.Has, has)
+			}
+		case "tag":
+			var tag string
+			if tag, err = ParseTag(value); err == nil {
+				q.Tags = append(q.Tags, tag)
+			}
+		default:
+			// Not a filter, e.g. a URL or a term containing a colon
+			text = append(text, token)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// HasFilters reports whether the query restricts results beyond its text
+func (q *Query) HasFilters() bool {
+	return len(q.From) > 0 || !q.Since.IsZero() || !q.Until.IsZero() || len(q.Has) > 0 || len(q.Tags) > 0
+}
+
+// Filter returns the SQL-level filters of the query
+func (q *Query) Filter() models.SearchFilter {
+	has := append([]string(nil), q.Has...)
+	sort.Strings(has)
+	tags := append([]string(nil), q.Tags...)
+	sort.Strings(tags)
+
+	return models.SearchFilter{
+		Users: q.From,
+		Since: q.Since,
+		Until: q.Until,
+		Has:   has,
+		Tags:  tags,
+	}
+}
+
+// tokenize splits on whitespace, keeping double-quoted phrases (including
+// quoted filter values such as from:"Jordan Liggitt") together
+func tokenize(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t' || r == '\n') && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens
+}
+
+// parseDay parses a YYYY-MM-DD date in local time
+func parseDay(key, value string) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: date %q (expected YYYY-MM-DD)", key, value)
+	}
+	return day, nil
+}
+
+// parsePeriod parses a during: value, which may be a year, a month or a day
+func parsePeriod(value string) (time.Time, time.Time, error) {
+	if start, err := time.ParseInLocation("2006", value, time.Local); err == nil {
+		return start, start.AddDate(1, 0, 0), nil
+	}
+	if start, err := time.ParseInLocation("2006-01", value, time.Local); err == nil {
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	if start, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return start, start.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid during: period %q (expected YYYY, YYYY-MM or YYYY-MM-DD)", value)
+}
+
+// ParseHas validates a has: value, returning it in canonical form
+func ParseHas(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, v := range HasValues {
+		if v == value {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("unknown has: filter %q (supported: %s)", value, strings.Join(HasValues, ", "))
+}
+
+// tagPattern is the form of a tag once lowercased
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ParseTag validates a tag name, such as needs-doc, returning it lowercased
+func ParseTag(value string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(value))
+	if !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("invalid tag %q: use letters, digits, '.', '_' and '-'", value)
+	}
+	return tag, nil
+}
+
+// later returns the later of two lower bounds, treating zero as unset
+func later(current, t time.Time) time.Time {
+	if current.IsZero() || t.After(current) {
+		return t
+	}
+	return current
+}
+
+// earlier returns the earlier of two upper bounds, treating zero as unset
+func earlier(current, t time.Time) time.Time {
+	if current.IsZero() || t.Before(current) {
+		return t
+	}
+	return current
+}
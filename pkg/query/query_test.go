@@ -0,0 +1,138 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  Query
+	}{
+		{"text only", "kubelet certificate", Query{Text: "kubelet certificate"}},
+		{"from", "from:@liggitt from:U123 tokens",
+			Query{Text: "tokens", From: []string{"liggitt", "U123"}}},
+		{"quoted from", `from:"Jordan Liggitt" tokens`,
+			Query{Text: "tokens", From: []string{"Jordan Liggitt"}}},
+		{"in", "in:#sig-auth in:sig-node", Query{In: []string{"sig-auth", "sig-node"}}},
+		{"keys are case insensitive", "FROM:liggitt In:#sig-auth",
+			Query{From: []string{"liggitt"}, In: []string{"sig-auth"}}},
+
+		// after: and before: both leave out the day they name
+		{"after", "after:2023-01-01", Query{Since: day(2023, time.January, 2)}},
+		{"before", "before:2023-01-01", Query{Until: day(2023, time.January, 1)}},
+		{"on", "on:2023-05-15",
+			Query{Since: day(2023, time.May, 15), Until: day(2023, time.May, 16)}},
+		{"during year", "during:2023",
+			Query{Since: day(2023, time.January, 1), Until: day(2024, time.January, 1)}},
+		{"during month", "during:2023-12",
+			Query{Since: day(2023, time.December, 1), Until: day(2024, time.January, 1)}},
+		{"during day", "during:2023-05-15",
+			Query{Since: day(2023, time.May, 15), Until: day(2023, time.May, 16)}},
+		{"date filters narrow each other", "during:2023 after:2023-03-01 before:2023-04-01 after:2022-06-01",
+			Query{Since: day(2023, time.March, 2), Until: day(2023, time.April, 1)}},
+
+		{"has", "has:link has:CODE", Query{Has: []string{"link", "code"}}},
+		{"tag", "tag:Needs-Doc tag:flake", Query{Tags: []string{"needs-doc", "flake"}}},
+
+		{"quoted phrase", `"bound tokens" from:liggitt`,
+			Query{Text: `"bound tokens"`, From: []string{"liggitt"}}},
+		{"quoted phrase with a colon", `"error: context deadline exceeded"`,
+			Query{Text: `"error: context deadline exceeded"`}},
+		{"unknown keys are text", "https://kubernetes.io user_name:liggitt",
+			Query{Text: "https://kubernetes.io user_name:liggitt"}},
+		{"empty value is text", "from: kubelet", Query{Text: "from: kubelet"}},
+		{"operators are text", "kubelet NOT (certificate OR csr*)",
+			Query{Text: "kubelet NOT (certificate OR csr*)"}},
+		{"extra whitespace", " kubelet\t\tin:#sig-node\n", Query{Text: "kubelet", In: []string{"sig-node"}}},
+		{"empty", "", Query{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"after:yesterday",
+		"before:2023-13-01",
+		"on:2023/05/15",
+		"during:May",
+		"during:2023-5",
+		"has:image",
+		"tag:needs/doc",
+		`tag:"needs doc"`,
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if got, err := Parse(input); err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", input, *got)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"kubelet  certificate", []string{"kubelet", "certificate"}},
+		{`"bound service account tokens" kubelet`, []string{`"bound service account tokens"`, "kubelet"}},
+		{`from:"Jordan Liggitt" in:#sig-auth`, []string{`from:"Jordan Liggitt"`, "in:#sig-auth"}},
+		// An unterminated quote runs to the end
+		{`"bound tokens kubelet`, []string{`"bound tokens kubelet`}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := tokenize(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	q, err := Parse("from:liggitt has:link has:code tag:flake tag:api on:2023-05-15 kubelet")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !q.HasFilters() {
+		t.Error("HasFilters = false, want true")
+	}
+
+	filter := q.Filter()
+	if !reflect.DeepEqual(filter.Has, []string{"code", "link"}) {
+		t.Errorf("filter has %q, want it sorted", filter.Has)
+	}
+	if !reflect.DeepEqual(filter.Tags, []string{"api", "flake"}) {
+		t.Errorf("filter tags %q, want them sorted", filter.Tags)
+	}
+	if !reflect.DeepEqual(q.Has, []string{"link", "code"}) {
+		t.Errorf("Filter reordered the query's has: values to %q", q.Has)
+	}
+
+	// in: picks databases rather than filtering messages
+	if q, _ := Parse("in:#sig-auth kubelet"); q.HasFilters() {
+		t.Error("HasFilters = true for a query with only in:, want false")
+	}
+}
@@ -0,0 +1,109 @@
+// Package scheduler runs jobs at fixed intervals and logs the outcome of
+// every run
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// Logger receives a line for each run. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Job is a task run every Every
+type Job struct {
+	Name  string
+	Every time.Duration
+	// Run performs the task and returns a short description of what it did
+	Run func(ctx context.Context) (string, error)
+}
+
+// Scheduler runs jobs one at a time, so jobs writing the same database
+// never overlap
+type Scheduler struct {
+	jobs   []Job
+	logger Logger
+}
+
+// New creates a scheduler for jobs that logs to logger
+func New(jobs []Job, logger Logger) *Scheduler {
+	return &Scheduler{jobs: jobs, logger: logger}
+}
+
+// RunOnce runs every job once, in order, and returns the number that
+// failed
+func (s *Scheduler) RunOnce(ctx context.Context) int {
+	failed := 0
+	for _, job := range s.jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		if !s.run(ctx, job) {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Run runs every job immediately and then each time its interval elapses,
+// until ctx is cancelled. A job that overruns its interval runs again as
+// soon as it finishes rather than catching up on missed runs.
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		<-ctx.Done()
+		return
+	}
+
+	next := make([]time.Time, len(s.jobs))
+	now := time.Now()
+	for i := range next {
+		next[i] = now
+	}
+
+	for {
+		due := 0
+		for i := range next {
+			if next[i].Before(next[due]) {
+				due = i
+			}
+		}
+
+		timer := time.NewTimer(time.Until(next[due]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		job := s.jobs[due]
+		s.run(ctx, job)
+		next[due] = next[due].Add(job.Every)
+		if now := time.Now(); next[due].Before(now) {
+			next[due] = now
+		}
+		s.logger.Printf("%s: next run at %s", job.Name, next[due].Format(time.RFC3339))
+	}
+}
+
+// run runs a job and logs its outcome, reporting whether it succeeded
+func (s *Scheduler) run(ctx context.Context, job Job) bool {
+	started := time.Now()
+	summary, err := job.Run(ctx)
+	elapsed := time.Since(started).Round(time.Millisecond)
+
+	if err != nil {
+		s.logger.Printf("%s: failed after %s: %v", job.Name, elapsed, err)
+		return false
+	}
+	if summary == "" {
+		summary = "done"
+	}
+	s.logger.Printf("%s: ok in %s: %s", job.Name, elapsed, summary)
+	return true
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder collects log lines. A scheduler logs from one goroutine at a
+// time, so it needs no locking.
+type recorder struct {
+	lines []string
+}
+
+func (r *recorder) Printf(format string, v ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, v...))
+}
+
+func TestRunOnce(t *testing.T) {
+	var ran []string
+	job := func(name, summary string, err error) Job {
+		return Job{Name: name, Every: time.Hour, Run: func(ctx context.Context) (string, error) {
+			ran = append(ran, name)
+			return summary, err
+		}}
+	}
+
+	logger := &recorder{}
+	s := New([]Job{
+		job("sync", "2 new messages", nil),
+		job("digest", "", errors.New("no SMTP server")),
+		job("optimize", "", nil),
+	}, logger)
+
+	if failed := s.RunOnce(context.Background()); failed != 1 {
+		t.Errorf("RunOnce = %d failed, want 1", failed)
+	}
+	if want := []string{"sync", "digest", "optimize"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+
+	tests := []struct {
+		prefix, suffix string
+	}{
+		{"sync: ok in ", ": 2 new messages"},
+		{"digest: failed after ", ": no SMTP server"},
+		{"optimize: ok in ", ": done"},
+	}
+	if len(logger.lines) != len(tests) {
+		t.Fatalf("logged %q, want %d lines", logger.lines, len(tests))
+	}
+	for i, tt := range tests {
+		if line := logger.lines[i]; !strings.HasPrefix(line, tt.prefix) || !strings.HasSuffix(line, tt.suffix) {
+			t.Errorf("line %d = %q, want %q...%q", i, line, tt.prefix, tt.suffix)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran = nil
+	if s.RunOnce(ctx); len(ran) != 0 {
+		t.Errorf("RunOnce with a cancelled context ran %q", ran)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		every []time.Duration
+		// Stop once this many runs have happened
+		runs int
+		want []int
+	}{
+		{"every job runs at once, in order", []time.Duration{time.Hour, time.Hour, time.Hour}, 3, []int{0, 1, 2}},
+		{"the shorter interval runs more often", []time.Duration{20 * time.Millisecond, time.Hour}, 4, []int{0, 1, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var ran []int
+			var jobs []Job
+			for i, every := range tt.every {
+				i := i
+				jobs = append(jobs, Job{Name: fmt.Sprint("job", i), Every: every, Run: func(ctx context.Context) (string, error) {
+					ran = append(ran, i)
+					if len(ran) == tt.runs {
+						cancel()
+					}
+					return "", nil
+				}})
+			}
+
+			runUntilDone(t, ctx, New(jobs, &recorder{}))
+			if !reflect.DeepEqual(ran, tt.want) {
+				t.Errorf("ran jobs %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAfterOverrun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const every = 20 * time.Millisecond
+	var started []time.Time
+	job := Job{Name: "sync", Every: every, Run: func(ctx context.Context) (string, error) {
+		started = append(started, time.Now())
+		switch len(started) {
+		case 1:
+			// Overrun by three intervals
+			time.Sleep(3*every + every/2)
+		case 4:
+			cancel()
+		}
+		return "", nil
+	}}
+
+	runUntilDone(t, ctx, New([]Job{job}, &recorder{}))
+	if len(started) != 4 {
+		t.Fatalf("ran %d times, want 4", len(started))
+	}
+	// The run after the overrun starts at once, but the missed runs are
+	// not made up with a burst after it
+	for i := 2; i < len(started); i++ {
+		if gap := started[i].Sub(started[i-1]); gap < every/2 {
+			t.Errorf("run %d started %s after the one before, want about %s", i+1, gap, every)
+		}
+	}
+}
+
+func TestRunWithoutJobs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	runUntilDone(t, ctx, New(nil, &recorder{}))
+}
+
+// runUntilDone runs s and fails the test if it doesn't stop soon after ctx
+// is cancelled
+func runUntilDone(t *testing.T, ctx context.Context, s *Scheduler) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+}
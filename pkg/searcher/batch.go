@@ -0,0 +1,124 @@
+package searcher
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Batch report formats
+const (
+	BatchFormatJSON = "json"
+	BatchFormatCSV  = "csv"
+)
+
+// BatchReport collects the outcome of running several queries, for
+// monitoring topics across archives
+type BatchReport struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Queries     []BatchQuery `json:"queries"`
+}
+
+// BatchQuery is one query's hit count and top results
+type BatchQuery struct {
+	Query     string        `json:"query"`
+	Databases []string      `json:"databases"`
+	Hits      int           `json:"hits"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchResult is a single result in a batch report
+type BatchResult struct {
+	Database  string    `json:"database"`
+	Timestamp string    `json:"ts"`
+	Date      time.Time `json:"date"`
+	UserName  string    `json:"user_name"`
+	Text      string    `json:"text"`
+}
+
+// NewBatchResult converts a search result for a batch report
+func NewBatchResult(database string, result *models.SearchResult) BatchResult {
+	if result.Database != "" {
+		database = result.Database
+	}
+	user := result.UserName
+	if user == "" {
+		user = result.UserID
+	}
+	return BatchResult{
+		Database:  database,
+		Timestamp: result.Timestamp,
+		Date:      result.Date,
+		UserName:  user,
+		Text:      result.Text,
+	}
+}
+
+// BatchFormatForPath picks the report format from a file extension
+func BatchFormatForPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return BatchFormatJSON, nil
+	case ".csv":
+		return BatchFormatCSV, nil
+	default:
+		return "", fmt.Errorf("unsupported report file %s: use a .json or .csv extension", path)
+	}
+}
+
+// WriteBatchReport writes the report as indented JSON or as CSV
+func WriteBatchReport(w io.Writer, format string, report *BatchReport) error {
+	if format == BatchFormatCSV {
+		return writeBatchCSV(w, report)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeBatchCSV writes one row per result, repeating the query and its hit
+// count; queries without results get a single row with empty result columns
+func writeBatchCSV(w io.Writer, report *BatchReport) error {
+	writer := csv.NewWriter(w)
+	header := []string{"query", "hits", "position", "database", "ts", "date", "user", "text"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, query := range report.Queries {
+		hits := strconv.Itoa(query.Hits)
+		if len(query.Results) == 0 {
+			if err := writer.Write([]string{query.Query, hits, "", "", "", "", "", ""}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i, result := range query.Results {
+			row := []string{
+				query.Query,
+				hits,
+				strconv.Itoa(i + 1),
+				result.Database,
+				result.Timestamp,
+				result.Date.Format(time.RFC3339),
+				result.UserName,
+				result.Text,
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
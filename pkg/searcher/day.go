@@ -0,0 +1,155 @@
+package searcher
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// DayPage is the data model passed to the day page template: every message
+// posted in a channel on one day, in reading order, with links to the days
+// either side
+type DayPage struct {
+	Database string
+	Day      time.Time
+	Messages []*models.Message
+	// Previous and Next are the nearest days before and after with
+	// messages; zero when there are none
+	Previous time.Time
+	Next     time.Time
+	// PreviousURL and NextURL link to the pages for Previous and Next;
+	// empty when those pages were not rendered
+	PreviousURL string
+	NextURL     string
+	// ReportURL links back to the search report the page belongs to
+	ReportURL string
+	// Hits holds the timestamps of the search results on the page
+	Hits        map[string]bool
+	GeneratedAt time.Time
+	ThemeCSS    template.CSS
+}
+
+// DayPageName returns the path, relative to the day pages directory, of a
+// database's page for a day
+func DayPageName(database string, day time.Time) string {
+	return path.Join(database, day.Format("2006-01-02")+".html")
+}
+
+// DayPage loads the messages posted on a day, as Browse does, along with
+// the nearest days either side that have messages
+func (s *Searcher) DayPage(ctx context.Context, day time.Time) (*DayPage, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	messages, err := s.Browse(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, next, err := s.db.GetAdjacentMessageDates(ctx, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &DayPage{
+		Database:    s.channelName,
+		Day:         start,
+		Messages:    messages,
+		Hits:        make(map[string]bool),
+		GeneratedAt: time.Now(),
+	}
+	if !previous.IsZero() {
+		previous = previous.In(day.Location())
+		page.Previous = time.Date(previous.Year(), previous.Month(), previous.Day(), 0, 0, 0, 0, day.Location())
+	}
+	if !next.IsZero() {
+		next = next.In(day.Location())
+		page.Next = time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, day.Location())
+	}
+
+	return page, nil
+}
+
+// ResultDayPages returns the day pages for a set of search results from
+// this database: the day of each result, marked with the results posted on
+// it, and the days either side so every result page can be navigated
+// from. Links between pages are filled in where both pages are returned.
+func (s *Searcher) ResultDayPages(ctx context.Context, results []*models.SearchResult) ([]*DayPage, error) {
+	pages := make(map[string]*DayPage)
+	load := func(day time.Time) (*DayPage, error) {
+		key := day.Format("2006-01-02")
+		if page, ok := pages[key]; ok {
+			return page, nil
+		}
+		page, err := s.DayPage(ctx, day)
+		if err != nil {
+			return nil, err
+		}
+		pages[key] = page
+		return page, nil
+	}
+
+	var resultDays []*DayPage
+	for _, result := range results {
+		page, err := load(result.Date.Local())
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Hits) == 0 {
+			resultDays = append(resultDays, page)
+		}
+		page.Hits[result.Timestamp] = true
+	}
+	for _, page := range resultDays {
+		for _, day := range []time.Time{page.Previous, page.Next} {
+			if day.IsZero() {
+				continue
+			}
+			if _, err := load(day); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ordered := make([]*DayPage, 0, len(pages))
+	for _, page := range pages {
+		if !page.Previous.IsZero() && pages[page.Previous.Format("2006-01-02")] != nil {
+			page.PreviousURL = path.Base(DayPageName(page.Database, page.Previous))
+		}
+		if !page.Next.IsZero() && pages[page.Next.Format("2006-01-02")] != nil {
+			page.NextURL = path.Base(DayPageName(page.Database, page.Next))
+		}
+		ordered = append(ordered, page)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Day.Before(ordered[j].Day)
+	})
+
+	return ordered, nil
+}
+
+// GenerateDayHTML renders a day page as a standalone HTML page using one of
+// the built-in themes
+func GenerateDayHTML(w io.Writer, page *DayPage, theme string) error {
+	css, err := ThemeCSS(theme)
+	if err != nil {
+		return err
+	}
+	page.ThemeCSS = css
+
+	tmpl, err := template.New("day.html").Funcs(templateFuncs()).ParseFS(htmlAssets, "templates/day.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, page); err != nil {
+		return fmt.Errorf("failed to render day page: %w", err)
+	}
+
+	return nil
+}
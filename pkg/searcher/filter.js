@@ -0,0 +1,53 @@
+// Filters the results of a report in place, without a server. Each result
+// is an article.result carrying data-user and data-date (YYYY-MM-DD).
+(function () {
+  var form = document.getElementById("filters");
+  if (!form) {
+    return;
+  }
+  var results = Array.prototype.slice.call(document.querySelectorAll("article.result"));
+  var text = form.querySelector("[name=text]");
+  var user = form.querySelector("[name=user]");
+  var from = form.querySelector("[name=from]");
+  var to = form.querySelector("[name=to]");
+  var status = form.querySelector(".filter-status");
+
+  var users = {};
+  results.forEach(function (result) {
+    users[result.getAttribute("data-user")] = true;
+  });
+  Object.keys(users).sort().forEach(function (name) {
+    var option = document.createElement("option");
+    option.value = name;
+    option.textContent = name;
+    user.appendChild(option);
+  });
+
+  function apply() {
+    var words = text.value.toLowerCase().split(/\s+/).filter(Boolean);
+    var shown = 0;
+    results.forEach(function (result) {
+      var content = result.textContent.toLowerCase();
+      var date = result.getAttribute("data-date");
+      var match = words.every(function (word) { return content.indexOf(word) !== -1; }) &&
+        (!user.value || result.getAttribute("data-user") === user.value) &&
+        (!from.value || date >= from.value) &&
+        (!to.value || date <= to.value);
+      result.hidden = !match;
+      if (match) {
+        shown++;
+      }
+    });
+    status.textContent = "Showing " + shown + " of " + results.length + " result(s)";
+  }
+
+  form.addEventListener("input", apply);
+  form.addEventListener("reset", function () {
+    setTimeout(apply, 0);
+  });
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+  });
+  form.hidden = false;
+  apply();
+})();
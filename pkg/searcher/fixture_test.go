@@ -0,0 +1,96 @@
+package searcher_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/testutil"
+)
+
+// Dates are read back in the local time zone; the golden files are in UTC,
+// as make e2e runs
+func TestMain(m *testing.M) {
+	time.Local = time.UTC
+	os.Exit(m.Run())
+}
+
+func TestFixtureNDJSON(t *testing.T) {
+	db := testutil.NewFixtureDB(t)
+
+	var out bytes.Buffer
+	writer, err := searcher.NewResultWriter(&out, searcher.StreamFormatNDJSON, testutil.FixtureChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.StreamMessagesFiltered(context.Background(), "kubelet", models.SearchFilter{}, 0, writer.Write)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	golden, err := os.ReadFile(testutil.GoldenFile("search.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(golden) {
+		t.Errorf("NDJSON output differs from search.ndjson\ngot:\n%s\nwant:\n%s", out.String(), golden)
+	}
+}
+
+func TestFixtureHTMLReport(t *testing.T) {
+	db := testutil.NewFixtureDB(t)
+
+	results, err := db.SearchMessagesFiltered(context.Background(), "kubelet", models.SearchFilter{}, 10)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	data := &searcher.ReportData{
+		Query:       "kubelet",
+		Database:    testutil.FixtureChannel,
+		GeneratedAt: time.Date(2024, 3, 7, 12, 0, 0, 0, time.UTC),
+		Results:     results,
+	}
+
+	for _, theme := range searcher.Themes() {
+		t.Run(theme, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := searcher.GenerateHTMLOutput(&out, data, searcher.HTMLOptions{Theme: theme}); err != nil {
+				t.Fatalf("failed to render report: %v", err)
+			}
+			html := out.String()
+
+			for _, want := range []string{
+				"<title>Search results for kubelet - sig-fixture</title>",
+				"Query: <code>kubelet</code>",
+				"Pasting the <mark>kubelet</mark> log:",
+				"The <mark>kubelet</mark> on node-1",
+			} {
+				if !strings.Contains(html, want) {
+					t.Errorf("report is missing %q", want)
+				}
+			}
+
+			// Results are listed in search order
+			var users []string
+			for _, part := range strings.Split(html, `<article class="result" data-user="`)[1:] {
+				users = append(users, part[:strings.Index(part, `"`)])
+			}
+			want := []string{"Bob Example (bob)", "Bob Example (bob)", "Alice Example (alice)"}
+			if strings.Join(users, ", ") != strings.Join(want, ", ") {
+				t.Errorf("report lists results by %v, want %v", users, want)
+			}
+		})
+	}
+
+	t.Run("unknown theme", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := searcher.GenerateHTMLOutput(&out, data, searcher.HTMLOptions{Theme: "sepia"}); err == nil {
+			t.Error("rendering with an unknown theme succeeded")
+		}
+	})
+}
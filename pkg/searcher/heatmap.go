@@ -0,0 +1,128 @@
+package searcher
+
+import (
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Heatmap cell geometry in SVG units; cells are 11 units square
+const (
+	heatmapStride = 13
+	heatmapLeft   = 28
+	heatmapTop    = 16
+)
+
+// Heatmap is a calendar of daily message counts, one grid per year, in the
+// style of GitHub's contribution graph
+type Heatmap struct {
+	Years []HeatmapYear
+	// Max is the highest daily count, which maps to the darkest level
+	Max int
+}
+
+// HeatmapYear is one year of the calendar: a column per week and a row per
+// weekday, Sunday first
+type HeatmapYear struct {
+	Year   int
+	Total  int
+	Width  int
+	Height int
+	Days   []HeatmapDay
+	Months []HeatmapLabel
+}
+
+// HeatmapDay is a single cell of the calendar
+type HeatmapDay struct {
+	Date  time.Time
+	Count int
+	// Level is 0 for no messages and 1-4 for increasing activity
+	Level int
+	X, Y  int
+}
+
+// HeatmapLabel is a month name positioned above its first week
+type HeatmapLabel struct {
+	Text string
+	X    int
+}
+
+// BuildHeatmap lays out daily counts as a calendar covering every year with
+// activity. It returns nil when there are no counts.
+func BuildHeatmap(counts []models.DayCount) *Heatmap {
+	byDay := make(map[string]int)
+	heatmap := &Heatmap{}
+	first, last := 0, 0
+	for _, count := range counts {
+		day, err := time.Parse("2006-01-02", count.Date)
+		if err != nil {
+			continue
+		}
+		byDay[count.Date] += count.Count
+		if byDay[count.Date] > heatmap.Max {
+			heatmap.Max = byDay[count.Date]
+		}
+		if first == 0 || day.Year() < first {
+			first = day.Year()
+		}
+		if day.Year() > last {
+			last = day.Year()
+		}
+	}
+	if first == 0 {
+		return nil
+	}
+
+	for year := first; year <= last; year++ {
+		heatmap.Years = append(heatmap.Years, buildHeatmapYear(year, byDay, heatmap.Max))
+	}
+
+	return heatmap
+}
+
+func buildHeatmapYear(year int, byDay map[string]int, max int) HeatmapYear {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	offset := int(start.Weekday())
+
+	result := HeatmapYear{Year: year}
+	weeks := 0
+	for day := start; day.Year() == year; day = day.AddDate(0, 0, 1) {
+		index := day.YearDay() - 1 + offset
+		week, weekday := index/7, index%7
+		weeks = week + 1
+
+		count := byDay[day.Format("2006-01-02")]
+		result.Total += count
+		result.Days = append(result.Days, HeatmapDay{
+			Date:  day,
+			Count: count,
+			Level: heatmapLevel(count, max),
+			X:     heatmapLeft + week*heatmapStride,
+			Y:     heatmapTop + weekday*heatmapStride,
+		})
+
+		if day.Day() == 1 {
+			result.Months = append(result.Months, HeatmapLabel{
+				Text: day.Format("Jan"),
+				X:    heatmapLeft + week*heatmapStride,
+			})
+		}
+	}
+
+	result.Width = heatmapLeft + weeks*heatmapStride
+	result.Height = heatmapTop + 7*heatmapStride
+
+	return result
+}
+
+// heatmapLevel buckets a count into quarters of the busiest day
+func heatmapLevel(count, max int) int {
+	if count <= 0 || max <= 0 {
+		return 0
+	}
+	level := (count*4 + max - 1) / max
+	if level > 4 {
+		level = 4
+	}
+	return level
+}
@@ -0,0 +1,210 @@
+package searcher
+
+import (
+	"strings"
+	"unicode"
+)
+
+// termColors are the ANSI styles given to query terms, in query order;
+// queries with more terms reuse them
+var termColors = []string{
+	"\x1b[1;33m", // yellow
+	"\x1b[1;36m", // cyan
+	"\x1b[1;35m", // magenta
+	"\x1b[1;32m", // green
+	"\x1b[1;31m", // red
+	"\x1b[1;34m", // blue
+}
+
+const (
+	// unknownTermColor styles matches the highlighter can't tie to a term
+	unknownTermColor = "\x1b[1m"
+	resetColor       = "\x1b[0m"
+)
+
+// QueryTerms returns the words and prefixes an FTS match expression searches
+// for, in order and without repeats: operators, column names, and terms
+// excluded with NOT are left out. The words of a phrase are returned
+// separately, as snippets mark them. Prefixes keep their trailing *.
+func QueryTerms(match string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	add := func(term string) {
+		key := strings.ToLower(term)
+		if strings.Trim(key, "*") == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		terms = append(terms, term)
+	}
+
+	// excludedDepth is the parenthesis depth of a group excluded by NOT,
+	// or -1 outside one
+	depth, excludedDepth := 0, -1
+	excludeNext := false
+	for _, token := range matchTokens(match) {
+		switch {
+		case token == "(":
+			if excludeNext && excludedDepth < 0 {
+				excludedDepth = depth
+			}
+			excludeNext = false
+			depth++
+			continue
+		case token == ")":
+			depth--
+			if depth == excludedDepth {
+				excludedDepth = -1
+			}
+			continue
+		case token == "AND" || token == "OR" || token == "NEAR" || strings.HasPrefix(token, "NEAR/"):
+			continue
+		case token == "NOT":
+			excludeNext = true
+			continue
+		}
+
+		excluded := excludeNext || excludedDepth >= 0
+		excludeNext = false
+		if excluded {
+			continue
+		}
+
+		// Column filters such as user_name:liggitt
+		if i := strings.Index(token, ":"); i >= 0 && !strings.HasPrefix(token, `"`) {
+			token = token[i+1:]
+		}
+		if strings.HasPrefix(token, `"`) {
+			// A prefix inside a phrase keeps its *, as in "cert*"
+			for _, word := range strings.Fields(strings.Trim(token, `"`)) {
+				add(word)
+			}
+			continue
+		}
+		add(token)
+	}
+
+	return terms
+}
+
+// matchTokens splits a match expression into parentheses, quoted phrases
+// and words
+func matchTokens(match string) []string {
+	var tokens []string
+	for i := 0; i < len(match); {
+		switch c := match[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := strings.IndexByte(match[i+1:], '"')
+			if end < 0 {
+				end = len(match) - i - 1
+			} else {
+				end += i + 2
+			}
+			if end > len(match) {
+				end = len(match)
+			}
+			tokens = append(tokens, match[i:end])
+			i = end
+		default:
+			end := i
+			for end < len(match) && !strings.ContainsRune(" \t\n()\"", rune(match[end])) {
+				end++
+			}
+			// A phrase right after a column name belongs to it
+			if end < len(match) && match[end] == '"' && strings.HasSuffix(match[i:end], ":") {
+				i = end
+				continue
+			}
+			tokens = append(tokens, match[i:end])
+			i = end
+		}
+	}
+	return tokens
+}
+
+// Highlighter colors the matches FTS snippets mark with <mark> tags for a
+// terminal, each query term in its own color
+type Highlighter struct {
+	terms []string
+	// parts holds the lower-cased words each term is indexed as, since
+	// identifiers such as kube-apiserver match as several words
+	parts [][]string
+}
+
+// NewHighlighter returns a highlighter for the given query terms, as
+// returned by QueryTerms
+func NewHighlighter(terms []string) *Highlighter {
+	h := &Highlighter{terms: terms}
+	for _, term := range terms {
+		h.parts = append(h.parts, strings.FieldsFunc(strings.ToLower(strings.TrimSuffix(term, "*")), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}))
+	}
+	return h
+}
+
+// Legend lists the query terms, each in its color, or returns "" for a
+// single term, which needs no legend
+func (h *Highlighter) Legend() string {
+	if h == nil || len(h.terms) < 2 {
+		return ""
+	}
+
+	var legend strings.Builder
+	legend.WriteString("Terms:")
+	for i, term := range h.terms {
+		legend.WriteString(" " + termColors[i%len(termColors)] + term + resetColor)
+	}
+	return legend.String()
+}
+
+// Highlight replaces the <mark> tags in a snippet with the color of the
+// term each marked word matches. A nil highlighter leaves text unchanged.
+func (h *Highlighter) Highlight(text string) string {
+	if h == nil {
+		return text
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.Index(text, "<mark>")
+		if start < 0 {
+			out.WriteString(text)
+			break
+		}
+		out.WriteString(text[:start])
+		text = text[start+len("<mark>"):]
+
+		// A snippet cut short may lose its closing tag
+		end := strings.Index(text, "</mark>")
+		word := text
+		if end >= 0 {
+			word = text[:end]
+			text = text[end+len("</mark>"):]
+		} else {
+			text = ""
+		}
+		out.WriteString(h.color(word) + word + resetColor)
+	}
+
+	return out.String()
+}
+
+// color returns the color of the term a marked word matches
+func (h *Highlighter) color(word string) string {
+	word = strings.ToLower(word)
+	for i, term := range h.terms {
+		prefix := strings.HasSuffix(term, "*")
+		for _, part := range h.parts[i] {
+			if word == part || (prefix && strings.HasPrefix(word, part)) {
+				return termColors[i%len(termColors)]
+			}
+		}
+	}
+	return unknownTermColor
+}
@@ -0,0 +1,209 @@
+package searcher
+
+import (
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+//go:embed templates/*.html themes/*.css print.css filter.js
+var htmlAssets embed.FS
+
+const (
+	// AutoTheme follows the reader's light or dark preference, using the
+	// light and dark themes
+	AutoTheme = "auto"
+	// DefaultTheme is the built-in theme used when none is specified
+	DefaultTheme = AutoTheme
+)
+
+// ReportData is the data model passed to HTML report templates.
+// Custom templates loaded from disk receive the same structure.
+type ReportData struct {
+	Query       string
+	Database    string
+	GeneratedAt time.Time
+	Results     []*models.SearchResult
+	// Activity is an optional calendar of messages per day for the
+	// searched databases
+	Activity *Heatmap
+	// Summary is an optional overview of the results: matches per month,
+	// top authors and co-occurring terms
+	Summary *ReportSummary
+	// Media holds the files shared in the results, when embedding them
+	// was asked for; see the media template function
+	Media ReportMedia
+	// DayPages is the directory, relative to the report, holding a day
+	// page for each result's day; empty when none were written
+	DayPages string
+	ThemeCSS template.CSS
+	// FilterScript filters the rendered results by text, user and date in
+	// the browser; see templates/report.html for the markup it expects
+	FilterScript template.JS
+}
+
+// HTMLOptions controls how GenerateHTMLOutput renders a report
+type HTMLOptions struct {
+	// TemplatePath is an optional path to a user-supplied template file
+	TemplatePath string
+	// Theme is the name of a built-in theme (see Themes)
+	Theme string
+}
+
+// Themes returns the names of the built-in HTML themes
+func Themes() []string {
+	entries, err := htmlAssets.ReadDir("themes")
+	if err != nil {
+		return nil
+	}
+
+	themes := []string{AutoTheme}
+	for _, entry := range entries {
+		themes = append(themes, strings.TrimSuffix(entry.Name(), ".css"))
+	}
+	sort.Strings(themes)
+
+	return themes
+}
+
+// ThemeCSS returns the stylesheet for a built-in theme, falling back to
+// DefaultTheme when no theme is given. Every theme prints in black on white,
+// without the heatmap and navigation, and keeps each result on one page.
+func ThemeCSS(theme string) (template.CSS, error) {
+	if theme == "" {
+		theme = DefaultTheme
+	}
+
+	var css string
+	if theme == AutoTheme {
+		light, err := htmlAssets.ReadFile("themes/light.css")
+		if err != nil {
+			return "", err
+		}
+		dark, err := htmlAssets.ReadFile("themes/dark.css")
+		if err != nil {
+			return "", err
+		}
+		css = string(light) + "@media (prefers-color-scheme: dark) {\n" + string(dark) + "}\n"
+	} else {
+		themeCSS, err := htmlAssets.ReadFile("themes/" + theme + ".css")
+		if err != nil {
+			return "", fmt.Errorf("unknown theme %q (available: %s)", theme, strings.Join(Themes(), ", "))
+		}
+		css = string(themeCSS)
+	}
+
+	printCSS, err := htmlAssets.ReadFile("print.css")
+	if err != nil {
+		return "", err
+	}
+
+	return template.CSS(css + string(printCSS)), nil
+}
+
+// GenerateHTMLOutput renders search results as a standalone HTML report
+func GenerateHTMLOutput(w io.Writer, data *ReportData, opts HTMLOptions) error {
+	css, err := ThemeCSS(opts.Theme)
+	if err != nil {
+		return err
+	}
+	data.ThemeCSS = css
+
+	script, err := htmlAssets.ReadFile("filter.js")
+	if err != nil {
+		return err
+	}
+	data.FilterScript = template.JS(script)
+
+	tmpl := template.New("report.html").Funcs(templateFuncs())
+	if opts.TemplatePath != "" {
+		tmpl, err = tmpl.ParseFiles(opts.TemplatePath)
+		if err == nil {
+			tmpl = tmpl.Lookup(filepath.Base(opts.TemplatePath))
+		}
+	} else {
+		tmpl, err = tmpl.ParseFS(htmlAssets, "templates/report.html")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return nil
+}
+
+// templateFuncs returns the helper functions available to report templates
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"inc": func(i int) int {
+			return i + 1
+		},
+		"formatDate": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04:05")
+		},
+		"formatDay": func(t time.Time) string {
+			return t.Format("Mon 2006-01-02")
+		},
+		"formatMonth": func(t time.Time) string {
+			return t.Format("Jan 2006")
+		},
+		"isoDate": func(t time.Time) string {
+			return t.Format("2006-01-02")
+		},
+		"displayName": displayUserName,
+		"messageName": messageUserName,
+		"highlight":   highlightHTML,
+		"dayLink":     dayLink,
+		"media":       resultMedia,
+	}
+}
+
+// dayLink returns the link from a report to the day page showing a result,
+// or an empty string when the report has no day pages
+func dayLink(data *ReportData, result *models.SearchResult) string {
+	if data.DayPages == "" {
+		return ""
+	}
+	database := result.Database
+	if database == "" {
+		database = data.Database
+	}
+	return path.Join(data.DayPages, DayPageName(database, result.Date.Local())) + "#ts-" + result.Timestamp
+}
+
+// resultMedia returns the files shared in a result, or nil when the report
+// doesn't embed media
+func resultMedia(data *ReportData, result *models.SearchResult) []MediaItem {
+	database := result.Database
+	if database == "" {
+		database = data.Database
+	}
+	return data.Media[mediaKey(database, result.Timestamp)]
+}
+
+// highlightHTML escapes a result's message text while preserving the
+// <mark> tags inserted by the FTS snippet function
+func highlightHTML(result *models.SearchResult) template.HTML {
+	text := result.Text
+	if result.Snippet != "" {
+		text = result.Snippet
+	}
+
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, "&lt;mark&gt;", "<mark>")
+	escaped = strings.ReplaceAll(escaped, "&lt;/mark&gt;", "</mark>")
+
+	return template.HTML(escaped)
+}
@@ -0,0 +1,116 @@
+package searcher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// MaxEmbedSize is the largest local image embedded in a report as a data
+// URI; larger ones are linked instead
+const MaxEmbedSize = 2 << 20
+
+// MediaItem is a file shared in a result, as shown in a report
+type MediaItem struct {
+	Name string
+	// Size is the file's size for display, e.g. "1.2 MB"
+	Size string
+	// Link opens the file: its local copy, or its Slack permalink
+	Link template.URL
+	// Image is the image or thumbnail shown, empty for other files
+	Image template.URL
+	// Preview is the start of a text file's contents
+	Preview string
+}
+
+// ReportMedia holds the files shared in a report's results, by database
+// and message timestamp
+type ReportMedia map[string][]MediaItem
+
+// AddFiles adds the files shared in a database's messages. Local copies
+// of images up to maxSize bytes are embedded; other images are shown from
+// their Slack thumbnails, which need a Slack login in the browser.
+func (m ReportMedia) AddFiles(database string, files map[string][]models.MessageFile, maxSize int64) {
+	for ts, shared := range files {
+		for _, file := range shared {
+			m[mediaKey(database, ts)] = append(m[mediaKey(database, ts)], mediaItem(file, maxSize))
+		}
+	}
+}
+
+func mediaKey(database, ts string) string {
+	return database + "\x00" + ts
+}
+
+func mediaItem(file models.MessageFile, maxSize int64) MediaItem {
+	item := MediaItem{
+		Name:    file.Title,
+		Size:    FormatSize(file.Size),
+		Link:    webURL(file.Permalink),
+		Preview: file.Preview,
+	}
+	if item.Name == "" {
+		item.Name = file.Name
+	}
+	isImage := strings.HasPrefix(file.Mimetype, "image/")
+
+	if file.LocalPath != "" {
+		if info, err := os.Stat(file.LocalPath); err == nil {
+			item.Link = template.URL((&url.URL{Scheme: "file", Path: filepath.ToSlash(file.LocalPath)}).String())
+			if isImage && info.Size() <= maxSize {
+				if data, err := os.ReadFile(file.LocalPath); err == nil {
+					item.Image = dataURI(data)
+				}
+			}
+		}
+	}
+	if item.Image == "" && isImage {
+		item.Image = webURL(file.Thumbnail)
+	}
+	if item.Link == "" {
+		item.Link = webURL(file.URL)
+	}
+
+	return item
+}
+
+// dataURI encodes an image to embed in a report, or returns an empty
+// string if the data isn't an image a browser can show
+func dataURI(data []byte) template.URL {
+	mimetype := http.DetectContentType(data)
+	if !strings.HasPrefix(mimetype, "image/") {
+		return ""
+	}
+	return template.URL("data:" + mimetype + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// webURL returns an http or https link for a report, or an empty string
+// for links of any other scheme
+func webURL(link string) template.URL {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ""
+	}
+	return template.URL(link)
+}
+
+// FormatSize describes a file size in bytes, KB or MB
+func FormatSize(size int64) string {
+	switch {
+	case size <= 0:
+		return ""
+	case size < 1<<10:
+		return fmt.Sprintf("%d bytes", size)
+	case size < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	}
+}
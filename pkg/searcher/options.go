@@ -0,0 +1,17 @@
+package searcher
+
+// Option configures a Searcher
+type Option func(*options)
+
+type options struct {
+	dataDir string
+}
+
+// WithDataDir reads the database from dir instead of the default databases
+// directory relative to the working directory. Use storagepaths.New(dir) to
+// list or check for databases there.
+func WithDataDir(dir string) Option {
+	return func(o *options) {
+		o.dataDir = dir
+	}
+}
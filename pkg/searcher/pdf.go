@@ -0,0 +1,99 @@
+package searcher
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// GeneratePDFOutput renders search results as a PDF report using the same
+// data model as GenerateHTMLOutput
+func GeneratePDFOutput(w io.Writer, data *ReportData) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle(fmt.Sprintf("Search results for %s - %s", data.Query, data.Database), true)
+
+	// Core fonts only cover cp1252, so translate message text from UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.AddPage()
+
+	// Report header
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Search results", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetTextColor(97, 96, 97)
+	pdf.MultiCell(0, 5, tr(fmt.Sprintf("Query: %s  |  Database: %s  |  Generated: %s",
+		data.Query, data.Database, data.GeneratedAt.Format("2006-01-02 15:04:05"))), "", "L", false)
+	pdf.Ln(2)
+
+	if len(data.Results) == 0 {
+		pdf.CellFormat(0, 6, "No results found.", "", 1, "L", false, 0, "")
+	} else {
+		pdf.CellFormat(0, 6, fmt.Sprintf("Found %d result(s)", len(data.Results)), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(2)
+
+	for i, result := range data.Results {
+		// Result header line
+		pdf.SetTextColor(18, 100, 163)
+		pdf.SetFont("Helvetica", "B", 10)
+		header := fmt.Sprintf("#%d  %s  -  %s  -  %s", i+1, displayUserName(result),
+			result.Date.Format("2006-01-02 15:04:05"), result.Filename)
+		if result.Database != "" {
+			header += "  -  " + result.Database
+		}
+		pdf.CellFormat(0, 6, tr(header), "", 1, "L", false, 0, "")
+
+		// Message body, with FTS highlight markers rendered in bold
+		pdf.SetTextColor(29, 28, 29)
+		text := result.Text
+		if result.Snippet != "" {
+			text = result.Snippet
+		}
+		writeHighlighted(pdf, tr, text)
+		pdf.Ln(8)
+
+		// Separator between results
+		x, y := pdf.GetXY()
+		pdf.SetDrawColor(221, 221, 221)
+		pdf.Line(x, y-2, 195, y-2)
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	return nil
+}
+
+// writeHighlighted writes flowing text, switching to bold for segments
+// wrapped in <mark> tags
+func writeHighlighted(pdf *fpdf.Fpdf, tr func(string) string, text string) {
+	const lineHeight = 5
+
+	for text != "" {
+		start := strings.Index(text, "<mark>")
+		if start < 0 {
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.Write(lineHeight, tr(text))
+			return
+		}
+
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.Write(lineHeight, tr(text[:start]))
+		text = text[start+len("<mark>"):]
+
+		end := strings.Index(text, "</mark>")
+		if end < 0 {
+			end = len(text)
+		}
+
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.Write(lineHeight, tr(text[:end]))
+		text = strings.TrimPrefix(text[end:], "</mark>")
+	}
+}
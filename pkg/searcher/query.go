@@ -0,0 +1,71 @@
+package searcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultNearDistance is the FTS default for NEAR, used as the default of
+// the --distance flag
+const DefaultNearDistance = 10
+
+// QueryOptions holds search modifiers that compile into FTS query syntax,
+// so users don't need to learn the operators themselves
+type QueryOptions struct {
+	// Exclude lists terms that must not appear in matching messages
+	Exclude []string
+	// Near is a space-separated list of terms that must appear close together
+	Near string
+	// Distance is the maximum number of words between Near terms, and must
+	// be positive when Near is set
+	Distance int
+}
+
+// BuildMatchQuery combines a raw FTS query with the given options into a
+// single FTS MATCH expression
+func BuildMatchQuery(query string, opts QueryOptions) (string, error) {
+	var parts []string
+
+	if query = strings.TrimSpace(query); query != "" {
+		if opts.Near == "" && len(opts.Exclude) == 0 {
+			return query, nil
+		}
+		parts = append(parts, "("+query+")")
+	}
+
+	if terms := strings.Fields(opts.Near); len(terms) > 0 {
+		if len(terms) < 2 {
+			return "", fmt.Errorf("--near needs at least two terms")
+		}
+
+		if opts.Distance <= 0 {
+			return "", fmt.Errorf("--distance must be positive")
+		}
+
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = quoteTerm(term)
+		}
+		parts = append(parts, strings.Join(quoted, fmt.Sprintf(" NEAR/%d ", opts.Distance)))
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("a search query or --near terms are required")
+	}
+
+	match := strings.Join(parts, " ")
+	for _, term := range opts.Exclude {
+		if term = strings.TrimSpace(term); term != "" {
+			match += " NOT " + quoteTerm(term)
+		}
+	}
+
+	return match, nil
+}
+
+// quoteTerm wraps a term in double quotes so it is matched literally rather
+// than interpreted as an operator. A trailing * is kept inside the quotes,
+// where FTS4 reads it as a prefix match; FTS4 ignores a * after a phrase.
+func quoteTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, "") + `"`
+}
@@ -0,0 +1,138 @@
+package searcher_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/testutil"
+)
+
+func TestBuildMatchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		opts  searcher.QueryOptions
+		want  string
+	}{
+		{"plain query", "kubelet", searcher.QueryOptions{}, "kubelet"},
+		{"exclude", "kubelet", searcher.QueryOptions{Exclude: []string{"timeout"}},
+			`(kubelet) NOT "timeout"`},
+		{"exclude prefix", "kubelet", searcher.QueryOptions{Exclude: []string{"time*"}},
+			`(kubelet) NOT "time*"`},
+		{"exclude strips quotes", "kubelet", searcher.QueryOptions{Exclude: []string{`"csr" approver`, " "}},
+			`(kubelet) NOT "csr approver"`},
+		{"near", "", searcher.QueryOptions{Near: "kubelet certificate", Distance: 5},
+			`"kubelet" NEAR/5 "certificate"`},
+		{"near prefix", "", searcher.QueryOptions{Near: "kube* events", Distance: searcher.DefaultNearDistance},
+			`"kube*" NEAR/10 "events"`},
+		{"near with query and exclude", "rotation", searcher.QueryOptions{
+			Near: "kubelet cert*", Distance: 3, Exclude: []string{"csr*"}},
+			`(rotation) "kubelet" NEAR/3 "cert*" NOT "csr*"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searcher.BuildMatchQuery(tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("BuildMatchQuery failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildMatchQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildMatchQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		opts  searcher.QueryOptions
+	}{
+		{"no query", "", searcher.QueryOptions{Exclude: []string{"kubelet"}}},
+		{"one near term", "", searcher.QueryOptions{Near: "kubelet", Distance: 5}},
+		{"zero distance", "", searcher.QueryOptions{Near: "kubelet certificate"}},
+		{"negative distance", "", searcher.QueryOptions{Near: "kubelet certificate", Distance: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := searcher.BuildMatchQuery(tt.query, tt.opts); err == nil {
+				t.Errorf("BuildMatchQuery(%q) = %q, want an error", tt.query, got)
+			}
+		})
+	}
+}
+
+// The compiled queries must mean to the FTS4 index what they say: a
+// prefix outside the quotes would be silently ignored
+func TestBuildMatchQueryMatches(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewFixtureDB(t)
+
+	search := func(t *testing.T, query string, opts searcher.QueryOptions) []*models.SearchResult {
+		t.Helper()
+		match, err := searcher.BuildMatchQuery(query, opts)
+		if err != nil {
+			t.Fatalf("BuildMatchQuery failed: %v", err)
+		}
+		results, err := db.SearchMessagesFiltered(ctx, match, models.SearchFilter{}, 100)
+		if err != nil {
+			t.Fatalf("search for %q failed: %v", match, err)
+		}
+		return results
+	}
+
+	all := search(t, "kubelet", searcher.QueryOptions{})
+
+	t.Run("exclude prefix", func(t *testing.T) {
+		results := search(t, "kubelet", searcher.QueryOptions{Exclude: []string{"upgra*"}})
+		if len(results) == 0 || len(results) >= len(all) {
+			t.Fatalf("got %d results excluding upgra*, want between 1 and %d", len(results), len(all)-1)
+		}
+		for _, result := range results {
+			if strings.Contains(result.Text, "upgrade") {
+				t.Errorf("excluded message matched: %q", result.Text)
+			}
+		}
+	})
+
+	t.Run("near prefix", func(t *testing.T) {
+		results := search(t, "", searcher.QueryOptions{Near: "kube* rotation", Distance: 1})
+		if len(results) == 0 {
+			t.Fatal("got no results for kube* near rotation")
+		}
+		for _, result := range results {
+			if !strings.Contains(result.Text, "kubelet certificate rotation") {
+				t.Errorf("message without kubelet near rotation matched: %q", result.Text)
+			}
+		}
+	})
+}
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		match string
+		want  []string
+	}{
+		{"kubelet", []string{"kubelet"}},
+		{"cert* AND rotate*", []string{"cert*", "rotate*"}},
+		{`(kubelet) NOT "time*"`, []string{"kubelet"}},
+		{`"kube*" NEAR/10 "events"`, []string{"kube*", "events"}},
+		{`"bound tokens" user_name:liggitt`, []string{"bound", "tokens", "liggitt"}},
+		{`text:"csr approver*" OR Kubelet kubelet`, []string{"csr", "approver*", "Kubelet"}},
+		{`kubelet NOT (certificate OR "csr")`, []string{"kubelet"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.match, func(t *testing.T) {
+			if got := searcher.QueryTerms(tt.match); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryTerms(%q) = %q, want %q", tt.match, got, tt.want)
+			}
+		})
+	}
+}
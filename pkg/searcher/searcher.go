@@ -1,26 +1,64 @@
 package searcher
 
 import (
+	"context"
 	"fmt"
-	"path/filepath"
+	"math/rand/v2"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/raesene/k8s-slack-searcher/pkg/database"
 	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
 )
 
 type Searcher struct {
-	db *database.DB
+	db          *database.DB
+	channelName string
+	snippets    int
 }
 
 // NewSearcher creates a new searcher for a specific database
-func NewSearcher(channelName string) (*Searcher, error) {
-	db, err := database.NewDB(channelName)
+func NewSearcher(channelName string, opts ...Option) (*Searcher, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	db, err := database.NewDBInDir(o.dataDir, channelName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	return &Searcher{db: db}, nil
+	return &Searcher{db: db, channelName: channelName}, nil
+}
+
+// SetSnippets makes results show up to n snippets of messages matching in
+// several places, rather than one (see database.DB.SetSnippets)
+func (s *Searcher) SetSnippets(n int) {
+	s.snippets = n
+	s.db.SetSnippets(n)
+}
+
+// SetTracer passes fn a trace of each search the searcher runs against its
+// database, labelled with the database's name. A nil fn stops tracing.
+func (s *Searcher) SetTracer(fn func(*models.SearchTrace)) {
+	if fn == nil {
+		s.db.SetTracer(nil)
+		return
+	}
+	s.db.SetTracer(func(trace *models.SearchTrace) {
+		trace.Database = s.channelName
+		fn(trace)
+	})
+}
+
+// ChannelName returns the channel whose database the searcher reads,
+// without any workspace qualifier
+func (s *Searcher) ChannelName() string {
+	_, channel := storagepaths.SplitName(s.channelName)
+	return channel
 }
 
 // Close closes the searcher and database connection
@@ -28,22 +66,309 @@ func (s *Searcher) Close() error {
 	return s.db.Close()
 }
 
-// Search performs a full-text search and returns formatted results
-func (s *Searcher) Search(query string, limit int) ([]*models.SearchResult, error) {
+// Search performs a full-text search and returns formatted results. A
+// limit of zero or less returns every match.
+func (s *Searcher) Search(ctx context.Context, query string, limit int) ([]*models.SearchResult, error) {
+	return s.SearchFiltered(ctx, query, models.SearchFilter{}, limit)
+}
+
+// SearchFiltered performs a search restricted by user, date and content
+// filters. The query may be empty when the filter is not. A limit of zero
+// or less returns every match, as for StreamFiltered.
+func (s *Searcher) SearchFiltered(ctx context.Context, query string, filter models.SearchFilter, limit int) ([]*models.SearchResult, error) {
+	return s.db.SearchMessagesFiltered(ctx, query, filter, limit)
+}
+
+// Count returns the number of messages a filtered search would match,
+// without fetching them
+func (s *Searcher) Count(ctx context.Context, query string, filter models.SearchFilter) (int, error) {
+	return s.db.CountMessages(ctx, query, filter)
+}
+
+// CountThreadHits sets the ThreadHits of each result to the number of times
+// the query's terms occur in its whole thread
+func (s *Searcher) CountThreadHits(ctx context.Context, query string, results []*models.SearchResult) error {
+	return s.db.CountThreadHits(ctx, query, results)
+}
+
+// Explain describes how a filtered search would run, without running it.
+// A limit of zero means no limit, as for StreamFiltered.
+func (s *Searcher) Explain(ctx context.Context, query string, filter models.SearchFilter, limit int) (*models.SearchExplanation, error) {
+	return s.db.ExplainSearch(ctx, query, filter, limit)
+}
+
+// LastMessageID returns the ID of the most recently stored message, for
+// finding the messages a later ingest adds with SearchFilter.AfterID
+func (s *Searcher) LastMessageID(ctx context.Context) (int, error) {
+	return s.db.LastMessageID(ctx)
+}
+
+// IntegrityCheck runs SQLite's integrity check on the database, returning
+// the problems found
+func (s *Searcher) IntegrityCheck(ctx context.Context) ([]string, error) {
+	return s.db.IntegrityCheck(ctx)
+}
+
+// VerifyFTS compares the database's tables with its search indexes
+func (s *Searcher) VerifyFTS(ctx context.Context) (*models.FTSReport, error) {
+	return s.db.VerifyFTS(ctx)
+}
+
+// RebuildFTS recreates the database's search indexes from its tables
+func (s *Searcher) RebuildFTS(ctx context.Context) error {
+	return s.db.RebuildFTS(ctx)
+}
+
+// SortResults orders results merged from several databases the same way
+// each database orders its own (see models.Sorts)
+func SortResults(results []*models.SearchResult, order string) {
+	if order == models.SortRandom {
+		rand.Shuffle(len(results), func(i, j int) {
+			results[i], results[j] = results[j], results[i]
+		})
+		return
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if order == models.SortOldest {
+			if a.TSMicros != b.TSMicros {
+				return a.TSMicros < b.TSMicros
+			}
+			return a.ID < b.ID
+		}
+		if order != models.SortNewest && a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.TSMicros != b.TSMicros {
+			return a.TSMicros > b.TSMicros
+		}
+		return a.ID > b.ID
+	})
+}
+
+// Suggest returns indexed terms starting with prefix, weighted by how often
+// they occur, for search box autocompletion
+func (s *Searcher) Suggest(ctx context.Context, prefix string, limit int) ([]models.TermSuggestion, error) {
 	if limit <= 0 {
 		limit = 10
 	}
+	return s.db.SuggestTerms(ctx, prefix, limit)
+}
+
+// GetTermFilter returns the stopwords and minimum term length applied to
+// suggestions and term reports
+func (s *Searcher) GetTermFilter(ctx context.Context) (*models.TermFilter, error) {
+	return s.db.GetTermFilter(ctx)
+}
 
-	return s.db.SearchMessages(query, limit)
+// SetTermFilter stores the stopwords and minimum term length applied to
+// suggestions and term reports; nil restores the defaults
+func (s *Searcher) SetTermFilter(ctx context.Context, filter *models.TermFilter) error {
+	return s.db.SetTermFilter(ctx, filter)
+}
+
+// SearchUsers finds users matching a name pattern
+func (s *Searcher) SearchUsers(ctx context.Context, pattern string, limit int) ([]*models.User, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	return s.db.SearchUsers(ctx, pattern, limit)
+}
+
+// UserProfiles returns the profiles each user matching an ID or name has
+// posted under, by user and oldest first
+func (s *Searcher) UserProfiles(ctx context.Context, user string) ([]*models.UserProfile, error) {
+	return s.db.GetUserProfiles(ctx, user)
+}
+
+// SearchChannels finds channels whose name, topic or purpose match the query
+func (s *Searcher) SearchChannels(ctx context.Context, query string, limit int) ([]*models.ChannelSearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	return s.db.SearchChannels(ctx, query, limit)
+}
+
+// GetChannelInfo returns metadata and indexed date range for a channel
+func (s *Searcher) GetChannelInfo(ctx context.Context, name string) (*models.ChannelInfo, error) {
+	return s.db.GetChannelInfo(ctx, name)
+}
+
+// ReadOnlyQuery runs a single SELECT statement against the database,
+// calling fn with each row, and returns the column names. Statements that
+// could change the database are rejected.
+func (s *Searcher) ReadOnlyQuery(ctx context.Context, query string, fn func(columns []string, values []interface{}) error) ([]string, error) {
+	return s.db.ReadOnlyQuery(ctx, query, fn)
 }
 
 // GetStats returns database statistics
-func (s *Searcher) GetStats() (map[string]int, error) {
-	return s.db.GetStats()
+func (s *Searcher) GetStats(ctx context.Context) (map[string]int, error) {
+	return s.db.GetStats(ctx)
+}
+
+// DailyActivity returns the number of messages posted on each active day
+func (s *Searcher) DailyActivity(ctx context.Context) ([]models.DayCount, error) {
+	return s.db.GetDailyMessageCounts(ctx)
+}
+
+// GetMessage returns a single message by its ID
+func (s *Searcher) GetMessage(ctx context.Context, id int) (*models.Message, error) {
+	return s.db.GetMessage(ctx, id)
+}
+
+// GetMessageByTimestamp returns a single message by its Slack timestamp
+func (s *Searcher) GetMessageByTimestamp(ctx context.Context, ts string) (*models.Message, error) {
+	return s.db.GetMessageByTimestamp(ctx, ts)
+}
+
+// SimilarMessages returns up to limit messages from other threads
+// resembling message, most similar first, and the salient terms, at most
+// maxTerms, they were found by
+func (s *Searcher) SimilarMessages(ctx context.Context, message *models.Message, maxTerms, limit int) ([]string, []*models.SimilarMessage, error) {
+	return s.db.SimilarMessages(ctx, message, maxTerms, limit)
+}
+
+// GetRawMessage returns the source JSON kept for a message at ingest, and
+// whether any was kept
+func (s *Searcher) GetRawMessage(ctx context.Context, ts string) (string, bool, error) {
+	return s.db.GetRawMessage(ctx, ts)
+}
+
+// SetFeedback marks a message as helpful (score above zero) or not helpful
+// (below zero) for future searches, or clears the mark with zero
+func (s *Searcher) SetFeedback(ctx context.Context, message *models.Message, score int, query string) error {
+	return s.db.SetFeedback(ctx, message, score, query)
+}
+
+// GetFeedback returns the feedback recorded in the database
+func (s *Searcher) GetFeedback(ctx context.Context) ([]*models.Feedback, error) {
+	return s.db.GetFeedback(ctx)
+}
+
+// AddTag tags a message, or with thread set the whole thread it belongs to
+func (s *Searcher) AddTag(ctx context.Context, message *models.Message, tag string, thread bool) error {
+	return s.db.AddTag(ctx, message, tag, thread)
+}
+
+// RemoveTag removes a tag from a message and its thread, returning the
+// number of tags removed
+func (s *Searcher) RemoveTag(ctx context.Context, message *models.Message, tag string) (int, error) {
+	return s.db.RemoveTag(ctx, message, tag)
+}
+
+// SaveResultSet saves messages, by timestamp and in order, as a named
+// result set, replacing any set of that name
+func (s *Searcher) SaveResultSet(ctx context.Context, name, query string, timestamps []string) error {
+	return s.db.SaveResultSet(ctx, name, query, timestamps)
+}
+
+// GetResultSets returns the saved result sets
+func (s *Searcher) GetResultSets(ctx context.Context) ([]models.ResultSet, error) {
+	return s.db.GetResultSets(ctx)
+}
+
+// GetResultSet returns a saved result set and its message timestamps
+func (s *Searcher) GetResultSet(ctx context.Context, name string) (*models.ResultSet, []string, error) {
+	return s.db.GetResultSet(ctx, name)
+}
+
+// GetMessagesByTimestamps returns the messages with the given timestamps
+// that are still in the database, in order
+func (s *Searcher) GetMessagesByTimestamps(ctx context.Context, timestamps []string) ([]*models.Message, error) {
+	return s.db.GetMessagesByTimestamps(ctx, timestamps)
+}
+
+// GetMessageFiles returns the files shared in the messages with the given
+// timestamps, by message timestamp
+func (s *Searcher) GetMessageFiles(ctx context.Context, timestamps []string) (map[string][]models.MessageFile, error) {
+	return s.db.GetMessageFiles(ctx, timestamps)
+}
+
+// GetMediaFiles returns the files in the media catalog
+func (s *Searcher) GetMediaFiles(ctx context.Context) ([]models.MediaFile, error) {
+	return s.db.GetMediaFiles(ctx)
+}
+
+// GetSkipReport summarizes the messages ingest skipped, with up to examples
+// examples of each reason
+func (s *Searcher) GetSkipReport(ctx context.Context, examples int) (*models.SkipReport, error) {
+	return s.db.GetSkipReport(ctx, examples)
+}
+
+// DeleteResultSet removes a saved result set, reporting whether it existed
+func (s *Searcher) DeleteResultSet(ctx context.Context, name string) (bool, error) {
+	return s.db.DeleteResultSet(ctx, name)
+}
+
+// GetTags returns the tags in use in the database
+func (s *Searcher) GetTags(ctx context.Context) ([]models.TagCount, error) {
+	return s.db.GetTags(ctx)
+}
+
+// GetMessageTags returns the tags on a message, including its thread's
+func (s *Searcher) GetMessageTags(ctx context.Context, message *models.Message) ([]string, error) {
+	return s.db.GetMessageTags(ctx, message)
+}
+
+// GetThread returns the starter and replies of the thread a message belongs
+// to, oldest first. A message outside any thread is returned on its own.
+func (s *Searcher) GetThread(ctx context.Context, message *models.Message) ([]*models.Message, error) {
+	threadTS := message.ThreadTS
+	if threadTS == "" {
+		threadTS = message.Timestamp
+	}
+
+	return s.db.GetThreadMessages(ctx, threadTS)
+}
+
+// Browse returns the messages posted on a day in reading order: each
+// message followed by its thread's replies, including replies posted on
+// later days. Replies to threads started on earlier days appear where
+// they were posted.
+func (s *Searcher) Browse(ctx context.Context, day time.Time) ([]*models.Message, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	messages, err := s.db.GetMessagesInRange(ctx, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	starters := make(map[string]bool)
+	for _, message := range messages {
+		if message.ThreadTS == message.Timestamp {
+			starters[message.Timestamp] = true
+		}
+	}
+
+	var ordered []*models.Message
+	for _, message := range messages {
+		isReply := message.ThreadTS != "" && message.ThreadTS != message.Timestamp
+		switch {
+		case isReply && starters[message.ThreadTS]:
+			// Shown beneath its thread starter
+		case starters[message.Timestamp]:
+			thread, err := s.db.GetThreadMessages(ctx, message.Timestamp)
+			if err != nil {
+				return nil, err
+			}
+			ordered = append(ordered, thread...)
+		default:
+			ordered = append(ordered, message)
+		}
+	}
+
+	return ordered, nil
 }
 
 // FormatResults formats search results for display
 func FormatResults(results []*models.SearchResult) string {
+	return FormatResultsHighlighted(results, nil)
+}
+
+// FormatResultsHighlighted formats search results for display, coloring
+// the matched terms of each snippet with h
+func FormatResultsHighlighted(results []*models.SearchResult, h *Highlighter) string {
 	if len(results) == 0 {
 		return "No results found."
 	}
@@ -53,90 +378,184 @@ func FormatResults(results []*models.SearchResult) string {
 	output.WriteString(fmt.Sprintf("Found %d result(s):\n\n", len(results)))
 	
 	for i, result := range results {
-		// Parse date for display
-		date := result.Date.Format("2006-01-02 15:04:05")
-		
-		// Determine user display name
-		userName := result.UserName
-		if result.UserRealName != "" {
-			userName = fmt.Sprintf("%s (%s)", result.UserRealName, result.UserName)
-		}
-		if userName == "" {
-			userName = result.UserID
+		output.WriteString(formatResult(i+1, result, h))
+	}
+	
+	return output.String()
+}
+
+// formatResult formats one numbered search result for display, with its
+// snippet's matches colored by h when it is not nil
+func formatResult(number int, result *models.SearchResult, h *Highlighter) string {
+	var output strings.Builder
+	
+	// Parse date for display
+	date := result.Date.Format("2006-01-02 15:04:05")
+	
+	// Determine user display name
+	userName := displayUserName(result)
+	
+	// Format message
+	output.WriteString(fmt.Sprintf("--- Result %d ---\n", number))
+	output.WriteString(fmt.Sprintf("User: %s\n", userName))
+	output.WriteString(fmt.Sprintf("Date: %s\n", date))
+	output.WriteString(fmt.Sprintf("File: %s\n", result.Filename))
+	if result.Database != "" {
+		output.WriteString(fmt.Sprintf("Database: %s\n", result.Database))
+	}
+	if matches := formatHits(result); matches != "" {
+		output.WriteString(fmt.Sprintf("Matches: %s\n", matches))
+	}
+	
+	// Show snippet if available, otherwise show full text
+	messageText := result.Text
+	if result.Snippet != "" {
+		messageText = result.Snippet
+	}
+	
+	// Clean up the message text
+	messageText = strings.ReplaceAll(messageText, "\n", " ")
+	// Snippets are bounded already, and cutting one could split a <mark> tag
+	if result.Snippet == "" && len(messageText) > 500 {
+		messageText = messageText[:497] + "..."
+	}
+	
+	output.WriteString(fmt.Sprintf("Message: %s\n\n", h.Highlight(messageText)))
+	
+	return output.String()
+}
+
+// FormatMessage formats a single message in full for display
+func FormatMessage(message *models.Message) string {
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("User: %s\n", messageUserName(message)))
+	output.WriteString(fmt.Sprintf("Date: %s\n", message.Date.Format("2006-01-02 15:04:05")))
+	output.WriteString(fmt.Sprintf("File: %s\n", message.Filename))
+	output.WriteString(fmt.Sprintf("Timestamp: %s\n", message.Timestamp))
+	if message.ThreadTS != "" && message.ThreadTS != message.Timestamp {
+		output.WriteString(fmt.Sprintf("In thread: %s\n", message.ThreadTS))
+	}
+	if message.ReplyCount > 0 {
+		output.WriteString(fmt.Sprintf("Replies: %d\n", message.ReplyCount))
+	}
+	if message.ReactionCount > 0 {
+		output.WriteString(fmt.Sprintf("Reactions: %d\n", message.ReactionCount))
+	}
+	output.WriteString(fmt.Sprintf("\n%s\n", message.Text))
+
+	return output.String()
+}
+
+// FormatThread formats the messages of a thread for display
+func FormatThread(messages []*models.Message) string {
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("Thread with %d message(s):\n\n", len(messages)))
+	for i, message := range messages {
+		output.WriteString(fmt.Sprintf("--- %s - %s ---\n", messageUserName(message), message.Date.Format("2006-01-02 15:04:05")))
+		output.WriteString(message.Text)
+		output.WriteString("\n")
+		if i < len(messages)-1 {
+			output.WriteString("\n")
 		}
-		
-		// Format message
+	}
+
+	return output.String()
+}
+
+// FormatSimilar formats messages found by SimilarMessages for display, each
+// with how similar it is and the terms it shares
+func FormatSimilar(similar []*models.SimilarMessage) string {
+	if len(similar) == 0 {
+		return "No similar messages found.\n"
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Found %d similar message(s):\n\n", len(similar)))
+	for i, result := range similar {
 		output.WriteString(fmt.Sprintf("--- Result %d ---\n", i+1))
-		output.WriteString(fmt.Sprintf("User: %s\n", userName))
-		output.WriteString(fmt.Sprintf("Date: %s\n", date))
+		output.WriteString(fmt.Sprintf("User: %s\n", displayUserName(&result.SearchResult)))
+		output.WriteString(fmt.Sprintf("Date: %s\n", result.Date.Format("2006-01-02 15:04:05")))
 		output.WriteString(fmt.Sprintf("File: %s\n", result.Filename))
-		
-		// Show snippet if available, otherwise show full text
-		messageText := result.Text
-		if result.Snippet != "" {
-			messageText = result.Snippet
+		output.WriteString(fmt.Sprintf("Similarity: %.0f%% (%s)\n", result.Similarity*100, strings.Join(result.SharedTerms, ", ")))
+		output.WriteString(fmt.Sprintf("Message: %s\n\n", strings.ReplaceAll(result.Snippet, "\n", " ")))
+	}
+
+	return output.String()
+}
+
+// FormatDay formats messages returned by Browse as a transcript, with
+// thread replies indented beneath the messages they answer
+func FormatDay(messages []*models.Message) string {
+	var output strings.Builder
+
+	thread := ""
+	for _, message := range messages {
+		isReply := message.ThreadTS != "" && message.ThreadTS != message.Timestamp
+		indent := ""
+		if isReply {
+			indent = "    "
+			// The starter of a thread from an earlier day is not shown
+			if message.ThreadTS != thread {
+				output.WriteString(fmt.Sprintf("%s(reply in thread %s)\n", indent, message.ThreadTS))
+			}
 		}
-		
-		// Clean up the message text
-		messageText = strings.ReplaceAll(messageText, "\n", " ")
-		if len(messageText) > 500 {
-			messageText = messageText[:497] + "..."
+		if message.ThreadTS != "" {
+			thread = message.ThreadTS
+		} else {
+			thread = ""
 		}
-		
-		output.WriteString(fmt.Sprintf("Message: %s\n\n", messageText))
+
+		output.WriteString(fmt.Sprintf("%s[%s] %s\n", indent, message.Date.Format("2006-01-02 15:04"), messageUserName(message)))
+		for _, line := range strings.Split(message.Text, "\n") {
+			output.WriteString(fmt.Sprintf("%s  %s\n", indent, line))
+		}
+		output.WriteString("\n")
 	}
-	
+
 	return output.String()
 }
 
-// ValidateDatabaseExists checks if a database file exists for the given channel
+// displayUserName returns the best available name for a result's author
+func displayUserName(result *models.SearchResult) string {
+	return messageUserName(&result.Message)
+}
+
+// messageUserName returns the best available name for a message's author
+func messageUserName(message *models.Message) string {
+	userName := message.UserName
+	if message.UserRealName != "" {
+		userName = fmt.Sprintf("%s (%s)", message.UserRealName, message.UserName)
+	}
+	if userName == "" {
+		userName = message.UserID
+	}
+
+	return userName
+}
+
+// ValidateDatabaseExists checks if a database file exists for the given
+// channel, which may be qualified with a workspace as "workspace/channel"
 func ValidateDatabaseExists(channelName string) bool {
-	// Sanitize filename same way as database package
-	filename := sanitizeFilename(channelName) + ".db"
-	dbPath := filepath.Join("databases", filename)
-	
-	return fileExists(dbPath)
+	return storagepaths.DatabaseExists(channelName)
 }
 
-// ListDatabases lists all available database files
+// ListDatabases lists all available database files. Databases in a
+// workspace are returned qualified as "workspace/channel".
 func ListDatabases() ([]string, error) {
-	pattern := filepath.Join("databases", "*.db")
-	matches, err := filepath.Glob(pattern)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list databases: %w", err)
+	return storagepaths.ListDatabases()
+}
+
+// formatHits describes how often the query's terms occur in a result and,
+// when other messages in its thread match too, in the thread
+func formatHits(result *models.SearchResult) string {
+	if result.Hits == 0 && result.ThreadHits == 0 {
+		return ""
 	}
-	
-	var databases []string
-	for _, match := range matches {
-		// Extract just the filename without extension
-		base := filepath.Base(match)
-		name := strings.TrimSuffix(base, ".db")
-		databases = append(databases, name)
+	hits := fmt.Sprintf("%d in message", result.Hits)
+	if result.ThreadHits > result.Hits {
+		hits += fmt.Sprintf(", %d in thread", result.ThreadHits)
 	}
-	
-	return databases, nil
-}
-
-// fileExists checks if a file exists
-func fileExists(filename string) bool {
-	_, err := filepath.Abs(filename)
-	return err == nil
-}
-
-// sanitizeFilename removes problematic characters from channel names
-// This should match the implementation in database package
-func sanitizeFilename(name string) string {
-	replacer := strings.NewReplacer(
-		":", "_",
-		"/", "_",
-		"\\", "_",
-		"*", "_",
-		"?", "_",
-		"\"", "_",
-		"<", "_",
-		">", "_",
-		"|", "_",
-		" ", "_",
-	)
-	return replacer.Replace(name)
-}
\ No newline at end of file
+	return hits
+}
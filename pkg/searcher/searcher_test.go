@@ -0,0 +1,60 @@
+package searcher_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+	"github.com/raesene/k8s-slack-searcher/pkg/searcher"
+	"github.com/raesene/k8s-slack-searcher/pkg/testutil"
+)
+
+// newFixtureSearcher returns a searcher for the fixture export, closed when
+// the test finishes
+func newFixtureSearcher(t *testing.T) *searcher.Searcher {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := testutil.BuildFixture(context.Background(), dir); err != nil {
+		t.Fatalf("failed to build fixture database: %v", err)
+	}
+	search, err := searcher.NewSearcher(testutil.FixtureChannel, searcher.WithDataDir(dir))
+	if err != nil {
+		t.Fatalf("failed to open fixture database: %v", err)
+	}
+	t.Cleanup(func() { search.Close() })
+	return search
+}
+
+func TestSearchFilteredLimit(t *testing.T) {
+	ctx := context.Background()
+	search := newFixtureSearcher(t)
+
+	count, err := search.Count(ctx, "kubelet", models.SearchFilter{})
+	if err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if count < 2 {
+		t.Fatalf("fixture has %d kubelet messages, want at least 2", count)
+	}
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, count},
+		{-1, count},
+		{1, 1},
+		{count + 5, count},
+	}
+
+	for _, tt := range tests {
+		results, err := search.SearchFiltered(ctx, "kubelet", models.SearchFilter{}, tt.limit)
+		if err != nil {
+			t.Fatalf("search with limit %d failed: %v", tt.limit, err)
+		}
+		if len(results) != tt.want {
+			t.Errorf("search with limit %d returned %d results, want %d", tt.limit, len(results), tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package searcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Output formats for search results written as they arrive
+const (
+	StreamFormatText   = "text"
+	StreamFormatNDJSON = "ndjson"
+)
+
+// StreamFormats lists the supported streamed output formats
+var StreamFormats = []string{StreamFormatText, StreamFormatNDJSON}
+
+// StreamedResult is a search result as written to NDJSON output, one per
+// line
+type StreamedResult struct {
+	Database     string    `json:"database"`
+	Timestamp    string    `json:"ts"`
+	ThreadTS     string    `json:"thread_ts,omitempty"`
+	Date         time.Time `json:"date"`
+	UserID       string    `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	UserRealName string    `json:"user_real_name,omitempty"`
+	Filename     string    `json:"filename"`
+	Text         string    `json:"text"`
+	// Hits is the number of times the query's terms occur in Text
+	Hits int `json:"hits,omitempty"`
+}
+
+// ResultWriter writes search results one at a time, in the same text form
+// as FormatResults or as NDJSON
+type ResultWriter struct {
+	w        io.Writer
+	format   string
+	database string
+	encoder  *json.Encoder
+	count    int
+	// highlighter colors text results' matches (see SetHighlighter)
+	highlighter *Highlighter
+}
+
+// NewResultWriter returns a writer for results from database in one of
+// StreamFormats. Results labelled with another database keep their label.
+func NewResultWriter(w io.Writer, format, database string) (*ResultWriter, error) {
+	if format != StreamFormatText && format != StreamFormatNDJSON {
+		return nil, fmt.Errorf("unsupported format: %s (supported: %s)", format, strings.Join(StreamFormats, ", "))
+	}
+	return &ResultWriter{w: w, format: format, database: database, encoder: json.NewEncoder(w)}, nil
+}
+
+// SetHighlighter colors the matched terms of text results with h
+func (rw *ResultWriter) SetHighlighter(h *Highlighter) {
+	rw.highlighter = h
+}
+
+// Write writes the next result
+func (rw *ResultWriter) Write(result *models.SearchResult) error {
+	rw.count++
+	if rw.format == StreamFormatText {
+		_, err := io.WriteString(rw.w, formatResult(rw.count, result, rw.highlighter))
+		return err
+	}
+
+	database := result.Database
+	if database == "" {
+		database = rw.database
+	}
+	return rw.encoder.Encode(StreamedResult{
+		Database:     database,
+		Timestamp:    result.Timestamp,
+		ThreadTS:     result.ThreadTS,
+		Date:         result.Date,
+		UserID:       result.UserID,
+		UserName:     result.UserName,
+		UserRealName: result.UserRealName,
+		Filename:     result.Filename,
+		Text:         result.Text,
+		Hits:         result.Hits,
+	})
+}
+
+// Count returns the number of results written so far
+func (rw *ResultWriter) Count() int {
+	return rw.count
+}
+
+// StreamFiltered runs a filtered search like SearchFiltered, passing each
+// result to fn as it is read instead of collecting them. A limit of zero
+// returns every match.
+func (s *Searcher) StreamFiltered(ctx context.Context, query string, filter models.SearchFilter, limit int, fn func(*models.SearchResult) error) error {
+	return s.db.StreamMessagesFiltered(ctx, query, filter, limit, fn)
+}
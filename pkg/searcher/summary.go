@@ -0,0 +1,202 @@
+package searcher
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// summaryTop is the number of authors and terms listed in a report summary
+const summaryTop = 10
+
+// Month chart geometry in SVG units
+const (
+	monthBarStride = 14
+	monthBarHeight = 100
+	monthChartTop  = 14
+	monthChartLeft = 4
+	// monthLabelGap is the fewest bars between month labels, so they
+	// don't overlap
+	monthLabelGap = 4
+	// monthLabelWidth is the width of a label such as "Jan 2024"
+	monthLabelWidth = 40
+)
+
+// ReportSummary gives the context of a report's results: when they were
+// posted, who posted them and what else they talk about
+type ReportSummary struct {
+	// Months is a bar per month from the first result to the last,
+	// including months with none
+	Months []SummaryMonth
+	Width  int
+	Height int
+	// LabelY is the baseline of the month labels below the bars
+	LabelY int
+	// MaxMonth is the highest monthly count, which maps to the tallest bar
+	MaxMonth int
+	Authors  []SummaryCount
+	// Terms are the words, other than the query's, used in the most
+	// results
+	Terms []SummaryCount
+}
+
+// SummaryMonth is one bar of the matches per month chart
+type SummaryMonth struct {
+	Month time.Time
+	Count int
+	X, Y  int
+	// Height is the bar's height, 0 for a month with no results
+	Height int
+	// Label is set on the months labeled below the chart: the first and
+	// each January with room for it
+	Label string
+}
+
+// SummaryCount is an author or term with the number of results it
+// appears in
+type SummaryCount struct {
+	Name  string
+	Count int
+}
+
+// BuildSummary summarizes results for a report. Terms in match, stopwords,
+// terms shorter than the filter's minimum length and terms containing
+// digits are left out of the co-occurring terms. It returns nil when there
+// are no results.
+func BuildSummary(results []*models.SearchResult, match string, filter models.TermFilter) *ReportSummary {
+	if len(results) == 0 {
+		return nil
+	}
+
+	summary := &ReportSummary{}
+	summary.buildMonths(results)
+	summary.Authors = topAuthors(results)
+	summary.Terms = cooccurringTerms(results, match, filter)
+	return summary
+}
+
+func (s *ReportSummary) buildMonths(results []*models.SearchResult) {
+	byMonth := make(map[time.Time]int)
+	var first, last time.Time
+	for _, result := range results {
+		date := result.Date.Local()
+		month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.Local)
+		byMonth[month]++
+		if first.IsZero() || month.Before(first) {
+			first = month
+		}
+		if month.After(last) {
+			last = month
+		}
+		if byMonth[month] > s.MaxMonth {
+			s.MaxMonth = byMonth[month]
+		}
+	}
+
+	lastLabel := -monthLabelGap
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		count := byMonth[month]
+		height := count * monthBarHeight / s.MaxMonth
+		if count > 0 && height == 0 {
+			height = 1
+		}
+		bar := SummaryMonth{
+			Month:  month,
+			Count:  count,
+			X:      monthChartLeft + len(s.Months)*monthBarStride,
+			Y:      monthChartTop + monthBarHeight - height,
+			Height: height,
+		}
+		if len(s.Months) == 0 || (month.Month() == time.January && len(s.Months)-lastLabel >= monthLabelGap) {
+			bar.Label = month.Format("Jan 2006")
+			lastLabel = len(s.Months)
+		}
+		s.Months = append(s.Months, bar)
+	}
+
+	// Leave room for a label on the last bar
+	s.Width = monthChartLeft + len(s.Months)*monthBarStride + monthLabelWidth
+	s.LabelY = monthChartTop + monthBarHeight + 12
+	s.Height = s.LabelY + 4
+}
+
+// topAuthors counts the results posted by each author, most first
+func topAuthors(results []*models.SearchResult) []SummaryCount {
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[displayUserName(result)]++
+	}
+	return topCounts(counts, 1)
+}
+
+// cooccurringTerms counts the results using each term other than the
+// query's, most first. Terms in only one result are left out.
+func cooccurringTerms(results []*models.SearchResult, match string, filter models.TermFilter) []SummaryCount {
+	stopwords := make(map[string]bool, len(filter.Stopwords))
+	for _, word := range filter.Stopwords {
+		stopwords[word] = true
+	}
+	var queryWords, queryPrefixes []string
+	for _, term := range QueryTerms(match) {
+		term = strings.ToLower(strings.Trim(term, `"`))
+		if strings.HasSuffix(term, "*") {
+			queryPrefixes = append(queryPrefixes, strings.TrimSuffix(term, "*"))
+		} else {
+			queryWords = append(queryWords, term)
+		}
+	}
+	inQuery := func(term string) bool {
+		for _, word := range queryWords {
+			if term == word {
+				return true
+			}
+		}
+		for _, prefix := range queryPrefixes {
+			if strings.HasPrefix(term, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		seen := make(map[string]bool)
+		words := strings.FieldsFunc(strings.ToLower(result.Text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if seen[word] || len(word) < filter.MinLength || stopwords[word] ||
+				strings.ContainsAny(word, "0123456789") || inQuery(word) {
+				continue
+			}
+			seen[word] = true
+			counts[word]++
+		}
+	}
+	return topCounts(counts, 2)
+}
+
+// topCounts returns the summaryTop entries with the highest counts of at
+// least minCount, ties in name order
+func topCounts(counts map[string]int, minCount int) []SummaryCount {
+	var top []SummaryCount
+	for name, count := range counts {
+		if count >= minCount {
+			top = append(top, SummaryCount{Name: name, Count: count})
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+	if len(top) > summaryTop {
+		top = top[:summaryTop]
+	}
+	return top
+}
@@ -0,0 +1,142 @@
+package slacktext
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// FlattenBlocks reconstructs readable text from a message's Block Kit blocks.
+// Links, mentions and emoji are written in Slack's own markup (<url|label>,
+// <@U123>, :emoji:) so the result reads like a message's text field, and
+// code is wrapped in backticks.
+func FlattenBlocks(blocks []models.SlackBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		if text := flattenBlock(block); strings.TrimSpace(text) != "" {
+			parts = append(parts, strings.TrimRight(text, "\n"))
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// HasLayoutBlocks reports whether blocks include layout blocks such as
+// sections. Messages posted by apps carry their content in these, and their
+// text field is only a notification fallback.
+func HasLayoutBlocks(blocks []models.SlackBlock) bool {
+	for _, block := range blocks {
+		switch block.Type {
+		case "section", "header", "context":
+			return true
+		}
+	}
+	return false
+}
+
+func flattenBlock(block models.SlackBlock) string {
+	switch block.Type {
+	case "rich_text":
+		var b strings.Builder
+		for _, element := range block.Elements {
+			writeRichTextContainer(&b, element)
+		}
+		return b.String()
+
+	case "section", "header":
+		var lines []string
+		if block.Text != nil && block.Text.Text != "" {
+			lines = append(lines, block.Text.Text)
+		}
+		for _, field := range block.Fields {
+			if field.Text != "" {
+				lines = append(lines, field.Text)
+			}
+		}
+		return strings.Join(lines, "\n")
+
+	case "context":
+		var texts []string
+		for _, element := range block.Elements {
+			if element.Text != "" {
+				texts = append(texts, element.Text)
+			}
+		}
+		return strings.Join(texts, " ")
+	}
+
+	return ""
+}
+
+// writeRichTextContainer writes a top-level rich text element: a section,
+// list, quote or preformatted block
+func writeRichTextContainer(b *strings.Builder, element models.SlackBlockElement) {
+	switch element.Type {
+	case "rich_text_section":
+		writeInline(b, element.Elements)
+		b.WriteString("\n")
+
+	case "rich_text_list":
+		for i, item := range element.Elements {
+			if element.Style.Name == "ordered" {
+				b.WriteString(strconv.Itoa(i+1) + ". ")
+			} else {
+				b.WriteString("• ")
+			}
+			writeInline(b, item.Elements)
+			b.WriteString("\n")
+		}
+
+	case "rich_text_quote":
+		var quote strings.Builder
+		writeInline(&quote, element.Elements)
+		for _, line := range strings.Split(quote.String(), "\n") {
+			b.WriteString("> " + line + "\n")
+		}
+
+	case "rich_text_preformatted":
+		b.WriteString("```\n")
+		writeInline(b, element.Elements)
+		b.WriteString("\n```\n")
+
+	default:
+		writeInline(b, []models.SlackBlockElement{element})
+	}
+}
+
+// writeInline writes inline rich text elements
+func writeInline(b *strings.Builder, elements []models.SlackBlockElement) {
+	for _, element := range elements {
+		switch element.Type {
+		case "text":
+			if element.Style.Code {
+				b.WriteString("`" + element.Text + "`")
+			} else {
+				b.WriteString(element.Text)
+			}
+		case "link":
+			if element.Text != "" && element.Text != element.URL {
+				b.WriteString("<" + element.URL + "|" + element.Text + ">")
+			} else {
+				b.WriteString("<" + element.URL + ">")
+			}
+		case "user":
+			b.WriteString("<@" + element.UserID + ">")
+		case "channel":
+			b.WriteString("<#" + element.ChannelID + ">")
+		case "usergroup":
+			b.WriteString("<!subteam^" + element.UserID + ">")
+		case "broadcast":
+			b.WriteString("<!" + element.Range + ">")
+		case "emoji":
+			b.WriteString(":" + element.Name + ":")
+		default:
+			// Nested containers and unknown elements with text of their own
+			if element.Text != "" {
+				b.WriteString(element.Text)
+			}
+			writeInline(b, element.Elements)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package slacktext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultWorkspaceURL is the Kubernetes Slack workspace, used to build
+// permalinks when no other workspace is given
+const DefaultWorkspaceURL = "https://kubernetes.slack.com"
+
+var (
+	// Slack wraps links as <https://example.com> or <https://example.com|label>
+	slackLinkPattern = regexp.MustCompile(`<(https?://[^|>\s]+)(?:\|[^>]*)?>`)
+	// Plain URLs appear in text that did not come through Slack's formatter
+	plainLinkPattern = regexp.MustCompile(`https?://[^\s<>|"')\]]+`)
+	// Message timestamps, as seconds.microseconds or in permalink form
+	timestampPattern = regexp.MustCompile(`^\d{10}\.\d{6}$`)
+	permalinkPattern = regexp.MustCompile(`(?:^|/)p(\d{10})(\d{6})(?:[?#]|$)`)
+)
+
+// ExtractLinks returns the URLs referenced in a message's text, in order of
+// appearance and without duplicates
+func ExtractLinks(text string) []string {
+	seen := make(map[string]bool)
+	var links []string
+
+	add := func(link string) {
+		link = strings.TrimRight(link, ".,;:!?")
+		if link == "" || seen[link] {
+			return
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+
+	for _, match := range slackLinkPattern.FindAllStringSubmatch(text, -1) {
+		add(match[1])
+	}
+
+	// Remove Slack-formatted links before looking for plain ones so they
+	// are not matched twice
+	remaining := slackLinkPattern.ReplaceAllString(text, " ")
+	for _, match := range plainLinkPattern.FindAllString(remaining, -1) {
+		add(match)
+	}
+
+	return links
+}
+
+// Permalink builds a Slack permalink for a message. workspaceURL is the
+// workspace's base URL, e.g. https://kubernetes.slack.com. threadTS may be
+// empty for messages that are not thread replies.
+func Permalink(workspaceURL, channelID, ts, threadTS string) string {
+	if workspaceURL == "" || channelID == "" || ts == "" {
+		return ""
+	}
+
+	link := fmt.Sprintf("%s/archives/%s/p%s", strings.TrimSuffix(workspaceURL, "/"), channelID, strings.Replace(ts, ".", "", 1))
+	if threadTS != "" && threadTS != ts {
+		link += fmt.Sprintf("?thread_ts=%s&cid=%s", threadTS, channelID)
+	}
+
+	return link
+}
+
+// ParseTimestamp accepts a message timestamp (1684141200.000100), the
+// p-prefixed form used in permalinks (p1684141200000100) or a full
+// permalink, and returns the timestamp
+func ParseTimestamp(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if timestampPattern.MatchString(s) {
+		return s, nil
+	}
+	if match := permalinkPattern.FindStringSubmatch(s); match != nil {
+		return match[1] + "." + match[2], nil
+	}
+	return "", fmt.Errorf("invalid message timestamp or permalink: %s", s)
+}
@@ -0,0 +1,44 @@
+// Package slowlog records searches that took longer than a threshold, with
+// their query plans, so slow queries on a shared archive can be found and
+// tuned
+package slowlog
+
+import (
+	"fmt"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/appendlog"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+// Entry is a slow search recorded in the log
+type Entry struct {
+	models.SearchTrace
+	User    string `json:"user"`
+	Command string `json:"command"`
+	// Query is the search as given, before its filters were separated out
+	Query string `json:"query"`
+}
+
+// DurationMS returns how long the search took in milliseconds
+func (e Entry) DurationMS() float64 {
+	return float64(e.Duration.Microseconds()) / 1000
+}
+
+// Append adds an entry to the log at path, creating it if needed. Entries
+// are written as one JSON object per line.
+func Append(path string, entry Entry) error {
+	if err := appendlog.AppendJSON(path, entry); err != nil {
+		return fmt.Errorf("failed to write slow query log: %w", err)
+	}
+	return nil
+}
+
+// Read returns the entries in the log at path, oldest first. A missing log
+// has no entries.
+func Read(path string) ([]Entry, error) {
+	entries, err := appendlog.ReadJSON[Entry](path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read slow query log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,200 @@
+package storagepaths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDir is the directory databases are stored in, relative to the
+// working directory
+const DefaultDir = "databases"
+
+// EncryptedSuffix is appended to the file name of a database encrypted at
+// rest
+const EncryptedSuffix = ".enc"
+
+// MetaFile is the file name of a workspace's shared users and channels
+// database, kept beside the workspace's channel databases
+const MetaFile = "workspace-meta.db"
+
+// MediaDir is the directory of a workspace's media store, holding the
+// files shared in its messages by content hash
+const MediaDir = ".media"
+
+// Layout resolves where databases and related files live under a data
+// directory
+type Layout struct {
+	Dir string
+}
+
+// Default is the layout rooted at DefaultDir
+var Default = Layout{Dir: DefaultDir}
+
+// New returns the layout rooted at dir, or Default when dir is empty
+func New(dir string) Layout {
+	if dir == "" {
+		return Default
+	}
+	return Layout{Dir: dir}
+}
+
+// Sanitize replaces characters that are unsafe in file names
+func Sanitize(name string) string {
+	replacer := strings.NewReplacer(
+		":", "_",
+		"/", "_",
+		"\\", "_",
+		"*", "_",
+		"?", "_",
+		"\"", "_",
+		"<", "_",
+		">", "_",
+		"|", "_",
+		" ", "_",
+	)
+	return replacer.Replace(name)
+}
+
+// SplitName splits a database name of the form "workspace/channel" into its
+// parts. Names without a workspace belong to the default workspace, "".
+func SplitName(name string) (workspace, channel string) {
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// QualifiedName joins a workspace and channel into a database name
+func QualifiedName(workspace, channel string) string {
+	if workspace == "" {
+		return channel
+	}
+	return workspace + "/" + channel
+}
+
+// DatabasePath returns the file path of a database. Databases in a workspace
+// live in that workspace's subdirectory.
+func (l Layout) DatabasePath(name string) string {
+	workspace, channel := SplitName(name)
+
+	filename := Sanitize(channel) + ".db"
+	if workspace != "" {
+		return filepath.Join(l.Dir, Sanitize(workspace), filename)
+	}
+	return filepath.Join(l.Dir, filename)
+}
+
+// MetaPath returns the file path of the shared users and channels database
+// of the workspace a database belongs to
+func (l Layout) MetaPath(name string) string {
+	workspace, _ := SplitName(name)
+	if workspace != "" {
+		return filepath.Join(l.Dir, Sanitize(workspace), MetaFile)
+	}
+	return filepath.Join(l.Dir, MetaFile)
+}
+
+// MediaPath returns the media store of the workspace a database belongs to
+func (l Layout) MediaPath(name string) string {
+	workspace, _ := SplitName(name)
+	if workspace != "" {
+		return filepath.Join(l.Dir, Sanitize(workspace), MediaDir)
+	}
+	return filepath.Join(l.Dir, MediaDir)
+}
+
+// EncryptedPath returns the file path of a database encrypted at rest
+func (l Layout) EncryptedPath(name string) string {
+	return l.DatabasePath(name) + EncryptedSuffix
+}
+
+// IsEncrypted reports whether a database is stored encrypted
+func (l Layout) IsEncrypted(name string) bool {
+	return isFile(l.EncryptedPath(name))
+}
+
+// DatabaseExists reports whether a database file exists, encrypted or not
+func (l Layout) DatabaseExists(name string) bool {
+	return isFile(l.DatabasePath(name)) || l.IsEncrypted(name)
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ListDatabases returns the names of all databases. Databases in a workspace
+// are returned qualified as "workspace/channel".
+func (l Layout) ListDatabases() ([]string, error) {
+	var databases []string
+	seen := make(map[string]bool)
+	for _, pattern := range []string{"*.db", filepath.Join("*", "*.db"), "*.db" + EncryptedSuffix, filepath.Join("*", "*.db"+EncryptedSuffix)} {
+		matches, err := filepath.Glob(filepath.Join(l.Dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list databases: %w", err)
+		}
+
+		for _, match := range matches {
+			if filepath.Base(match) == MetaFile {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(match), EncryptedSuffix), ".db")
+			if dir := filepath.Dir(match); filepath.Clean(dir) != filepath.Clean(l.Dir) {
+				name = QualifiedName(filepath.Base(dir), name)
+			}
+			if !seen[name] {
+				seen[name] = true
+				databases = append(databases, name)
+			}
+		}
+	}
+
+	return databases, nil
+}
+
+// StatePath returns the path of the session state file kept alongside the
+// databases
+func (l Layout) StatePath() string {
+	return filepath.Join(l.Dir, ".session.json")
+}
+
+// BookmarksPath returns the path of the bookmarks file kept alongside the
+// databases
+func (l Layout) BookmarksPath() string {
+	return filepath.Join(l.Dir, ".bookmarks.json")
+}
+
+// DatabasePath returns the file path of a database in the default layout
+func DatabasePath(name string) string {
+	return Default.DatabasePath(name)
+}
+
+// DatabaseExists reports whether a database exists in the default layout
+func DatabaseExists(name string) bool {
+	return Default.DatabaseExists(name)
+}
+
+// IsEncrypted reports whether a database in the default layout is stored
+// encrypted
+func IsEncrypted(name string) bool {
+	return Default.IsEncrypted(name)
+}
+
+// EncryptedPath returns the file path of an encrypted database in the
+// default layout
+func EncryptedPath(name string) string {
+	return Default.EncryptedPath(name)
+}
+
+// MediaPath returns the media store of a database's workspace in the
+// default layout
+func MediaPath(name string) string {
+	return Default.MediaPath(name)
+}
+
+// ListDatabases returns the names of all databases in the default layout
+func ListDatabases() ([]string, error) {
+	return Default.ListDatabases()
+}
@@ -0,0 +1,118 @@
+package summarizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backend generates text completions for a prompt
+type Backend interface {
+	// Complete returns the model's response to the prompt, giving up when
+	// ctx is cancelled
+	Complete(ctx context.Context, prompt string) (string, error)
+	// Model returns the name of the model used, for cache keys
+	Model() string
+}
+
+// OpenAIBackend talks to any OpenAI-compatible chat completions API,
+// including local servers such as Ollama, llama.cpp or vLLM
+type OpenAIBackend struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAIBackend creates a backend for an OpenAI-compatible endpoint.
+// The API key may be empty for local endpoints that do not require one.
+func NewOpenAIBackend(baseURL, apiKey, model string) *OpenAIBackend {
+	return &OpenAIBackend{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Model returns the configured model name
+func (b *OpenAIBackend) Model() string {
+	return b.model
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Complete sends the prompt to the chat completions endpoint
+func (b *OpenAIBackend) Complete(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: b.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request to %s failed: %w", b.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var parsed chatResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", fmt.Errorf("failed to parse response (status %s): %w", resp.Status, err)
+	}
+
+	if parsed.Error != nil {
+		return "", fmt.Errorf("backend error: %s", parsed.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("backend returned status %s", resp.Status)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("backend returned no choices")
+	}
+
+	return strings.TrimSpace(parsed.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,135 @@
+package summarizer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/models"
+)
+
+const systemPrompt = `You summarize discussions from a Slack archive of the Kubernetes community.
+Answer concisely in a few sentences. Cite the messages you rely on using their
+bracketed numbers, e.g. [1] or [2][4]. Do not invent information that is not
+present in the messages.`
+
+// Summary is a generated summary and the messages it may cite
+type Summary struct {
+	Text      string
+	Citations []*models.Message
+	Cached    bool
+}
+
+type Summarizer struct {
+	db      *database.DB
+	backend Backend
+	noCache bool
+}
+
+// NewSummarizer creates a new summarizer for a specific database
+func NewSummarizer(channelName string, backend Backend) (*Summarizer, error) {
+	db, err := database.NewDB(channelName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Summarizer{db: db, backend: backend}, nil
+}
+
+// Close closes the summarizer and database connection
+func (s *Summarizer) Close() error {
+	return s.db.Close()
+}
+
+// SetNoCache disables reading previously cached summaries; new summaries
+// are still stored
+func (s *Summarizer) SetNoCache(noCache bool) {
+	s.noCache = noCache
+}
+
+// SummarizeThread summarizes all messages in the thread started at threadTS
+func (s *Summarizer) SummarizeThread(ctx context.Context, threadTS string) (*Summary, error) {
+	messages, err := s.db.GetThreadMessages(ctx, threadTS)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("thread not found: %s", threadTS)
+	}
+
+	return s.summarize(ctx, "Summarize this thread.", messages)
+}
+
+// SummarizeQuery answers a query using the top search results as context
+func (s *Summarizer) SummarizeQuery(ctx context.Context, query string, limit int) (*Summary, error) {
+	results, err := s.db.SearchMessages(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results found for: %s", query)
+	}
+
+	messages := make([]*models.Message, len(results))
+	for i, result := range results {
+		messages[i] = &result.Message
+	}
+
+	instruction := fmt.Sprintf("Using these search results for %q, give a short answer summarizing what was discussed.", query)
+	return s.summarize(ctx, instruction, messages)
+}
+
+// summarize builds a prompt with numbered messages and returns the
+// backend's response, using the database cache where possible
+func (s *Summarizer) summarize(ctx context.Context, instruction string, messages []*models.Message) (*Summary, error) {
+	prompt := buildPrompt(instruction, messages)
+	key := cacheKey(s.backend.Model(), prompt)
+
+	if !s.noCache {
+		cached, ok, err := s.db.GetCachedSummary(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &Summary{Text: cached, Citations: messages, Cached: true}, nil
+		}
+	}
+
+	text, err := s.backend.Complete(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.SaveSummary(ctx, key, s.backend.Model(), text); err != nil {
+		return nil, err
+	}
+
+	return &Summary{Text: text, Citations: messages}, nil
+}
+
+// buildPrompt formats messages as a numbered list the model can cite
+func buildPrompt(instruction string, messages []*models.Message) string {
+	var prompt strings.Builder
+
+	prompt.WriteString(instruction)
+	prompt.WriteString("\n\nMessages:\n")
+	for i, message := range messages {
+		author := message.UserName
+		if author == "" {
+			author = message.UserID
+		}
+		text := strings.Join(strings.Fields(message.Text), " ")
+		fmt.Fprintf(&prompt, "[%d] %s (%s): %s\n", i+1, author, message.Date.Format("2006-01-02 15:04"), text)
+	}
+
+	return prompt.String()
+}
+
+// cacheKey identifies a summary by the model and the exact prompt used
+func cacheKey(model, prompt string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + prompt))
+	return hex.EncodeToString(sum[:])
+}
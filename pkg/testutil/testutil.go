@@ -0,0 +1,64 @@
+// Package testutil builds databases from the synthetic Slack export bundled
+// in pkg/testdata, for tests and the end-to-end checks run by make e2e
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/database"
+	"github.com/raesene/k8s-slack-searcher/pkg/indexer"
+	"github.com/raesene/k8s-slack-searcher/pkg/storagepaths"
+)
+
+// FixtureChannel is the channel of the fixture export
+const FixtureChannel = "sig-fixture"
+
+// FixtureDir returns the directory of the fixture export, found from this
+// package's source so tests can run from any package directory
+func FixtureDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "testdata", "export")
+}
+
+// GoldenFile returns the path of a golden file in pkg/testdata/golden, the
+// expected output of the fixture export that make e2e also checks
+func GoldenFile(name string) string {
+	return filepath.Join(FixtureDir(), "..", "golden", name)
+}
+
+// BuildFixture ingests the fixture export into a database under dataDir,
+// as ingest sig-fixture would, and returns the database's path
+func BuildFixture(ctx context.Context, dataDir string) (string, error) {
+	idx, err := indexer.NewIndexer(FixtureDir(), FixtureChannel, indexer.WithDataDir(dataDir))
+	if err != nil {
+		return "", err
+	}
+	defer idx.Close()
+
+	if err := idx.IndexChannel(ctx); err != nil {
+		return "", fmt.Errorf("failed to index fixture export: %w", err)
+	}
+	return storagepaths.New(dataDir).DatabasePath(FixtureChannel), nil
+}
+
+// NewFixtureDB returns the fixture export ingested into a database in a
+// temporary directory, closed when the test finishes
+func NewFixtureDB(t testing.TB) *database.DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := BuildFixture(context.Background(), dir); err != nil {
+		t.Fatalf("failed to build fixture database: %v", err)
+	}
+
+	db, err := database.NewDBInDir(dir, FixtureChannel)
+	if err != nil {
+		t.Fatalf("failed to open fixture database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
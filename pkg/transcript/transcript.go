@@ -0,0 +1,120 @@
+// Package transcript keeps a Markdown log of a research session: the
+// commands run, the results they found and the messages opened and files
+// exported along the way, so the research can be followed and repeated
+package transcript
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raesene/k8s-slack-searcher/pkg/appendlog"
+)
+
+// EnvPath names the environment variable giving the transcript to keep
+// when --transcript isn't passed, so one can be kept for a whole shell
+// session
+const EnvPath = "K8S_SLACK_SEARCHER_TRANSCRIPT"
+
+// MaxResults is the number of results listed for each search; the rest
+// are only counted
+const MaxResults = 20
+
+// excerptLength is the number of characters of a message's text shown
+const excerptLength = 100
+
+// Message is a message found or opened during the session
+type Message struct {
+	Database  string
+	Timestamp string
+	User      string
+	Date      time.Time
+	Text      string
+}
+
+// Entry is a command run during the session
+type Entry struct {
+	Time time.Time
+	// Command is the command line run, quoted so it can be run again
+	Command string
+	// Query and Databases describe a search; Query is empty for other
+	// commands
+	Query     string
+	Databases []string
+	// Total is the number of results a search found, of which the first
+	// MaxResults are listed in Results
+	Total    int
+	Results  []Message
+	Opened   []Message
+	Exported []string
+}
+
+// Append adds an entry to the transcript at path, starting the transcript
+// with a heading if the file is new
+func Append(path, user string, entry Entry) error {
+	err := appendlog.Append(path, func(empty bool) []byte {
+		var out strings.Builder
+		if empty {
+			out.WriteString("# Research transcript\n\n")
+			out.WriteString(fmt.Sprintf("Started %s", entry.Time.Format("2006-01-02 15:04 MST")))
+			if user != "" {
+				out.WriteString(" by " + user)
+			}
+			out.WriteString(".\n\n")
+		}
+		writeEntry(&out, entry)
+		return []byte(out.String())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
+
+func writeEntry(out *strings.Builder, entry Entry) {
+	out.WriteString(fmt.Sprintf("## %s\n\n", entry.Time.Format("2006-01-02 15:04:05")))
+	out.WriteString("```sh\n" + entry.Command + "\n```\n\n")
+
+	if entry.Query != "" {
+		out.WriteString(fmt.Sprintf("- Query: `%s`\n", strings.ReplaceAll(entry.Query, "`", "'")))
+		out.WriteString(fmt.Sprintf("- Databases: %s\n", strings.Join(entry.Databases, ", ")))
+		out.WriteString(fmt.Sprintf("- Results: %d\n\n", entry.Total))
+		for i, message := range entry.Results {
+			out.WriteString(fmt.Sprintf("%d. %s\n", i+1, formatMessage(message)))
+		}
+		if entry.Total > len(entry.Results) && len(entry.Results) > 0 {
+			out.WriteString(fmt.Sprintf("\n...and %d more.\n", entry.Total-len(entry.Results)))
+		}
+		if len(entry.Results) > 0 {
+			out.WriteString("\n")
+		}
+	}
+
+	if len(entry.Opened) > 0 {
+		out.WriteString("Opened:\n\n")
+		for _, message := range entry.Opened {
+			out.WriteString("- " + formatMessage(message) + "\n")
+		}
+		out.WriteString("\n")
+	}
+
+	if len(entry.Exported) > 0 {
+		out.WriteString("Exported:\n\n")
+		for _, export := range entry.Exported {
+			out.WriteString("- " + export + "\n")
+		}
+		out.WriteString("\n")
+	}
+}
+
+// formatMessage describes a message on one line, with what's needed to
+// fetch it again with get
+func formatMessage(message Message) string {
+	// Backticks in the text would end its excerpt's Markdown early
+	text := strings.ReplaceAll(strings.Join(strings.Fields(message.Text), " "), "`", "'")
+	if runes := []rune(text); len(runes) > excerptLength {
+		text = string(runes[:excerptLength]) + "..."
+	}
+	return fmt.Sprintf("`%s` `%s` %s, %s: %s", message.Database, message.Timestamp,
+		message.User, message.Date.Format("2006-01-02 15:04"), text)
+}
@@ -0,0 +1,86 @@
+#!/bin/sh
+# End-to-end check: builds the binary, ingests the fixture export in
+# pkg/testdata/export and compares the output of searches, reports and the
+# skipped report with the golden files in pkg/testdata/golden.
+#
+# Run with UPDATE_GOLDEN=1 (make golden) to rewrite the golden files after
+# an intended change in output, and review the diff before committing.
+set -eu
+
+root=$(cd "$(dirname "$0")/.." && pwd)
+golden="$root/pkg/testdata/golden"
+work=$(mktemp -d)
+trap 'rm -rf "$work"' EXIT
+
+bin="$work/k8s-slack-searcher"
+(cd "$root" && go build -o "$bin" .)
+
+# Run in a scratch directory with its own home, config and time zone, so
+# nothing from the developer's setup leaks into the output
+mkdir -p "$work/run" "$work/home" "$work/out"
+cp -R "$root/pkg/testdata/export" "$work/run/source-data"
+cd "$work/run"
+export HOME="$work/home" XDG_CONFIG_HOME="$work/home/.config" TZ=UTC NO_COLOR=1
+export K8S_SLACK_SEARCHER_CONFIG="$work/home/config.yaml"
+unset K8S_SLACK_SEARCHER_TRANSCRIPT 2>/dev/null || true
+
+"$bin" ingest sig-fixture --no-alerts > "$work/ingest.log" 2>&1 || {
+	cat "$work/ingest.log"
+	echo "e2e: ingest failed" >&2
+	exit 1
+}
+
+# check NAME ARGS... runs the binary and records its output as NAME
+check() {
+	name=$1
+	shift
+	if ! "$bin" "$@" > "$work/out/$name" 2>&1; then
+		cat "$work/out/$name"
+		echo "e2e: $name: k8s-slack-searcher $* failed" >&2
+		exit 1
+	fi
+}
+
+check search.txt search kubelet -d sig-fixture
+check search-filters.txt search "rbac from:@alice after:2024-03-04" -d sig-fixture
+check search-oldest.txt search "certificate OR authorizer" -d sig-fixture --sort oldest --limit 0
+check search.ndjson search kubelet -d sig-fixture --format ndjson --limit 0
+check show.txt show 1
+check thread.txt thread 1
+check skipped.txt skipped sig-fixture
+check report.log search kubelet -d sig-fixture --html "$work/report.html"
+
+# The report records when it was generated
+sed 's/Generated: .*/Generated: (time)/' "$work/report.html" > "$work/out/report.html"
+rm "$work/out/report.log"
+
+if [ "${UPDATE_GOLDEN:-}" = 1 ]; then
+	rm -f "$golden"/*
+	cp "$work/out/"* "$golden/"
+	echo "e2e: golden files updated in $golden"
+	exit 0
+fi
+
+status=0
+for file in "$golden"/* "$work/out"/*; do
+	name=$(basename "$file")
+	if [ ! -f "$golden/$name" ]; then
+		echo "e2e: $name: no golden file (run make golden)" >&2
+		status=1
+	elif [ ! -f "$work/out/$name" ]; then
+		echo "e2e: $name: golden file with no output" >&2
+		status=1
+	fi
+done
+for file in "$work/out"/*; do
+	name=$(basename "$file")
+	if [ -f "$golden/$name" ] && ! diff -u "$golden/$name" "$file"; then
+		echo "e2e: $name differs from its golden file" >&2
+		status=1
+	fi
+done
+
+if [ "$status" = 0 ]; then
+	echo "e2e: ok ($(ls "$work/out" | wc -l | tr -d ' ') outputs match)"
+fi
+exit $status